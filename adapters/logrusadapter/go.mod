@@ -0,0 +1,10 @@
+module github.com/octacian/migrate/adapters/logrusadapter
+
+go 1.14
+
+require (
+	github.com/octacian/migrate v0.0.0
+	github.com/sirupsen/logrus v1.9.4
+)
+
+replace github.com/octacian/migrate => ../..
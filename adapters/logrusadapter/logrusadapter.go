@@ -0,0 +1,45 @@
+// Package logrusadapter passes the events of a migrate.Instance to a logrus
+// logger, with the fields of each event attached as logrus fields:
+//
+//	instance, err := migrate.NewInstance(db, "migrations",
+//		migrate.WithLogger(logrusadapter.New(logrus.StandardLogger())))
+//
+// It is a module of its own so that the logrus dependency is only required by
+// those who use it.
+package logrusadapter
+
+import (
+	"github.com/octacian/migrate"
+	"github.com/sirupsen/logrus"
+)
+
+// adapter implements migrate.Logger for a logrus.FieldLogger.
+type adapter struct {
+	logger logrus.FieldLogger
+}
+
+// New returns a migrate.Logger which passes events to logger, such as a
+// *logrus.Logger or a *logrus.Entry.
+func New(logger logrus.FieldLogger) migrate.Logger {
+	return &adapter{logger}
+}
+
+// Log implements the migrate.Logger interface.
+func (adapter *adapter) Log(level migrate.Level, message string, fields ...migrate.Field) {
+	values := make(logrus.Fields, len(fields))
+	for _, field := range fields {
+		values[field.Key] = field.Value
+	}
+
+	entry := adapter.logger.WithFields(values)
+	switch level {
+	case migrate.LevelDebug:
+		entry.Debug(message)
+	case migrate.LevelInfo:
+		entry.Info(message)
+	case migrate.LevelWarn:
+		entry.Warn(message)
+	default:
+		entry.Error(message)
+	}
+}
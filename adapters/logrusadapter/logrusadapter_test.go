@@ -0,0 +1,53 @@
+package logrusadapter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/octacian/migrate"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLog(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	adapter := New(logger)
+
+	adapter.Log(migrate.LevelInfo, "part applied", migrate.Field{Key: "version", Value: 1},
+		migrate.Field{Key: "part", Value: "a.sql"})
+	adapter.Log(migrate.LevelDebug, "part skipped", migrate.Field{Key: "reason", Value: nil})
+	adapter.Log(migrate.LevelWarn, "optional part failed")
+	adapter.Log(migrate.LevelError, "run failed", migrate.Field{Key: "error", Value: errors.New("boom")})
+
+	expected := []struct {
+		level   logrus.Level
+		message string
+		fields  logrus.Fields
+	}{
+		{logrus.InfoLevel, "part applied", logrus.Fields{"version": 1, "part": "a.sql"}},
+		{logrus.DebugLevel, "part skipped", logrus.Fields{"reason": nil}},
+		{logrus.WarnLevel, "optional part failed", logrus.Fields{}},
+		{logrus.ErrorLevel, "run failed", logrus.Fields{"error": errors.New("boom")}},
+	}
+
+	entries := hook.AllEntries()
+	if len(entries) != len(expected) {
+		t.Fatalf("Log: got %d entries expected %d", len(entries), len(expected))
+	}
+	for i, entry := range entries {
+		if entry.Level != expected[i].level || entry.Message != expected[i].message ||
+			!reflect.DeepEqual(entry.Data, expected[i].fields) {
+			t.Errorf("Log: got entry %s '%s' %v expected %s '%s' %v", entry.Level, entry.Message, entry.Data,
+				expected[i].level, expected[i].message, expected[i].fields)
+		}
+	}
+
+	// The fields of an entry are kept alongside those of each event
+	hook.Reset()
+	New(logger.WithField("service", "api")).Log(migrate.LevelInfo, "run finished")
+	if entry := hook.LastEntry(); entry == nil || entry.Data["service"] != "api" {
+		t.Errorf("Log: got entry %v expected the fields of the entry to be kept", entry)
+	}
+}
@@ -0,0 +1,51 @@
+// Package zapadapter passes the events of a migrate.Instance to a zap logger,
+// with the fields of each event attached as structured fields:
+//
+//	logger, _ := zap.NewProduction()
+//	instance, err := migrate.NewInstance(db, "migrations",
+//		migrate.WithLogger(zapadapter.New(logger.Sugar())))
+//
+// The package does not itself depend on zap; any logger with the leveled
+// key-value methods of *zap.SugaredLogger may be used.
+package zapadapter
+
+import "github.com/octacian/migrate"
+
+// SugaredLogger is the subset of the methods of *zap.SugaredLogger used by
+// the adapter.
+type SugaredLogger interface {
+	Debugw(message string, keysAndValues ...interface{})
+	Infow(message string, keysAndValues ...interface{})
+	Warnw(message string, keysAndValues ...interface{})
+	Errorw(message string, keysAndValues ...interface{})
+}
+
+// adapter implements migrate.Logger for a SugaredLogger.
+type adapter struct {
+	logger SugaredLogger
+}
+
+// New returns a migrate.Logger which passes events to logger, typically
+// obtained from a *zap.Logger with its Sugar method.
+func New(logger SugaredLogger) migrate.Logger {
+	return &adapter{logger}
+}
+
+// Log implements the migrate.Logger interface.
+func (adapter *adapter) Log(level migrate.Level, message string, fields ...migrate.Field) {
+	keysAndValues := make([]interface{}, 0, len(fields)*2)
+	for _, field := range fields {
+		keysAndValues = append(keysAndValues, field.Key, field.Value)
+	}
+
+	switch level {
+	case migrate.LevelDebug:
+		adapter.logger.Debugw(message, keysAndValues...)
+	case migrate.LevelInfo:
+		adapter.logger.Infow(message, keysAndValues...)
+	case migrate.LevelWarn:
+		adapter.logger.Warnw(message, keysAndValues...)
+	default:
+		adapter.logger.Errorw(message, keysAndValues...)
+	}
+}
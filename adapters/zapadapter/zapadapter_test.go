@@ -0,0 +1,56 @@
+package zapadapter
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/octacian/migrate"
+)
+
+// sugared records every call made to it in the form
+// "level message [keys and values]".
+type sugared struct {
+	calls []string
+}
+
+func (logger *sugared) record(level, message string, keysAndValues []interface{}) {
+	logger.calls = append(logger.calls, fmt.Sprintf("%s %s %v", level, message, keysAndValues))
+}
+
+func (logger *sugared) Debugw(message string, keysAndValues ...interface{}) {
+	logger.record("debug", message, keysAndValues)
+}
+
+func (logger *sugared) Infow(message string, keysAndValues ...interface{}) {
+	logger.record("info", message, keysAndValues)
+}
+
+func (logger *sugared) Warnw(message string, keysAndValues ...interface{}) {
+	logger.record("warn", message, keysAndValues)
+}
+
+func (logger *sugared) Errorw(message string, keysAndValues ...interface{}) {
+	logger.record("error", message, keysAndValues)
+}
+
+func TestLog(t *testing.T) {
+	logger := &sugared{}
+	adapter := New(logger)
+
+	adapter.Log(migrate.LevelInfo, "part applied", migrate.Field{Key: "version", Value: 1},
+		migrate.Field{Key: "part", Value: "a.sql"})
+	adapter.Log(migrate.LevelDebug, "part skipped")
+	adapter.Log(migrate.LevelWarn, "optional part failed", migrate.Field{Key: "error", Value: "boom"})
+	adapter.Log(migrate.LevelError, "run failed")
+
+	expected := []string{
+		"info part applied [version 1 part a.sql]",
+		"debug part skipped []",
+		"warn optional part failed [error boom]",
+		"error run failed []",
+	}
+	if !reflect.DeepEqual(logger.calls, expected) {
+		t.Errorf("Log: got calls '%#v' expected '%#v'", logger.calls, expected)
+	}
+}
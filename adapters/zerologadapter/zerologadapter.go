@@ -0,0 +1,48 @@
+// Package zerologadapter passes the events of a migrate.Instance to a zerolog
+// logger, with the fields of each event attached as zerolog fields:
+//
+//	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+//	instance, err := migrate.NewInstance(db, "migrations",
+//		migrate.WithLogger(zerologadapter.New(&logger)))
+//
+// It is a module of its own so that the zerolog dependency is only required by
+// those who use it.
+package zerologadapter
+
+import (
+	"github.com/octacian/migrate"
+	"github.com/rs/zerolog"
+)
+
+// adapter implements migrate.Logger for a *zerolog.Logger.
+type adapter struct {
+	logger *zerolog.Logger
+}
+
+// New returns a migrate.Logger which passes events to logger. Events below the
+// level of logger are discarded by zerolog as usual.
+func New(logger *zerolog.Logger) migrate.Logger {
+	return &adapter{logger}
+}
+
+// Log implements the migrate.Logger interface.
+func (adapter *adapter) Log(level migrate.Level, message string, fields ...migrate.Field) {
+	var event *zerolog.Event
+	switch level {
+	case migrate.LevelDebug:
+		event = adapter.logger.Debug()
+	case migrate.LevelInfo:
+		event = adapter.logger.Info()
+	case migrate.LevelWarn:
+		event = adapter.logger.Warn()
+	default:
+		event = adapter.logger.Error()
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		values[field.Key] = field.Value
+	}
+
+	event.Fields(values).Msg(message)
+}
@@ -0,0 +1,46 @@
+package zerologadapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/octacian/migrate"
+	"github.com/rs/zerolog"
+)
+
+func TestLog(t *testing.T) {
+	var output bytes.Buffer
+	logger := zerolog.New(&output).Level(zerolog.InfoLevel)
+	adapter := New(&logger)
+
+	adapter.Log(migrate.LevelInfo, "part applied", migrate.Field{Key: "version", Value: 1},
+		migrate.Field{Key: "part", Value: "a.sql"})
+	adapter.Log(migrate.LevelDebug, "part skipped")
+	adapter.Log(migrate.LevelWarn, "optional part failed", migrate.Field{Key: "error", Value: "boom"})
+	adapter.Log(migrate.LevelError, "run failed", migrate.Field{Key: "error", Value: errors.New("boom")},
+		migrate.Field{Key: "reason", Value: nil})
+
+	expected := []map[string]interface{}{
+		{"level": "info", "message": "part applied", "version": float64(1), "part": "a.sql"},
+		{"level": "warn", "message": "optional part failed", "error": "boom"},
+		{"level": "error", "message": "run failed", "error": "boom", "reason": nil},
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Log: got %d events expected %d, as debug is below the level of the logger:\n%s", len(lines),
+			len(expected), output.String())
+	}
+	for i, line := range lines {
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatal("json.Unmarshal: got error:\n", err)
+		} else if !reflect.DeepEqual(event, expected[i]) {
+			t.Errorf("Log: got event %v expected %v", event, expected[i])
+		}
+	}
+}
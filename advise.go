@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	// regexAlterType matches a statement which changes the type of a column,
+	// capturing the table, the column, and the new type.
+	regexAlterType = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` +
+		regexIdentifier + `\s+(?:ALTER\s+(?:COLUMN\s+)?` + regexIdentifier + `\s+(?:SET\s+DATA\s+)?TYPE|` +
+		`MODIFY\s+(?:COLUMN\s+)?` + regexIdentifier + `|CHANGE\s+(?:COLUMN\s+)?` + regexIdentifier + `\s+[^\s]+)` +
+		`\s+([^\s,;]+)`)
+	// regexSetNotNull matches a statement which makes an existing column NOT
+	// NULL, capturing the table and the column.
+	regexSetNotNull = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` +
+		regexIdentifier + `\s+ALTER\s+(?:COLUMN\s+)?` + regexIdentifier + `\s+SET\s+NOT\s+NULL`)
+	// regexNotNull and regexDefault match the clauses of a column definition.
+	regexNotNull = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	regexDefault = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+)
+
+// Advise inspects the up migrations of every pending version for changes
+// which rewrite or lock a populated table, returning a Diagnostic with the
+// check "destructive-change" for each. Changing the type of a column, making
+// an existing column NOT NULL, and adding a NOT NULL column without a default
+// are reported if the table already holds rows, with a Remediation spelling
+// out the expand/contract pattern with which the change may instead be made
+// safely across several versions. Changes to tables which are empty or do not
+// yet exist are not reported. Advise is also run by Doctor.
+func (instance *Instance) Advise() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	instance.advise(func(check, remediation, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Check: check, Message: fmt.Sprintf(format, args...),
+			Remediation: remediation})
+	})
+
+	return diagnostics
+}
+
+// advise runs the checks made by Advise, passing each problem found to add.
+func (instance *Instance) advise(add func(check, remediation, format string, args ...interface{})) {
+	current := instance.Version()
+	populated := make(map[string]bool)
+	isPopulated := func(table string) bool {
+		if value, ok := populated[table]; ok {
+			return value
+		}
+
+		populated[table] = tableExists(instance.db, table) && instance.db.QueryRow(`SELECT 1 FROM `+table+
+			` LIMIT 1;`).Scan(new(int)) == nil
+		return populated[table]
+	}
+
+	for _, version := range instance.List() {
+		if version <= current {
+			continue
+		}
+
+		for _, part := range instance.migrations[version].Parts {
+			for _, statement := range part.UpStatements {
+				_, sql := splitLeadingComments(statement.SQL)
+				table, change, remediation := destructiveChange(sql)
+				if change == "" || !isPopulated(table) {
+					continue
+				}
+
+				add("destructive-change", remediation, "part '%s' of version %d %s, rewriting or locking the "+
+					"populated table '%s'", part.Name, version, change, identifier(table))
+			}
+		}
+	}
+}
+
+// destructiveChange returns the table altered by a statement which changes
+// the type of a column or makes it NOT NULL, along with a description of the
+// change and the steps of the expand/contract pattern with which to make the
+// change safely. The description is empty if the statement makes no such
+// change.
+func destructiveChange(sql string) (string, string, string) {
+	if matches := regexAlterType.FindStringSubmatch(sql); matches != nil {
+		column := matches[2] + matches[3] + matches[4]
+		name := identifier(column)
+		return matches[1], fmt.Sprintf("changes the type of column '%s' to %s", name, matches[5]),
+			fmt.Sprintf("Expand and contract across several versions instead: add a nullable column '%[1]s_new' "+
+				"of type %[2]s, backfill it from '%[1]s' in batches while the application writes to both, switch "+
+				"reads to '%[1]s_new', then in a later version drop '%[1]s' and rename '%[1]s_new' to '%[1]s'.",
+				name, matches[5])
+	}
+
+	if matches := regexSetNotNull.FindStringSubmatch(sql); matches != nil {
+		name := identifier(matches[2])
+		return matches[1], fmt.Sprintf("makes column '%s' NOT NULL", name),
+			fmt.Sprintf("Expand and contract across several versions instead: backfill NULL values of '%[1]s' in "+
+				"batches, add a CHECK (%[1]s IS NOT NULL) constraint, created NOT VALID and validated separately "+
+				"on PostgreSQL, then in a later version set '%[1]s' NOT NULL.", name)
+	}
+
+	if matches := regexAddColumn.FindStringSubmatch(sql); matches != nil && !isConstraint(matches[2]) {
+		definition := sql[len(matches[0]):]
+		if regexNotNull.MatchString(definition) && !regexDefault.MatchString(definition) {
+			name := identifier(matches[2])
+			return matches[1], fmt.Sprintf("adds column '%s' NOT NULL without a default", name),
+				fmt.Sprintf("Expand and contract across several versions instead: add '%[1]s' as a nullable "+
+					"column or with a DEFAULT, backfill it in batches, then in a later version set '%[1]s' NOT "+
+					"NULL.", name)
+		}
+	}
+
+	return "", "", ""
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAdvise ensures that destructive changes to populated tables are
+// reported along with the expand/contract pattern to use instead.
+func TestAdvise(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/advise")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		// Tables which do not yet exist are not populated
+		if diagnostics := instance.Advise(); len(diagnostics) != 0 {
+			t.Errorf("Instance.Advise: got '%v' expected no diagnostics", diagnostics)
+		}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if _, err := db.Exec(`INSERT INTO users VALUES (1, 'jane', 30);`); err != nil {
+			t.Fatal("db.Exec: got error:\n", err)
+		}
+
+		diagnostics := instance.Advise()
+		messages := make([]string, 0)
+		for _, diagnostic := range diagnostics {
+			if diagnostic.Check != "destructive-change" {
+				t.Errorf("Instance.Advise: got unexpected check '%s'", diagnostic.Check)
+			}
+			messages = append(messages, diagnostic.Message)
+		}
+
+		expected := []string{
+			"part 'columns.sql' of version 2 changes the type of column 'age' to BIGINT, rewriting or locking " +
+				"the populated table 'users'",
+			"part 'columns.sql' of version 2 makes column 'name' NOT NULL, rewriting or locking the populated " +
+				"table 'users'",
+			"part 'columns.sql' of version 2 adds column 'email' NOT NULL without a default, rewriting or " +
+				"locking the populated table 'users'",
+		}
+		if !reflect.DeepEqual(messages, expected) {
+			t.Fatalf("Instance.Advise: got '%#v' expected '%#v'", messages, expected)
+		}
+
+		if !strings.Contains(diagnostics[0].Remediation, "add a nullable column 'age_new' of type BIGINT") {
+			t.Errorf("Instance.Advise: got remediation '%s' expected expand/contract steps",
+				diagnostics[0].Remediation)
+		}
+
+		checks := make(map[string]int)
+		for _, diagnostic := range instance.Doctor() {
+			checks[diagnostic.Check]++
+		}
+		if checks["destructive-change"] != 3 {
+			t.Errorf("Instance.Doctor: got %d destructive-change diagnostics expected 3",
+				checks["destructive-change"])
+		}
+	})
+}
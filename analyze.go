@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// parseAnalyze adds the comma-separated tables provided with
+// `-- @migrate/analyze` to part, ignoring any which it already names.
+func parseAnalyze(part *Part, argument string) error {
+	for _, table := range strings.Split(argument, ",") {
+		table = strings.TrimSpace(table)
+		if table == "" || strings.ContainsAny(table, " \t;") {
+			return fmt.Errorf("migrate: expected comma-separated tables without spaces, got '%s'", argument)
+		}
+
+		found := false
+		for _, existing := range part.Analyze {
+			found = found || existing == table
+		}
+		if !found {
+			part.Analyze = append(part.Analyze, table)
+		}
+	}
+
+	return nil
+}
+
+// settledVersion is a version applied by a run, along with the tables named
+// with `-- @migrate/analyze` by the parts applied, awaiting the commit of the
+// run before they are analyzed.
+type settledVersion struct {
+	version int
+	tables  []string
+}
+
+// afterVersion refreshes the statistics of the tables provided and then calls
+// the AfterVersion hooks registered, once a version has been applied in the
+// direction provided and committed.
+func (instance *Instance) afterVersion(ctx context.Context, version int, direction string, tables []string) {
+	instance.analyze(ctx, version, tables)
+
+	for _, hooks := range instance.plugins.hooks {
+		if hooks.AfterVersion != nil {
+			hooks.AfterVersion(instance, version, direction)
+		}
+	}
+}
+
+// analyze refreshes the statistics of each of the tables provided with the
+// Statistics of the Dialect, if it implements Statistics. As the version
+// which changed the tables has already been committed, a table which cannot
+// be analyzed is reported as a warning rather than failing the run.
+func (instance *Instance) analyze(ctx context.Context, version int, tables []string) {
+	statistics, ok := instance.dialect.(Statistics)
+	if !ok || len(tables) == 0 {
+		return
+	}
+
+	exec, release, err := instance.pin(ctx)
+	if err != nil {
+		instance.say(MessageAnalyzeFailed, MessageData{Version: version, Table: strings.Join(tables, ", "),
+			Err: err})
+		instance.log(LevelWarn, "analyze failed", Field{"version", version}, Field{"error", err})
+		return
+	}
+	defer release()
+
+	analyzed := make(map[string]bool)
+	for _, table := range tables {
+		statement := statistics.Analyze(table)
+		if analyzed[table] || statement == "" {
+			continue
+		}
+		analyzed[table] = true
+
+		if _, err := exec.ExecContext(ctx, statement); err != nil {
+			instance.say(MessageAnalyzeFailed, MessageData{Version: version, Table: table, Err: err})
+			instance.log(LevelWarn, "analyze failed", Field{"version", version}, Field{"table", table},
+				Field{"error", err})
+			continue
+		}
+
+		instance.say(MessageAnalyzed, MessageData{Version: version, Table: table})
+		instance.log(LevelInfo, "table analyzed", Field{"version", version}, Field{"table", table})
+	}
+}
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestAnalyze ensures that the tables named with `-- @migrate/analyze` are
+// analyzed with the statement of the Dialect once their version has been
+// applied, that a table which cannot be analyzed does not fail the run, and
+// that the AfterVersion hooks registered are called for every version.
+func TestAnalyze(t *testing.T) {
+	part, err := parsePart("reports.sql", []byte("-- @migrate/analyze reports, reports,missing\n"+
+		"-- @migrate/up\nCREATE TABLE reports(ID INT PRIMARY KEY);\n-- @migrate/down\nDROP TABLE reports;\n"))
+	if err != nil {
+		t.Fatal("parsePart: got error:\n", err)
+	} else if !reflect.DeepEqual(part.Analyze, []string{"reports", "missing"}) {
+		t.Errorf("parsePart: got tables %q to analyze expected [reports missing]", part.Analyze)
+	}
+
+	expectError(t, "parsePart", "malformed tables", func() error {
+		_, err := parsePart("reports.sql", []byte("-- @migrate/analyze reports orders\n-- @migrate/up\nSELECT 1;\n"+
+			"-- @migrate/down\nSELECT 1;\n"))
+		return err
+	}, "expected comma-separated tables")
+
+	for dialect, expected := range map[Dialect]string{Postgres: "ANALYZE reports;", MySQL: "ANALYZE TABLE reports;",
+		BigQuery: ""} {
+		if statement := dialect.(Statistics).Analyze("reports"); statement != expected {
+			t.Errorf("Dialect.Analyze: got '%s' for %s expected '%s'", statement, dialect.Name(), expected)
+		}
+	}
+
+	registry.Lock()
+	saved := registry.plugins
+	registry.Unlock()
+	defer func() {
+		registry.Lock()
+		registry.plugins = saved
+		registry.Unlock()
+	}()
+
+	var settled []string
+	RegisterHooks(Hooks{AfterVersion: func(instance *Instance, version int, direction string) {
+		settled = append(settled, fmt.Sprintf("%s %d", direction, version))
+	}})
+
+	root := CopyTree(t, "testing/meta")
+	if err := ioutil.WriteFile(filepath.Join(root, "version_2", "reports.sql"), []byte("-- @migrate/analyze "+
+		"reports,missing\n-- @migrate/up\nCREATE TABLE reports(ID INT PRIMARY KEY);\nINSERT INTO reports VALUES (1);\n"+
+		"-- @migrate/down\nDROP TABLE reports;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		if !strings.Contains(output.String(), "- Analyzed 'reports'") ||
+			!strings.Contains(output.String(), "- Failed to analyze 'missing'") {
+			t.Errorf("Instance.Latest: expected reports to be analyzed and missing to fail, got:\n%s", output)
+		}
+		if !tableExists(db, "sqlite_stat1") {
+			t.Error("Instance.Latest: expected statistics to be gathered")
+		}
+
+		if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if strings.Count(output.String(), "Analyzed") != 1 {
+			t.Errorf("Instance.Goto: expected no tables to be analyzed migrating down, got:\n%s", output)
+		}
+	})
+
+	if strings.Join(settled, ",") != "up 1,up 2,down 2,down 1" {
+		t.Errorf("Hooks.AfterVersion: got %v expected every version in either direction", settled)
+	}
+}
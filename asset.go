@@ -0,0 +1,197 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// regexLoadCSV matches the argument of a load-csv directive, such as
+// `countries.csv INTO countries`.
+var regexLoadCSV = regexp.MustCompile(`(?i)^(\S+)\s+INTO\s+(\S+)$`)
+
+// Asset is a file within a migration directory which is not itself a part,
+// such as a CSV file loaded by a part with `-- @migrate/load-csv` or a README
+// describing the version. Assets are validated and checksummed along with the
+// parts of their migration, but are never executed.
+type Asset struct {
+	Name string
+	Path string
+	// Checksum is the hex-encoded digest of the asset exactly as read, with
+	// the algorithm provided with WithChecksumAlgorithm or SHA-256 otherwise.
+	Checksum string
+
+	contents []byte // Contents of the asset, from which its checksum may be recomputed
+}
+
+// lockEntry returns a Part standing in for the asset when comparing checksums
+// to those of the LockFile, in which an asset is recorded by the checksum of
+// its contents exactly as read, as a part once was.
+func (asset *Asset) lockEntry() *Part {
+	return &Part{Name: asset.Name, Path: asset.Path, Checksum: asset.Checksum, RawChecksum: asset.Checksum,
+		contents: asset.contents}
+}
+
+// CSVLoad describes a `-- @migrate/load-csv <file> INTO <table>` directive,
+// which inserts every row of a CSV asset of the same migration into a table.
+type CSVLoad struct {
+	File  string
+	Table string
+
+	records [][]string // Records of the file, beginning with the header naming the columns
+}
+
+// parseLoadCSV parses the argument of a load-csv directive within the part
+// file at path.
+func parseLoadCSV(path, argument string) (*CSVLoad, error) {
+	matches := regexLoadCSV.FindStringSubmatch(argument)
+	if matches == nil {
+		return nil, NewFatalf("Migration.AddFile: expected load-csv directive in part file '%s' to be formatted "+
+			"as '<file> INTO <table>', got '%s'", path, argument)
+	} else if strings.ContainsAny(matches[1], `/\`) {
+		return nil, NewFatalf("Migration.AddFile: file '%s' loaded by part file '%s' must be within the same "+
+			"directory", matches[1], path)
+	} else if !regexTable.MatchString(matches[2]) {
+		return nil, NewFatalf("Migration.AddFile: invalid table name '%s' in load-csv directive of part file '%s'",
+			matches[2], path)
+	}
+
+	return &CSVLoad{File: matches[1], Table: matches[2]}, nil
+}
+
+// isAsset returns true if the file named within the migration directory
+// provided should be loaded as an asset, either because it matches one of the
+// patterns provided with WithAssets or because a part loads it.
+func (loader loader) isAsset(directory, name string, loaded map[string]bool) bool {
+	return loaded[name] || loader.assets.match(directory+"/"+name)
+}
+
+// asset reads the asset at path, validating its contents if the format of the
+// file is known. A CSV file must hold a header naming at least one column, and
+// the same number of fields on every line.
+func (loader loader) asset(path string) (*Asset, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, name := filepath.Split(path)
+	asset := &Asset{Name: name, Path: path, Checksum: loader.algorithm.digest(contents), contents: contents}
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		if _, err := parseCSV(contents); err != nil {
+			return nil, NewFatalf("NewMigration: got error while parsing asset '%s':\n%s", path, err)
+		}
+	}
+
+	return asset, nil
+}
+
+// parseCSV returns the records of a CSV file, beginning with its header.
+func parseCSV(contents []byte) ([][]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(contents)).ReadAll()
+	if err != nil {
+		return nil, err
+	} else if len(records) == 0 {
+		return nil, fmt.Errorf("migrate: expected header naming the columns to load, got empty file")
+	}
+
+	for _, column := range records[0] {
+		if strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("migrate: expected header naming the columns to load, got empty column name")
+		} else if !regexColumn.MatchString(column) {
+			return nil, fmt.Errorf("migrate: invalid column name '%s' in header", column)
+		}
+	}
+
+	return records, nil
+}
+
+// resolveLoads attaches the records of the asset loaded by each load-csv
+// directive within the parts of migration, returning an error if a part loads
+// a file which is not among the assets of the migration.
+func resolveLoads(migration *Migration) error {
+	assets := make(map[string]*Asset, len(migration.Assets))
+	for _, asset := range migration.Assets {
+		assets[asset.Name] = asset
+	}
+
+	for _, part := range migration.Parts {
+		for _, statement := range part.UpStatements {
+			if statement.CSV == nil {
+				continue
+			}
+
+			asset, ok := assets[statement.CSV.File]
+			if !ok {
+				return NewFatalf("NewMigration: file '%s' loaded by part '%s' not found in '%s'", statement.CSV.File,
+					part.Name, migration.Path)
+			}
+
+			records, err := parseCSV(asset.contents)
+			if err != nil {
+				return NewFatalf("NewMigration: got error while parsing asset '%s':\n%s", asset.Path, err)
+			}
+			statement.CSV.records = records
+		}
+	}
+
+	return nil
+}
+
+// loadedFiles returns the names of the files loaded by the load-csv
+// directives of the parts provided.
+func loadedFiles(parts []*Part) map[string]bool {
+	loaded := make(map[string]bool)
+	for _, part := range parts {
+		for _, statement := range part.UpStatements {
+			if statement.CSV != nil {
+				loaded[statement.CSV.File] = true
+			}
+		}
+	}
+
+	return loaded
+}
+
+// loadCSV inserts every record of the CSV file described by load, other than
+// its header, into the table it names with executor, returning the number of
+// rows inserted. Empty fields are inserted as NULL, and every other field as
+// text which the database converts to the type of its column.
+func (instance *Instance) loadCSV(ctx context.Context, executor Executor, load *CSVLoad) (int64, error) {
+	if load.records == nil {
+		return 0, fmt.Errorf("migrate: file '%s' was not loaded along with the migration of the part", load.File)
+	}
+
+	query, err := instance.insertQuery(load.Table, load.records[0])
+	if err != nil {
+		return 0, err
+	}
+
+	inserted := int64(0)
+	for _, record := range load.records[1:] {
+		if _, err := executor.ExecContext(ctx, query, csvArgs(record)...); err != nil {
+			return inserted, err
+		}
+		inserted++
+	}
+
+	return inserted, nil
+}
+
+// csvArgs returns the arguments with which a record of a CSV file is
+// inserted, in which empty fields are NULL.
+func csvArgs(record []string) []interface{} {
+	args := make([]interface{}, len(record))
+	for i, field := range record {
+		if field != "" {
+			args[i] = field
+		}
+	}
+
+	return args
+}
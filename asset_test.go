@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestAssets ensures that files loaded by a part or matching WithAssets are
+// checksummed along with their migration, and that a CSV asset is loaded
+// into the table named by the load-csv directive.
+func TestAssets(t *testing.T) {
+	migration, err := NewMigration("testing/assets/version_1")
+	if err != nil {
+		t.Fatal("NewMigration: got error:\n", err)
+	}
+	if len(migration.Assets) != 1 || migration.Assets[0].Name != "countries.csv" {
+		t.Errorf("NewMigration: got assets '%#v' expected only countries.csv", migration.Assets)
+	}
+	if statements := migration.Parts[0].UpStatements; len(statements) != 2 || statements[1].CSV == nil ||
+		statements[1].CSV.Table != "countries" {
+		t.Errorf("NewMigration: got statements '%#v' expected CREATE TABLE and load-csv", statements)
+	}
+
+	root := CopyTree(t, "testing/assets")
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithAssets("*.md"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if assets := instance.migrations[1].Assets; len(assets) != 2 || assets[0].Name != "README.md" {
+			t.Errorf("WithAssets: got assets '%#v' expected README.md and countries.csv", assets)
+		}
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		var count, missing int
+		if err := db.QueryRow(`SELECT COUNT(*), COUNT(*) - COUNT(Population) FROM countries;`).Scan(&count,
+			&missing); err != nil {
+			t.Fatal("db.QueryRow: got error:\n", err)
+		} else if count != 3 || missing != 1 {
+			t.Errorf("Instance.Latest: got %d rows with %d NULL populations expected 3 with 1", count, missing)
+		}
+
+		checksum := instance.migrations[1].Checksum()
+		if err := ioutil.WriteFile(filepath.Join(root, "version_1", "README.md"), []byte("# Changed\n"),
+			0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		reloaded, err := NewInstance(db, root, WithAssets("*.md"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		if reloaded.migrations[1].Checksum() == checksum {
+			t.Error("Migration.Checksum: got unchanged checksum after modifying an asset")
+		}
+
+		expectError(t, "NewInstance", "modified asset with lock file", func() error {
+			_, err := NewInstance(db, root, WithAssets("*.md"), WithStrictLockFile())
+			return err
+		}, "version_1/README.md")
+	})
+}
+
+// TestAssetErrors ensures that malformed load-csv directives and CSV assets
+// are rejected when the migration is loaded.
+func TestAssetErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		part     string
+		csv      string
+		expected string
+	}{
+		{"missing file", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv missing.csv INTO t\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "A\n1\n", "'missing.csv' loaded by part"},
+		{"inconsistent fields", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv data.csv INTO t\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "A\n1,2\n", "wrong number of fields"},
+		{"empty file", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv data.csv INTO t\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "", "got empty file"},
+		{"outside directory", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv ../data.csv INTO t\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "A\n1\n", "must be within the same directory"},
+		{"malformed", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv data.csv t\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "A\n1\n", "'<file> INTO <table>'"},
+		{"down section", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/down\nDROP TABLE t;\n" +
+			"-- @migrate/load-csv data.csv INTO t\n", "A\n1\n", "only appear within the upward migration"},
+		{"invalid table", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv data.csv INTO t;DROP\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "A\n1\n", "invalid table name 't;DROP'"},
+		{"invalid column", "-- @migrate/up\nCREATE TABLE t(A INT);\n-- @migrate/load-csv data.csv INTO t\n" +
+			"-- @migrate/down\nDROP TABLE t;\n", "\"A) SELECT 1; --\"\n1\n", "invalid column name 'A) SELECT 1; --'"},
+	}
+
+	for _, c := range cases {
+		root := filepath.Join(CopyTree(t, "testing/meta"), "version_1")
+		if err := ioutil.WriteFile(filepath.Join(root, "load.sql"), []byte(c.part), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		} else if err := ioutil.WriteFile(filepath.Join(root, "data.csv"), []byte(c.csv), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		expectError(t, "NewMigration", c.name, func() error {
+			_, err := NewMigration(root)
+			return err
+		}, c.expected)
+	}
+}
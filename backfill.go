@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"context"
+
+	"github.com/octacian/migrate/backfill"
+)
+
+// RunBackfill runs the backfill named, declared within the backfills
+// directory of the instance directory as described by package backfill, from
+// where it last stopped until every row of its table has been visited. Each
+// batch is committed along with the progress it made, so a backfill which
+// fails or is interrupted may be resumed by calling RunBackfill again, and a
+// backfill which has finished does nothing. An error is returned if the
+// database has not reached the version which the backfill requires.
+//
+// Backfills are not applied by Goto or Latest, and do not hold the migration
+// lock, so that moving the data of a large table never blocks the migration
+// of the schema.
+func (instance *Instance) RunBackfill(name string) error {
+	return instance.RunBackfillContext(context.Background(), name)
+}
+
+// RunBackfillContext behaves exactly as RunBackfill, stopping once the
+// current batch is committed if ctx is done, in which case ctx.Err() is
+// included in the error returned.
+func (instance *Instance) RunBackfillContext(ctx context.Context, name string) error {
+	if instance.closed {
+		return NewFatalf("Instance.RunBackfill: instance has been closed")
+	} else if instance.readOnly {
+		return NewFatalf("Instance.RunBackfill: instance is read-only")
+	}
+
+	fills, err := backfill.Load(instance.root)
+	if err != nil {
+		return NewFatalf("Instance.RunBackfill: got error while loading backfills:\n%s", err)
+	}
+
+	fill, ok := fills[name]
+	if !ok {
+		return NewFatalf("Instance.RunBackfill: no backfill named '%s' in '%s'", name, instance.root)
+	}
+
+	current, _, err := instance.readVersion(instance.db)
+	if err != nil {
+		return NewFatalf("Instance.RunBackfill: got error while reading version:\n%s", err)
+	} else if current < fill.Version {
+		return NewFatalf("Instance.RunBackfill: backfill '%s' requires version %d, database at version %d",
+			name, fill.Version, current)
+	}
+
+	runner := instance.backfillRunner()
+	runner.Notify = func(progress backfill.Progress) {
+		if !progress.Finished {
+			instance.say(MessageBackfillBatch, MessageData{Part: name, Applied: int(progress.Batches),
+				Rows: progress.Rows})
+		}
+	}
+
+	start := instance.clock.Now()
+	progress, err := runner.Run(ctx, fill)
+	if err != nil {
+		instance.log(LevelError, "backfill failed", Field{"backfill", name}, Field{"batches", progress.Batches},
+			Field{"rows", progress.Rows}, Field{"error", err})
+		return NewFatalf("Instance.RunBackfill: got error while running backfill '%s':\n%s", name, err)
+	}
+
+	instance.say(MessageBackfilled, MessageData{Part: name, Rows: progress.Rows,
+		Duration: instance.clock.Now().Sub(start)})
+	instance.log(LevelInfo, "backfill finished", Field{"backfill", name}, Field{"batches", progress.Batches},
+		Field{"rows", progress.Rows})
+	return nil
+}
+
+// backfillRunner returns a backfill.Runner which persists progress within the
+// table of the Instance, as of the time read from its Clock.
+func (instance *Instance) backfillRunner() *backfill.Runner {
+	return &backfill.Runner{DB: instance.db, Table: instance.table("migrate_backfill"),
+		Numbered: numberedPlaceholders(instance.dialect), Clock: instance.clock}
+}
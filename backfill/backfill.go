@@ -0,0 +1,454 @@
+// Package backfill runs long-running data backfills in small batches, pacing
+// them to limit their load on the database and persisting their progress so
+// that a backfill interrupted by a deploy or failure resumes where it stopped.
+// Backfills are declared within the Directory of a migration tree, kept apart
+// from the versions so that moving the data of a large table never blocks the
+// migration of the schema, and are usually run with
+// migrate.Instance.RunBackfill.
+//
+// Each backfill is a file named `<name>.sql`, beginning with a directive which
+// names the table visited, its integer cursor column, and optionally the rows
+// in each batch, the pause between batches, and the version which the schema
+// must have reached before the backfill may run:
+//
+//	-- @migrate/backfill table=users cursor=ID batch=500 pause=250ms version=3
+//	UPDATE users SET Email = LOWER(Email) WHERE ID > ? AND ID <= ?;
+//
+// The statement following the directive is executed once for each batch with
+// the cursor value after which the batch begins and that with which it ends,
+// so that every row is visited exactly once. It is executed as written, so
+// its placeholders must be those of the database, such as `$1` and `$2` for
+// PostgreSQL.
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Directory is the name of the directory within a migration tree from which
+// backfills are loaded.
+const Directory = "backfills"
+
+// DefaultBatch is the number of rows in each batch of a backfill which does
+// not specify one.
+const DefaultBatch = 1000
+
+// directive begins the line declaring a backfill.
+const directive = "-- @migrate/backfill"
+
+// regexTable matches the name of the table of a backfill, optionally
+// qualified with its schema, and regexCursor the name of its cursor column,
+// as each is interpolated into the queries which find the bounds of a batch.
+var (
+	regexTable  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	regexCursor = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Backfill is a data backfill declared within a migration tree.
+type Backfill struct {
+	Name      string        // Name of the file without its extension
+	Table     string        // Table whose rows are visited
+	Cursor    string        // Integer column by which rows are ordered, which should be unique and indexed
+	Batch     int           // Number of rows in each batch
+	Pause     time.Duration // Time waited between batches
+	Version   int           // Version which the schema must have reached, or 0 for any
+	Statement string        // Statement executed with the bounds of each batch
+}
+
+// Progress is the progress of a backfill, persisted after every batch.
+type Progress struct {
+	Name      string
+	Position  int64 // Cursor value of the last row visited
+	Rows      int64 // Number of rows affected so far
+	Batches   int64 // Number of batches completed so far
+	Finished  bool
+	UpdatedAt time.Time
+}
+
+// Parse returns the backfill named declared by contents.
+func Parse(name, contents string) (*Backfill, error) {
+	fill := &Backfill{Name: name, Batch: DefaultBatch}
+
+	var statement []string
+	found := false
+	for _, line := range strings.Split(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, directive) {
+			if found || !strings.HasPrefix(trimmed, "--") {
+				statement = append(statement, line)
+			}
+			continue
+		} else if found {
+			return nil, fmt.Errorf("backfill: '%s' declares more than one backfill", name)
+		}
+
+		found = true
+		if err := fill.parseDirective(strings.TrimPrefix(trimmed, directive)); err != nil {
+			return nil, fmt.Errorf("backfill: got error while parsing directive of '%s':\n%s", name, err)
+		}
+	}
+
+	fill.Statement = strings.TrimSpace(strings.Join(statement, "\n"))
+	switch {
+	case !found:
+		return nil, fmt.Errorf("backfill: '%s' is missing the `%s` directive", name, directive)
+	case fill.Table == "" || fill.Cursor == "":
+		return nil, fmt.Errorf("backfill: '%s' must name both a table and a cursor column", name)
+	case !regexTable.MatchString(fill.Table):
+		return nil, fmt.Errorf("backfill: '%s' names invalid table '%s'", name, fill.Table)
+	case !regexCursor.MatchString(fill.Cursor):
+		return nil, fmt.Errorf("backfill: '%s' names invalid cursor column '%s'", name, fill.Cursor)
+	case fill.Statement == "":
+		return nil, fmt.Errorf("backfill: '%s' has no statement to execute", name)
+	}
+
+	return fill, nil
+}
+
+// parseDirective sets the fields of fill from the `key=value` pairs provided.
+func (fill *Backfill) parseDirective(pairs string) error {
+	for _, pair := range strings.Fields(pairs) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return fmt.Errorf("expected 'key=value', got '%s'", pair)
+		}
+
+		var err error
+		switch key, value := parts[0], parts[1]; key {
+		case "table":
+			fill.Table = value
+		case "cursor":
+			fill.Cursor = value
+		case "batch":
+			if fill.Batch, err = strconv.Atoi(value); err == nil && fill.Batch < 1 {
+				err = fmt.Errorf("batch must be at least 1, got %d", fill.Batch)
+			}
+		case "pause":
+			fill.Pause, err = time.ParseDuration(value)
+		case "version":
+			fill.Version, err = strconv.Atoi(value)
+		default:
+			return fmt.Errorf("unknown key '%s'", key)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load returns every backfill declared within the Directory of root, keyed by
+// name. An empty map is returned if root has no such directory.
+func Load(root string) (map[string]*Backfill, error) {
+	directory := filepath.Join(root, Directory)
+	files, err := ioutil.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return map[string]*Backfill{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	fills := make(map[string]*Backfill)
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != ".sql" {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(filepath.Join(directory, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		fill, err := Parse(strings.TrimSuffix(file.Name(), ".sql"), string(contents))
+		if err != nil {
+			return nil, err
+		}
+		fills[fill.Name] = fill
+	}
+
+	return fills, nil
+}
+
+// Clock provides the current time and the timer by which the pause between
+// batches is waited for, as does the Clock of package migrate.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Runner runs backfills against a database, persisting their progress within
+// a table of its own.
+type Runner struct {
+	DB       *sql.DB
+	Table    string         // Table in which progress is persisted, "migrate_backfill" if empty
+	Numbered bool           // Whether the database expects numbered placeholders, as in `$1`, rather than `?`
+	Clock    Clock          // Source of the time recorded with progress and of pauses, the system clock if nil
+	Notify   func(Progress) // Called after every batch, if not nil
+}
+
+// now returns the current time as read from the Clock of the runner.
+func (runner *Runner) now() time.Time {
+	if runner.Clock == nil {
+		return time.Now()
+	}
+
+	return runner.Clock.Now()
+}
+
+// after returns a channel which receives the time once d has elapsed, as read
+// from the Clock of the runner.
+func (runner *Runner) after(d time.Duration) <-chan time.Time {
+	if runner.Clock == nil {
+		return time.After(d)
+	}
+
+	return runner.Clock.After(d)
+}
+
+// table returns the name of the table in which progress is persisted.
+func (runner *Runner) table() string {
+	if runner.Table == "" {
+		return "migrate_backfill"
+	}
+
+	return runner.Table
+}
+
+// bind rewrites each `?` placeholder within a query of the runner itself as
+// `$1`, `$2`, and so on if the database expects numbered placeholders. The
+// statement of a backfill is executed as written.
+func (runner *Runner) bind(query string) string {
+	if !runner.Numbered {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, char := range query {
+		if char == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+		} else {
+			builder.WriteRune(char)
+		}
+	}
+
+	return builder.String()
+}
+
+// createTable creates the table in which progress is persisted if it does not
+// already exist.
+func (runner *Runner) createTable(ctx context.Context) error {
+	_, err := runner.DB.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS `+runner.table()+`(
+			Name VARCHAR(255) NOT NULL PRIMARY KEY,
+			Position BIGINT NOT NULL,
+			Affected BIGINT NOT NULL,
+			Batches BIGINT NOT NULL,
+			Finished INT NOT NULL,
+			UpdatedAt BIGINT NOT NULL
+		);
+	`)
+	return err
+}
+
+// Progress returns the progress persisted for the backfill named, or a zero
+// Progress if it has never run.
+func (runner *Runner) Progress(ctx context.Context, name string) (Progress, error) {
+	if err := runner.createTable(ctx); err != nil {
+		return Progress{}, fmt.Errorf("backfill: got error while creating progress table:\n%s", err)
+	}
+
+	return runner.progress(ctx, runner.DB, name)
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// progress reads the progress of the backfill named through db.
+func (runner *Runner) progress(ctx context.Context, db queryRower, name string) (Progress, error) {
+	progress := Progress{Name: name, Position: math.MinInt64}
+
+	var finished int
+	var updatedAt int64
+	err := db.QueryRowContext(ctx, runner.bind(`SELECT Position, Affected, Batches, Finished, UpdatedAt FROM `+
+		runner.table()+` WHERE Name = ?;`), name).Scan(&progress.Position, &progress.Rows, &progress.Batches,
+		&finished, &updatedAt)
+	if err == sql.ErrNoRows {
+		return progress, nil
+	} else if err != nil {
+		return Progress{}, fmt.Errorf("backfill: got error while reading progress of '%s':\n%s", name, err)
+	}
+
+	progress.Finished = finished != 0
+	progress.UpdatedAt = time.Unix(0, updatedAt)
+	return progress, nil
+}
+
+// Run runs fill from where it last stopped until every row of its table has
+// been visited or ctx is done, committing each batch along with the progress
+// it made, such that the batches already committed are never repeated. The
+// progress reached is returned along with any error. Rows inserted behind the
+// cursor once a batch has passed them are not visited, and a backfill which
+// has finished does nothing until its progress is reset with Reset.
+func (runner *Runner) Run(ctx context.Context, fill *Backfill) (Progress, error) {
+	progress, err := runner.Progress(ctx, fill.Name)
+	if err != nil || progress.Finished {
+		return progress, err
+	}
+
+	bound := runner.bind(`SELECT MAX(` + fill.Cursor + `) FROM (SELECT ` + fill.Cursor + ` FROM ` + fill.Table +
+		` WHERE ` + fill.Cursor + ` > ? ORDER BY ` + fill.Cursor + ` LIMIT ` + strconv.Itoa(fill.Batch) +
+		`) batch;`)
+	for {
+		var end sql.NullInt64
+		if err := runner.DB.QueryRowContext(ctx, bound, progress.Position).Scan(&end); err != nil {
+			return progress, fmt.Errorf("backfill: got error while finding end of batch %d of '%s':\n%s",
+				progress.Batches+1, fill.Name, err)
+		}
+
+		next, err := runner.batch(ctx, fill, progress, end)
+		if err != nil {
+			return progress, err
+		}
+
+		progress = next
+		if runner.Notify != nil {
+			runner.Notify(progress)
+		}
+		if progress.Finished {
+			return progress, nil
+		}
+
+		if fill.Pause > 0 {
+			select {
+			case <-ctx.Done():
+				return progress, ctx.Err()
+			case <-runner.after(fill.Pause):
+			}
+		} else if err := ctx.Err(); err != nil {
+			return progress, err
+		}
+	}
+}
+
+// batch executes the statement of fill over the rows following the progress
+// provided up to end, or marks fill finished if end is not valid, persisting
+// the progress made within the same transaction.
+func (runner *Runner) batch(ctx context.Context, fill *Backfill, progress Progress, end sql.NullInt64) (Progress,
+	error) {
+	transaction, err := runner.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return progress, fmt.Errorf("backfill: got error while beginning batch of '%s':\n%s", fill.Name, err)
+	}
+	defer transaction.Rollback()
+
+	next := progress
+	if end.Valid {
+		result, err := transaction.ExecContext(ctx, fill.Statement, progress.Position, end.Int64)
+		if err != nil {
+			return progress, fmt.Errorf("backfill: got error while executing batch %d of '%s':\n%s",
+				progress.Batches+1, fill.Name, err)
+		}
+
+		if affected, err := result.RowsAffected(); err == nil {
+			next.Rows += affected
+		}
+		next.Position = end.Int64
+		next.Batches++
+	} else {
+		next.Finished = true
+	}
+	next.UpdatedAt = runner.now()
+
+	if err := runner.save(ctx, transaction, progress, next); err != nil {
+		return progress, err
+	} else if err := transaction.Commit(); err != nil {
+		return progress, fmt.Errorf("backfill: got error while committing batch of '%s':\n%s", fill.Name, err)
+	}
+
+	return next, nil
+}
+
+// save persists progress within transaction, replacing the progress previous
+// from which the batch began. The row is updated only if it still holds
+// previous, such that the update locks it until transaction ends, and the
+// batch of a runner which finds that another has committed the same batch in
+// the meantime is rolled back rather than repeated. The row is inserted if
+// previous was never persisted, where its primary key likewise prevents two
+// runners from both committing the first batch.
+func (runner *Runner) save(ctx context.Context, transaction *sql.Tx, previous, progress Progress) error {
+	finished := 0
+	if progress.Finished {
+		finished = 1
+	}
+
+	args := []interface{}{progress.Position, progress.Rows, progress.Batches, finished,
+		progress.UpdatedAt.UnixNano(), progress.Name}
+	result, err := transaction.ExecContext(ctx, runner.bind(`UPDATE `+runner.table()+` SET Position = ?, `+
+		`Affected = ?, Batches = ?, Finished = ?, UpdatedAt = ? WHERE Name = ? AND Position = ? AND Batches = ? `+
+		`AND Finished = 0;`), append(args, previous.Position, previous.Batches)...)
+	if err != nil {
+		return fmt.Errorf("backfill: got error while saving progress of '%s':\n%s", progress.Name, err)
+	} else if updated, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("backfill: got error while saving progress of '%s':\n%s", progress.Name, err)
+	} else if updated == 1 {
+		return nil
+	}
+
+	if previous.Batches > 0 || previous.Position != math.MinInt64 {
+		return fmt.Errorf("backfill: progress of '%s' changed while running, is another runner running it?",
+			progress.Name)
+	}
+
+	if _, err := transaction.ExecContext(ctx, runner.bind(`INSERT INTO `+runner.table()+` (Position, Affected, `+
+		`Batches, Finished, UpdatedAt, Name) VALUES (?, ?, ?, ?, ?, ?);`), args...); err != nil {
+		return fmt.Errorf("backfill: got error while saving progress of '%s', is another runner running it?\n%s",
+			progress.Name, err)
+	}
+
+	return nil
+}
+
+// Reset discards the progress of the backfill named, such that it runs from
+// the beginning when next run.
+func (runner *Runner) Reset(ctx context.Context, name string) error {
+	if err := runner.createTable(ctx); err != nil {
+		return fmt.Errorf("backfill: got error while creating progress table:\n%s", err)
+	}
+
+	if _, err := runner.DB.ExecContext(ctx, runner.bind(`DELETE FROM `+runner.table()+` WHERE Name = ?;`),
+		name); err != nil {
+		return fmt.Errorf("backfill: got error while resetting progress of '%s':\n%s", name, err)
+	}
+
+	return nil
+}
+
+// ResetAll discards the progress of every backfill, such that each runs from
+// the beginning when next run.
+func (runner *Runner) ResetAll(ctx context.Context) error {
+	if err := runner.createTable(ctx); err != nil {
+		return fmt.Errorf("backfill: got error while creating progress table:\n%s", err)
+	}
+
+	if _, err := runner.DB.ExecContext(ctx, `DELETE FROM `+runner.table()+`;`); err != nil {
+		return fmt.Errorf("backfill: got error while resetting progress:\n%s", err)
+	}
+
+	return nil
+}
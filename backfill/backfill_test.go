@@ -0,0 +1,217 @@
+package backfill
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TestParse ensures that a backfill is parsed from its directive and
+// statement, and that malformed declarations are rejected.
+func TestParse(t *testing.T) {
+	fill, err := Parse("lowercase", "-- Lower case every email\n"+
+		"-- @migrate/backfill table=users cursor=ID batch=500 pause=250ms version=3\n"+
+		"UPDATE users SET Email = LOWER(Email) WHERE ID > ? AND ID <= ?;\n")
+	if err != nil {
+		t.Fatal("Parse: got error:\n", err)
+	}
+
+	expected := &Backfill{Name: "lowercase", Table: "users", Cursor: "ID", Batch: 500, Pause: 250 * time.Millisecond,
+		Version: 3, Statement: "UPDATE users SET Email = LOWER(Email) WHERE ID > ? AND ID <= ?;"}
+	if *fill != *expected {
+		t.Errorf("Parse: got '%#v' expected '%#v'", fill, expected)
+	}
+
+	cases := map[string]string{
+		"missing directive": "UPDATE users SET Email = '';",
+		"missing cursor":    "-- @migrate/backfill table=users\nUPDATE users SET Email = '';",
+		"missing statement": "-- @migrate/backfill table=users cursor=ID\n",
+		"unknown key":       "-- @migrate/backfill table=users cursor=ID size=5\nUPDATE users SET Email = '';",
+		"invalid batch":     "-- @migrate/backfill table=users cursor=ID batch=0\nUPDATE users SET Email = '';",
+		"invalid pause":     "-- @migrate/backfill table=users cursor=ID pause=soon\nUPDATE users SET Email = '';",
+		"invalid table":     "-- @migrate/backfill table=users;-- cursor=ID\nUPDATE users SET Email = '';",
+		"invalid cursor":    "-- @migrate/backfill table=users cursor=ID)\nUPDATE users SET Email = '';",
+	}
+	for name, contents := range cases {
+		if _, err := Parse("broken", contents); err == nil {
+			t.Errorf("Parse: expected error for %s", name)
+		}
+	}
+}
+
+// fixedClock is a Clock which always reads the same time, while its timers
+// run in real time.
+type fixedClock time.Time
+
+func (clock fixedClock) Now() time.Time {
+	return time.Time(clock)
+}
+
+func (clock fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// TestRunner ensures that a backfill visits every row exactly once in
+// batches, persisting its progress such that it resumes where it stopped.
+func TestRunner(t *testing.T) {
+	directory, err := ioutil.TempDir("", "backfill")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	db, err := sql.Open("sqlite3", filepath.Join(directory, "backfill.sqlite"))
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE items(ID INT PRIMARY KEY, Visits INT NOT NULL);`); err != nil {
+		t.Fatal("db.Exec: got error:\n", err)
+	}
+	for id := 1; id <= 25; id++ {
+		if _, err := db.Exec(`INSERT INTO items (ID, Visits) VALUES (?, 0);`, id*2); err != nil {
+			t.Fatal("db.Exec: got error:\n", err)
+		}
+	}
+
+	fill := &Backfill{Name: "visit", Table: "items", Cursor: "ID", Batch: 10,
+		Statement: "UPDATE items SET Visits = Visits + 1 WHERE ID > ? AND ID <= ?;"}
+
+	// Stop after the first batch, as though the process were interrupted
+	ctx, cancel := context.WithCancel(context.Background())
+	runner := &Runner{DB: db, Notify: func(Progress) { cancel() }}
+	progress, err := runner.Run(ctx, fill)
+	if err != context.Canceled {
+		t.Fatalf("Runner.Run: got error '%v' expected context.Canceled", err)
+	} else if progress.Position != 20 || progress.Rows != 10 || progress.Batches != 1 {
+		t.Errorf("Runner.Run: got progress '%#v' expected position 20 after 10 rows", progress)
+	}
+
+	var batches []int64
+	clock := fixedClock(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	runner = &Runner{DB: db, Clock: clock, Notify: func(progress Progress) {
+		batches = append(batches, progress.Rows)
+	}}
+	if progress, err = runner.Run(context.Background(), fill); err != nil {
+		t.Fatal("Runner.Run: got error while resuming:\n", err)
+	}
+	if !progress.Finished || progress.Rows != 25 || progress.Batches != 3 {
+		t.Errorf("Runner.Run: got progress '%#v' expected 25 rows in 3 batches", progress)
+	}
+	if len(batches) != 3 || batches[0] != 20 || batches[1] != 25 {
+		t.Errorf("Runner.Notify: got rows %v expected 20, 25, and 25 once finished", batches)
+	}
+	if !progress.UpdatedAt.Equal(time.Time(clock)) {
+		t.Errorf("Runner.Run: got progress updated at %s expected %s from Clock", progress.UpdatedAt,
+			time.Time(clock))
+	}
+
+	var visited, repeated int
+	if err := db.QueryRow(`SELECT COUNT(*), COUNT(CASE WHEN Visits > 1 THEN 1 END) FROM items WHERE Visits > 0;`).
+		Scan(&visited, &repeated); err != nil {
+		t.Fatal("db.QueryRow: got error:\n", err)
+	} else if visited != 25 || repeated != 0 {
+		t.Errorf("Runner.Run: got %d rows visited and %d more than once expected 25 and 0", visited, repeated)
+	}
+
+	if progress, err = runner.Run(context.Background(), fill); err != nil || progress.Batches != 3 {
+		t.Errorf("Runner.Run: got progress '%#v' and error '%v' expected nothing once finished", progress, err)
+	}
+
+	if err := runner.Reset(context.Background(), "visit"); err != nil {
+		t.Fatal("Runner.Reset: got error:\n", err)
+	}
+	if progress, err := runner.Progress(context.Background(), "visit"); err != nil {
+		t.Fatal("Runner.Progress: got error:\n", err)
+	} else if progress.Finished || progress.Batches != 0 {
+		t.Errorf("Runner.Reset: got progress '%#v' expected none", progress)
+	}
+}
+
+// TestRunnerConflict ensures that a runner which finds that another has
+// committed the batch it was running rolls that batch back rather than
+// visiting its rows again, including when the other runner commits after the
+// batch statement has run.
+func TestRunnerConflict(t *testing.T) {
+	directory, err := ioutil.TempDir("", "backfill")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	db, err := sql.Open("sqlite3", filepath.Join(directory, "backfill.sqlite"))
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE items(ID INT PRIMARY KEY, Visits INT NOT NULL);`); err != nil {
+		t.Fatal("db.Exec: got error:\n", err)
+	}
+	for id := 1; id <= 25; id++ {
+		if _, err := db.Exec(`INSERT INTO items (ID, Visits) VALUES (?, 0);`, id); err != nil {
+			t.Fatal("db.Exec: got error:\n", err)
+		}
+	}
+
+	fill := &Backfill{Name: "visit", Table: "items", Cursor: "ID", Batch: 10,
+		Statement: "UPDATE items SET Visits = Visits + 1 WHERE ID > ? AND ID <= ?;"}
+
+	// Once the first batch is committed, another runner commits the second before this runner does
+	var other error
+	runner := &Runner{DB: db}
+	runner.Notify = func(progress Progress) {
+		if progress.Batches == 1 {
+			_, other = (&Runner{DB: db}).batch(context.Background(), fill, progress, sql.NullInt64{Int64: 20,
+				Valid: true})
+		}
+	}
+
+	progress, err := runner.Run(context.Background(), fill)
+	if other != nil {
+		t.Fatal("Runner.batch: got error from other runner:\n", other)
+	} else if err == nil || !strings.Contains(err.Error(), "another runner") {
+		t.Errorf("Runner.Run: got error '%v' expected progress to have changed", err)
+	} else if progress.Position != 10 || progress.Batches != 1 {
+		t.Errorf("Runner.Run: got progress '%#v' expected position 10 after the first batch", progress)
+	}
+
+	var visited, repeated int
+	if err := db.QueryRow(`SELECT COUNT(*), COUNT(CASE WHEN Visits > 1 THEN 1 END) FROM items WHERE Visits > 0;`).
+		Scan(&visited, &repeated); err != nil {
+		t.Fatal("db.QueryRow: got error:\n", err)
+	} else if visited != 20 || repeated != 0 {
+		t.Errorf("Runner.Run: got %d rows visited and %d more than once expected 20 and 0", visited, repeated)
+	}
+
+	// The progress is checked by the update which persists it, after the batch statement has run
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal("sqlmock.New: got error:\n", err)
+	}
+	defer mockDB.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(fill.Statement).WithArgs(10, 20).WillReturnResult(sqlmock.NewResult(0, 10))
+	mock.ExpectExec(`UPDATE migrate_backfill SET Position = ?, Affected = ?, Batches = ?, Finished = ?, `+
+		`UpdatedAt = ? WHERE Name = ? AND Position = ? AND Batches = ? AND Finished = 0;`).
+		WithArgs(20, 20, 2, 0, sqlmock.AnyArg(), "visit", 10, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err = (&Runner{DB: mockDB}).batch(context.Background(), fill, Progress{Name: "visit", Position: 10,
+		Rows: 10, Batches: 1}, sql.NullInt64{Int64: 20, Valid: true})
+	if err == nil || !strings.Contains(err.Error(), "another runner") {
+		t.Errorf("Runner.batch: got error '%v' expected progress to have changed", err)
+	} else if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error("Runner.batch: got unexpected queries:\n", err)
+	}
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestRunBackfill ensures that a backfill declared within the instance
+// directory is skipped when loading migrations and run by RunBackfill once the
+// database reaches the version which it requires.
+func TestRunBackfill(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/backfill")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		expectError(t, "Instance.RunBackfill", "version not reached", func() error {
+			return instance.RunBackfill("lowercase")
+		}, "requires version 1, database at version 0")
+		expectError(t, "Instance.RunBackfill", "unknown backfill", func() error {
+			return instance.RunBackfill("missing")
+		}, "no backfill named 'missing'")
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.RunBackfill("lowercase"); err != nil {
+			t.Fatal("Instance.RunBackfill: got error:\n", err)
+		}
+
+		var mixed int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM users WHERE Email <> LOWER(Email);`).Scan(&mixed); err != nil {
+			t.Fatal("db.QueryRow: got error:\n", err)
+		} else if mixed != 0 {
+			t.Errorf("Instance.RunBackfill: got %d emails not lower case expected 0", mixed)
+		}
+
+		for _, expected := range []string{"committed batch 2, 3 row(s) so far",
+			"Backfill 'lowercase' finished with 3 row(s)"} {
+			if !strings.Contains(output.String(), expected) {
+				t.Errorf("Instance.RunBackfill: got output '%s' expected '%s'", output.String(), expected)
+			}
+		}
+	})
+}
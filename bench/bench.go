@@ -0,0 +1,80 @@
+// Package bench generates synthetic migration trees of any size, with which
+// the benchmarks of the package measure the time taken by migrate to load,
+// plan, and apply migrations. Changes affecting performance, such as to the
+// parser or the loading of parts, may be evaluated by comparing the results
+// of `go test -bench . ./bench` before and after the change.
+package bench
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Shape describes the size of a synthetic migration tree.
+type Shape struct {
+	Versions   int // Number of migration versions
+	Parts      int // Number of parts within each version
+	Statements int // Number of statements within the up section of each part, at least 1
+}
+
+// String returns the shape as `<versions>x<parts>x<statements>`, suitable for
+// naming a sub-benchmark.
+func (shape Shape) String() string {
+	return fmt.Sprintf("%dx%dx%d", shape.Versions, shape.Parts, shape.Statements)
+}
+
+// Generate writes a migration tree of the shape provided to root, which is
+// created if it does not already exist. Each part creates a table of its own
+// and inserts a row into it with every further statement, and drops the table
+// when migrating down, such that the tree may be applied to any database.
+func Generate(root string, shape Shape) error {
+	if shape.Versions < 1 || shape.Parts < 1 || shape.Statements < 1 {
+		return fmt.Errorf("bench: shape %s must have at least one of everything", shape)
+	}
+
+	for version := 1; version <= shape.Versions; version++ {
+		directory := filepath.Join(root, fmt.Sprintf("version_%d", version))
+		if err := os.MkdirAll(directory, 0755); err != nil {
+			return err
+		}
+
+		for part := 1; part <= shape.Parts; part++ {
+			table := fmt.Sprintf("bench_%d_%d", version, part)
+			var builder strings.Builder
+			fmt.Fprintf(&builder, "-- @migrate/up\n\nCREATE TABLE %s(ID INT PRIMARY KEY, Name VARCHAR(255));\n",
+				table)
+			for statement := 1; statement < shape.Statements; statement++ {
+				fmt.Fprintf(&builder, "INSERT INTO %s (ID, Name) VALUES (%d, 'row %d');\n", table, statement,
+					statement)
+			}
+			fmt.Fprintf(&builder, "\n-- @migrate/down\n\nDROP TABLE %s;\n", table)
+
+			path := filepath.Join(directory, fmt.Sprintf("part_%03d.sql", part))
+			if err := ioutil.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Temp generates a migration tree of the shape provided within a new
+// temporary directory, returning its path. The directory should be removed
+// with os.RemoveAll once it is no longer needed.
+func Temp(shape Shape) (string, error) {
+	root, err := ioutil.TempDir("", "migrate-bench")
+	if err != nil {
+		return "", err
+	}
+
+	if err := Generate(root, shape); err != nil {
+		os.RemoveAll(root)
+		return "", err
+	}
+
+	return root, nil
+}
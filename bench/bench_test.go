@@ -0,0 +1,183 @@
+package bench
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/octacian/migrate"
+	"github.com/octacian/migrate/fake"
+)
+
+// shapes holds the shapes of the trees measured by each benchmark.
+var shapes = []Shape{
+	{Versions: 10, Parts: 2, Statements: 5},
+	{Versions: 100, Parts: 5, Statements: 20},
+}
+
+// open returns a handle to a new SQLite database within directory, along with
+// a function which closes and removes it.
+func open(tb testing.TB, directory string) (*sql.DB, func()) {
+	path := filepath.Join(directory, "bench.sqlite")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		tb.Fatal("sql.Open: got error:\n", err)
+	}
+
+	return db, func() {
+		db.Close()
+		os.Remove(path)
+	}
+}
+
+// tree generates a tree of the shape provided, returning its path and a
+// function which removes it.
+func tree(tb testing.TB, shape Shape) (string, func()) {
+	root, err := Temp(shape)
+	if err != nil {
+		tb.Fatal("Temp: got error:\n", err)
+	}
+
+	return root, func() { os.RemoveAll(root) }
+}
+
+// TestGenerate ensures that a generated tree is loaded and applied in full.
+func TestGenerate(t *testing.T) {
+	shape := Shape{Versions: 3, Parts: 2, Statements: 4}
+	root, remove := tree(t, shape)
+	defer remove()
+
+	db, closeDB := open(t, root)
+	defer closeDB()
+
+	instance, err := migrate.NewInstance(db, root)
+	if err != nil {
+		t.Fatal("migrate.NewInstance: got error:\n", err)
+	}
+	instance.Output = ioutil.Discard
+
+	planned, err := instance.Plan(shape.Versions)
+	if err != nil {
+		t.Fatal("Instance.Plan: got error:\n", err)
+	} else if expected := shape.Versions * shape.Parts * shape.Statements; len(planned) != expected {
+		t.Errorf("Instance.Plan: got %d statements expected %d", len(planned), expected)
+	}
+
+	if err := instance.Latest(); err != nil {
+		t.Fatal("Instance.Latest: got error:\n", err)
+	}
+	if err := instance.Goto(0); err != nil {
+		t.Error("Instance.Goto: got error when migrating down:\n", err)
+	}
+
+	if err := Generate(root, Shape{}); err == nil {
+		t.Error("Generate: expected error with empty shape")
+	}
+}
+
+// BenchmarkLoad measures the time taken to read and parse a tree.
+func BenchmarkLoad(b *testing.B) {
+	for _, shape := range shapes {
+		b.Run(shape.String(), func(b *testing.B) {
+			root, remove := tree(b, shape)
+			defer remove()
+
+			db, closeDB := open(b, root)
+			defer closeDB()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := migrate.NewInstance(db, root, migrate.WithReadOnly()); err != nil {
+					b.Fatal("migrate.NewInstance: got error:\n", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkPlan measures the time taken to plan a run from version 0 to the
+// latest version.
+func BenchmarkPlan(b *testing.B) {
+	for _, shape := range shapes {
+		b.Run(shape.String(), func(b *testing.B) {
+			root, remove := tree(b, shape)
+			defer remove()
+
+			db, closeDB := open(b, root)
+			defer closeDB()
+
+			instance, err := migrate.NewInstance(db, root, migrate.WithReadOnly())
+			if err != nil {
+				b.Fatal("migrate.NewInstance: got error:\n", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := instance.Plan(shape.Versions); err != nil {
+					b.Fatal("Instance.Plan: got error:\n", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkApply measures the time taken to apply a tree to a SQLite database
+// and back down again.
+func BenchmarkApply(b *testing.B) {
+	for _, shape := range shapes {
+		b.Run(shape.String(), func(b *testing.B) {
+			root, remove := tree(b, shape)
+			defer remove()
+
+			db, closeDB := open(b, root)
+			defer closeDB()
+
+			instance, err := migrate.NewInstance(db, root)
+			if err != nil {
+				b.Fatal("migrate.NewInstance: got error:\n", err)
+			}
+			instance.Output = ioutil.Discard
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := instance.Latest(); err != nil {
+					b.Fatal("Instance.Latest: got error:\n", err)
+				} else if err := instance.Goto(0); err != nil {
+					b.Fatal("Instance.Goto: got error:\n", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkOverhead measures the time spent by migrate itself while applying a
+// tree, with statements passed to a fake.Executor rather than the database.
+func BenchmarkOverhead(b *testing.B) {
+	for _, shape := range shapes {
+		b.Run(shape.String(), func(b *testing.B) {
+			root, remove := tree(b, shape)
+			defer remove()
+
+			db, closeDB := open(b, root)
+			defer closeDB()
+
+			instance, err := migrate.NewInstance(db, root, migrate.WithExecutor(fake.New()))
+			if err != nil {
+				b.Fatal("migrate.NewInstance: got error:\n", err)
+			}
+			instance.Output = ioutil.Discard
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := instance.Latest(); err != nil {
+					b.Fatal("Instance.Latest: got error:\n", err)
+				} else if err := instance.Goto(0); err != nil {
+					b.Fatal("Instance.Goto: got error:\n", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	bootstrapAttempts = 5                      // Times the tables of migrate are created before giving up
+	bootstrapBackoff  = 100 * time.Millisecond // Time waited before the second attempt, growing with each attempt
+)
+
+// isDuplicate reports whether err was returned by the database because an
+// object being created was created at the same time by another process, as
+// when `CREATE TABLE IF NOT EXISTS` races with itself.
+func isDuplicate(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "already exists") || strings.Contains(message, "duplicate")
+}
+
+// retryDuplicate calls fn until it succeeds, returns an error other than one
+// reported by isDuplicate, or has been called attempts times, waiting longer
+// between each attempt. The last error returned by fn is returned.
+func retryDuplicate(attempts int, backoff time.Duration, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff * time.Duration(attempt))
+		}
+
+		if err = fn(); err == nil || !isDuplicate(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// bootstrap creates the tables of migrate which do not already exist, as when
+// NewInstance is first called against a database. If the Dialect implements
+// AdvisoryLocks, the tables are created while holding an advisory lock, and
+// creation is retried should it still race with another process, so that
+// many processes may safely call NewInstance against a new database at once.
+// If the Dialect cannot create the tables, as with BigQuery, they are instead
+// checked to exist.
+func (instance *Instance) bootstrap() error {
+	if dialect, ok := instance.dialect.(*dialect); ok && dialect.noCreate {
+		instance.meta = instance.newMetaStore()
+		return instance.checkTables()
+	}
+
+	return retryDuplicate(bootstrapAttempts, bootstrapBackoff, instance.createTables)
+}
+
+// stateTables returns the names of the tables in which migrate records its
+// state, as created by createTables.
+func (instance *Instance) stateTables() []string {
+	return []string{instance.metadataTable(), instance.table("migrate_journal"), instance.table("migrate_lock"),
+		instance.table("migrate_history"), instance.table("migrate_pending"), instance.table("migrate_parts")}
+}
+
+// checkTables returns an *ErrFatal naming the first of the tables of migrate
+// which cannot be read, as when it has not been created.
+func (instance *Instance) checkTables() error {
+	for _, table := range instance.stateTables() {
+		rows, err := instance.db.Query(`SELECT * FROM ` + table + ` LIMIT 0;`)
+		if err != nil {
+			return NewFatalf("NewInstance: table '%s' must be created beforehand, as migrate cannot create its "+
+				"tables in a %s database:\n%s", table, instance.dialect.Name(), err)
+		}
+		rows.Close()
+	}
+
+	return nil
+}
+
+// bootstrapLock takes the advisory lock of the Dialect, if it has one,
+// returning the execer of the connection which holds it and a function which
+// releases it. The lock is taken on a best-effort basis: should it fail, as
+// when the database lacks the function, or should the database report that it
+// was not granted, as when MySQL's GET_LOCK times out and returns 0, the
+// database itself is returned, and any race is left to be retried by bootstrap.
+func (instance *Instance) bootstrapLock() (execer, func()) {
+	locks, ok := instance.dialect.(AdvisoryLocks)
+	if !ok || locks.AdvisoryLock("") == "" {
+		return instance.db, func() {}
+	}
+
+	ctx := context.Background()
+	conn, err := instance.db.Conn(ctx)
+	if err != nil {
+		instance.log(LevelWarn, "bootstrap lock not taken", Field{"error", err})
+		return instance.db, func() {}
+	}
+
+	name := instance.table("migrate_bootstrap")
+	var granted sql.NullString
+	err = conn.QueryRowContext(ctx, locks.AdvisoryLock(name)).Scan(&granted)
+	if err == nil && (!granted.Valid || granted.String == "0") {
+		err = errors.New("migrate: advisory lock was not granted")
+	}
+	if err != nil && err != sql.ErrNoRows {
+		conn.Close()
+		instance.log(LevelWarn, "bootstrap lock not taken", Field{"error", err})
+		return instance.db, func() {}
+	}
+
+	return connExecer{conn}, func() {
+		conn.ExecContext(ctx, locks.AdvisoryUnlock(name))
+		conn.Close()
+	}
+}
+
+// createTables creates the tables of migrate which do not already exist
+// while holding the advisory lock of the Dialect, if it has one.
+func (instance *Instance) createTables() error {
+	exec, release := instance.bootstrapLock()
+	defer release()
+
+	instance.meta = instance.newMetaStore()
+	if err := instance.createMetadata(exec); err != nil {
+		return NewFatalf("NewInstance: got error while creating metadata table:\n%s", err)
+	}
+
+	if err := createJournal(exec, instance.table("migrate_journal")); err != nil {
+		return NewFatalf("NewInstance: got error while creating journal table:\n%s", err)
+	}
+
+	if err := createLock(exec, instance.table("migrate_lock")); err != nil {
+		return NewFatalf("NewInstance: got error while creating lock table:\n%s", err)
+	}
+
+	if err := createHistory(exec, instance.table("migrate_history")); err != nil {
+		return NewFatalf("NewInstance: got error while creating history table:\n%s", err)
+	}
+
+	if err := createPending(exec, instance.table("migrate_pending")); err != nil {
+		return NewFatalf("NewInstance: got error while creating pending table:\n%s", err)
+	}
+
+	if err := createPartStates(exec, instance.table("migrate_parts")); err != nil {
+		return NewFatalf("NewInstance: got error while creating part state table:\n%s", err)
+	}
+
+	return nil
+}
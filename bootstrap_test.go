@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// lockingDialect is a Dialect which records the advisory locks taken and
+// released in the locks table rather than taking them.
+type lockingDialect struct {
+	Dialect
+}
+
+// AdvisoryLock implements the AdvisoryLocks interface for lockingDialect.
+func (lockingDialect) AdvisoryLock(name string) string {
+	return "INSERT INTO locks (Event) VALUES ('lock " + name + "');"
+}
+
+// AdvisoryUnlock implements the AdvisoryLocks interface for lockingDialect.
+func (lockingDialect) AdvisoryUnlock(name string) string {
+	return "INSERT INTO locks (Event) VALUES ('unlock " + name + "');"
+}
+
+// refusingDialect is a lockingDialect whose advisory lock is never granted, as
+// when MySQL's GET_LOCK times out and returns 0.
+type refusingDialect struct {
+	lockingDialect
+}
+
+// AdvisoryLock implements the AdvisoryLocks interface for refusingDialect.
+func (refusingDialect) AdvisoryLock(name string) string {
+	return "SELECT 0;"
+}
+
+// TestBootstrap ensures that the tables of migrate are created while holding
+// the advisory lock of the Dialect, and that creation which races with
+// another process is retried.
+func TestBootstrap(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if _, err := db.Exec(`CREATE TABLE locks(Event TEXT);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := NewInstance(db, "testing/meta", WithDialect(lockingDialect{SQLite})); err != nil {
+				t.Fatal("NewInstance: got error:\n", err)
+			}
+		}
+
+		var events []string
+		if err := query(db, `SELECT Event FROM locks ORDER BY rowid;`, nil, func(rows *sql.Rows) error {
+			var event string
+			err := rows.Scan(&event)
+			events = append(events, event)
+			return err
+		}); err != nil {
+			t.Fatal("query: got error:\n", err)
+		}
+
+		expected := "lock migrate_bootstrap,unlock migrate_bootstrap,lock migrate_bootstrap,unlock migrate_bootstrap"
+		if strings.Join(events, ",") != expected {
+			t.Errorf("NewInstance: got lock events %v expected the lock to be taken and released twice", events)
+		}
+		if !tableExists(db, "migrate_history") || !tableExists(db, "migrate_pending") {
+			t.Error("NewInstance: expected the tables of migrate to be created")
+		}
+
+		// A lock which is not granted is neither held nor released
+		logger := &recordingLogger{}
+		if _, err := db.Exec(`DELETE FROM locks;`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		} else if _, err := NewInstance(db, "testing/meta", WithDialect(refusingDialect{lockingDialect{SQLite}}),
+			WithLogger(logger)); err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM locks;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if count != 0 || len(logger.events) == 0 || logger.events[0] != "warn bootstrap lock not taken" {
+			t.Errorf("NewInstance: got %d lock events and log %v expected the refused lock to be reported", count,
+				logger.events)
+		}
+
+		// A database without the lock is bootstrapped regardless
+		if _, err := NewInstance(db, "testing/meta", WithDialect(MySQL)); err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+	})
+
+	if lock := Postgres.(AdvisoryLocks).AdvisoryLock("migrate_bootstrap"); lock !=
+		"SELECT pg_advisory_lock(hashtext('migrate_bootstrap'));" {
+		t.Errorf("Postgres.AdvisoryLock: got '%s'", lock)
+	}
+	if lock := SQLite.(AdvisoryLocks).AdvisoryLock("migrate_bootstrap"); lock != "" {
+		t.Errorf("SQLite.AdvisoryLock: got '%s' expected none", lock)
+	}
+
+	calls := 0
+	err := retryDuplicate(3, 0, func() error {
+		calls++
+		if calls == 1 {
+			return errors.New(`pq: relation "migrate_history" already exists`)
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("retryDuplicate: got '%v' after %d call(s) expected success after 2", err, calls)
+	}
+
+	calls = 0
+	err = retryDuplicate(3, 0, func() error {
+		calls++
+		return errors.New("Error 1060: Duplicate column name 'Actor'")
+	})
+	if err == nil || calls != 3 {
+		t.Errorf("retryDuplicate: got '%v' after %d call(s) expected an error after 3", err, calls)
+	}
+
+	calls = 0
+	err = retryDuplicate(3, 0, func() error {
+		calls++
+		return errors.New("permission denied for schema public")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("retryDuplicate: got '%v' after %d call(s) expected an error after 1", err, calls)
+	}
+}
@@ -0,0 +1,89 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// GotoWithBudget migrates toward version one whole version at a time,
+// committing each, for as long as the budget provided allows, so that a
+// database far behind may catch up over several deploy windows rather than in
+// one long run. It returns the version reached and the versions which remain
+// to be applied, or reverted if migrating down, in the order in which they
+// would be, which is empty once version is reached.
+//
+// The budget is measured by the Clock of the Instance, as provided with
+// WithClock. A version is not begun if the time remaining is less than that
+// taken by the version before it. Should a version nonetheless overrun the budget, it is
+// interrupted and rolled back as with GotoContext, and GotoWithBudget returns
+// without error as though the version had not been begun, unless the rollback
+// left the database dirty. Any other failure is returned along with the
+// version reached before it.
+//
+// The policies configured on the Instance are checked once against the whole
+// migration from the current version to version, before any is applied, and
+// those named by overrides are disregarded as with Force.
+func (instance *Instance) GotoWithBudget(version int, budget time.Duration, overrides ...Override) (int, []int,
+	error) {
+	current, _, err := instance.readVersion(instance.db)
+	if err != nil {
+		return 0, nil, NewFatalf("Instance.GotoWithBudget: got error while reading version:\n%s", err)
+	} else if _, ok := instance.migrations[version]; !ok && version != 0 {
+		return current, nil, &ErrNoVersion{Version: version, Target: version}
+	}
+
+	direction := "up"
+	if version < current {
+		direction = "down"
+	}
+	if current != version {
+		if err := instance.checkPolicies(current, version, direction, overrides); err != nil {
+			return current, remaining(current, version), err
+		}
+	}
+
+	ctx, cancel := instance.withTimeout(context.Background(), budget)
+	defer cancel()
+
+	start := instance.clock.Now()
+	var last time.Duration
+	for current != version {
+		if left := budget - instance.since(start); left <= 0 || left < last {
+			break
+		}
+
+		next := current + 1
+		if version < current {
+			next = current - 1
+		}
+
+		began := instance.clock.Now()
+		if err := instance.run(ctx, next, false, "", overrides...); err != nil {
+			if ctx.Err() == context.DeadlineExceeded && !instance.Dirty() {
+				break
+			}
+
+			return current, remaining(current, version), err
+		}
+
+		last = instance.since(began)
+		current = next
+	}
+
+	return current, remaining(current, version), nil
+}
+
+// remaining returns the versions which remain to be applied in order to
+// migrate from current to target, or those which remain to be reverted if
+// target is below current.
+func remaining(current, target int) []int {
+	versions := make([]int, 0)
+	for version := current + 1; version <= target; version++ {
+		versions = append(versions, version)
+	}
+	for version := current; version > target; version-- {
+		versions = append(versions, version)
+	}
+
+	return versions
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// steppingClock is a Clock which advances by an hour every time it is read,
+// while its timers run in real time.
+type steppingClock struct {
+	systemClock
+	now time.Time
+}
+
+func (clock *steppingClock) Now() time.Time {
+	clock.now = clock.now.Add(time.Hour)
+	return clock.now
+}
+
+// budgetClock is a Clock whose timers fire only once expire is closed.
+type budgetClock struct {
+	systemClock
+	expire chan time.Time
+}
+
+func (clock budgetClock) After(time.Duration) <-chan time.Time {
+	return clock.expire
+}
+
+// budgetExecutor is an Executor which lets the budget of a run expire while a
+// statement is being executed, and returns once its context is done.
+type budgetExecutor struct {
+	expire chan time.Time
+}
+
+func (executor budgetExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result,
+	error) {
+	close(executor.expire)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("context was not done once the budget expired")
+	}
+}
+
+// TestGotoWithBudget ensures that GotoWithBudget applies whole versions only
+// while the budget allows, as measured by the Clock of the Instance, and
+// reports the versions which remain.
+func TestGotoWithBudget(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working", WithClock(&steppingClock{}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		// Every version appears to take hours, so only the first fits within the budget
+		reached, left, err := instance.GotoWithBudget(3, 90*time.Minute)
+		if err != nil {
+			t.Fatal("Instance.GotoWithBudget: got error:\n", err)
+		} else if reached != 1 || !reflect.DeepEqual(left, []int{2, 3}) {
+			t.Errorf("Instance.GotoWithBudget: got version %d with %v remaining expected 1 with [2 3]", reached, left)
+		}
+		if version := instance.Version(); version != 1 {
+			t.Errorf("Instance.Version: got %d expected 1", version)
+		}
+
+		instance.clock = systemClock{}
+		if reached, left, err = instance.GotoWithBudget(3, time.Minute); err != nil {
+			t.Fatal("Instance.GotoWithBudget: got error:\n", err)
+		} else if reached != 3 || len(left) != 0 {
+			t.Errorf("Instance.GotoWithBudget: got version %d with %v remaining expected 3 with none", reached, left)
+		}
+
+		if reached, left, err = instance.GotoWithBudget(0, 0); err != nil {
+			t.Fatal("Instance.GotoWithBudget: got error:\n", err)
+		} else if reached != 3 || !reflect.DeepEqual(left, []int{3, 2, 1}) {
+			t.Errorf("Instance.GotoWithBudget: got version %d with %v remaining expected 3 with [3 2 1]", reached,
+				left)
+		}
+
+		expectError(t, "Instance.GotoWithBudget", "unknown version", func() error {
+			_, _, err := instance.GotoWithBudget(7, time.Minute)
+			return err
+		}, "version '7'")
+	})
+
+	// A version which overruns the budget according to the Clock is interrupted and rolled back
+	RunWithDB(func(db *sql.DB) {
+		expire := make(chan time.Time)
+		instance, err := NewInstance(db, "testing/working", WithClock(budgetClock{expire: expire}),
+			WithExecutor(budgetExecutor{expire: expire}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if reached, left, err := instance.GotoWithBudget(3, time.Hour); err != nil {
+			t.Fatal("Instance.GotoWithBudget: got error:\n", err)
+		} else if reached != 0 || !reflect.DeepEqual(left, []int{1, 2, 3}) {
+			t.Errorf("Instance.GotoWithBudget: got version %d with %v remaining expected 0 with [1 2 3]", reached,
+				left)
+		}
+		if version := instance.Version(); version != 0 || instance.Dirty() {
+			t.Errorf("Instance.Version: got %d expected 0 and clean once the budget expired", version)
+		}
+	})
+}
+
+// TestGotoWithBudgetPolicies ensures that GotoWithBudget checks WithMaxJump
+// against the whole migration rather than each version it applies, unless
+// the policy is overridden.
+func TestGotoWithBudgetPolicies(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working", WithMaxJump(1))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		expectError(t, "Instance.GotoWithBudget", "WithMaxJump", func() error {
+			_, _, err := instance.GotoWithBudget(3, time.Hour)
+			return err
+		}, "max-jump", "would apply 3 versions")
+		if version := instance.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d expected 0 once refused", version)
+		}
+
+		if reached, left, err := instance.GotoWithBudget(3, time.Hour, OverrideMaxJump); err != nil {
+			t.Fatal("Instance.GotoWithBudget: got error with OverrideMaxJump:\n", err)
+		} else if reached != 3 || len(left) != 0 {
+			t.Errorf("Instance.GotoWithBudget: got version %d with %v remaining expected 3 with none", reached, left)
+		}
+	})
+}
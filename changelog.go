@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Environment names a database, such as that of staging or production, whose
+// history is included in a changelog.
+type Environment struct {
+	Name string
+	DB   *sql.DB
+}
+
+// Changelog writes a Markdown changelog of every available migration to w,
+// newest first, for use in release notes and compliance documentation. Each
+// version lists its parts along with the description and other metadata
+// provided with `-- @migrate/meta`, followed by the time at which the version
+// reached each of the environments provided, read from their history. If no
+// environments are provided, the database of the Instance is used alone.
+func (instance *Instance) Changelog(w io.Writer, environments ...Environment) error {
+	if len(environments) == 0 {
+		environments = []Environment{{Name: "database", DB: instance.db}}
+	}
+
+	reached := make([]map[int]time.Time, len(environments))
+	for i, environment := range environments {
+		history, err := readHistory(environment.DB, instance.table("migrate_history"), instance.encrypter)
+		if err != nil {
+			return NewFatalf("Instance.Changelog: got error while reading history of environment '%s':\n%s",
+				environment.Name, err)
+		}
+		reached[i] = reachedAt(history)
+	}
+
+	fmt.Fprintln(w, "# Changelog")
+
+	versions := instance.List()
+	for i := len(versions) - 1; i >= 0; i-- {
+		migration := instance.migrations[versions[i]]
+
+		fmt.Fprintf(w, "\n## Version %d\n\n", migration.Version)
+		for _, part := range migration.Parts {
+			if description := part.Meta["description"]; description != "" {
+				fmt.Fprintf(w, "- **%s**: %s\n", part.Name, description)
+			} else {
+				fmt.Fprintf(w, "- **%s**\n", part.Name)
+			}
+
+			keys := make([]string, 0, len(part.Meta))
+			for key := range part.Meta {
+				if key != "description" {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				fmt.Fprintf(w, "  - %s: %s\n", key, part.Meta[key])
+			}
+		}
+
+		fmt.Fprint(w, "\n| Environment | Applied |\n| --- | --- |\n")
+		for j, environment := range environments {
+			applied := "not applied"
+			if at, ok := reached[j][migration.Version]; ok {
+				applied = at.UTC().Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "| %s | %s |\n", environment.Name, applied)
+		}
+	}
+
+	return nil
+}
+
+// reachedAt returns the time at which each version currently applied was
+// most recently applied, according to the history provided.
+func reachedAt(history []HistoryEntry) map[int]time.Time {
+	reached := make(map[int]time.Time)
+	for _, entry := range history {
+		if entry.Direction == "down" {
+			delete(reached, entry.Version)
+		} else if _, ok := reached[entry.Version]; !ok {
+			reached[entry.Version] = entry.AppliedAt
+		}
+	}
+
+	return reached
+}
@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChangelog(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	staging, err := sql.Open("sqlite3", filepath.Join(directory, "staging.db"))
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer staging.Close()
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		stagingInstance, err := NewInstance(staging, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		stagingInstance.Output = &strings.Builder{}
+		if err := stagingInstance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		var builder strings.Builder
+		if err := instance.Changelog(&builder, Environment{"production", db},
+			Environment{"staging", staging}); err != nil {
+			t.Fatal("Instance.Changelog: got error:\n", err)
+		}
+		changelog := builder.String()
+
+		for _, expected := range []string{
+			"## Version 1\n\n- **billing.sql**: add billing tables\n  - author: jane\n  - ticket: PROJ-123\n",
+			"## Version 2\n\n- **invoices.sql**\n",
+			"| production | not applied |",
+		} {
+			if !strings.Contains(changelog, expected) {
+				t.Errorf("Instance.Changelog: expected '%s' in changelog, got:\n%s", expected, changelog)
+			}
+		}
+
+		if strings.Index(changelog, "## Version 2") > strings.Index(changelog, "## Version 1") {
+			t.Errorf("Instance.Changelog: expected newest version first, got:\n%s", changelog)
+		}
+
+		if strings.Count(changelog, "| staging | 20") != 2 || strings.Count(changelog, "| production | 20") != 1 {
+			t.Errorf("Instance.Changelog: got unexpected application times in changelog:\n%s", changelog)
+		}
+	})
+}
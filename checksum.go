@@ -0,0 +1,77 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"strings"
+)
+
+// ChecksumAlgorithm is a hash function with which the checksums of parts and
+// migrations are computed. The Name of the algorithm is recorded alongside
+// every digest written to the LockFile, so that a LockFile written with one
+// algorithm is still verified after switching to another.
+type ChecksumAlgorithm struct {
+	Name string // Name recorded alongside each digest, such as "sha256"
+	New  func() hash.Hash
+}
+
+// The checksum algorithms known to every Instance. SHA256 is the default.
+var (
+	SHA256 = ChecksumAlgorithm{Name: "sha256", New: sha256.New}
+	SHA512 = ChecksumAlgorithm{Name: "sha512", New: sha512.New}
+)
+
+// checksumAlgorithms holds the built-in algorithms by name, with which a
+// digest recorded with an algorithm other than that of the Instance is
+// verified.
+var checksumAlgorithms = map[string]ChecksumAlgorithm{
+	SHA256.Name: SHA256,
+	SHA512.Name: SHA512,
+}
+
+// orDefault returns the algorithm, or SHA256 if it is the zero value.
+func (algorithm ChecksumAlgorithm) orDefault() ChecksumAlgorithm {
+	if algorithm.New == nil {
+		return SHA256
+	}
+
+	return algorithm
+}
+
+// digest returns the hex-encoded digest of data.
+func (algorithm ChecksumAlgorithm) digest(data []byte) string {
+	hash := algorithm.orDefault().New()
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// checksums returns the hex-encoded digests of the canonical form of the
+// contents of a part file, as returned by normalizeSQL, and of the raw
+// contents.
+func (algorithm ChecksumAlgorithm) checksums(contents []byte) (string, string) {
+	return algorithm.digest([]byte(normalizeSQL(string(contents)))), algorithm.digest(contents)
+}
+
+// splitDigest separates a digest recorded in the LockFile into the name of its
+// algorithm and the hex-encoded digest itself. Digests recorded before the
+// algorithm was recorded alongside them are SHA256.
+func splitDigest(recorded string) (string, string) {
+	if index := strings.IndexByte(recorded, ':'); index >= 0 {
+		return recorded[:index], recorded[index+1:]
+	}
+
+	return SHA256.Name, recorded
+}
+
+// lookupAlgorithm returns the algorithm named, which is either the algorithm
+// of the Instance or one of the built-in algorithms.
+func (instance *Instance) lookupAlgorithm(name string) (ChecksumAlgorithm, bool) {
+	if algorithm := instance.loader.algorithm.orDefault(); algorithm.Name == name {
+		return algorithm, true
+	}
+
+	algorithm, ok := checksumAlgorithms[name]
+	return algorithm, ok
+}
@@ -0,0 +1,139 @@
+package migrate
+
+import (
+	"context"
+	"strings"
+)
+
+// droppable is an object listed by the objects query of a dialect.
+type droppable struct {
+	kind string // Kind of object, such as TABLE or VIEW
+	name string // Name of the object, quoted as required by the dialect
+}
+
+// managed reports whether the object is one of the tables in which migrate
+// records its own state, which Clean empties rather than drops.
+func (object droppable) managed() bool {
+	name := strings.Trim(object.name, "\"`")
+	return object.kind == "TABLE" && (name == "metadata" || strings.HasPrefix(name, "migrate_"))
+}
+
+// Clean drops every table, view, sequence, and routine within the schema in
+// which migrations are applied, as found by introspection, and resets the
+// recorded version to 0, such that a development or CI database may be reset
+// without migrating down through every version. The tables in which migrate
+// records its own state are emptied rather than dropped, though the history
+// is kept. Objects are dropped in an order which respects their dependencies
+// where the dialect allows, and any object which cannot yet be dropped is
+// retried once the others have been. Clean is destructive and so returns an
+// error unless WithAllowClean is in use.
+func (instance *Instance) Clean(ctx context.Context) (err error) {
+	if instance.closed {
+		return NewFatalf("Instance.Clean: instance has been closed")
+	} else if instance.readOnly {
+		return NewFatalf("Instance.Clean: instance is read-only")
+	} else if !instance.allowClean {
+		return NewFatalf("Instance.Clean: cleaning is disabled, use WithAllowClean to enable it")
+	}
+
+	dialect, ok := instance.dialect.(*dialect)
+	if !ok || dialect.objects == "" {
+		return NewFatalf("Instance.Clean: dialect '%s' does not support introspection", instance.dialect.Name())
+	}
+
+	if err := instance.lock(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if unlockErr := instance.unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	exec, release, err := instance.pin(ctx)
+	if err != nil {
+		return NewFatalf("Instance.Clean: got error while setting search path:\n%s", err)
+	}
+	defer release()
+
+	objects, err := listObjects(ctx, exec, dialect.objects)
+	if err != nil {
+		return NewFatalf("Instance.Clean: got error while listing objects:\n%s", err)
+	}
+
+	var remaining []droppable
+	for _, object := range objects {
+		if !object.managed() {
+			remaining = append(remaining, object)
+		}
+	}
+
+	dropped := 0
+	for len(remaining) > 0 {
+		var failed []droppable
+		var lastErr error
+		for _, object := range remaining {
+			statement := "DROP " + object.kind + " IF EXISTS " + object.name
+			if dialect.cascade {
+				statement += " CASCADE"
+			}
+
+			if _, err := exec.ExecContext(ctx, statement+";"); err != nil {
+				failed, lastErr = append(failed, object), err
+			} else {
+				dropped++
+			}
+		}
+
+		// Give up once a pass fails to drop anything more
+		if len(failed) == len(remaining) {
+			return NewFatalf("Instance.Clean: got error while dropping %s %s:\n%s",
+				strings.ToLower(failed[0].kind), failed[0].name, lastErr)
+		}
+		remaining = failed
+	}
+
+	for _, table := range []string{"migrate_journal", "migrate_pending", "migrate_parts"} {
+		if _, err := exec.ExecContext(ctx, `DELETE FROM `+instance.table(table)+`;`); err != nil {
+			return NewFatalf("Instance.Clean: got error while emptying %s:\n%s", table, err)
+		}
+	}
+
+	// Backfills run again once the tables they visit are recreated
+	if err := instance.backfillRunner().ResetAll(ctx); err != nil {
+		return NewFatalf("Instance.Clean: got error while emptying migrate_backfill:\n%s", err)
+	}
+
+	for _, key := range append(instance.versionKeys(), instance.metaKey("migrateTarget")) {
+		if instance.meta.Exists(key) {
+			if err := instance.meta.Delete(key); err != nil {
+				return NewFatalf("Instance.Clean: got error while removing '%s':\n%s", key, err)
+			}
+		}
+	}
+
+	instance.say(MessageCleaned, MessageData{Statements: dropped})
+	instance.log(LevelInfo, "database cleaned", Field{"dropped", dropped})
+	return nil
+}
+
+// listObjects returns every object listed by the objects query of a dialect.
+func listObjects(ctx context.Context, exec execer, query string) ([]droppable, error) {
+	rows, err := exec.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := make([]droppable, 0)
+	for rows.Next() {
+		var object droppable
+		if err := rows.Scan(&object.kind, &object.name); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	return objects, rows.Err()
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestClean ensures that Clean is disabled unless WithAllowClean is in use, and
+// that it drops every object while keeping the state of migrate, such that
+// the database may be migrated again from version 0.
+func TestClean(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		guarded, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		expectError(t, "Instance.Clean", "without WithAllowClean", func() error {
+			return guarded.Clean(context.Background())
+		}, "use WithAllowClean")
+
+		instance, err := NewInstance(db, "testing/meta", WithAllowClean())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		for _, statement := range []string{
+			`CREATE VIEW billed AS SELECT billing.ID FROM billing JOIN invoices ON invoices.ID = billing.ID;`,
+			`CREATE TRIGGER billing_insert AFTER INSERT ON billing BEGIN INSERT INTO invoices VALUES (NEW.ID); END;`,
+		} {
+			if _, err := db.Exec(statement); err != nil {
+				t.Fatal("sql.DB.Exec: got error:\n", err)
+			}
+		}
+
+		if err := instance.Clean(context.Background()); err != nil {
+			t.Fatal("Instance.Clean: got error:\n", err)
+		}
+		for _, table := range []string{"billing", "invoices", "billed"} {
+			if tableExists(db, table) {
+				t.Errorf("Instance.Clean: expected '%s' to be dropped", table)
+			}
+		}
+		if !tableExists(db, "metadata") || !tableExists(db, "migrate_lock") {
+			t.Error("Instance.Clean: expected tables of migrate to be kept")
+		}
+		if version := instance.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d expected 0 once cleaned", version)
+		}
+		if !strings.Contains(output.String(), "Dropped 4 object(s)") {
+			t.Errorf("Instance.Clean: got output '%s' expected 4 objects to be dropped", output.String())
+		}
+		if history, err := instance.History(); err != nil || len(history) != 2 {
+			t.Errorf("Instance.History: got %d entries and error '%v' expected 2 to be kept", len(history), err)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Error("Instance.Latest: got error once cleaned:\n", err)
+		}
+	})
+}
+
+// TestCleanBackfill ensures that Clean discards the progress of backfills,
+// such that each runs again once the database is migrated anew.
+func TestCleanBackfill(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/backfill", WithAllowClean())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		} else if err := instance.RunBackfill("lowercase"); err != nil {
+			t.Fatal("Instance.RunBackfill: got error:\n", err)
+		} else if err := instance.Clean(context.Background()); err != nil {
+			t.Fatal("Instance.Clean: got error:\n", err)
+		}
+
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM migrate_backfill;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if count != 0 {
+			t.Errorf("Instance.Clean: got %d backfills with progress expected none", count)
+		}
+
+		output.Reset()
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error once cleaned:\n", err)
+		} else if err := instance.RunBackfill("lowercase"); err != nil {
+			t.Fatal("Instance.RunBackfill: got error once cleaned:\n", err)
+		} else if !strings.Contains(output.String(), "committed batch 2, 3 row(s) so far") {
+			t.Errorf("Instance.RunBackfill: got output '%s' expected the backfill to run again", output.String())
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Clock provides the current time to an Instance, from which the durations
+// written to Output, the timestamps recorded in the History, and the age of
+// the migration lock are derived, along with the timers by which it waits:
+// between heartbeats, retries of deferred parts and of DDL which timed out
+// waiting for a lock, polls of WaitForVersion, and batches of a backfill. A
+// Clock provided with WithClock allows tests of these to be deterministic.
+type Clock interface {
+	Now() time.Time
+	// After returns a channel which receives the time once d has elapsed, as
+	// does time.After.
+	After(d time.Duration) <-chan time.Time
+	// NewTicker returns a Ticker which delivers the time every d, as does
+	// time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the time of a Clock at intervals, as does a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// systemClock is the Clock used unless another is provided with WithClock,
+// reading the time from the system.
+type systemClock struct{}
+
+// Now returns the current time of the system.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// After implements the Clock interface for systemClock with time.After.
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// NewTicker implements the Clock interface for systemClock with a time.Ticker.
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+// systemTicker is the Ticker of systemClock.
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+// C returns the channel on which the ticks are delivered.
+func (ticker systemTicker) C() <-chan time.Time {
+	return ticker.ticker.C
+}
+
+// Stop turns off the ticker.
+func (ticker systemTicker) Stop() {
+	ticker.ticker.Stop()
+}
+
+// since returns the time elapsed since t according to the Clock of the
+// Instance.
+func (instance *Instance) since(t time.Time) time.Duration {
+	return instance.clock.Now().Sub(t)
+}
+
+// withTimeout returns a copy of parent which is done once d has elapsed, as
+// read from the Clock of the Instance rather than the system, after which its
+// Err returns context.DeadlineExceeded as with context.WithTimeout.
+func (instance *Instance) withTimeout(parent context.Context, d time.Duration) (context.Context,
+	context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	clocked := &clockContext{Context: ctx, deadline: instance.clock.Now().Add(d)}
+	expired := instance.clock.After(d)
+	go func() {
+		select {
+		case <-expired:
+			atomic.StoreInt32(&clocked.expired, 1)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return clocked, cancel
+}
+
+// clockContext is a context.Context whose deadline is kept by a Clock.
+type clockContext struct {
+	context.Context
+	deadline time.Time
+	expired  int32 // Set to 1 once the deadline has passed according to the Clock
+}
+
+// Deadline returns the time at which the context expires according to the
+// Clock.
+func (ctx *clockContext) Deadline() (time.Time, bool) {
+	return ctx.deadline, true
+}
+
+// Err returns context.DeadlineExceeded once the deadline has passed according
+// to the Clock, or otherwise the error of the underlying context.
+func (ctx *clockContext) Err() error {
+	err := ctx.Context.Err()
+	if err != nil && atomic.LoadInt32(&ctx.expired) == 1 {
+		return context.DeadlineExceeded
+	}
+
+	return err
+}
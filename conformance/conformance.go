@@ -0,0 +1,305 @@
+// Package conformance asserts that a migration tree produces the same logical
+// schema on every database which migrate supports, so that changes to how
+// statements are executed for one dialect cannot silently diverge from the
+// others. Check applies the tree to each Target, reverts it, applies it once
+// more, and compares the schemas read with Instance.Introspect after
+// normalizing the spelling which each database gives to types, defaults, and
+// names.
+//
+// The suite of the package is gated behind the `conformance` build tag, as it
+// requires the lib/pq and go-sql-driver/mysql drivers alongside running
+// databases, named by the data source names in the environment variables
+// MIGRATE_CONFORMANCE_POSTGRES and MIGRATE_CONFORMANCE_MYSQL:
+//
+//	MIGRATE_CONFORMANCE_POSTGRES="postgres://localhost/migrate?sslmode=disable" \
+//	MIGRATE_CONFORMANCE_MYSQL="root@tcp(localhost)/migrate" \
+//	go test -tags conformance ./conformance
+//
+// SQLite is always checked, and a database without a data source name is
+// skipped.
+package conformance
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/octacian/migrate"
+)
+
+// Target is a database against which a migration tree is checked.
+type Target struct {
+	Name   string // Name identifying the database in failures, such as "postgres"
+	Driver string // Name of the database driver
+	DSN    string // Data source name, or empty for a temporary SQLite database
+}
+
+// Targets returns a Target for SQLite, along with Postgres and MySQL if the
+// environment variables MIGRATE_CONFORMANCE_POSTGRES and
+// MIGRATE_CONFORMANCE_MYSQL hold their data source names. Migrate records its
+// runs in PostgreSQL through a metadata table and queries written for it, but
+// the suite has so far only been run against SQLite; the normalization of
+// Postgres and MySQL schemas may need adjusting when first run against them.
+func Targets() []Target {
+	targets := []Target{{Name: "sqlite", Driver: "sqlite3"}}
+	if dsn := os.Getenv("MIGRATE_CONFORMANCE_POSTGRES"); dsn != "" {
+		targets = append(targets, Target{Name: "postgres", Driver: "postgres", DSN: dsn})
+	}
+	if dsn := os.Getenv("MIGRATE_CONFORMANCE_MYSQL"); dsn != "" {
+		targets = append(targets, Target{Name: "mysql", Driver: "mysql", DSN: dsn})
+	}
+
+	return targets
+}
+
+// Check applies every migration in root to each target, reverts them all,
+// and applies them once more, failing the test if any run fails, if reverting
+// leaves any table behind, or if the schema differs between the first and
+// second application. The schema of every target is then compared to that of
+// the first, failing the test with a unified diff of the lines which differ,
+// colored unless Color is false. The database of each target should be empty,
+// and is left at version 0.
+func Check(t testing.TB, targets []Target, root string, options ...migrate.Option) {
+	t.Helper()
+
+	var expected []string
+	for _, target := range targets {
+		lines, err := apply(target, root, options)
+		if err != nil {
+			t.Errorf("conformance.Check: %s: %s", target.Name, err)
+			continue
+		}
+
+		if expected == nil {
+			expected = lines
+		} else if diff := unifiedDiff(expected, lines, Color); diff != "" {
+			t.Errorf("conformance.Check: schema of %s differs from that of %s:\n%s", target.Name,
+				targets[0].Name, diff)
+		}
+	}
+}
+
+// apply migrates the database of target up, down, and up once more, returning
+// the normalized schema read after the first migration up.
+func apply(target Target, root string, options []migrate.Option) ([]string, error) {
+	dsn := target.DSN
+	if dsn == "" {
+		directory, err := ioutil.TempDir("", "conformance")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(directory)
+
+		dsn = filepath.Join(directory, "conformance.sqlite")
+	}
+
+	db, err := sql.Open(target.Driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	instance, err := migrate.NewInstance(db, root, options...)
+	if err != nil {
+		return nil, err
+	}
+	instance.Output = ioutil.Discard
+
+	introspect := func(step string, run func() error) ([]string, error) {
+		if err := run(); err != nil {
+			return nil, fmt.Errorf("got error while migrating %s:\n%s", step, err)
+		}
+
+		schema, err := instance.Introspect()
+		if err != nil {
+			return nil, err
+		}
+
+		return Describe(Normalize(schema)), nil
+	}
+
+	first, err := introspect("up", instance.Latest)
+	if err != nil {
+		return nil, err
+	}
+
+	down, err := introspect("down", func() error { return instance.Goto(0) })
+	if err != nil {
+		return nil, err
+	} else if len(down) > 0 {
+		return nil, fmt.Errorf("expected no tables after migrating down, got:\n%s", strings.Join(down, "\n"))
+	}
+
+	second, err := introspect("up again", instance.Latest)
+	if err != nil {
+		return nil, err
+	} else if diff := unifiedDiff(first, second, Color); diff != "" {
+		return nil, fmt.Errorf("schema differs after migrating down and up again:\n%s", diff)
+	}
+
+	if err := instance.Goto(0); err != nil {
+		return nil, fmt.Errorf("got error while migrating down:\n%s", err)
+	}
+
+	return first, nil
+}
+
+// typeAliases maps the spelling which each database gives to a type to the
+// logical type it represents, after removing the length or precision.
+var typeAliases = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"integer":                     "integer",
+	"bigint":                      "bigint",
+	"int8":                        "bigint",
+	"smallint":                    "smallint",
+	"int2":                        "smallint",
+	"bool":                        "boolean",
+	"boolean":                     "boolean",
+	"character varying":           "varchar",
+	"varchar":                     "varchar",
+	"character":                   "char",
+	"char":                        "char",
+	"text":                        "text",
+	"decimal":                     "numeric",
+	"numeric":                     "numeric",
+	"real":                        "real",
+	"float4":                      "real",
+	"double precision":            "double",
+	"double":                      "double",
+	"float8":                      "double",
+	"timestamp":                   "timestamp",
+	"timestamp without time zone": "timestamp",
+	"datetime":                    "timestamp",
+	"date":                        "date",
+}
+
+// regexType splits a type into its name and any length or precision.
+var regexType = regexp.MustCompile(`^([a-z ]+?)\s*(\([0-9, ]+\))?(\s+unsigned)?$`)
+
+// Normalize returns a copy of schema in which names are lower case, types
+// and default values are spelled alike whichever database they were read
+// from, and the indexes backing primary keys, which each database names and
+// reports differently, are omitted. Comments, which SQLite does not support,
+// are omitted as well.
+func Normalize(schema *migrate.Schema) *migrate.Schema {
+	normalized := &migrate.Schema{}
+	for _, table := range schema.Tables {
+		copied := migrate.Table{Name: strings.ToLower(table.Name)}
+		for _, column := range table.Columns {
+			logical := normalizeType(column.Type)
+			copied.Columns = append(copied.Columns, migrate.Column{Name: strings.ToLower(column.Name),
+				Type: logical, Nullable: column.Nullable, Default: normalizeDefault(column.Default, logical)})
+		}
+
+		for _, index := range table.Indexes {
+			name := strings.ToLower(index.Name)
+			if name == "primary" || strings.HasSuffix(name, "_pkey") ||
+				strings.HasPrefix(name, "sqlite_autoindex_") {
+				continue
+			}
+
+			copied.Indexes = append(copied.Indexes, migrate.Index{Name: name, Unique: index.Unique,
+				Columns: strings.ToLower(strings.Replace(index.Columns, " ", "", -1))})
+		}
+
+		normalized.Tables = append(normalized.Tables, copied)
+	}
+
+	return normalized
+}
+
+// normalizeType returns the logical type of a column, keeping any length or
+// precision other than the display width of an integer.
+func normalizeType(spelled string) string {
+	spelled = strings.ToLower(strings.TrimSpace(spelled))
+	if spelled == "tinyint(1)" {
+		return "boolean" // MySQL stores BOOLEAN as TINYINT(1)
+	}
+
+	matches := regexType.FindStringSubmatch(spelled)
+	if matches == nil {
+		return spelled
+	}
+
+	name, ok := typeAliases[matches[1]]
+	if !ok {
+		return spelled
+	}
+
+	switch name {
+	case "integer", "bigint", "smallint":
+		return name
+	}
+
+	return name + strings.Replace(matches[2], " ", "", -1)
+}
+
+// normalizeDefault returns the default value of a column of the logical type
+// provided, without casts, quotes, or redundant parentheses, and with numbers
+// and booleans spelled alike.
+func normalizeDefault(value, logical string) string {
+	value = strings.TrimSpace(value)
+	for strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		value = strings.TrimSpace(value[1 : len(value)-1])
+	}
+
+	// Postgres casts literals to the type of their column, as in 'text'::character varying
+	if cast := strings.LastIndex(value, "::"); cast >= 0 && !strings.Contains(value[cast:], "'") {
+		value = value[:cast]
+	}
+	if len(value) >= 2 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") {
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1)
+	}
+
+	lower := strings.ToLower(value)
+	switch {
+	case logical == "boolean" && (lower == "0" || lower == "false"):
+		return "false"
+	case logical == "boolean" && (lower == "1" || lower == "true"):
+		return "true"
+	case lower == "current_timestamp" || lower == "current_timestamp()" || lower == "now()":
+		return "current_timestamp"
+	}
+
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return strconv.FormatFloat(number, 'g', -1, 64)
+	}
+
+	return value
+}
+
+// Describe returns a line describing each table, column, and index of schema,
+// in a form which may be compared with that of another schema line by line.
+func Describe(schema *migrate.Schema) []string {
+	lines := make([]string, 0)
+	for _, table := range schema.Tables {
+		lines = append(lines, "table "+table.Name)
+		for _, column := range table.Columns {
+			line := fmt.Sprintf("  column %s %s", column.Name, column.Type)
+			if !column.Nullable {
+				line += " not null"
+			}
+			if column.Default != "" {
+				line += " default " + column.Default
+			}
+			lines = append(lines, line)
+		}
+
+		for _, index := range table.Indexes {
+			kind := "index"
+			if index.Unique {
+				kind = "unique index"
+			}
+			lines = append(lines, fmt.Sprintf("  %s %s (%s)", kind, index.Name, index.Columns))
+		}
+	}
+
+	return lines
+}
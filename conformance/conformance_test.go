@@ -0,0 +1,87 @@
+package conformance
+
+import (
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/octacian/migrate"
+)
+
+// TestNormalize ensures that the spellings which SQLite, Postgres, and MySQL
+// give to the same table are normalized alike.
+func TestNormalize(t *testing.T) {
+	schemas := map[string]*migrate.Schema{
+		"sqlite": {Tables: []migrate.Table{{Name: "accounts", Columns: []migrate.Column{
+			{Name: "ID", Type: "INTEGER"},
+			{Name: "Email", Type: "VARCHAR(255)", Default: "'none'"},
+			{Name: "Active", Type: "BOOLEAN", Default: "FALSE"},
+			{Name: "Balance", Type: "NUMERIC(10,2)", Default: "0"},
+			{Name: "CreatedAt", Type: "TIMESTAMP", Default: "CURRENT_TIMESTAMP"},
+		}, Indexes: []migrate.Index{{Name: "sqlite_autoindex_accounts_1", Unique: true, Columns: "ID"},
+			{Name: "accounts_email", Unique: true, Columns: "Email, Active"}}}}},
+		"postgres": {Tables: []migrate.Table{{Name: "accounts", Columns: []migrate.Column{
+			{Name: "id", Type: "integer"},
+			{Name: "email", Type: "character varying(255)", Default: "'none'::character varying"},
+			{Name: "active", Type: "boolean", Default: "false"},
+			{Name: "balance", Type: "numeric(10,2)", Default: "0"},
+			{Name: "createdat", Type: "timestamp without time zone", Default: "CURRENT_TIMESTAMP"},
+		}, Indexes: []migrate.Index{{Name: "accounts_pkey", Unique: true, Columns: "id"},
+			{Name: "accounts_email", Unique: true, Columns: "email, active"}}}}},
+		"mysql": {Tables: []migrate.Table{{Name: "accounts", Columns: []migrate.Column{
+			{Name: "ID", Type: "int(11)"},
+			{Name: "Email", Type: "varchar(255)", Default: "none"},
+			{Name: "Active", Type: "tinyint(1)", Default: "0"},
+			{Name: "Balance", Type: "decimal(10,2)", Default: "0.00"},
+			{Name: "CreatedAt", Type: "timestamp", Default: "CURRENT_TIMESTAMP"},
+		}, Indexes: []migrate.Index{{Name: "PRIMARY", Unique: true, Columns: "ID"},
+			{Name: "accounts_email", Unique: true, Columns: "Email, Active"}}}}},
+	}
+
+	expected := strings.Join(Describe(Normalize(schemas["sqlite"])), "\n")
+	if !strings.Contains(expected, "column email varchar(255) not null default none") ||
+		!strings.Contains(expected, "column active boolean not null default false") ||
+		strings.Contains(expected, "autoindex") {
+		t.Errorf("Normalize: got unexpected schema for sqlite:\n%s", expected)
+	}
+
+	for name, schema := range schemas {
+		if described := strings.Join(Describe(Normalize(schema)), "\n"); described != expected {
+			t.Errorf("Normalize: got schema for %s:\n%s\nexpected:\n%s", name, described, expected)
+		}
+	}
+}
+
+// TestCheck ensures that the reference tree conforms between two SQLite
+// databases.
+func TestCheck(t *testing.T) {
+	Check(t, []Target{{Name: "sqlite", Driver: "sqlite3"}, {Name: "sqlite again", Driver: "sqlite3"}},
+		"../testing/conformance")
+
+}
+
+// TestUnifiedDiff ensures that differing lines are reported as a unified diff
+// with context around each change, colored if requested.
+func TestUnifiedDiff(t *testing.T) {
+	if diff := unifiedDiff([]string{"table a"}, []string{"table a"}, true); diff != "" {
+		t.Errorf("unifiedDiff: got '%s' for the same lines expected none", diff)
+	}
+
+	diff := unifiedDiff([]string{"table a", "  column id integer"}, []string{"table a", "  column id bigint"}, false)
+	if diff != "@@ -1,2 +1,2 @@\n table a\n-  column id integer\n+  column id bigint\n" {
+		t.Errorf("unifiedDiff: got '%s'", diff)
+	}
+
+	// Changes far apart are written as separate hunks, with only the nearby lines as context
+	expected := []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"}
+	actual := []string{"one", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"}
+	diff = unifiedDiff(expected, actual, false)
+	if diff != "@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,3 @@\n 9\n 10\n 11\n-12\n" {
+		t.Errorf("unifiedDiff: got hunks:\n%s", diff)
+	}
+
+	diff = unifiedDiff([]string{"a"}, []string{"b"}, true)
+	if diff != "\033[36m@@ -1 +1 @@\033[0m\n\033[31m-a\033[0m\n\033[32m+b\033[0m\n" {
+		t.Errorf("unifiedDiff: got colored diff %q", diff)
+	}
+}
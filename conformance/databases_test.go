@@ -0,0 +1,22 @@
+//go:build conformance
+// +build conformance
+
+package conformance
+
+import (
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// TestConformance ensures that the reference tree produces the same logical
+// schema on every database named in the environment.
+func TestConformance(t *testing.T) {
+	targets := Targets()
+	if len(targets) < 2 {
+		t.Skip("set MIGRATE_CONFORMANCE_POSTGRES or MIGRATE_CONFORMANCE_MYSQL to compare databases")
+	}
+
+	Check(t, targets, "../testing/conformance")
+}
@@ -0,0 +1,177 @@
+package conformance
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Color reports whether the differences reported by Check are colored with
+// terminal escape codes, removed lines in red and added lines in green, as
+// most CI logs display. It is true unless the NO_COLOR environment variable
+// is set.
+var Color = os.Getenv("NO_COLOR") == ""
+
+// diffContext is the number of unchanged lines written around each change.
+const diffContext = 3
+
+// Terminal escape codes with which differences are colored.
+const (
+	colorHunk    = "\033[36m"
+	colorRemoved = "\033[31m"
+	colorAdded   = "\033[32m"
+	colorReset   = "\033[0m"
+)
+
+// edit is a single line of a difference, kept if op is ' ', removed if '-',
+// or added if '+'.
+type edit struct {
+	op   byte
+	line string
+}
+
+// edits returns the shortest sequence of edits which turns expected into
+// actual, found from the longest common subsequence of their lines. Removed
+// lines are placed before the lines added in their place.
+func edits(expected, actual []string) []edit {
+	// common[i][j] is the length of the longest common subsequence of expected[i:] and actual[j:]
+	common := make([][]int, len(expected)+1)
+	for i := range common {
+		common[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	result := make([]edit, 0, len(expected)+len(actual))
+	i, j := 0, 0
+	for i < len(expected) && j < len(actual) {
+		switch {
+		case expected[i] == actual[j]:
+			result = append(result, edit{' ', expected[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			result = append(result, edit{'-', expected[i]})
+			i++
+		default:
+			result = append(result, edit{'+', actual[j]})
+			j++
+		}
+	}
+	for ; i < len(expected); i++ {
+		result = append(result, edit{'-', expected[i]})
+	}
+	for ; j < len(actual); j++ {
+		result = append(result, edit{'+', actual[j]})
+	}
+
+	return result
+}
+
+// hunkRange returns the range of a hunk as written in its header, starting
+// at the line provided and spanning count lines. An empty range starts at
+// the line before it, as with diff -u.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// unifiedDiff returns the lines of expected and actual which differ as a
+// unified diff, grouped into hunks with diffContext unchanged lines around
+// each change, or an empty string if both hold the same lines in the same
+// order. If color is true, the hunk headers and changed lines are colored.
+func unifiedDiff(expected, actual []string, color bool) string {
+	script := edits(expected, actual)
+
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + colorReset
+	}
+
+	var builder strings.Builder
+	for start := 0; start < len(script); {
+		// Find the next change, and the first unchanged line far enough from any change to end its hunk
+		first := start
+		for first < len(script) && script[first].op == ' ' {
+			first++
+		}
+		if first == len(script) {
+			break
+		}
+
+		end, unchanged := first, 0
+		for end < len(script) && unchanged <= 2*diffContext {
+			if script[end].op == ' ' {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+			end++
+		}
+		if unchanged > diffContext {
+			end -= unchanged - diffContext
+		}
+
+		from := first - diffContext
+		if from < start {
+			from = start
+		}
+		if from < 0 {
+			from = 0
+		}
+
+		// Count the lines of each side which precede the hunk and which it spans
+		oldStart, newStart := 1, 1
+		for _, e := range script[:from] {
+			if e.op != '+' {
+				oldStart++
+			}
+			if e.op != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, e := range script[from:end] {
+			if e.op != '+' {
+				oldCount++
+			}
+			if e.op != '-' {
+				newCount++
+			}
+		}
+
+		builder.WriteString(paint(colorHunk, fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldCount),
+			hunkRange(newStart, newCount))) + "\n")
+		for _, e := range script[from:end] {
+			switch e.op {
+			case '-':
+				builder.WriteString(paint(colorRemoved, "-"+e.line) + "\n")
+			case '+':
+				builder.WriteString(paint(colorAdded, "+"+e.line) + "\n")
+			default:
+				builder.WriteString(" " + e.line + "\n")
+			}
+		}
+
+		start = end
+	}
+
+	return builder.String()
+}
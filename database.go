@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"database/sql"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// regexKeyword matches the `dbname` keyword of a key/value Postgres data
+// source name, such as `host=localhost dbname=app sslmode=disable`.
+var regexKeyword = regexp.MustCompile(`(^|\s)dbname\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// splitPostgresDSN returns the name of the database to which dsn connects,
+// along with a data source name connecting to the `postgres` maintenance
+// database of the same server instead. Both URL and key/value data source
+// names are supported. An empty name is returned if dsn names no database.
+func splitPostgresDSN(dsn string) (string, string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		parsed, err := url.Parse(dsn)
+		if err != nil {
+			return "", "", err
+		}
+
+		name := strings.TrimPrefix(parsed.Path, "/")
+		parsed.Path = "/postgres"
+		return name, parsed.String(), nil
+	}
+
+	matches := regexKeyword.FindStringSubmatchIndex(dsn)
+	if matches == nil {
+		return "", dsn, nil
+	}
+
+	name := dsn[matches[4]:matches[5]]
+	if strings.HasPrefix(name, "'") {
+		name = strings.Replace(name[1:len(name)-1], `\'`, "'", -1)
+	}
+
+	return name, dsn[:matches[4]] + "postgres" + dsn[matches[5]:], nil
+}
+
+// splitMySQLDSN returns the name of the database to which dsn connects,
+// along with a data source name connecting to the same server without
+// selecting a database, as understood by github.com/go-sql-driver/mysql. An
+// empty name is returned if dsn names no database.
+func splitMySQLDSN(dsn string) (string, string, error) {
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return "", dsn, nil
+	}
+
+	name := dsn[slash+1:]
+	rest := ""
+	if question := strings.IndexByte(name, '?'); question >= 0 {
+		name, rest = name[:question], name[question:]
+	}
+
+	return name, dsn[:slash+1] + rest, nil
+}
+
+// quoteIdentifier quotes name for use as an identifier with the quote
+// character provided, doubling any occurrences of the quote within it.
+func quoteIdentifier(name, quote string) string {
+	return quote + strings.Replace(name, quote, quote+quote, -1) + quote
+}
+
+// CreateDatabase creates the database to which the data source name provided
+// connects if it does not already exist, returning true if it was created.
+// For Postgres and MySQL, the database is created from a connection to the
+// same server without the database: the `postgres` maintenance database for
+// Postgres, or no default database for MySQL. The user connected as must be
+// permitted to create databases. SQLite creates the database file itself when
+// it is first opened. CreateDatabase is called by OpenInstance when
+// WithCreateDatabase is in use, removing the need to create the database
+// before migrating a fresh server, such as in local development or CI.
+func CreateDatabase(driver, dsn string) (bool, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return false, NewFatalf("CreateDatabase: got error while opening database:\n%s", err)
+	}
+	dialect := detectDialect(db)
+	db.Close()
+
+	var name, maintenanceDSN, exists, create string
+	switch dialect {
+	case SQLite:
+		return false, nil
+	case Postgres:
+		name, maintenanceDSN, err = splitPostgresDSN(dsn)
+		exists = `SELECT COUNT(*) FROM pg_database WHERE datname = $1;`
+		create = `CREATE DATABASE ` + quoteIdentifier(name, `"`) + `;`
+	case MySQL:
+		name, maintenanceDSN, err = splitMySQLDSN(dsn)
+		exists = `SELECT COUNT(*) FROM information_schema.SCHEMATA WHERE SCHEMA_NAME = ?;`
+		create = "CREATE DATABASE IF NOT EXISTS " + quoteIdentifier(name, "`") + ";"
+	default:
+		return false, NewFatalf("CreateDatabase: cannot create databases with the %s dialect", dialect.Name())
+	}
+
+	if err != nil {
+		return false, NewFatalf("CreateDatabase: got error while parsing data source name:\n%s", err)
+	} else if name == "" {
+		return false, NewFatalf("CreateDatabase: no database named in data source name")
+	}
+
+	maintenance, err := sql.Open(driver, maintenanceDSN)
+	if err != nil {
+		return false, NewFatalf("CreateDatabase: got error while opening maintenance database:\n%s", err)
+	}
+	defer maintenance.Close()
+
+	var count int
+	if err := maintenance.QueryRow(exists, name).Scan(&count); err != nil {
+		return false, NewFatalf("CreateDatabase: got error while checking for database '%s':\n%s", name, err)
+	} else if count > 0 {
+		return false, nil
+	}
+
+	if _, err := maintenance.Exec(create); err != nil {
+		return false, NewFatalf("CreateDatabase: got error while creating database '%s':\n%s", name, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,275 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DeferredPart describes a part queued to be applied by RunDeferred, either
+// because it is marked with `-- @migrate/deferred` or because it is a data
+// part deferred by LatestSchema.
+type DeferredPart struct {
+	Version   int
+	Part      string
+	QueuedAt  time.Time
+	Attempts  int    // Number of times RunDeferred has failed to apply the part
+	LastError string // Error returned by the most recent failed attempt, if any
+}
+
+// createPending creates the table in which deferred parts are queued, named
+// table, if it does not already exist.
+func createPending(exec execer, table string) error {
+	_, err := exec.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + table + `(
+			Version INT NOT NULL,
+			Part VARCHAR(255) NOT NULL,
+			QueuedAt BIGINT NOT NULL,
+			Attempts INT NOT NULL,
+			LastError TEXT NOT NULL
+		);
+	`)
+	return err
+}
+
+// isDeferred reports whether part is queued for RunDeferred rather than
+// applied when migrating up, as it is marked with `-- @migrate/deferred` or is
+// of the kind deferred by the run, if any.
+func isDeferred(part *Part, deferred Kind) bool {
+	return part.Deferred || deferred != "" && part.Kind == deferred
+}
+
+// deferPart queues a part of a migration version to be applied by
+// RunDeferred rather than applying it.
+func (instance *Instance) deferPart(exec execer, version int, name string) error {
+	if _, err := exec.Exec(instance.rebind(`INSERT INTO `+instance.table("migrate_pending")+` (Version, Part, `+
+		`QueuedAt, Attempts, LastError) VALUES (?, ?, ?, 0, '');`), version, name,
+		instance.clock.Now().UnixNano()); err != nil {
+		return fmt.Errorf("migrate: failed to queue part '%s' of version %d:\n%s", name, version, err)
+	}
+
+	return nil
+}
+
+// clearPending removes a part from the queue of deferred parts, returning true
+// if the part had been queued.
+func (instance *Instance) clearPending(exec execer, table string, version int, name string) (bool, error) {
+	res, err := exec.Exec(instance.rebind(`DELETE FROM `+table+` WHERE Version = ? AND Part = ?;`), version, name)
+	if err != nil {
+		return false, fmt.Errorf("migrate: failed to dequeue part '%s' of version %d:\n%s", name, version, err)
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// Deferred returns every part queued to be applied by RunDeferred, in the
+// order in which it would apply them, along with the attempts made so far.
+func (instance *Instance) Deferred() ([]DeferredPart, error) {
+	queued := make([]DeferredPart, 0)
+	if instance.readOnly && !tableExists(instance.db, instance.table("migrate_pending")) {
+		return queued, nil
+	}
+
+	err := query(instance.db, `SELECT Version, Part, QueuedAt, Attempts, LastError FROM `+
+		instance.table("migrate_pending")+` ORDER BY Version, Part;`, nil, func(rows *sql.Rows) error {
+		var part DeferredPart
+		var queuedAt int64
+		if err := rows.Scan(&part.Version, &part.Part, &queuedAt, &part.Attempts, &part.LastError); err != nil {
+			return err
+		}
+
+		part.QueuedAt = time.Unix(0, queuedAt)
+		queued = append(queued, part)
+		return nil
+	})
+	if err != nil {
+		return nil, NewFatalf("Instance.Deferred: got error while reading deferred parts:\n%s", err)
+	}
+
+	return queued, nil
+}
+
+// RunDeferred applies every part queued by an earlier run, in order of
+// version and then name, such as from a background job once a deploy has
+// finished. Parts marked with `-- @migrate/deferred` are always queued rather
+// than applied by Goto and the like, as are data parts when migrating with
+// LatestSchema. Each part is applied within a transaction of its own unless
+// WithoutTransaction is in use, and is bounded by the timeout provided with
+// WithDataTimeout rather than that of WithVersionTimeout.
+//
+// A part which fails to apply is retried as configured with
+// WithDeferredRetries, and every failed attempt is recorded alongside the
+// part, as returned by Deferred. If the part still fails, RunDeferred stops,
+// leaving it and any later parts queued, and returns an *ErrApply, such that
+// calling RunDeferred again retries it.
+func (instance *Instance) RunDeferred(ctx context.Context) (err error) {
+	if instance.closed {
+		return NewFatalf("Instance.RunDeferred: instance has been closed")
+	} else if instance.readOnly {
+		return NewFatalf("Instance.RunDeferred: instance is read-only")
+	}
+
+	if err := instance.lock(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if unlockErr := instance.unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	queued, err := instance.Deferred()
+	if err != nil {
+		return err
+	}
+
+	current := instance.Version()
+	report := &RunReport{From: current, Target: current, Direction: "up", Version: current, Actor: instance.actor,
+		Reason: instance.reason}
+	instance.report = report
+	start := instance.clock.Now()
+	report.StartedAt = start
+	defer instance.beginRun(report)()
+	defer func() {
+		report.Duration = instance.since(start)
+		report.Err = err
+	}()
+
+	for _, entry := range queued {
+		var part *Part
+		if migration, ok := instance.migrations[entry.Version]; ok {
+			for _, candidate := range migration.Parts {
+				if candidate.Name == entry.Part {
+					part = candidate
+				}
+			}
+		}
+		if part == nil {
+			return NewFatalf("Instance.RunDeferred: deferred part '%s' of version %d no longer exists", entry.Part,
+				entry.Version)
+		}
+
+		if err := instance.runDeferredPart(ctx, report, entry.Version, part); err != nil {
+			return err
+		}
+	}
+
+	report.Outcome = Succeeded
+	return nil
+}
+
+// runDeferredPart applies a single deferred part, retrying it as configured
+// with WithDeferredRetries and recording each failed attempt.
+func (instance *Instance) runDeferredPart(ctx context.Context, report *RunReport, version int, part *Part) error {
+	var err error
+	for attempt := 0; attempt <= instance.deferredRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+			case <-instance.clock.After(instance.deferredBackoff):
+			}
+		}
+
+		var rows []int64
+		if rows, err = instance.applyDeferred(ctx, version, part); err == nil {
+			report.add(version, part, part.statementText("up"), rows, nil)
+			instance.say(MessageApplied, MessageData{Version: version, Part: part.Name})
+			instance.sayRows(version, part, "up", rows)
+			instance.log(LevelInfo, "part applied", Field{"version", version}, Field{"part", part.Name},
+				Field{"direction", "up"})
+			instance.analyze(ctx, version, part.Analyze)
+			return nil
+		} else if _, fatal := err.(*ErrFatal); fatal {
+			return err
+		}
+
+		instance.log(LevelWarn, "deferred part failed", Field{"version", version}, Field{"part", part.Name},
+			Field{"attempt", attempt + 1}, Field{"error", err})
+		if _, recordErr := instance.db.Exec(instance.rebind(`UPDATE `+instance.table("migrate_pending")+
+			` SET Attempts = Attempts + 1, LastError = ? WHERE Version = ? AND Part = ?;`), err.Error(), version,
+			part.Name); recordErr != nil {
+			return NewFatalf("Instance.RunDeferred: got error while recording failed attempt:\n%s", recordErr)
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	report.add(version, part, part.statementText("up"), nil, err)
+	instance.say(MessageFailed, MessageData{Version: version, Part: part.Name, Err: err})
+	instance.log(LevelError, "part failed", Field{"version", version}, Field{"part", part.Name},
+		Field{"direction", "up"}, Field{"error", err})
+
+	report.Interrupted = ctx.Err() == context.Canceled
+	report.Outcome = RolledBack
+	if instance.noTransaction {
+		report.Outcome = LeftDirty
+	}
+
+	return &ErrApply{report}
+}
+
+// applyDeferred applies a single deferred part and removes it from the queue,
+// within a transaction unless WithoutTransaction is in use. The error
+// returned by the part is returned as is, while any other error is returned
+// as an *ErrFatal. The number of rows affected by each statement is returned
+// if the part is applied.
+func (instance *Instance) applyDeferred(ctx context.Context, version int, part *Part) ([]int64, error) {
+	var exec execer
+	var transaction *sql.Tx
+	if instance.noTransaction {
+		var release func()
+		var err error
+		if exec, release, err = instance.pin(ctx); err != nil {
+			return nil, NewFatalf("Instance.RunDeferred: got error while setting search path:\n%s", err)
+		}
+		defer release()
+	} else {
+		var err error
+		if transaction, err = instance.begin(); err != nil {
+			return nil, NewFatalf("Instance.RunDeferred: got error while starting a transaction:\n%s", err)
+		}
+		exec = transaction
+	}
+
+	if instance.dataTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, instance.dataTimeout)
+		defer cancel()
+	}
+
+	stopHeartbeat := instance.startHeartbeat(version, part.Name, transaction != nil)
+	rows, err := instance.applyPart(ctx, exec, transaction != nil, version, part, "up")
+	stopHeartbeat()
+
+	if err != nil {
+		if transaction != nil {
+			if rollbackErr := transaction.Rollback(); rollbackErr != nil {
+				return nil, NewFatalf("Instance.RunDeferred: got error while rolling back '%s':\n%s", part.Name,
+					rollbackErr)
+			}
+		}
+
+		return nil, err
+	}
+
+	if _, err := instance.clearPending(exec, instance.table("migrate_pending"), version, part.Name); err != nil {
+		return nil, instance.abort(transaction, err)
+	} else if err := instance.recordHistory(exec, version, part, "up", instance.report); err != nil {
+		return nil, instance.abort(transaction, err)
+	} else if err := instance.setPartState(exec, version, part.Name, PartApplied, instance.report.StartedAt); err != nil {
+		return nil, instance.abort(transaction, err)
+	}
+
+	if transaction != nil {
+		if err := transaction.Commit(); err != nil {
+			return nil, NewFatalf("Instance.RunDeferred: got error while committing transaction:\n%s", err)
+		}
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestRunDeferred ensures that deferred parts are queued by Goto and applied by
+// RunDeferred, which retries and records failed attempts.
+func TestRunDeferred(t *testing.T) {
+	indexed := func(db *sql.DB) bool {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'users_name';`).Scan(&count); err != nil {
+			t.Fatal("db.QueryRow: got error:\n", err)
+		}
+		return count == 1
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/deferred", WithDeferredRetries(2, 0))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if !instance.migrations[1].Parts[1].Deferred || instance.migrations[1].Parts[0].Deferred {
+			t.Error("Part.Deferred: expected only '2_index.sql' of version 1 to be deferred")
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if indexed(db) {
+			t.Error("Instance.Latest: expected deferred index not to be created")
+		}
+		queued, err := instance.Deferred()
+		if err != nil {
+			t.Fatal("Instance.Deferred: got error:\n", err)
+		} else if len(queued) != 2 || queued[0].Part != "2_index.sql" || queued[1].Part != "audit.sql" {
+			t.Errorf("Instance.Deferred: got '%v' expected '2_index.sql' and 'audit.sql'", queued)
+		}
+
+		// The audit table does not yet exist, so the second part fails every attempt
+		err = instance.RunDeferred(context.Background())
+		applyErr, ok := err.(*ErrApply)
+		if !ok {
+			t.Fatalf("Instance.RunDeferred: got '%v' expected *ErrApply", err)
+		} else if applied := applyErr.Report.Applied; len(applied) != 1 || applied[0].Part != "2_index.sql" {
+			t.Errorf("Instance.RunDeferred: got applied parts '%v' expected '2_index.sql'", applied)
+		}
+		if !indexed(db) {
+			t.Error("Instance.RunDeferred: expected deferred index to be created")
+		}
+		queued, _ = instance.Deferred()
+		if len(queued) != 1 || queued[0].Part != "audit.sql" || queued[0].Attempts != 3 ||
+			!strings.Contains(queued[0].LastError, "no such table") {
+			t.Errorf("Instance.Deferred: got '%#v' expected 'audit.sql' after 3 failed attempts", queued)
+		}
+
+		if _, err := db.Exec(`CREATE TABLE audit(Note TEXT);`); err != nil {
+			t.Fatal("db.Exec: got error:\n", err)
+		}
+		if err := instance.RunDeferred(context.Background()); err != nil {
+			t.Fatal("Instance.RunDeferred: got error:\n", err)
+		}
+		if queued, _ := instance.Deferred(); len(queued) != 0 {
+			t.Errorf("Instance.Deferred: got '%v' expected none after RunDeferred", queued)
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/deferred")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		// Queued parts were never applied, so are never reverted
+		if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if skipped := instance.Report().Skipped; len(skipped) != 2 || skipped[0].Reason != "never applied" ||
+			skipped[0].SQL != "" {
+			t.Errorf("Instance.Report: got skipped parts '%v' expected both deferred parts, never applied", skipped)
+		}
+		if queued, _ := instance.Deferred(); len(queued) != 0 {
+			t.Errorf("Instance.Deferred: got '%v' expected none after reverting", queued)
+		}
+	})
+}
@@ -0,0 +1,557 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the behavior of a particular database system which
+// migrate must account for when applying migrations.
+type Dialect interface {
+	// Name returns the name of the dialect, such as "postgres".
+	Name() string
+	// Idempotent rewrites statement into a form which may be safely executed
+	// more than once, returning it unchanged if no rewrite is known.
+	Idempotent(statement string) string
+}
+
+// TransactionalDDL may be implemented by a Dialect to report whether the
+// database supports transactional DDL, that is whether statements such as
+// `CREATE TABLE` are rolled back along with the transaction in which they are
+// executed. A Dialect which does not implement TransactionalDDL is assumed to
+// support transactional DDL.
+type TransactionalDDL interface {
+	TransactionalDDL() bool
+}
+
+// Transactions may be implemented by a Dialect to report whether the
+// database supports interactive transactions, that is whether statements may
+// be grouped by BEGIN and COMMIT over a connection. Runs against a database
+// which does not, such as BigQuery, are applied as though WithoutTransaction
+// were in use. A Dialect which does not implement Transactions is assumed to
+// support interactive transactions.
+type Transactions interface {
+	Transactions() bool
+}
+
+// SearchPath may be implemented by a Dialect to return the statement which
+// sets the schema within which unqualified names are resolved, as used by
+// WithSchema. The statement applies to the current transaction only if local
+// is true, or to the session otherwise, and an empty schema restores the
+// default. An empty statement is returned if the database has no such
+// setting, in which case only the tables of migrate itself are qualified.
+type SearchPath interface {
+	SearchPath(schema string, local bool) string
+}
+
+// AdvisoryLocks may be implemented by a Dialect to return the statements
+// which take and release a lock identified by name and held by the session,
+// waiting for the lock if it is held by another. NewInstance holds the lock
+// while creating the tables of migrate, so that processes started together
+// against a new database do not race to create them. The lock is held only
+// if its statement returns no row, or a row whose value is neither NULL nor 0.
+// Empty statements are returned if the database has no such lock.
+type AdvisoryLocks interface {
+	AdvisoryLock(name string) string
+	AdvisoryUnlock(name string) string
+}
+
+// Placeholders may be implemented by a Dialect to report whether the
+// arguments of a query are marked with numbered placeholders, as in `$1`,
+// rather than with `?`. The queries with which migrate records its state are
+// written accordingly. A Dialect which does not implement Placeholders is
+// assumed to use `?`.
+type Placeholders interface {
+	NumberedPlaceholders() bool
+}
+
+// Statistics may be implemented by a Dialect to return the statement which
+// refreshes the statistics from which the query planner estimates the rows
+// of a table, as used by the `-- @migrate/analyze` directive. An empty
+// statement is returned if the database has no such statement, in which case
+// the directive is ignored.
+type Statistics interface {
+	Analyze(table string) string
+}
+
+// Guards may be implemented by a Dialect to return a query which guards a
+// statement that the database offers no idempotent form of, as used by
+// WithIdempotent. The statement is skipped if the query returns a truthy
+// value, as with a guard query provided with `-- @migrate/skip-if`. An empty
+// query is returned if the statement needs no guard.
+type Guards interface {
+	Guard(statement string) string
+}
+
+// regexDDL matches a statement which alters the schema of the database.
+var regexDDL = regexp.MustCompile(`(?i)^(?:CREATE|ALTER|DROP|TRUNCATE|RENAME)\b`)
+
+// isDDL reports whether statement alters the schema of the database.
+func isDDL(statement string) bool {
+	_, body := splitLeadingComments(statement)
+	return regexDDL.MatchString(body)
+}
+
+// supportsTransactions reports whether the Dialect provided supports
+// interactive transactions.
+func supportsTransactions(dialect Dialect) bool {
+	if transactions, ok := dialect.(Transactions); ok {
+		return transactions.Transactions()
+	}
+
+	return true
+}
+
+// numberedPlaceholders reports whether the Dialect provided marks the
+// arguments of a query with numbered placeholders.
+func numberedPlaceholders(dialect Dialect) bool {
+	if placeholders, ok := dialect.(Placeholders); ok {
+		return placeholders.NumberedPlaceholders()
+	}
+
+	return false
+}
+
+// rebind rewrites each `?` placeholder within query as `$1`, `$2`, and so on
+// if numbered is true. It is applied only to the queries with which migrate
+// records its state, which hold no literal `?`, and never to the SQL of a
+// migration.
+func rebind(query string, numbered bool) string {
+	if !numbered {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, char := range query {
+		if char == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+		} else {
+			builder.WriteRune(char)
+		}
+	}
+
+	return builder.String()
+}
+
+// supportsTransactionalDDL reports whether the Dialect provided supports
+// transactional DDL.
+func supportsTransactionalDDL(dialect Dialect) bool {
+	if transactional, ok := dialect.(TransactionalDDL); ok {
+		return transactional.TransactionalDDL()
+	}
+
+	return true
+}
+
+// rewriteRule rewrites statements beginning with a particular pattern by
+// inserting a guard after the matched prefix, unless the statement is already
+// guarded with an `IF` clause or names no object, as with an unnamed index
+// created with `CREATE INDEX ON`.
+type rewriteRule struct {
+	pattern *regexp.Regexp // Must capture the prefix and the word which follows it
+	guard   string         // Inserted between the prefix and the word which follows it
+	replace string         // If not empty, replaces the prefix rather than inserting guard
+}
+
+// newRule returns a rewriteRule which inserts guard after prefix, a regular
+// expression matched case-insensitively at the beginning of a statement.
+func newRule(prefix, guard string) rewriteRule {
+	return rewriteRule{pattern: regexp.MustCompile(`(?is)^(` + prefix + `)\s+(\S+)`), guard: guard}
+}
+
+// newReplaceRule returns a rewriteRule which replaces prefix, a regular
+// expression matched case-insensitively at the beginning of a statement, with
+// replace.
+func newReplaceRule(prefix, replace string) rewriteRule {
+	return rewriteRule{pattern: regexp.MustCompile(`(?is)^(` + prefix + `)\s+(\S+)`), replace: replace}
+}
+
+// apply rewrites statement according to the rule, returning the rewritten
+// statement and true if the rule matched.
+func (rule rewriteRule) apply(statement string) (string, bool) {
+	matches := rule.pattern.FindStringSubmatchIndex(statement)
+	if matches == nil {
+		return statement, false
+	}
+
+	prefix := statement[matches[2]:matches[3]]
+	word := statement[matches[4]:matches[5]]
+	if rule.replace != "" {
+		return rule.replace + " " + statement[matches[4]:], true
+	} else if strings.EqualFold(word, "IF") || strings.EqualFold(word, "ON") {
+		return statement, true
+	}
+
+	return prefix + " " + rule.guard + " " + statement[matches[4]:], true
+}
+
+// dialect implements Dialect for the dialects built into the package.
+type dialect struct {
+	name  string
+	rules []rewriteRule
+
+	numbered    bool   // Whether placeholders are numbered, as in `$1`, rather than `?`
+	implicitDDL bool   // Whether DDL statements implicitly commit the transaction in which they run
+	noTx        bool   // Whether the database lacks interactive transactions, executing each statement as a job
+	useSchema   bool   // Whether the schema in which names are resolved is set with `USE SCHEMA`
+	searchPath  bool   // Whether the schema in which names are resolved is set with `SET search_path`
+	lockTimeout bool   // Whether the time spent waiting for locks is bounded with `SET lock_timeout`
+	references  string // Query listing the tables referenced by the foreign keys of a table
+	cascade     bool   // Whether DROP statements accept CASCADE
+	explain     string // Prefix which explains a statement without executing it, as used by WithRowLimit
+	explainJSON bool   // Whether the plan explained is a single JSON document rather than a row per table
+	grants      bool   // Whether privileges are granted to roles with GRANT
+	grantKinds  bool   // Whether GRANT names the kind of object, as in `ON SEQUENCE`
+	owners      bool   // Whether the owner of an object is changed with `ALTER ... OWNER TO`
+	metadata    string // Format of the statement creating the metadata table, if the types of metadb are not accepted
+	noCreate    bool   // Whether the tables of migrate cannot be created by migrate, and must exist beforehand
+	indexGuard  bool   // Whether CREATE INDEX and DROP INDEX are guarded by a query of information_schema
+
+	analyze string // Format of the statement which refreshes the statistics of a table, as used by Statistics
+
+	// Formats of the statements which take and release a lock held by the
+	// session, given its name, as used by AdvisoryLocks
+	advisoryLock, advisoryUnlock string
+
+	// Queries used by Introspect, listing the name and comment of every table,
+	// the name, type, nullability, default, and comment of the columns of a
+	// table, and the name, uniqueness, and columns of the indexes of a table
+	tables, columns, indexes string
+
+	// Query used by Clean, listing the kind, such as VIEW, and the quoted name
+	// of every object in the current schema, in the order they should be dropped
+	objects string
+}
+
+// Name implements the Dialect interface for dialect.
+func (dialect *dialect) Name() string {
+	return dialect.name
+}
+
+// TransactionalDDL implements the TransactionalDDL interface for dialect.
+func (dialect *dialect) TransactionalDDL() bool {
+	return !dialect.implicitDDL
+}
+
+// NumberedPlaceholders implements the Placeholders interface for dialect.
+func (dialect *dialect) NumberedPlaceholders() bool {
+	return dialect.numbered
+}
+
+// Transactions implements the Transactions interface for dialect.
+func (dialect *dialect) Transactions() bool {
+	return !dialect.noTx
+}
+
+// AdvisoryLock implements the AdvisoryLocks interface for dialect.
+func (dialect *dialect) AdvisoryLock(name string) string {
+	if dialect.advisoryLock == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(dialect.advisoryLock, name)
+}
+
+// AdvisoryUnlock implements the AdvisoryLocks interface for dialect.
+func (dialect *dialect) AdvisoryUnlock(name string) string {
+	if dialect.advisoryUnlock == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(dialect.advisoryUnlock, name)
+}
+
+// Analyze implements the Statistics interface for dialect.
+func (dialect *dialect) Analyze(table string) string {
+	if dialect.analyze == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(dialect.analyze, table)
+}
+
+// SearchPath implements the SearchPath interface for dialect. `USE SCHEMA`
+// always applies to the session and cannot restore the default, so an empty
+// statement is returned for an empty schema where it is used.
+func (dialect *dialect) SearchPath(schema string, local bool) string {
+	if dialect.useSchema && schema != "" {
+		return "USE SCHEMA " + schema + ";"
+	} else if !dialect.searchPath {
+		return ""
+	}
+
+	if schema == "" {
+		scope := "SESSION"
+		if local {
+			scope = "LOCAL"
+		}
+		return "SET " + scope + " search_path TO DEFAULT;"
+	}
+
+	// Search the schema ahead of the path already set, so that objects of the
+	// default schema, such as extensions, remain visible
+	return fmt.Sprintf("SELECT set_config('search_path', '%s, ' || current_setting('search_path'), %t);", schema,
+		local)
+}
+
+// Guard implements the Guards interface for dialect. Where `CREATE INDEX` and
+// `DROP INDEX` accept no `IF` clause, as in MySQL, each is guarded by a query
+// of information_schema.STATISTICS reporting whether the index already exists
+// or no longer exists respectively.
+func (dialect *dialect) Guard(statement string) string {
+	_, body := splitLeadingComments(statement)
+	matches := regexIndex.FindStringSubmatch(body)
+	if !dialect.indexGuard || matches == nil {
+		return ""
+	}
+
+	literal := func(name string) string {
+		if strings.HasPrefix(name, "`") {
+			name = strings.Replace(name[1:len(name)-1], "``", "`", -1)
+		}
+		return quoteIdentifier(name, "'")
+	}
+
+	schema := "DATABASE()"
+	if matches[3] != "" {
+		schema = literal(matches[3])
+	}
+
+	condition := "COUNT(*) > 0"
+	if strings.EqualFold(matches[1], "DROP") {
+		condition = "COUNT(*) = 0"
+	}
+
+	return fmt.Sprintf("SELECT %s FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = %s AND TABLE_NAME = %s "+
+		"AND INDEX_NAME = %s;", condition, schema, literal(matches[4]), literal(matches[2]))
+}
+
+// Idempotent implements the Dialect interface for dialect, applying the first
+// rewrite rule which matches the statement after any leading comments.
+func (dialect *dialect) Idempotent(statement string) string {
+	comments, body := splitLeadingComments(statement)
+	for _, rule := range dialect.rules {
+		if rewritten, ok := rule.apply(body); ok {
+			return comments + rewritten
+		}
+	}
+
+	return statement
+}
+
+// regexIndex matches a statement creating or dropping a named index on a
+// table, capturing the verb, the index, the schema of the table if it is
+// qualified, and the table, each name of which may be quoted with backquotes.
+var regexIndex = regexp.MustCompile("(?is)^(CREATE|DROP)\\s+(?:(?:UNIQUE|FULLTEXT|SPATIAL)\\s+)?INDEX\\s+" +
+	"(`(?:[^`]|``)+`|[\\w$]+)\\s+ON\\s+(?:(`(?:[^`]|``)+`|[\\w$]+)\\.)?(`(?:[^`]|``)+`|[\\w$]+)")
+
+// Rules shared by every built-in dialect except Generic.
+var (
+	ruleCreateTable = newRule(`CREATE\s+(?:(?:TEMP|TEMPORARY)\s+)?TABLE`, "IF NOT EXISTS")
+	ruleDropTable   = newRule(`DROP\s+TABLE`, "IF EXISTS")
+	ruleDropView    = newRule(`DROP\s+VIEW`, "IF EXISTS")
+	ruleCreateIndex = newRule(`CREATE\s+(?:UNIQUE\s+)?INDEX(?:\s+CONCURRENTLY)?`, "IF NOT EXISTS")
+	ruleDropIndex   = newRule(`DROP\s+INDEX(?:\s+CONCURRENTLY)?`, "IF EXISTS")
+)
+
+var (
+	// Generic is used when the dialect of a database cannot be determined,
+	// and knows no rewrites.
+	Generic Dialect = &dialect{name: "generic"}
+
+	// SQLite is the dialect of SQLite databases.
+	SQLite Dialect = &dialect{name: "sqlite", rules: []rewriteRule{
+		ruleCreateTable,
+		ruleDropTable,
+		newRule(`CREATE\s+(?:(?:TEMP|TEMPORARY)\s+)?VIEW`, "IF NOT EXISTS"),
+		ruleDropView,
+		ruleCreateIndex,
+		ruleDropIndex,
+		newRule(`CREATE\s+(?:(?:TEMP|TEMPORARY)\s+)?TRIGGER`, "IF NOT EXISTS"),
+		newRule(`DROP\s+TRIGGER`, "IF EXISTS"),
+	}, references: `SELECT "table" FROM pragma_foreign_key_list(?);`,
+		tables: `SELECT name, '' FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ` +
+			`ORDER BY name;`,
+		columns: `SELECT name, type, "notnull" = 0, COALESCE(dflt_value, ''), '' FROM pragma_table_info(?) ` +
+			`ORDER BY cid;`,
+		indexes: `SELECT list.name, list."unique", (SELECT group_concat(name, ', ') FROM ` +
+			`pragma_index_info(list.name)) FROM pragma_index_list(?) list ORDER BY list.name;`,
+		objects: `SELECT UPPER(type), '"' || REPLACE(name, '"', '""') || '"' FROM sqlite_master WHERE type IN ` +
+			`('view', 'table', 'trigger') AND name NOT LIKE 'sqlite_%' ORDER BY type = 'table', name;`,
+		analyze: "ANALYZE %s;"}
+
+	// Postgres is the dialect of PostgreSQL databases.
+	Postgres Dialect = &dialect{name: "postgres", rules: []rewriteRule{
+		ruleCreateTable,
+		ruleDropTable,
+		newReplaceRule(`CREATE\s+VIEW`, "CREATE OR REPLACE VIEW"),
+		ruleDropView,
+		ruleCreateIndex,
+		ruleDropIndex,
+		newRule(`CREATE\s+SCHEMA`, "IF NOT EXISTS"),
+		newRule(`DROP\s+SCHEMA`, "IF EXISTS"),
+		newRule(`CREATE\s+SEQUENCE`, "IF NOT EXISTS"),
+		newRule(`DROP\s+SEQUENCE`, "IF EXISTS"),
+		newRule(`ALTER\s+TABLE\s+\S+\s+ADD\s+COLUMN`, "IF NOT EXISTS"),
+		newRule(`ALTER\s+TABLE\s+\S+\s+DROP\s+COLUMN`, "IF EXISTS"),
+	}, numbered: true, references: `SELECT ccu.table_name FROM information_schema.table_constraints tc ` +
+		`JOIN information_schema.constraint_column_usage ccu ON tc.constraint_name = ccu.constraint_name ` +
+		`WHERE tc.constraint_type = 'FOREIGN KEY' AND tc.table_name = $1;`,
+		tables: `SELECT c.relname, COALESCE(obj_description(c.oid, 'pg_class'), '') FROM pg_class c ` +
+			`JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind = 'r' AND ` +
+			`n.nspname = current_schema() ORDER BY c.relname;`,
+		columns: `SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull, ` +
+			`COALESCE(pg_get_expr(d.adbin, d.adrelid), ''), COALESCE(col_description(a.attrelid, a.attnum), '') ` +
+			`FROM pg_attribute a LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum ` +
+			`WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum;`,
+		indexes: `SELECT i.relname, x.indisunique, array_to_string(ARRAY(SELECT ` +
+			`pg_get_indexdef(x.indexrelid, k + 1, true) FROM generate_subscripts(x.indkey, 1) k ORDER BY k), ` +
+			`', ') FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid WHERE x.indrelid = $1::regclass ` +
+			`ORDER BY i.relname;`,
+		objects: `SELECT kind, name FROM (SELECT CASE c.relkind WHEN 'v' THEN 1 WHEN 'm' THEN 2 WHEN 'S' THEN 4 ` +
+			`ELSE 3 END AS rank, CASE c.relkind WHEN 'v' THEN 'VIEW' WHEN 'm' THEN 'MATERIALIZED VIEW' ` +
+			`WHEN 'S' THEN 'SEQUENCE' ELSE 'TABLE' END AS kind, quote_ident(c.relname) AS name FROM pg_class c ` +
+			`JOIN pg_namespace n ON n.oid = c.relnamespace WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S') AND ` +
+			`n.nspname = current_schema() UNION ALL SELECT 5, CASE p.prokind WHEN 'p' THEN 'PROCEDURE' ` +
+			`ELSE 'FUNCTION' END, p.oid::regprocedure::text FROM pg_proc p JOIN pg_namespace n ON ` +
+			`n.oid = p.pronamespace WHERE p.prokind IN ('f', 'p') AND n.nspname = current_schema()) objects ` +
+			`ORDER BY rank, name;`, searchPath: true, lockTimeout: true,
+		cascade: true, explain: "EXPLAIN (FORMAT JSON)", explainJSON: true, grants: true, grantKinds: true,
+		owners: true, analyze: "ANALYZE %s;", advisoryLock: "SELECT pg_advisory_lock(hashtext('%s'));",
+		advisoryUnlock: "SELECT pg_advisory_unlock(hashtext('%s'));", metadata: `
+			CREATE TABLE IF NOT EXISTS %s(
+				ID SERIAL PRIMARY KEY,
+				Name VARCHAR(255) NOT NULL UNIQUE,
+				Value TEXT NOT NULL,
+				ValueType SMALLINT NOT NULL
+			);
+		`}
+
+	// MySQL is the dialect of MySQL and MariaDB databases. MySQL accepts no
+	// `IF` clause with `CREATE INDEX` or `DROP INDEX`, so when WithIdempotent
+	// is in use, each is skipped if the index already exists or no longer
+	// exists respectively, as found in information_schema.STATISTICS.
+	MySQL Dialect = &dialect{name: "mysql", rules: []rewriteRule{
+		ruleCreateTable,
+		ruleDropTable,
+		newReplaceRule(`CREATE\s+VIEW`, "CREATE OR REPLACE VIEW"),
+		ruleDropView,
+	}, implicitDDL: true, references: `SELECT REFERENCED_TABLE_NAME FROM information_schema.KEY_COLUMN_USAGE ` +
+		`WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND REFERENCED_TABLE_NAME IS NOT NULL;`,
+		tables: `SELECT TABLE_NAME, TABLE_COMMENT FROM information_schema.TABLES WHERE ` +
+			`TABLE_SCHEMA = DATABASE() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME;`,
+		columns: `SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE = 'YES', COALESCE(COLUMN_DEFAULT, ''), ` +
+			`COLUMN_COMMENT FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND ` +
+			`TABLE_NAME = ? ORDER BY ORDINAL_POSITION;`,
+		indexes: `SELECT INDEX_NAME, MAX(NON_UNIQUE) = 0, GROUP_CONCAT(COLUMN_NAME ORDER BY SEQ_IN_INDEX ` +
+			`SEPARATOR ', ') FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND ` +
+			`TABLE_NAME = ? GROUP BY INDEX_NAME ORDER BY INDEX_NAME;`,
+		objects: "SELECT kind, CONCAT('`', REPLACE(name, '`', '``'), '`') FROM (SELECT 1 AS `rank`, 'VIEW' AS " +
+			"kind, TABLE_NAME AS name FROM information_schema.VIEWS WHERE TABLE_SCHEMA = DATABASE() UNION ALL " +
+			"SELECT 2, 'TABLE', TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND " +
+			"TABLE_TYPE = 'BASE TABLE' UNION ALL SELECT 3, ROUTINE_TYPE, ROUTINE_NAME FROM " +
+			"information_schema.ROUTINES WHERE ROUTINE_SCHEMA = DATABASE()) objects ORDER BY `rank`, name;",
+		explain: "EXPLAIN", grants: true, analyze: "ANALYZE TABLE %s;", advisoryLock: "SELECT GET_LOCK('%s', 60);",
+		advisoryUnlock: "SELECT RELEASE_LOCK('%s');", indexGuard: true}
+
+	// Snowflake is the dialect of Snowflake warehouses, in which DDL commits
+	// implicitly and WithSchema selects the schema with `USE SCHEMA`, which
+	// remains selected for the session.
+	Snowflake Dialect = &dialect{name: "snowflake", rules: []rewriteRule{
+		ruleCreateTable,
+		ruleDropTable,
+		newReplaceRule(`CREATE\s+VIEW`, "CREATE OR REPLACE VIEW"),
+		ruleDropView,
+		newRule(`CREATE\s+SCHEMA`, "IF NOT EXISTS"),
+		newRule(`DROP\s+SCHEMA`, "IF EXISTS"),
+		newRule(`CREATE\s+SEQUENCE`, "IF NOT EXISTS"),
+		newRule(`DROP\s+SEQUENCE`, "IF EXISTS"),
+	}, implicitDDL: true, useSchema: true,
+		tables: `SELECT TABLE_NAME, COALESCE(COMMENT, '') FROM INFORMATION_SCHEMA.TABLES WHERE ` +
+			`TABLE_SCHEMA = CURRENT_SCHEMA() AND TABLE_TYPE = 'BASE TABLE' ORDER BY TABLE_NAME;`,
+		columns: `SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE = 'YES', COALESCE(COLUMN_DEFAULT, ''), ` +
+			`COALESCE(COMMENT, '') FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = CURRENT_SCHEMA() AND ` +
+			`TABLE_NAME = ? ORDER BY ORDINAL_POSITION;`,
+		grants: true, grantKinds: true}
+
+	// BigQuery is the dialect of BigQuery datasets. BigQuery runs every
+	// statement as a job of its own rather than within an interactive
+	// transaction, so runs are applied as with WithoutTransaction, and a
+	// failed run leaves the database dirty rather than being rolled back.
+	// BigQuery cannot set the dataset in which names are resolved, so
+	// WithSchema qualifies only the tables of migrate itself with the
+	// dataset, and parts should qualify their own names. BigQuery accepts
+	// none of the statements with which migrate creates the tables in which
+	// it records its state, so NewInstance does not create them, and
+	// returns an *ErrFatal if any does not already exist.
+	BigQuery Dialect = &dialect{name: "bigquery", rules: []rewriteRule{
+		ruleCreateTable,
+		ruleDropTable,
+		newReplaceRule(`CREATE\s+VIEW`, "CREATE OR REPLACE VIEW"),
+		ruleDropView,
+		newRule(`CREATE\s+SCHEMA`, "IF NOT EXISTS"),
+		newRule(`DROP\s+SCHEMA`, "IF EXISTS"),
+	}, implicitDDL: true, noTx: true, noCreate: true,
+		tables: "SELECT table_name, '' FROM INFORMATION_SCHEMA.TABLES WHERE table_type = 'BASE TABLE' " +
+			"ORDER BY table_name;",
+		columns: "SELECT column_name, data_type, is_nullable = 'YES', COALESCE(column_default, ''), '' FROM " +
+			"INFORMATION_SCHEMA.COLUMNS WHERE table_name = ? ORDER BY ordinal_position;"}
+)
+
+// detectDialect returns the Dialect registered with RegisterDialect or the
+// built-in Dialect matching the driver used by the database handle provided,
+// or Generic if the driver is not recognized.
+func detectDialect(db *sql.DB) Dialect {
+	driver := strings.ToLower(fmt.Sprintf("%T", db.Driver()))
+	if dialect := registeredDialectFor(driver); dialect != nil {
+		return dialect
+	}
+
+	switch {
+	case strings.Contains(driver, "sqlite"):
+		return SQLite
+	case strings.Contains(driver, "pq.") || strings.Contains(driver, "pgx") || strings.Contains(driver, "stdlib."):
+		return Postgres
+	case strings.Contains(driver, "mysql"):
+		return MySQL
+	case strings.Contains(driver, "snowflake"):
+		return Snowflake
+	case strings.Contains(driver, "bigquery"):
+		return BigQuery
+	default:
+		return Generic
+	}
+}
+
+// splitLeadingComments splits a statement into any comments and whitespace
+// preceding it and the remainder of the statement.
+func splitLeadingComments(statement string) (string, string) {
+	i := 0
+	for i < len(statement) {
+		switch rest := statement[i:]; {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r':
+			i++
+		case strings.HasPrefix(rest, "--"):
+			if end := strings.IndexByte(rest, '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(statement)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			if end := strings.Index(rest, "*/"); end >= 0 {
+				i += end + 2
+			} else {
+				i = len(statement)
+			}
+		default:
+			return statement[:i], statement[i:]
+		}
+	}
+
+	return statement, ""
+}
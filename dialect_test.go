@@ -0,0 +1,348 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestIdempotent ensures that each built-in Dialect rewrites statements into
+// their idempotent forms, leaving statements which are already guarded or
+// which have no known rewrite unchanged.
+func TestIdempotent(t *testing.T) {
+	cases := []struct {
+		dialect  Dialect
+		input    string
+		expected string
+	}{
+		{SQLite, "CREATE TABLE test(ID INT);", "CREATE TABLE IF NOT EXISTS test(ID INT);"},
+		{SQLite, "create temp table test(ID INT);", "create temp table IF NOT EXISTS test(ID INT);"},
+		{SQLite, "CREATE TABLE IF NOT EXISTS test(ID INT);", "CREATE TABLE IF NOT EXISTS test(ID INT);"},
+		{SQLite, "-- Comment\nDROP TABLE test;", "-- Comment\nDROP TABLE IF EXISTS test;"},
+		{SQLite, "CREATE UNIQUE INDEX test_id ON test (ID);", "CREATE UNIQUE INDEX IF NOT EXISTS test_id ON test (ID);"},
+		{SQLite, "INSERT INTO test (ID) VALUES (1);", "INSERT INTO test (ID) VALUES (1);"},
+		{Postgres, "CREATE VIEW test_view AS SELECT 1;", "CREATE OR REPLACE VIEW test_view AS SELECT 1;"},
+		{Postgres, "ALTER TABLE test ADD COLUMN name TEXT;", "ALTER TABLE test ADD COLUMN IF NOT EXISTS name TEXT;"},
+		{Postgres, "DROP INDEX test_id;", "DROP INDEX IF EXISTS test_id;"},
+		{Postgres, "CREATE INDEX CONCURRENTLY test_id ON test (ID);",
+			"CREATE INDEX CONCURRENTLY IF NOT EXISTS test_id ON test (ID);"},
+		{Postgres, "create unique index concurrently test_id on test (ID);",
+			"create unique index concurrently IF NOT EXISTS test_id on test (ID);"},
+		{Postgres, "CREATE INDEX concurrently_id ON test (ID);", "CREATE INDEX IF NOT EXISTS concurrently_id ON test (ID);"},
+		{Postgres, "DROP INDEX CONCURRENTLY test_id;", "DROP INDEX CONCURRENTLY IF EXISTS test_id;"},
+		{Postgres, "CREATE INDEX ON test (ID);", "CREATE INDEX ON test (ID);"},
+		{Postgres, "CREATE INDEX CONCURRENTLY ON test (ID);", "CREATE INDEX CONCURRENTLY ON test (ID);"},
+		{MySQL, "DROP INDEX test_id ON test;", "DROP INDEX test_id ON test;"},
+		{MySQL, "/* Create */ CREATE TABLE test(ID INT);", "/* Create */ CREATE TABLE IF NOT EXISTS test(ID INT);"},
+		{Generic, "CREATE TABLE test(ID INT);", "CREATE TABLE test(ID INT);"},
+		{Snowflake, "CREATE SEQUENCE ids;", "CREATE SEQUENCE IF NOT EXISTS ids;"},
+		{BigQuery, "CREATE VIEW sales.recent AS SELECT 1;", "CREATE OR REPLACE VIEW sales.recent AS SELECT 1;"},
+		{BigQuery, "DROP SCHEMA sales;", "DROP SCHEMA IF EXISTS sales;"},
+	}
+
+	for _, c := range cases {
+		if rewritten := c.dialect.Idempotent(c.input); rewritten != c.expected {
+			t.Errorf("Dialect.Idempotent: got '%s' from %s dialect with '%s' expected '%s'", rewritten,
+				c.dialect.Name(), c.input, c.expected)
+		}
+	}
+}
+
+// TestGuards ensures that MySQL guards the index statements which it offers
+// no idempotent form of with a query of information_schema, and that other
+// statements and dialects are left unguarded.
+func TestGuards(t *testing.T) {
+	cases := []struct {
+		dialect  Dialect
+		input    string
+		expected string
+	}{
+		{MySQL, "DROP INDEX test_id ON test;", "SELECT COUNT(*) = 0 FROM information_schema.STATISTICS WHERE " +
+			"TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'test' AND INDEX_NAME = 'test_id';"},
+		{MySQL, "-- Comment\ncreate unique index `it's` on shop.`te``st` (ID);", "SELECT COUNT(*) > 0 FROM " +
+			"information_schema.STATISTICS WHERE TABLE_SCHEMA = 'shop' AND TABLE_NAME = 'te`st' AND " +
+			"INDEX_NAME = 'it''s';"},
+		{MySQL, "CREATE INDEX IF NOT EXISTS test_id ON test (ID);", ""},
+		{MySQL, "DROP TABLE test;", ""},
+		{SQLite, "DROP INDEX test_id ON test;", ""},
+		{Postgres, "CREATE INDEX test_id ON test (ID);", ""},
+	}
+
+	for _, c := range cases {
+		if guard := c.dialect.(Guards).Guard(c.input); guard != c.expected {
+			t.Errorf("Dialect.Guard: got '%s' from %s dialect with '%s' expected '%s'", guard, c.dialect.Name(),
+				c.input, c.expected)
+		}
+	}
+}
+
+// indexDialect is a Dialect which, as MySQL does, offers no idempotent form
+// of index statements, but guards them with a query of sqlite_master.
+type indexDialect struct {
+	Dialect
+}
+
+// regexIndexName matches an index statement of SQLite, capturing its verb
+// and the index.
+var regexIndexName = regexp.MustCompile(`(?i)^(CREATE|DROP)\s+INDEX\s+(\w+)`)
+
+func (dialect indexDialect) Idempotent(statement string) string {
+	if regexIndexName.MatchString(statement) {
+		return statement
+	}
+
+	return dialect.Dialect.Idempotent(statement)
+}
+
+// Guard implements the Guards interface for indexDialect.
+func (indexDialect) Guard(statement string) string {
+	matches := regexIndexName.FindStringSubmatch(statement)
+	if matches == nil {
+		return ""
+	}
+
+	condition := "COUNT(*) > 0"
+	if strings.EqualFold(matches[1], "DROP") {
+		condition = "COUNT(*) = 0"
+	}
+	return "SELECT " + condition + " FROM sqlite_master WHERE type = 'index' AND name = '" + matches[2] + "';"
+}
+
+// TestGuardIdempotent ensures that a statement guarded by the Dialect is
+// skipped when WithIdempotent is in use once applying it would change
+// nothing, and is planned as guarded.
+func TestGuardIdempotent(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if _, err := db.Exec(`CREATE TABLE existing(ID INT PRIMARY KEY);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		} else if _, err := db.Exec(`CREATE INDEX existing_id ON existing (ID);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		instance, err := NewInstance(db, "testing/idempotent", WithDialect(indexDialect{SQLite}), WithIdempotent())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if planned, err := instance.Plan(1); err != nil {
+			t.Fatal("Instance.Plan: got error:\n", err)
+		} else if len(planned) != 2 || planned[0].Guarded || !planned[1].Guarded {
+			t.Errorf("Instance.Plan: got '%#v' expected only the index to be guarded", planned)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error with existing index:\n", err)
+		}
+
+		if _, err := db.Exec(`DROP INDEX existing_id;`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		} else if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error with index already dropped:\n", err)
+		} else if tableExists(db, "existing") {
+			t.Error("Instance.Goto: expected the statements following the index to be applied")
+		}
+	})
+}
+
+// TestDetectDialect ensures that the dialect is detected from the database
+// driver, and that statements are rewritten when WithIdempotent is in use.
+func TestDetectDialect(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if dialect := detectDialect(db); dialect != SQLite {
+			t.Errorf("detectDialect: got '%s' expected 'sqlite'", dialect.Name())
+		}
+
+		if _, err := db.Exec(`CREATE TABLE existing(ID INT PRIMARY KEY);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		instance, err := NewInstance(db, "testing/idempotent", WithIdempotent())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Error("Instance.Latest: got error with idempotent rewriting:\n", err)
+		}
+		if err := instance.Goto(0); err != nil {
+			t.Error("Instance.Goto: got error with idempotent rewriting:\n", err)
+		}
+	})
+}
+
+// TestNonTransactionalDDL ensures that a warning is written before running
+// DDL within a transaction against a dialect without transactional DDL,
+// unless acknowledged.
+func TestNonTransactionalDDL(t *testing.T) {
+	if supportsTransactionalDDL(MySQL) || !supportsTransactionalDDL(Postgres) || !supportsTransactionalDDL(Generic) {
+		t.Error("supportsTransactionalDDL: expected only MySQL to lack transactional DDL")
+	}
+
+	if !isDDL("-- comment\nalter table a add column b int;") || isDDL("INSERT INTO a VALUES (1);") {
+		t.Error("isDDL: got unexpected result")
+	}
+
+	for _, test := range []struct {
+		options []Option
+		warned  bool
+	}{
+		{[]Option{WithDialect(SQLite)}, false},
+		{[]Option{WithDialect(MySQL)}, true},
+		{[]Option{WithDialect(MySQL), WithNonTransactionalDDL()}, false},
+		{[]Option{WithDialect(MySQL), WithoutTransaction()}, false},
+	} {
+		RunWithDB(func(db *sql.DB) {
+			instance, err := NewInstance(db, "testing/meta", test.options...)
+			if err != nil {
+				t.Fatal("NewInstance: got error:\n", err)
+			}
+
+			output := &strings.Builder{}
+			instance.Output = output
+			if err := instance.Latest(); err != nil {
+				t.Fatal("Instance.Latest: got error:\n", err)
+			}
+
+			expected := "mysql commits DDL implicitly, so 2 DDL statement(s) about to run cannot be rolled back"
+			if warned := strings.Contains(output.String(), expected); warned != test.warned {
+				t.Errorf("Instance.Latest: got warning %t expected %t with %d option(s), output:\n%s", warned,
+					test.warned, len(test.options), output)
+			}
+		})
+	}
+}
+
+// TestWarehouse ensures that a database without interactive transactions is
+// migrated as with WithoutTransaction, that statements may be run as jobs
+// with a JobFunc, that BigQuery requires the tables of migrate to exist
+// rather than creating them, within the dataset provided with WithSchema if
+// any, where it records its runs, and that Snowflake selects its schema with
+// USE SCHEMA.
+func TestWarehouse(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		expectError(t, "NewInstance", "without the tables of migrate", func() error {
+			_, err := NewInstance(db, "testing/meta", WithDialect(BigQuery))
+			return err
+		}, "table 'metadata' must be created beforehand")
+		if tableExists(db, "metadata") || tableExists(db, "migrate_journal") {
+			t.Error("NewInstance: expected BigQuery not to create the tables of migrate")
+		}
+
+		// Create the tables as they would be created by hand in BigQuery
+		if _, err := NewInstance(db, "testing/meta"); err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		var jobs []string
+		instance, err := NewInstance(db, "testing/meta", WithDialect(BigQuery),
+			WithExecutor(JobFunc(func(ctx context.Context, statement string) (int64, error) {
+				jobs = append(jobs, statement)
+				_, err := db.ExecContext(ctx, statement)
+				return 0, err
+			})))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if !instance.noTransaction {
+			t.Error("NewInstance: expected BigQuery to be migrated without transactions")
+		}
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if len(jobs) != 2 || !tableExists(db, "invoices") {
+			t.Errorf("JobFunc: got jobs %q expected both parts to be run as jobs", jobs)
+		}
+	})
+
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	RunWithDB(func(db *sql.DB) {
+		// Attached databases are the closest equivalent of a dataset in SQLite
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec(`ATTACH DATABASE ? AS analytics;`, filepath.Join(directory, "analytics.sqlite")); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		// The tables of migrate outside of the dataset do not satisfy BigQuery
+		if _, err := NewInstance(db, "testing/meta"); err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		expectError(t, "NewInstance", "without the tables of migrate within the dataset", func() error {
+			_, err := NewInstance(db, "testing/meta", WithDialect(BigQuery), WithSchema("analytics"))
+			return err
+		}, "table 'analytics.metadata' must be created beforehand")
+
+		if _, err := NewInstance(db, "testing/meta", WithSchema("analytics")); err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		var jobs []string
+		instance, err := NewInstance(db, "testing/meta", WithDialect(BigQuery), WithSchema("analytics"),
+			WithExecutor(JobFunc(func(ctx context.Context, statement string) (int64, error) {
+				jobs = append(jobs, statement)
+				_, err := db.ExecContext(ctx, statement)
+				return 0, err
+			})))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		} else if len(jobs) != 1 {
+			t.Errorf("JobFunc: got jobs %q expected the part of version 1 to be run as a job", jobs)
+		}
+
+		for table, expected := range map[string]int{"analytics.migrate_history": 1, "migrate_history": 0,
+			"analytics.metadata": 1, "metadata": 0} {
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table + `;`).Scan(&count); err != nil {
+				t.Fatal("sql.DB.QueryRow: got error:\n", err)
+			} else if count != expected {
+				t.Errorf("Instance.Goto: got %d entries in %s expected %d", count, table, expected)
+			}
+		}
+	})
+
+	if _, err := JobFunc(func(context.Context, string) (int64, error) { return 0, nil }).ExecContext(
+		context.Background(), "SELECT ?;", 1); err == nil {
+		t.Error("JobFunc.ExecContext: expected error for statement with arguments")
+	}
+
+	path := Snowflake.(SearchPath)
+	if statement := path.SearchPath("analytics", true); statement != "USE SCHEMA analytics;" {
+		t.Errorf("Dialect.SearchPath: got '%s' from snowflake expected 'USE SCHEMA analytics;'", statement)
+	} else if statement := path.SearchPath("", false); statement != "" {
+		t.Errorf("Dialect.SearchPath: got '%s' from snowflake expected nothing to restore the default", statement)
+	}
+}
+
+// TestRebind ensures that the placeholders of the queries with which migrate
+// records its state are numbered only for dialects which expect it.
+func TestRebind(t *testing.T) {
+	query := `UPDATE metadata SET Value = ? WHERE Name = ? AND Value = ?;`
+	if rebound := rebind(query, numberedPlaceholders(Postgres)); rebound !=
+		`UPDATE metadata SET Value = $1 WHERE Name = $2 AND Value = $3;` {
+		t.Errorf("rebind: got '%s' for postgres dialect", rebound)
+	}
+
+	for _, dialect := range []Dialect{SQLite, MySQL, Generic} {
+		if rebound := rebind(query, numberedPlaceholders(dialect)); rebound != query {
+			t.Errorf("rebind: got '%s' for %s dialect expected query unchanged", rebound, dialect.Name())
+		}
+	}
+}
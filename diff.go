@@ -0,0 +1,170 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change describes how a version or part differs between two migration trees.
+type Change int
+
+const (
+	// Added indicates that the version or part is only present in the new tree.
+	Added Change = iota
+	// Removed indicates that the version or part is only present in the old tree.
+	Removed
+	// Modified indicates that the version or part is present in both trees,
+	// but that its SQL differs, or for a version that any of its parts differ.
+	Modified
+)
+
+// String implements the fmt.Stringer interface for Change.
+func (change Change) String() string {
+	switch change {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	default:
+		return "modified"
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface for Change,
+// such that it is written to JSON by name.
+func (change Change) MarshalText() ([]byte, error) {
+	return []byte(change.String()), nil
+}
+
+// PartDiff describes a part which differs between two migration trees.
+type PartDiff struct {
+	Name   string `json:"name"`
+	Change Change `json:"change"`
+}
+
+// VersionDiff describes a version which differs between two migration trees,
+// along with each of its parts which differ.
+type VersionDiff struct {
+	Version int        `json:"version"`
+	Change  Change     `json:"change"`
+	Parts   []PartDiff `json:"parts"`
+}
+
+// TreeDiff describes every difference between two migration trees, as
+// returned by PlanDiff.
+type TreeDiff struct {
+	Versions []VersionDiff `json:"versions"`
+}
+
+// Empty reports whether the two trees compared hold the same migrations.
+func (diff *TreeDiff) Empty() bool {
+	return len(diff.Versions) == 0
+}
+
+// String implements the fmt.Stringer interface for TreeDiff, listing each
+// version which differs followed by its parts, marked with `+` if added, `-`
+// if removed, or `~` if modified.
+func (diff *TreeDiff) String() string {
+	if diff.Empty() {
+		return "No schema changes\n"
+	}
+
+	marks := map[Change]string{Added: "+", Removed: "-", Modified: "~"}
+	var builder strings.Builder
+	for _, version := range diff.Versions {
+		fmt.Fprintf(&builder, "%s version %d (%s)\n", marks[version.Change], version.Version, version.Change)
+		for _, part := range version.Parts {
+			fmt.Fprintf(&builder, "  %s %s\n", marks[part.Change], part.Name)
+		}
+	}
+
+	return builder.String()
+}
+
+// PlanDiff loads the migration trees within oldRoot and newRoot, such as the
+// release which is deployed and the release about to be, and returns every
+// version and part added, removed, or modified by the new tree, answering
+// which schema changes a release contains. Parts are compared by checksum,
+// so changes to comments or whitespace alone are not reported. Both trees are
+// loaded as by Inspect, applying any options provided, without a database.
+func PlanDiff(oldRoot, newRoot string, options ...Option) (*TreeDiff, error) {
+	old, err := inspect(oldRoot, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := inspect(newRoot, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]bool)
+	for version := range old.migrations {
+		versions[version] = true
+	}
+	for version := range current.migrations {
+		versions[version] = true
+	}
+
+	sorted := make([]int, 0, len(versions))
+	for version := range versions {
+		sorted = append(sorted, version)
+	}
+	sort.Ints(sorted)
+
+	diff := &TreeDiff{Versions: make([]VersionDiff, 0)}
+	for _, version := range sorted {
+		before, after := old.migrations[version], current.migrations[version]
+		entry := VersionDiff{Version: version, Change: Modified, Parts: diffParts(before, after)}
+		if before == nil {
+			entry.Change = Added
+		} else if after == nil {
+			entry.Change = Removed
+		}
+
+		if len(entry.Parts) > 0 {
+			diff.Versions = append(diff.Versions, entry)
+		}
+	}
+
+	return diff, nil
+}
+
+// diffParts returns every part which differs between two versions of a
+// migration, either of which may be nil, ordered by name.
+func diffParts(before, after *Migration) []PartDiff {
+	parts := make(map[string][2]*Part)
+	for i, migration := range []*Migration{before, after} {
+		if migration == nil {
+			continue
+		}
+
+		for _, part := range migration.Parts {
+			pair := parts[part.Name]
+			pair[i] = part
+			parts[part.Name] = pair
+		}
+	}
+
+	names := make([]string, 0, len(parts))
+	for name := range parts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	diffs := make([]PartDiff, 0)
+	for _, name := range names {
+		pair := parts[name]
+		switch {
+		case pair[0] == nil:
+			diffs = append(diffs, PartDiff{Name: name, Change: Added})
+		case pair[1] == nil:
+			diffs = append(diffs, PartDiff{Name: name, Change: Removed})
+		case pair[0].Checksum != pair[1].Checksum:
+			diffs = append(diffs, PartDiff{Name: name, Change: Modified})
+		}
+	}
+
+	return diffs
+}
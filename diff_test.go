@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPlanDiff ensures that versions and parts added, removed, and modified
+// between two trees are reported, while changes to comments are not.
+func TestPlanDiff(t *testing.T) {
+	root := CopyTree(t, "testing/meta")
+	write := func(path, contents string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal("os.MkdirAll: got error:\n", err)
+		} else if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+	}
+
+	billing, err := ioutil.ReadFile(filepath.Join(root, "version_1", "billing.sql"))
+	if err != nil {
+		t.Fatal("ioutil.ReadFile: got error:\n", err)
+	}
+	write(filepath.Join(root, "version_1", "billing.sql"), string(billing)+"\n-- Reviewed\n")
+	write(filepath.Join(root, "version_1", "accounts.sql"),
+		"-- @migrate/up\nCREATE TABLE accounts(ID INT);\n-- @migrate/down\nDROP TABLE accounts;\n")
+	write(filepath.Join(root, "version_2", "invoices.sql"),
+		"-- @migrate/up\nCREATE TABLE invoices(ID INT PRIMARY KEY, Total INT);\n-- @migrate/down\nDROP TABLE invoices;\n")
+	write(filepath.Join(root, "version_3", "payments.sql"),
+		"-- @migrate/up\nCREATE TABLE payments(ID INT);\n-- @migrate/down\nDROP TABLE payments;\n")
+
+	diff, err := PlanDiff("testing/meta", root)
+	if err != nil {
+		t.Fatal("PlanDiff: got error:\n", err)
+	}
+
+	expected := "~ version 1 (modified)\n  + accounts.sql\n~ version 2 (modified)\n  ~ invoices.sql\n" +
+		"+ version 3 (added)\n  + payments.sql\n"
+	if output := diff.String(); output != expected {
+		t.Errorf("TreeDiff.String: got:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	reverse, err := PlanDiff(root, "testing/meta")
+	if err != nil {
+		t.Fatal("PlanDiff: got error:\n", err)
+	}
+	encoded, err := json.Marshal(reverse.Versions[2])
+	if err != nil {
+		t.Fatal("json.Marshal: got error:\n", err)
+	} else if !strings.Contains(string(encoded), `"change":"removed"`) {
+		t.Errorf("PlanDiff: got '%s' expected version 3 to be removed", encoded)
+	}
+
+	if same, err := PlanDiff("testing/meta", "testing/meta"); err != nil || !same.Empty() {
+		t.Errorf("PlanDiff: got '%v' and error '%v' expected no changes between identical trees", same, err)
+	}
+
+	expectError(t, "PlanDiff", "missing tree", func() error {
+		_, err := PlanDiff("testing/meta", filepath.Join(root, "missing"))
+		return err
+	})
+}
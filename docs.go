@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// docsFuncs are the functions available to the documentation templates.
+var docsFuncs = map[string]interface{}{
+	// cell escapes a value for use within a Markdown table cell
+	"cell": func(value string) string {
+		value = strings.Replace(value, "|", `\|`, -1)
+		return strings.Replace(value, "\n", " ", -1)
+	},
+	"yes": func(value bool) string {
+		if value {
+			return "yes"
+		}
+		return "no"
+	},
+}
+
+// docsMarkdown is the template from which `schema.md` is rendered.
+var docsMarkdown = template.Must(template.New("schema.md").Funcs(docsFuncs).Parse(`# Database Schema
+
+Generated from version {{.Version}} of the migrations.
+{{range .Schema.Tables}}
+## {{.Name}}
+{{if .Comment}}
+{{.Comment}}
+{{end}}
+| Column | Type | Nullable | Default | Comment |
+| --- | --- | --- | --- | --- |
+{{range .Columns}}| {{cell .Name}} | {{cell .Type}} | {{yes .Nullable}} | {{cell .Default}} | {{cell .Comment}} |
+{{end}}{{if .Indexes}}
+| Index | Unique | Columns |
+| --- | --- | --- |
+{{range .Indexes}}| {{cell .Name}} | {{yes .Unique}} | {{cell .Columns}} |
+{{end}}{{end}}{{end}}`))
+
+// docsHTML is the template from which `schema.html` is rendered.
+var docsHTML = htmltemplate.Must(htmltemplate.New("schema.html").Funcs(docsFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Database Schema</title>
+</head>
+<body>
+<h1>Database Schema</h1>
+<p>Generated from version {{.Version}} of the migrations.</p>
+{{range .Schema.Tables}}
+<h2 id="{{.Name}}">{{.Name}}</h2>
+{{if .Comment}}<p>{{.Comment}}</p>{{end}}
+<table>
+<tr><th>Column</th><th>Type</th><th>Nullable</th><th>Default</th><th>Comment</th></tr>
+{{range .Columns}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{yes .Nullable}}</td>
+<td>{{.Default}}</td><td>{{.Comment}}</td></tr>
+{{end}}</table>
+{{if .Indexes}}<table>
+<tr><th>Index</th><th>Unique</th><th>Columns</th></tr>
+{{range .Indexes}}<tr><td>{{.Name}}</td><td>{{yes .Unique}}</td><td>{{.Columns}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// GenerateDocs writes human-readable documentation of the current schema of
+// the database to the directory provided, listing every table along with its
+// columns, indexes, and comments as read by Introspect. Both `schema.md` and
+// `schema.html` are written, replacing any existing files, and the directory
+// is created if it does not exist. GenerateDocs is intended to be called after
+// migrating, keeping the documentation in step with the migrations.
+func (instance *Instance) GenerateDocs(dir string) error {
+	schema, err := instance.Introspect()
+	if err != nil {
+		return err
+	}
+
+	data := struct {
+		Version int
+		Schema  *Schema
+	}{instance.Version(), schema}
+
+	var markdown, html bytes.Buffer
+	if err := docsMarkdown.Execute(&markdown, data); err != nil {
+		return NewFatalf("Instance.GenerateDocs: got error while rendering Markdown:\n%s", err)
+	} else if err := docsHTML.Execute(&html, data); err != nil {
+		return NewFatalf("Instance.GenerateDocs: got error while rendering HTML:\n%s", err)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return NewFatalf("Instance.GenerateDocs: got error while creating directory:\n%s", err)
+	}
+
+	for name, contents := range map[string][]byte{"schema.md": markdown.Bytes(), "schema.html": html.Bytes()} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			return NewFatalf("Instance.GenerateDocs: got error while writing '%s':\n%s", name, err)
+		}
+	}
+
+	return nil
+}
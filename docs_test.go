@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIntrospect(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/docs")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		schema, err := instance.Introspect()
+		if err != nil {
+			t.Fatal("Instance.Introspect: got error:\n", err)
+		}
+
+		expected := &Schema{Tables: []Table{{
+			Name: "users",
+			Columns: []Column{
+				{Name: "ID", Type: "INTEGER", Nullable: true},
+				{Name: "Name", Type: "TEXT"},
+				{Name: "Email", Type: "TEXT", Nullable: true},
+				{Name: "Active", Type: "INT", Default: "1"},
+			},
+			Indexes: []Index{{Name: "users_email", Unique: true, Columns: "Email"}},
+		}}}
+		if !reflect.DeepEqual(schema, expected) {
+			t.Errorf("Instance.Introspect: got '%#v' expected '%#v'", schema, expected)
+		}
+
+		instance.dialect = Generic
+		expectError(t, "Instance.Introspect", "the generic dialect", func() error {
+			_, err := instance.Introspect()
+			return err
+		}, "does not support introspection")
+	})
+}
+
+func TestGenerateDocs(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/docs")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		dir := filepath.Join(directory, "docs")
+		if err := instance.GenerateDocs(dir); err != nil {
+			t.Fatal("Instance.GenerateDocs: got error:\n", err)
+		}
+
+		for name, expected := range map[string][]string{
+			"schema.md": {"Generated from version 1", "## users\n", "| Active | INT | no | 1 |  |",
+				"| users_email | yes | Email |"},
+			"schema.html": {"<h2 id=\"users\">users</h2>", "<td>Email</td><td>TEXT</td><td>yes</td>\n"},
+		} {
+			contents, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("ioutil.ReadFile: got error reading '%s':\n%s", name, err)
+			}
+
+			for _, substr := range expected {
+				if !strings.Contains(string(contents), substr) {
+					t.Errorf("Instance.GenerateDocs: expected '%s' in %s, got:\n%s", substr, name, contents)
+				}
+			}
+		}
+	})
+}
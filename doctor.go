@@ -0,0 +1,242 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Diagnostic describes a single problem found by Doctor, along with the steps
+// which should be taken to resolve it.
+type Diagnostic struct {
+	Check       string `json:"check"` // Name of the check which found the problem, such as "gap"
+	Message     string `json:"message"`
+	Remediation string `json:"remediation"`
+}
+
+// String implements the fmt.Stringer interface for Diagnostic.
+func (diagnostic Diagnostic) String() string {
+	return fmt.Sprintf("[%s] %s\n  %s", diagnostic.Check, diagnostic.Message, diagnostic.Remediation)
+}
+
+// Doctor runs a series of checks against the instance directory and the
+// database, returning a Diagnostic for every problem found and writing each
+// to Output. Doctor never modifies the database. The checks look for gaps
+// between and duplicates of migration versions on disk, parts on disk which no
+// longer match the LockFile, a dirty database or one ahead of the known
+// migrations, irreversible parts, the problems reported by Lint and Advise,
+// history recorded for unknown migrations, part states at odds with the
+// version or the queue of deferred parts, optional parts which failed, and a
+// leftover migration lock.
+func (instance *Instance) Doctor() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	add := func(check, remediation, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Check: check, Message: fmt.Sprintf(format, args...),
+			Remediation: remediation})
+	}
+
+	instance.checkLayout(add)
+
+	if _, err := os.Stat(filepath.Join(instance.root, LockFile)); err == nil {
+		if err := instance.compareLockFile(instance.diskChecksums()); err != nil {
+			add("checksum", "Revert the change to the part, or if it is intended and the part has not been "+
+				"applied anywhere, regenerate the lock file with WriteLockFile.", "%s", err)
+		}
+	}
+
+	current := instance.Version()
+	if instance.Dirty() {
+		add("dirty", "Resolve the cause of the failure and call Resume to continue the interrupted run.",
+			"database was left dirty at version %d by an interrupted run", current)
+	}
+
+	if latest := instance.latest(); current > latest {
+		add("future-schema", "Deploy a release which includes the newer migrations.",
+			"database at version %d is ahead of the latest known migration version %d", current, latest)
+	}
+
+	for _, version := range instance.List() {
+		for _, part := range instance.migrations[version].Parts {
+			if part.Irreversible {
+				add("irreversible", "Ensure that migrating below this version will never be required, or "+
+					"provide downward migration SQL.", "part '%s' of version %d has no downward migration SQL",
+					part.Name, version)
+			}
+		}
+	}
+
+	instance.lint(add)
+	instance.advise(add)
+	instance.checkHistory(add)
+	instance.checkPartStates(add)
+	instance.checkLock(add)
+
+	for _, diagnostic := range diagnostics {
+		instance.say(MessageDiagnostic, MessageData{Diagnostic: diagnostic})
+	}
+
+	if len(diagnostics) == 0 {
+		instance.say(MessageHealthy, MessageData{})
+	}
+
+	return diagnostics
+}
+
+// checkLayout reads the instance directory afresh, reporting any gaps between
+// migration versions and any versions claimed by more than one directory.
+func (instance *Instance) checkLayout(add func(check, remediation, format string, args ...interface{})) {
+	paths, err := versionDirectories(instance.root)
+	if err != nil {
+		add("layout", "Ensure that the instance directory exists and is readable.",
+			"got error while reading instance directory:\n%s", err)
+		return
+	}
+
+	versions := make([]int, 0, len(paths))
+	for version := range paths {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	last := 0
+	for _, version := range versions {
+		if version != last+1 {
+			add("gap", "Renumber the migrations with CloseGaps and Renumber so that every version follows on "+
+				"from the last.",
+				"found gap between migration version %d and %d", last, version)
+		}
+		last = version
+
+		if len(paths[version]) > 1 {
+			add("duplicate", "Rename all but one of the migrations to a new version, such as after the "+
+				"latest version.", "found %d migrations claiming version %d: %s", len(paths[version]), version,
+				strings.Join(paths[version], ", "))
+		}
+	}
+}
+
+// diskChecksums returns every part and asset held by the Instance as currently
+// found on disk, keyed by the path of each relative to the instance directory,
+// so that their checksums may be compared. Parts and assets which can no
+// longer be read or parsed are omitted.
+func (instance *Instance) diskChecksums() map[string]*Part {
+	parts := make(map[string]*Part)
+	for _, version := range instance.List() {
+		migration := instance.migrations[version]
+		for _, part := range migration.Parts {
+			if reloaded, err := instance.loader.part(part.Path); err == nil {
+				parts[migration.Name+"/"+part.Name] = reloaded
+			}
+		}
+		for _, asset := range migration.Assets {
+			if reloaded, err := instance.loader.asset(asset.Path); err == nil {
+				parts[migration.Name+"/"+asset.Name] = reloaded.lockEntry()
+			}
+		}
+	}
+
+	return parts
+}
+
+// checkHistory reports any entries in the history for versions or parts
+// which do not exist in the instance directory.
+func (instance *Instance) checkHistory(add func(check, remediation, format string, args ...interface{})) {
+	entries, err := instance.History()
+	if err != nil {
+		add("history", "Ensure that the history table is readable.", "%s", err)
+		return
+	}
+
+	reported := make(map[string]bool)
+	for _, entry := range entries {
+		key := fmt.Sprintf("%d/%s", entry.Version, entry.Part)
+		if reported[key] || !instance.inRange(entry.Version) {
+			continue
+		}
+
+		migration, ok := instance.migrations[entry.Version]
+		found := false
+		if ok {
+			for _, part := range migration.Parts {
+				found = found || part.Name == entry.Part
+			}
+		}
+
+		if !found {
+			reported[key] = true
+			add("orphaned-history", "Restore the missing part if it was removed by mistake, or otherwise ensure "+
+				"that its changes are accounted for by another migration.", "history records part '%s' of "+
+				"version %d, which does not exist", entry.Part, entry.Version)
+		}
+	}
+}
+
+// checkPartStates reports the parts recorded in a state which does not agree
+// with the version of the database or with the queue of deferred parts, along
+// with every optional part which failed. Parts of versions above the current
+// version are not reported while the database is dirty, as the interrupted
+// version may have been partly applied.
+func (instance *Instance) checkPartStates(add func(check, remediation, format string, args ...interface{})) {
+	states, err := instance.partStates()
+	if err != nil {
+		add("part-state", "Ensure that the part state table is readable.", "%s", err)
+		return
+	}
+
+	deferred, err := instance.Deferred()
+	if err != nil {
+		add("part-state", "Ensure that the pending table is readable.", "%s", err)
+		return
+	}
+
+	queued := make(map[string]bool)
+	for _, part := range deferred {
+		queued[fmt.Sprintf("%d/%s", part.Version, part.Part)] = true
+	}
+
+	current, dirty := instance.Version(), instance.Dirty()
+	for _, version := range instance.List() {
+		for _, part := range instance.migrations[version].Parts {
+			state := states[version][part.Name]
+			key := fmt.Sprintf("%d/%s", version, part.Name)
+			switch {
+			case state == PartNone:
+				continue
+			case version > current && !dirty:
+				add("part-state", "Migrate to the version once more, or if the part was applied by hand, revert "+
+					"it.", "part '%s' of version %d is recorded as %s, but the database is at version %d",
+					part.Name, version, state, current)
+			case state == PartDeferred && !queued[key]:
+				add("part-state", "Migrate below the version and back to queue the part once more, or apply it "+
+					"by hand.", "part '%s' of version %d is recorded as deferred, but is not queued for "+
+					"RunDeferred", part.Name, version)
+			case state != PartDeferred && queued[key]:
+				add("part-state", "Remove the part from the queue of deferred parts, as it will otherwise be "+
+					"applied again.", "part '%s' of version %d is recorded as %s, but is queued for RunDeferred",
+					part.Name, version, state)
+			case state == PartFailed:
+				add("optional-failed", "Resolve the cause of the failure and apply the part by hand, or migrate "+
+					"below the version and back.", "optional part '%s' of version %d failed and was not applied",
+					part.Name, version)
+			}
+		}
+	}
+}
+
+// checkLock reports a migration lock left held, most likely by a process
+// which died while applying migrations.
+func (instance *Instance) checkLock(add func(check, remediation, format string, args ...interface{})) {
+	var holder string
+	var heartbeat int64
+	if err := instance.db.QueryRow(`SELECT Holder, Heartbeat FROM `+instance.table("migrate_lock")+
+		` WHERE ID = 1;`).Scan(&holder, &heartbeat); err != nil {
+		return
+	}
+
+	add("lock", "If no other process is applying migrations, use WithStaleLock to take over the lock or "+
+		"delete it from the migrate_lock table.", "migrations are locked by '%s', last refreshed %s ago", holder,
+		instance.since(time.Unix(heartbeat, 0)).Round(time.Second))
+}
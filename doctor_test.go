@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDoctor ensures that Doctor finds nothing wrong with a healthy instance
+// and reports each problem introduced afterward.
+func TestDoctor(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+
+		if diagnostics := instance.Doctor(); len(diagnostics) != 0 {
+			t.Errorf("Instance.Doctor: got '%v' expected no diagnostics", diagnostics)
+		}
+
+		// Introduce a problem for every check
+		if err := os.MkdirAll(filepath.Join(root, "version_05"), 0755); err != nil {
+			t.Fatal("os.MkdirAll: got error:\n", err)
+		}
+		if err := os.MkdirAll(filepath.Join(root, "version_5"), 0755); err != nil {
+			t.Fatal("os.MkdirAll: got error:\n", err)
+		}
+		path := filepath.Join(root, "version_1", "test.sql")
+		if err := ioutil.WriteFile(path, []byte("-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 1;\n"),
+			0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+		if err := instance.meta.Set("migrateTarget", 3); err != nil {
+			t.Fatal("metaStore.Set: got error:\n", err)
+		}
+		if _, err := db.Exec(`INSERT INTO migrate_history (Version, Part, Direction, Meta, AppliedAt) ` +
+			`VALUES (9, 'gone.sql', 'up', '{}', 0);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+		if _, err := db.Exec(`INSERT INTO migrate_lock (ID, Holder, Heartbeat) VALUES (1, 'elsewhere', ?);`,
+			time.Now().Unix()); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		checks := make(map[string]string)
+		for _, diagnostic := range instance.Doctor() {
+			checks[diagnostic.Check] = diagnostic.Message
+			if diagnostic.Remediation == "" {
+				t.Errorf("Instance.Doctor: got no remediation for '%s'", diagnostic.Check)
+			}
+		}
+
+		for check, message := range map[string]string{
+			"gap":              "between migration version 3 and 5",
+			"duplicate":        "2 migrations claiming version 5",
+			"checksum":         "'version_1/test.sql' has been modified",
+			"dirty":            "left dirty at version 3",
+			"orphaned-history": "part 'gone.sql' of version 9",
+			"lock":             "locked by 'elsewhere'",
+		} {
+			if !strings.Contains(checks[check], message) {
+				t.Errorf("Instance.Doctor: got '%s' for check '%s' expected substring '%s'", checks[check], check,
+					message)
+			}
+		}
+
+		if !strings.Contains(output.String(), "[lock] migrations are locked by 'elsewhere'") {
+			t.Errorf("Instance.Doctor: got unexpected output:\n%s", output)
+		}
+	})
+}
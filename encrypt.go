@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// encryptedPrefix marks a value stored in the history encrypted with the
+// Encrypter provided with WithEncryption, and encoded with base64.
+const encryptedPrefix = "enc:"
+
+// Encrypter encrypts the values recorded in the history before they are
+// stored, and decrypts them again when the history is read, as used with
+// WithEncryption. Decrypt must accept anything returned by Encrypt, including
+// that returned before the process was restarted.
+type Encrypter interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// aesEncrypter is an Encrypter using AES-GCM, prefixing each ciphertext with
+// the random nonce with which it was sealed.
+type aesEncrypter struct {
+	aead cipher.AEAD
+}
+
+// NewAESEncrypter returns an Encrypter which encrypts values with AES-GCM
+// under key, which must be 16, 24, or 32 bytes long to select AES-128,
+// AES-192, or AES-256.
+func NewAESEncrypter(key []byte) (Encrypter, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, NewFatalf("NewAESEncrypter: got error while creating cipher:\n%s", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, NewFatalf("NewAESEncrypter: got error while creating cipher:\n%s", err)
+	}
+
+	return &aesEncrypter{aead: aead}, nil
+}
+
+// Encrypt implements the Encrypter interface for aesEncrypter.
+func (encrypter *aesEncrypter) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, encrypter.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return encrypter.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt implements the Encrypter interface for aesEncrypter.
+func (encrypter *aesEncrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	size := encrypter.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, errors.New("ciphertext is shorter than its nonce")
+	}
+
+	return encrypter.aead.Open(nil, ciphertext[:size], ciphertext[size:], nil)
+}
+
+// encryptValue returns value as it is stored in the history, encrypted with
+// encrypter and encoded with base64 behind encryptedPrefix, or unchanged if
+// encrypter is nil.
+func encryptValue(encrypter Encrypter, value string) (string, error) {
+	if encrypter == nil {
+		return value, nil
+	}
+
+	ciphertext, err := encrypter.Encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+// decryptValue returns the value stored in the history, decrypting it with
+// encrypter if it was stored encrypted. An error is returned if the value is
+// encrypted and encrypter is nil.
+func decryptValue(encrypter Encrypter, stored string) (string, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return stored, nil
+	} else if encrypter == nil {
+		return "", errors.New("value is encrypted, but no Encrypter was provided with WithEncryption")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := encrypter.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestEncryption ensures that the metadata and SQL text recorded in the
+// history are stored encrypted with WithEncryption and decrypted when read,
+// and that they cannot be read without the Encrypter.
+func TestEncryption(t *testing.T) {
+	expectError(t, "NewAESEncrypter", "key of invalid length", func() error {
+		_, err := NewAESEncrypter([]byte("short"))
+		return err
+	}, "invalid key size")
+
+	encrypter, err := NewAESEncrypter(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal("NewAESEncrypter: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta", WithStoredSQL(true), WithEncryption(encrypter))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		var meta, statements string
+		if err := db.QueryRow(`SELECT Meta, Statements FROM migrate_history WHERE Part = 'billing.sql';`).Scan(
+			&meta, &statements); err != nil {
+			t.Fatal("db.QueryRow: got error:\n", err)
+		}
+		for _, stored := range []string{meta, statements} {
+			if !strings.HasPrefix(stored, encryptedPrefix) || strings.Contains(stored, "billing") {
+				t.Errorf("WithEncryption: got stored value '%s' expected ciphertext", stored)
+			}
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		}
+		if history[0].Meta["author"] != "jane" || !strings.Contains(history[0].SQL, "CREATE TABLE billing") {
+			t.Errorf("Instance.History: got entry '%#v' expected decrypted metadata and SQL", history[0])
+		}
+
+		plain, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		expectError(t, "Instance.History", "without encrypter", func() error {
+			_, err := plain.History()
+			return err
+		}, "no Encrypter was provided")
+	})
+}
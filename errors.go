@@ -0,0 +1,318 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrorCode identifies a class of error returned by the package. ErrorCode
+// implements the error interface so that each code may be used as a sentinel,
+// with every error type below reporting its code to errors.Is:
+//
+//	if errors.Is(err, migrate.CodeLocked) {
+//		// Another process is applying migrations
+//	}
+type ErrorCode int
+
+const (
+	// CodeGap is reported by ErrGap.
+	CodeGap ErrorCode = iota + 1
+	// CodeNoVersion is reported by ErrNoVersion.
+	CodeNoVersion
+	// CodeNoMigrations is reported by ErrNoMigrations.
+	CodeNoMigrations
+	// CodeDirty is reported by ErrDirty.
+	CodeDirty
+	// CodeLocked is reported by ErrLocked.
+	CodeLocked
+	// CodeChecksumMismatch is reported by ErrChecksumMismatch.
+	CodeChecksumMismatch
+	// CodeIrreversible is reported by ErrIrreversible.
+	CodeIrreversible
+	// CodeBehind is reported by ErrBehind.
+	CodeBehind
+	// CodeFutureSchema is reported by ErrFutureSchema.
+	CodeFutureSchema
+	// CodePolicy is reported by ErrPolicy.
+	CodePolicy
+	// CodeTimeout is reported by ErrTimeout.
+	CodeTimeout
+	// CodeDuplicateVersion is reported by ErrDuplicateVersion.
+	CodeDuplicateVersion
+	// CodeCodeMismatch is reported by ErrCodeMismatch.
+	CodeCodeMismatch
+	// CodeConcurrentModification is reported by ErrConcurrentModification.
+	CodeConcurrentModification
+	// CodeRowLimit is reported by ErrRowLimit.
+	CodeRowLimit
+)
+
+// codeNames maps each ErrorCode to a short description.
+var codeNames = map[ErrorCode]string{
+	CodeGap:                    "gap between migration versions",
+	CodeNoVersion:              "migration version does not exist",
+	CodeNoMigrations:           "no migrations to apply",
+	CodeDirty:                  "database is dirty",
+	CodeLocked:                 "migrations are locked",
+	CodeChecksumMismatch:       "checksum mismatch",
+	CodeIrreversible:           "migration is irreversible",
+	CodeBehind:                 "database is behind the available migrations",
+	CodeFutureSchema:           "database is ahead of the available migrations",
+	CodePolicy:                 "run forbidden by policy",
+	CodeTimeout:                "migration timed out",
+	CodeDuplicateVersion:       "duplicate migration version",
+	CodeCodeMismatch:           "migrations do not match those the code was generated against",
+	CodeConcurrentModification: "version modified concurrently",
+	CodeRowLimit:               "statement estimated to touch too many rows",
+}
+
+// Error implements the error interface for ErrorCode.
+func (code ErrorCode) Error() string {
+	if name, ok := codeNames[code]; ok {
+		return "migrate: " + name
+	}
+
+	return fmt.Sprintf("migrate: unknown error code %d", int(code))
+}
+
+// ErrGap is returned by NewInstance when there is a gap between two migration
+// versions.
+type ErrGap struct {
+	From int
+	To   int
+}
+
+// Error implements the error interface for ErrGap.
+func (err *ErrGap) Error() string {
+	return fmt.Sprintf("NewInstance: found gap between migration version %d and %d", err.From, err.To)
+}
+
+// Is reports whether target is CodeGap.
+func (err *ErrGap) Is(target error) bool {
+	return target == CodeGap
+}
+
+// ErrDirty is returned by Goto and Latest when a previous run without a
+// transaction was interrupted and must first be continued with Resume.
+type ErrDirty struct {
+	Version int
+	Target  int
+}
+
+// Error implements the error interface for ErrDirty.
+func (err *ErrDirty) Error() string {
+	return fmt.Sprintf("Instance.Goto: database is dirty at version %d after an interrupted migration to "+
+		"version %d, resolve the issue and call Resume to continue", err.Version, err.Target)
+}
+
+// Is reports whether target is CodeDirty.
+func (err *ErrDirty) Is(target error) bool {
+	return target == CodeDirty
+}
+
+// ErrLocked is returned by Goto, Latest, and Resume when another process holds
+// the migration lock.
+type ErrLocked struct {
+	Holder    string
+	Heartbeat time.Time // Time at which the holder last refreshed the lock
+}
+
+// Error implements the error interface for ErrLocked.
+func (err *ErrLocked) Error() string {
+	return fmt.Sprintf("Instance.Goto: migrations are locked by '%s', last refreshed at %s", err.Holder,
+		err.Heartbeat.Format(time.RFC3339))
+}
+
+// Is reports whether target is CodeLocked.
+func (err *ErrLocked) Is(target error) bool {
+	return target == CodeLocked
+}
+
+// ErrIrreversible is returned by Goto when migrating down would require
+// reverting a part marked as irreversible.
+type ErrIrreversible struct {
+	Version int
+	Part    string
+}
+
+// Error implements the error interface for ErrIrreversible.
+func (err *ErrIrreversible) Error() string {
+	return fmt.Sprintf("Instance.Goto: cannot migrate down past version %d, part '%s' is irreversible",
+		err.Version, err.Part)
+}
+
+// Is reports whether target is CodeIrreversible.
+func (err *ErrIrreversible) Is(target error) bool {
+	return target == CodeIrreversible
+}
+
+// ErrChecksumMismatch is returned when the contents of a part no longer match
+// the checksum recorded for it.
+type ErrChecksumMismatch struct {
+	Part     string // Path of the part relative to the instance directory
+	Expected string
+	Actual   string // Empty if the part no longer exists
+}
+
+// Error implements the error interface for ErrChecksumMismatch.
+func (err *ErrChecksumMismatch) Error() string {
+	if err.Actual == "" {
+		return fmt.Sprintf("NewInstance: part '%s' has been removed since its checksum was recorded", err.Part)
+	}
+
+	return fmt.Sprintf("NewInstance: part '%s' has been modified since its checksum was recorded, expected "+
+		"checksum %s got %s", err.Part, err.Expected, err.Actual)
+}
+
+// Is reports whether target is CodeChecksumMismatch.
+func (err *ErrChecksumMismatch) Is(target error) bool {
+	return target == CodeChecksumMismatch
+}
+
+// ErrBehind is returned by EnsureUpToDate when there are migrations available
+// which have not been applied to the database.
+type ErrBehind struct {
+	Version int
+	Pending []int
+}
+
+// Error implements the error interface for ErrBehind.
+func (err *ErrBehind) Error() string {
+	pending := make([]string, len(err.Pending))
+	for i, version := range err.Pending {
+		pending[i] = fmt.Sprint(version)
+	}
+
+	return fmt.Sprintf("Instance.EnsureUpToDate: database at version %d is behind, %d migration(s) pending: %s",
+		err.Version, len(err.Pending), strings.Join(pending, ", "))
+}
+
+// Is reports whether target is CodeBehind.
+func (err *ErrBehind) Is(target error) bool {
+	return target == CodeBehind
+}
+
+// ErrFutureSchema is returned when the version recorded in the database is
+// newer than the latest migration known to the Instance, as happens when an
+// application is rolled back to a release older than its schema.
+type ErrFutureSchema struct {
+	Version int
+	Latest  int
+}
+
+// Error implements the error interface for ErrFutureSchema.
+func (err *ErrFutureSchema) Error() string {
+	return fmt.Sprintf("migrate: database at version %d is ahead of the latest known migration version %d, "+
+		"refusing to run older migrations against a newer schema", err.Version, err.Latest)
+}
+
+// Is reports whether target is CodeFutureSchema.
+func (err *ErrFutureSchema) Is(target error) bool {
+	return target == CodeFutureSchema
+}
+
+// ErrTimeout is returned by Goto and Latest when applying a single version
+// takes longer than the duration configured with WithVersionTimeout. The
+// Report describes the state in which the database was left.
+type ErrTimeout struct {
+	Version int
+	Timeout time.Duration
+	Report  *RunReport
+}
+
+// Error implements the error interface for ErrTimeout.
+func (err *ErrTimeout) Error() string {
+	return fmt.Sprintf("Instance.Goto: applying version %d took longer than %s, database %s at version %d",
+		err.Version, err.Timeout, err.Report.Outcome, err.Report.Version)
+}
+
+// Is reports whether target is CodeTimeout.
+func (err *ErrTimeout) Is(target error) bool {
+	return target == CodeTimeout
+}
+
+// ErrDuplicateVersion is returned by NewInstance when two migration
+// directories, such as `version_2` and `version_02`, claim the same version
+// number. This most often follows a merge of two branches which each added a
+// migration.
+type ErrDuplicateVersion struct {
+	Version   int
+	Paths     [2]string
+	Checksums [2]string // Checksum of each migration, as returned by Migration.Checksum
+}
+
+// Error implements the error interface for ErrDuplicateVersion.
+func (err *ErrDuplicateVersion) Error() string {
+	return fmt.Sprintf("NewInstance: found two migrations claiming version %d, '%s' (checksum %s) and '%s' "+
+		"(checksum %s)", err.Version, err.Paths[0], err.Checksums[0], err.Paths[1], err.Checksums[1])
+}
+
+// Is reports whether target is CodeDuplicateVersion.
+func (err *ErrDuplicateVersion) Is(target error) bool {
+	return target == CodeDuplicateVersion
+}
+
+// ErrCodeMismatch is returned by Instance.AssertCodeMatches when the
+// migrations held by the Instance differ from those against which the code
+// was generated with Generate.
+type ErrCodeMismatch struct {
+	Generated Version
+	Actual    Version
+}
+
+// Error implements the error interface for ErrCodeMismatch.
+func (err *ErrCodeMismatch) Error() string {
+	return fmt.Sprintf("Instance.AssertCodeMatches: code was generated against migrations at version %d "+
+		"(checksum %s), found version %d (checksum %s), regenerate with Generate", err.Generated.Latest,
+		err.Generated.Checksum, err.Actual.Latest, err.Actual.Checksum)
+}
+
+// Is reports whether target is CodeCodeMismatch.
+func (err *ErrCodeMismatch) Is(target error) bool {
+	return target == CodeCodeMismatch
+}
+
+// ErrConcurrentModification is returned when the version recorded in the
+// database changed while a run was applying migrations, indicating that
+// another process migrated the database at the same time. The run is rolled
+// back if it was made within a transaction.
+type ErrConcurrentModification struct {
+	Expected int // Version read when the run was planned
+	Actual   int // Version found when recording the version reached
+}
+
+// Error implements the error interface for ErrConcurrentModification.
+func (err *ErrConcurrentModification) Error() string {
+	return fmt.Sprintf("Instance.Goto: expected database to be at version %d but found version %d, another "+
+		"process has migrated it concurrently", err.Expected, err.Actual)
+}
+
+// Is reports whether target is CodeConcurrentModification.
+func (err *ErrConcurrentModification) Is(target error) bool {
+	return target == CodeConcurrentModification
+}
+
+// ErrRowLimit is returned for a statement which is estimated to touch more
+// rows than the limit configured with WithRowLimit, in place of applying it.
+// It is reported as the error of the *ErrStatement for the statement, within
+// the *ErrApply returned by the run.
+type ErrRowLimit struct {
+	Version  int
+	Part     string
+	Verb     string // Keyword with which the statement begins, such as UPDATE
+	Estimate int64
+	Limit    int64
+}
+
+// Error implements the error interface for ErrRowLimit.
+func (err *ErrRowLimit) Error() string {
+	return fmt.Sprintf("%s in '%s' of version %d is estimated to touch %d row(s), more than the "+
+		"limit of %d, use WithRowLimitOverride to apply it regardless", err.Verb, err.Part, err.Version,
+		err.Estimate, err.Limit)
+}
+
+// Is reports whether target is CodeRowLimit.
+func (err *ErrRowLimit) Is(target error) bool {
+	return target == CodeRowLimit
+}
@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestErrorCodes ensures that the errors returned throughout the package can
+// be matched against their ErrorCode with errors.Is.
+func TestErrorCodes(t *testing.T) {
+	expectCode := func(name string, err error, code ErrorCode) {
+		if err == nil {
+			t.Errorf("%s: expected error matching '%s'", name, code)
+		} else if !errors.Is(err, code) {
+			t.Errorf("%s: expected error matching '%s', got:\n%s", name, code, err)
+		}
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		_, err := NewInstance(db, "testing/gap")
+		expectCode("NewInstance", err, CodeGap)
+
+		instance, err := NewInstance(db, "testing/irreversible")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		expectCode("Instance.Goto", instance.Goto(2), CodeNoVersion)
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		expectCode("Instance.Latest", instance.Latest(), CodeNoMigrations)
+		expectCode("Instance.Goto", instance.Goto(0), CodeIrreversible)
+
+		if errors.Is(instance.Goto(0), CodeLocked) {
+			t.Error("Instance.Goto: got error matching unrelated code")
+		}
+
+		if err := instance.meta.Set("migrateTarget", 0); err != nil {
+			t.Fatal("metaStore.Set: got error:\n", err)
+		}
+		expectCode("Instance.Goto", instance.Goto(0), CodeDirty)
+	})
+
+	if !strings.Contains(ErrorCode(100).Error(), "unknown error code 100") {
+		t.Errorf("ErrorCode.Error: got unexpected message for unknown code:\n%s", ErrorCode(100))
+	}
+}
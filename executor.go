@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Executor executes the statements which make up each part. Both *sql.DB and
+// *sql.Tx implement Executor. By default, statements are executed against the
+// database handle provided to NewInstance, or the transaction in use, while
+// WithExecutor allows them to be directed elsewhere, such as to the recording
+// Executor provided by the fake package or to a mock database handle. Only the
+// statements of parts are passed to the Executor, in exactly the order given
+// by Plan, so a mock need not expect any of the queries with which migrate
+// records its own state. To instead mock the database handle itself, with
+// DATA-DOG/go-sqlmock, the migratetest/mock package expects every query which
+// migrate sends along with the statements of parts.
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// JobFunc adapts a function which runs a statement as a job and waits for it
+// to finish, returning the number of rows which it affected, to the Executor
+// interface. It allows the parts of a warehouse such as BigQuery to be run
+// with its client library rather than a database/sql driver:
+//
+//	migrate.WithExecutor(migrate.JobFunc(func(ctx context.Context, statement string) (int64, error) {
+//		job, err := client.Query(statement).Run(ctx)
+//		if err != nil {
+//			return 0, err
+//		}
+//		status, err := job.Wait(ctx)
+//		if err != nil {
+//			return 0, err
+//		} else if err := status.Err(); err != nil {
+//			return 0, err
+//		}
+//		return status.Statistics.Details.(*bigquery.QueryStatistics).NumDMLAffectedRows, nil
+//	}))
+//
+// Statements run by a JobFunc are not part of any transaction in use, so it
+// should be combined with WithoutTransaction unless the Dialect reports that
+// the database lacks transactions.
+type JobFunc func(ctx context.Context, statement string) (int64, error)
+
+// ExecContext implements the Executor interface for JobFunc. Statements with
+// arguments are not supported.
+func (fn JobFunc) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if len(args) > 0 {
+		return nil, errors.New("migrate: statements run as jobs cannot take arguments")
+	}
+
+	affected, err := fn(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return jobResult(affected), nil
+}
+
+// jobResult is the sql.Result of a statement run by a JobFunc.
+type jobResult int64
+
+// LastInsertId implements the sql.Result interface for jobResult, which does
+// not support it.
+func (result jobResult) LastInsertId() (int64, error) {
+	return 0, errors.New("migrate: statements run as jobs report no insert ID")
+}
+
+// RowsAffected implements the sql.Result interface for jobResult.
+func (result jobResult) RowsAffected() (int64, error) {
+	return int64(result), nil
+}
@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"expvar"
+	"sync"
+)
+
+var (
+	// expvarOnce ensures that the expvar variables are published only once, as
+	// expvar.Publish panics if a name is reused.
+	expvarOnce sync.Once
+
+	// expvarMutex guards expvarInstance.
+	expvarMutex sync.Mutex
+
+	// expvarInstance is the Instance whose state is published with expvar.
+	expvarInstance *Instance
+)
+
+// publishExpvar publishes the state of the Instance provided with expvar,
+// replacing any Instance previously published.
+func publishExpvar(instance *Instance) {
+	expvarMutex.Lock()
+	expvarInstance = instance
+	expvarMutex.Unlock()
+
+	expvarOnce.Do(func() {
+		expvar.Publish("migrate.current_version", expvarFunc(func(stats Stats) interface{} {
+			return stats.Version
+		}))
+		expvar.Publish("migrate.pending", expvarFunc(func(stats Stats) interface{} {
+			return stats.Pending
+		}))
+		expvar.Publish("migrate.last_run_duration_ms", expvarFunc(func(stats Stats) interface{} {
+			return stats.LastDuration.Nanoseconds() / 1e6
+		}))
+		expvar.Publish("migrate.last_error", expvarFunc(func(stats Stats) interface{} {
+			if stats.LastErr == nil {
+				return ""
+			}
+			return stats.LastErr.Error()
+		}))
+	})
+}
+
+// unpublishExpvar stops publishing the state of the Instance provided, if it
+// is the Instance currently published.
+func unpublishExpvar(instance *Instance) {
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+
+	if expvarInstance == instance {
+		expvarInstance = nil
+	}
+}
+
+// expvarFunc returns an expvar.Func which passes the Stats of the published
+// Instance to fn, or which returns nil if no Instance is published.
+func expvarFunc(fn func(Stats) interface{}) expvar.Func {
+	return func() interface{} {
+		expvarMutex.Lock()
+		instance := expvarInstance
+		expvarMutex.Unlock()
+
+		if instance == nil {
+			return nil
+		}
+
+		return fn(instance.Stats())
+	}
+}
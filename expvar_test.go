@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"database/sql"
+	"expvar"
+	"strings"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta", WithExpvar())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		expect := func(name, value string) {
+			t.Helper()
+			if got := expvar.Get(name); got == nil {
+				t.Errorf("expvar.Get: expected variable '%s' to be published", name)
+			} else if got.String() != value {
+				t.Errorf("expvar.Get: got '%s' for '%s' expected '%s'", got, name, value)
+			}
+		}
+
+		expect("migrate.current_version", "0")
+		expect("migrate.pending", "2")
+		expect("migrate.last_error", `""`)
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		expect("migrate.current_version", "1")
+		expect("migrate.pending", "1")
+
+		if err := instance.Goto(3); err == nil {
+			t.Fatal("Instance.Goto: expected error")
+		}
+		if got := expvar.Get("migrate.last_error").String(); got == `""` {
+			t.Error("expvar.Get: expected 'migrate.last_error' to hold the error of the failed run")
+		}
+
+		if err := instance.Close(); err != nil {
+			t.Fatal("Instance.Close: got error:\n", err)
+		}
+		expect("migrate.current_version", "null")
+	})
+}
@@ -0,0 +1,170 @@
+package fake
+
+import (
+	"sync"
+	"time"
+
+	"github.com/octacian/migrate"
+)
+
+// Clock is a migrate.Clock which only advances when told to, for use with
+// migrate.WithClock. Its timers and tickers fire only as the Clock advances,
+// so that heartbeats, retries, and polls happen exactly when a test allows. A
+// Clock is safe for concurrent use.
+type Clock struct {
+	mutex   sync.Mutex
+	waiting *sync.Cond // Signalled whenever a timer is added
+	now     time.Time
+	step    time.Duration
+	timers  []*timer
+}
+
+// timer is a timer or ticker of a Clock, which fires once the Clock reaches
+// at.
+type timer struct {
+	at     time.Time
+	period time.Duration // Interval between the ticks of a ticker, or 0 for a timer which fires once
+	c      chan time.Time
+}
+
+// NewClock returns a Clock reading start.
+func NewClock(start time.Time) *Clock {
+	clock := &Clock{now: start}
+	clock.waiting = sync.NewCond(&clock.mutex)
+	return clock
+}
+
+// AutoAdvance causes the Clock to advance by step after every call to Now, so
+// that each duration measured by a run is a predictable multiple of step.
+// AutoAdvance returns the Clock to allow calls to be chained.
+func (clock *Clock) AutoAdvance(step time.Duration) *Clock {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.step = step
+	return clock
+}
+
+// Now returns the current time of the Clock.
+func (clock *Clock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	now := clock.now
+	clock.advance(clock.step)
+	return now
+}
+
+// Advance moves the Clock forward by d, firing every timer and ticker which
+// falls due.
+func (clock *Clock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.advance(d)
+}
+
+// After returns a channel which receives the time of the Clock once it has
+// advanced by d.
+func (clock *Clock) After(d time.Duration) <-chan time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.add(d, 0).c
+}
+
+// NewTicker returns a migrate.Ticker which delivers the time of the Clock
+// each time it advances by d. As with a time.Ticker, ticks are dropped rather
+// than queued if they are not received.
+func (clock *Clock) NewTicker(d time.Duration) migrate.Ticker {
+	if d <= 0 {
+		panic("fake: non-positive interval for Clock.NewTicker")
+	}
+
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return &ticker{clock: clock, timer: clock.add(d, d)}
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting for the
+// Clock to advance, such that a test may wait for the code under test to
+// begin waiting before calling Advance.
+func (clock *Clock) BlockUntil(n int) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	for len(clock.timers) < n {
+		clock.waiting.Wait()
+	}
+}
+
+// add adds a timer which fires once the Clock has advanced by d, and then
+// every period if period is not 0. The mutex must be held.
+func (clock *Clock) add(d, period time.Duration) *timer {
+	added := &timer{at: clock.now.Add(d), period: period, c: make(chan time.Time, 1)}
+	if d <= 0 && period == 0 {
+		added.c <- clock.now
+		return added
+	}
+
+	clock.timers = append(clock.timers, added)
+	clock.waiting.Broadcast()
+	return added
+}
+
+// advance moves the Clock forward by d, firing every timer which falls due
+// and removing those which fire only once. The mutex must be held.
+func (clock *Clock) advance(d time.Duration) {
+	clock.now = clock.now.Add(d)
+
+	remaining := clock.timers[:0]
+	for _, due := range clock.timers {
+		if due.at.After(clock.now) {
+			remaining = append(remaining, due)
+			continue
+		}
+
+		select {
+		case due.c <- clock.now:
+		default: // Drop the tick, as a time.Ticker would
+		}
+
+		if due.period > 0 {
+			for !due.at.After(clock.now) {
+				due.at = due.at.Add(due.period)
+			}
+			remaining = append(remaining, due)
+		}
+	}
+	clock.timers = remaining
+}
+
+// remove stops the timer provided from firing.
+func (clock *Clock) remove(stopped *timer) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	for index, existing := range clock.timers {
+		if existing == stopped {
+			clock.timers = append(clock.timers[:index], clock.timers[index+1:]...)
+			return
+		}
+	}
+}
+
+// ticker is the migrate.Ticker returned by Clock.NewTicker.
+type ticker struct {
+	clock *Clock
+	timer *timer
+}
+
+// C returns the channel on which the ticks are delivered.
+func (ticker *ticker) C() <-chan time.Time {
+	return ticker.timer.c
+}
+
+// Stop turns off the ticker.
+func (ticker *ticker) Stop() {
+	ticker.clock.remove(ticker.timer)
+}
@@ -0,0 +1,124 @@
+package fake
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octacian/migrate"
+)
+
+// TestClock ensures that durations and history timestamps are derived from
+// the Clock provided.
+func TestClock(t *testing.T) {
+	db, err := sql.Open("sqlite3", testDBPath)
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer os.Remove(testDBPath)
+	defer db.Close()
+
+	start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewClock(start).AutoAdvance(time.Second)
+	instance, err := migrate.NewInstance(db, "../testing/working", migrate.WithClock(clock))
+	if err != nil {
+		t.Fatal("migrate.NewInstance: got error:\n", err)
+	}
+	output := &strings.Builder{}
+	instance.Output = output
+
+	if err := instance.Latest(); err != nil {
+		t.Fatal("Instance.Latest: got error:\n", err)
+	}
+
+	if !strings.Contains(output.String(), "Successfully applied migrations in 4s") {
+		t.Errorf("Instance.Latest: expected duration of 4s in output, got:\n%s", output)
+	}
+	if duration := instance.Report().Duration; duration != 5*time.Second {
+		t.Errorf("Instance.Report: got duration %s expected 5s", duration)
+	}
+
+	history, err := instance.History()
+	if err != nil {
+		t.Fatal("Instance.History: got error:\n", err)
+	}
+	for key, entry := range history {
+		if expected := start.Add(time.Duration(key+2) * time.Second); !entry.AppliedAt.Equal(expected) {
+			t.Errorf("Instance.History: got time %s for entry %d expected %s", entry.AppliedAt, key, expected)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if now := clock.Now(); !now.Equal(start.Add(time.Hour + 7*time.Second)) {
+		t.Errorf("Clock.Now: got %s expected %s", now, start.Add(time.Hour+7*time.Second))
+	}
+}
+
+// TestClockTimers ensures that the timers and tickers of a Clock fire only as
+// it advances, and that they drive the polls of Instance.WaitForVersion.
+func TestClockTimers(t *testing.T) {
+	clock := NewClock(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+	after := clock.After(time.Minute)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(59 * time.Second)
+	select {
+	case <-after:
+		t.Error("Clock.After: fired before the Clock advanced by a minute")
+	default:
+	}
+	if len(ticker.C()) != 1 {
+		t.Errorf("Clock.NewTicker: got %d ticks pending expected 1, dropping the rest", len(ticker.C()))
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case <-after:
+	default:
+		t.Error("Clock.After: expected to fire once the Clock advanced by a minute")
+	}
+
+	<-ticker.C()
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	if len(ticker.C()) != 0 {
+		t.Error("Ticker.Stop: expected no ticks once stopped")
+	}
+
+	db, err := sql.Open("sqlite3", testDBPath)
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer os.Remove(testDBPath)
+	defer db.Close()
+
+	instance, err := migrate.NewInstance(db, "../testing/working", migrate.WithClock(clock))
+	if err != nil {
+		t.Fatal("migrate.NewInstance: got error:\n", err)
+	}
+	instance.Output = &strings.Builder{}
+
+	done := make(chan error)
+	go func() {
+		done <- instance.WaitForVersion(context.Background(), 1)
+	}()
+
+	// The first poll finds version 0, so the next is made only once the Clock advances
+	clock.BlockUntil(1)
+	if err := instance.Goto(1); err != nil {
+		t.Fatal("Instance.Goto: got error:\n", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatal("Instance.WaitForVersion: returned before the Clock advanced:\n", err)
+	default:
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Error("Instance.WaitForVersion: got error:\n", err)
+	}
+}
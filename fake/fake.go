@@ -0,0 +1,110 @@
+// Package fake provides an in-memory migrate.Executor which records the
+// statements it is given rather than executing them, and which may be
+// scripted to fail. It is intended for unit testing the ordering, rollback,
+// and error handling of migration runs with migrate.WithExecutor. A Clock is
+// also provided for deterministic timing with migrate.WithClock.
+package fake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/octacian/migrate"
+)
+
+// ErrScripted is returned by default for statements scripted to fail.
+var ErrScripted = errors.New("fake: scripted failure")
+
+// Executor records every statement passed to it and returns the failures
+// scripted with FailOn. An Executor is safe for concurrent use.
+type Executor struct {
+	mutex      sync.Mutex
+	statements []string
+	failures   map[int]error
+}
+
+// New returns an Executor which has recorded no statements and is scripted to
+// never fail.
+func New() *Executor {
+	return &Executor{failures: make(map[int]error)}
+}
+
+// FailOn scripts the nth statement executed, counting from 1, to fail with
+// err, or with ErrScripted if err is nil. The failing statement is recorded
+// like any other. FailOn returns the Executor to allow calls to be chained.
+func (executor *Executor) FailOn(n int, err error) *Executor {
+	if err == nil {
+		err = ErrScripted
+	}
+
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.failures[n] = err
+	return executor
+}
+
+// ExecContext implements the migrate.Executor interface for Executor,
+// recording the statement provided and returning any failure scripted for it.
+// If ctx is already done, its error is returned instead.
+func (executor *Executor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result,
+	error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.statements = append(executor.statements, query)
+	if err, ok := executor.failures[len(executor.statements)]; ok {
+		return nil, err
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+// Statements returns every statement executed so far, in order.
+func (executor *Executor) Statements() []string {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	return append([]string(nil), executor.statements...)
+}
+
+// Reset discards every statement recorded and every failure scripted.
+func (executor *Executor) Reset() {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.statements = nil
+	executor.failures = make(map[int]error)
+}
+
+// Expect compares the statements executed so far to those planned, such as by
+// migrate.Instance.Plan, returning an error describing the first difference.
+// Planned statements which are guarded by a skip-if guard query may be absent.
+func (executor *Executor) Expect(planned []migrate.PlannedStatement) error {
+	statements := executor.Statements()
+	index := 0
+	for _, statement := range planned {
+		if index < len(statements) && statements[index] == statement.SQL {
+			index++
+		} else if !statement.Guarded {
+			got := "nothing"
+			if index < len(statements) {
+				got = fmt.Sprintf("'%s'", statements[index])
+			}
+
+			return fmt.Errorf("fake: expected statement %d to be '%s' from part '%s' of version %d, got %s",
+				index+1, statement.SQL, statement.Part, statement.Version, got)
+		}
+	}
+
+	if index < len(statements) {
+		return fmt.Errorf("fake: got %d unexpected statement(s) beginning with '%s'", len(statements)-index,
+			statements[index])
+	}
+
+	return nil
+}
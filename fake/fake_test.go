@@ -0,0 +1,85 @@
+package fake
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/octacian/migrate"
+)
+
+const testDBPath = "./test.sqlite"
+
+// TestExecutor ensures that the statements of every part are passed to the
+// Executor in order and that a scripted failure rolls back the run.
+func TestExecutor(t *testing.T) {
+	db, err := sql.Open("sqlite3", testDBPath)
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer os.Remove(testDBPath)
+	defer db.Close()
+
+	executor := New()
+	instance, err := migrate.NewInstance(db, "../testing/working", migrate.WithExecutor(executor))
+	if err != nil {
+		t.Fatal("migrate.NewInstance: got error:\n", err)
+	}
+	instance.Output = &strings.Builder{}
+
+	if err := instance.Latest(); err != nil {
+		t.Fatal("Instance.Latest: got error:\n", err)
+	}
+
+	statements := executor.Statements()
+	if len(statements) != 4 || !strings.HasPrefix(statements[0], "CREATE TABLE") {
+		t.Fatalf("Executor.Statements: got '%#v' expected 4 statements beginning with CREATE TABLE", statements)
+	}
+
+	planned, err := instance.Plan(0)
+	if err != nil {
+		t.Fatal("Instance.Plan: got error:\n", err)
+	}
+	if err := executor.Expect(planned); err == nil {
+		t.Error("Executor.Expect: expected error with statements planned in the wrong direction")
+	}
+
+	// Nothing was applied to the database itself
+	if _, err := db.Exec(`SELECT * FROM test;`); err == nil {
+		t.Error("Instance.Latest: expected statements not to be applied to the database")
+	}
+
+	if err := instance.Goto(0); err != nil {
+		t.Fatal("Instance.Goto: got error:\n", err)
+	}
+	executor.Reset()
+	planned, err = instance.Plan(3)
+	if err != nil {
+		t.Fatal("Instance.Plan: got error:\n", err)
+	}
+	if err := instance.Latest(); err != nil {
+		t.Fatal("Instance.Latest: got error:\n", err)
+	}
+	if err := executor.Expect(planned); err != nil {
+		t.Error("Executor.Expect: got error:\n", err)
+	}
+
+	executor.Reset()
+	executor.FailOn(2, nil)
+	err = instance.Goto(0)
+	if applyErr, ok := err.(*migrate.ErrApply); !ok {
+		t.Fatalf("Instance.Goto: got '%v' expected *migrate.ErrApply", err)
+	} else if applyErr.Report.Outcome != migrate.RolledBack || !errors.Is(applyErr.Report.Failed[0].Err, ErrScripted) {
+		t.Errorf("Instance.Goto: got report '%s' expected scripted failure to be rolled back", applyErr.Report)
+	}
+
+	if len(executor.Statements()) != 2 {
+		t.Errorf("Executor.Statements: got %d statements expected 2", len(executor.Statements()))
+	}
+	if instance.Version() != 3 {
+		t.Errorf("Instance.Version: got %d expected 3 after rollback", instance.Version())
+	}
+}
@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Fingerprint returns a digest identifying the database produced by migrating
+// to the latest version of the migrations within root with the options
+// provided, such that two calls return the same digest only if they would
+// apply the same statements and record the same metadata. The digest covers
+// every part loaded, after rendering templates and selecting a scope, range,
+// and tags, along with the dialect, schema, roles, rewriting, and metadata
+// options in use. Like Inspect, Fingerprint needs no database, so it uses the
+// Generic dialect unless another is provided with WithDialect.
+//
+// WithSecrets, WithExecutor, and WithEncryption change what is applied or
+// recorded in ways known only once each part is applied, so an *ErrFatal is
+// returned if any of them is provided.
+func Fingerprint(root string, options ...Option) (string, error) {
+	instance, err := inspect(root, options...)
+	if err != nil {
+		return "", err
+	}
+
+	if instance.secrets != nil || instance.executor != nil || instance.encrypter != nil {
+		return "", NewFatalf("Fingerprint: WithSecrets, WithExecutor, and WithEncryption cannot be fingerprinted")
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "dialect=%s\nschema=%s\nscope=%s\nidempotent=%t\nroles=%v\ntags=%v\nexclude=%v\n",
+		instance.dialect.Name(), instance.schema, instance.scope, instance.idempotent, instance.roles,
+		instance.includeTags, instance.excludeTags)
+	fmt.Fprintf(hash, "key=%s\nlegacy=%v\nsql=%t,%t\nchecksum=%s\n", instance.versionKey, instance.legacyKeys,
+		instance.storeSQL, instance.compressSQL, instance.loader.algorithm.orDefault().Name)
+
+	versions := make([]int, 0, len(instance.migrations))
+	for version := range instance.migrations {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		for _, part := range instance.migrations[version].Parts {
+			fmt.Fprintf(hash, "%d/%s=%s\n", version, part.Name, part.RawChecksum)
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
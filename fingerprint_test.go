@@ -0,0 +1,34 @@
+package migrate
+
+import "testing"
+
+// TestFingerprint ensures that Fingerprint changes with the options which
+// change the migrations applied and refuses those it cannot fingerprint.
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(options ...Option) string {
+		digest, err := Fingerprint("testing/template", options...)
+		if err != nil {
+			t.Fatal("Fingerprint: got error:\n", err)
+		}
+		return digest
+	}
+
+	two := fingerprint(WithTemplateData(map[string]int{"Shards": 2}))
+	if again := fingerprint(WithTemplateData(map[string]int{"Shards": 2})); again != two {
+		t.Errorf("Fingerprint: got '%s' and '%s' expected the same digest for the same options", two, again)
+	}
+
+	for name, digest := range map[string]string{
+		"WithTemplateData": fingerprint(WithTemplateData(map[string]int{"Shards": 3})),
+		"WithSchema":       fingerprint(WithTemplateData(map[string]int{"Shards": 2}), WithSchema("app")),
+		"WithDialect":      fingerprint(WithTemplateData(map[string]int{"Shards": 2}), WithDialect(Postgres)),
+	} {
+		if digest == two {
+			t.Errorf("Fingerprint: got the same digest with %s expected another", name)
+		}
+	}
+
+	if _, err := Fingerprint("testing/meta", WithSecrets(EnvSecrets{})); err == nil {
+		t.Error("Fingerprint: expected error with WithSecrets")
+	}
+}
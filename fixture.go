@@ -0,0 +1,382 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// regexTable and regexColumn match the names of a table, optionally qualified
+// by its schema, and of a column into which fixtures and CSV files may be
+// loaded, such that they may be safely interpolated into statements.
+var (
+	regexTable  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+	regexColumn = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// fixtureRow is a single row of a table within a YAML fixture.
+type fixtureRow map[string]interface{}
+
+// fixture holds the contents of a single fixture file.
+type fixture struct {
+	Name   string
+	SQL    []Statement             // Statements of a SQL fixture
+	Tables []string                // Tables of a YAML fixture, in the order in which they appear
+	Rows   map[string][]fixtureRow // Rows of a YAML fixture, keyed by table
+}
+
+// LoadFixtures loads the fixtures named into the database, reading each from
+// the fixtures directory configured with WithFixtures. A fixture named
+// `users` is read from either `users.yml`, `users.yaml`, or `users.sql`.
+//
+// YAML fixtures map each table to a list of rows:
+//
+//	users:
+//	  - id: 1
+//	    name: "Jane Doe"
+//	posts:
+//	  - id: 1
+//	    user_id: 1
+//
+// Every table named within the YAML fixtures is first emptied, so that
+// LoadFixtures may be called at the start of each test case. Tables are
+// emptied and then filled in an order which respects the foreign keys between
+// them, where the Dialect is able to determine it. SQL fixtures are then
+// executed in the order named. Fixtures are loaded within a single
+// transaction, so either every fixture is loaded or none are.
+func (instance *Instance) LoadFixtures(names ...string) error {
+	if instance.readOnly {
+		return NewFatalf("Instance.LoadFixtures: instance is read-only")
+	} else if instance.fixtures == "" {
+		return NewFatalf("Instance.LoadFixtures: no fixtures directory configured, use WithFixtures")
+	}
+
+	fixtures := make([]*fixture, 0, len(names))
+	tables := make([]string, 0)
+	rows := make(map[string][]fixtureRow)
+	for _, name := range names {
+		fixture, err := readFixture(instance.fixtures, name)
+		if err != nil {
+			return err
+		}
+		fixtures = append(fixtures, fixture)
+
+		for _, table := range fixture.Tables {
+			if _, ok := rows[table]; !ok {
+				tables = append(tables, table)
+			}
+			rows[table] = append(rows[table], fixture.Rows[table]...)
+		}
+	}
+
+	tables, err := instance.orderTables(tables)
+	if err != nil {
+		return NewFatalf("Instance.LoadFixtures: got error while reading foreign keys:\n%s", err)
+	}
+
+	transaction, err := instance.begin()
+	if err != nil {
+		return NewFatalf("Instance.LoadFixtures: got error while starting a transaction:\n%s", err)
+	}
+
+	fail := func(format string, args ...interface{}) error {
+		transaction.Rollback()
+		return NewFatalf("Instance.LoadFixtures: "+format, args...)
+	}
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		if _, err := transaction.Exec(`DELETE FROM ` + tables[i] + `;`); err != nil {
+			return fail("got error while emptying table '%s':\n%s", tables[i], err)
+		}
+	}
+
+	for _, table := range tables {
+		for _, row := range rows[table] {
+			query, args, err := instance.insertRow(table, row)
+			if err != nil {
+				return fail("%s", err)
+			} else if _, err := transaction.Exec(query, args...); err != nil {
+				return fail("got error while inserting into table '%s':\n%s", table, err)
+			}
+		}
+	}
+
+	for _, fixture := range fixtures {
+		for index, statement := range fixture.SQL {
+			if _, err := transaction.Exec(statement.SQL); err != nil {
+				return fail("got error while loading fixture '%s':\n%s", fixture.Name, &ErrStatement{
+					Part: fixture.Name, Index: index, Line: statement.Line, Offset: driverOffset(err),
+					SQL: statement.SQL, Err: err})
+			}
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return NewFatalf("Instance.LoadFixtures: got error while committing transaction:\n%s", err)
+	}
+
+	return nil
+}
+
+// insertRow returns a statement and its arguments which insert row into the
+// table specified, with columns in alphabetical order, or an error if the
+// table or any column is not a valid identifier.
+func (instance *Instance) insertRow(table string, row fixtureRow) (string, []interface{}, error) {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	args := make([]interface{}, len(columns))
+	for i, column := range columns {
+		args[i] = row[column]
+	}
+
+	query, err := instance.insertQuery(table, columns)
+	return query, args, err
+}
+
+// insertQuery returns a statement which inserts a single row into the table
+// specified, with a placeholder for the value of each column in the order
+// provided. An error is returned if the table is not matched by regexTable or
+// any column by regexColumn, as each is interpolated into the statement.
+func (instance *Instance) insertQuery(table string, columns []string) (string, error) {
+	if !regexTable.MatchString(table) {
+		return "", fmt.Errorf("migrate: invalid table name '%s'", table)
+	}
+	for _, column := range columns {
+		if !regexColumn.MatchString(column) {
+			return "", fmt.Errorf("migrate: invalid column name '%s' for table '%s'", column, table)
+		}
+	}
+
+	numbered := false
+	if dialect, ok := instance.dialect.(*dialect); ok {
+		numbered = dialect.numbered
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = "?"
+		if numbered {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", table, strings.Join(columns, ", "),
+		strings.Join(placeholders, ", ")), nil
+}
+
+// orderTables sorts the tables provided so that every table follows those it
+// references with a foreign key, otherwise preserving their order. If the
+// Dialect cannot list foreign keys, the tables are returned unchanged.
+func (instance *Instance) orderTables(tables []string) ([]string, error) {
+	dialect, ok := instance.dialect.(*dialect)
+	if !ok || dialect.references == "" {
+		return tables, nil
+	}
+
+	references := make(map[string][]string)
+	for _, table := range tables {
+		rows, err := instance.db.Query(dialect.references, table)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var referenced string
+			if err := rows.Scan(&referenced); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			references[table] = append(references[table], referenced)
+		}
+
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	loading := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		loading[table] = true
+	}
+
+	ordered := make([]string, 0, len(tables))
+	visited := make(map[string]bool)
+	var visit func(table string)
+	visit = func(table string) {
+		if visited[table] {
+			return // Already ordered, or a cycle which cannot be resolved
+		}
+		visited[table] = true
+
+		for _, referenced := range references[table] {
+			if loading[referenced] {
+				visit(referenced)
+			}
+		}
+		ordered = append(ordered, table)
+	}
+
+	for _, table := range tables {
+		visit(table)
+	}
+
+	return ordered, nil
+}
+
+// readFixture reads the fixture named from the directory provided.
+func readFixture(directory, name string) (*fixture, error) {
+	for _, extension := range []string{".yml", ".yaml", ".sql"} {
+		path := filepath.Join(directory, name+extension)
+		contents, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, NewFatalf("Instance.LoadFixtures: got error while reading fixture '%s':\n%s", name, err)
+		}
+
+		if extension == ".sql" {
+			lines := make([]sourceLine, 0)
+			scanner := bufio.NewScanner(bytes.NewReader(contents))
+			for number := 1; scanner.Scan(); number++ {
+				if text := strings.TrimSpace(scanner.Text()); text != "" {
+					lines = append(lines, sourceLine{number, text})
+				}
+			}
+
+			return &fixture{Name: name, SQL: splitStatements(lines)}, scanner.Err()
+		}
+
+		fixture, err := parseYAMLFixture(name, contents)
+		if err != nil {
+			return nil, NewFatalf("Instance.LoadFixtures: got error while parsing fixture '%s':\n%s", path, err)
+		}
+
+		return fixture, nil
+	}
+
+	return nil, NewFatalf("Instance.LoadFixtures: no fixture named '%s' found in '%s'", name, directory)
+}
+
+// parseYAMLFixture parses the subset of YAML used by fixtures, in which each
+// top-level key names a table holding a list of rows, and each row maps
+// column names to scalar values. As table and column names are interpolated
+// into the statements which empty and fill each table, an error is returned
+// for any table not matched by regexTable or column not matched by
+// regexColumn, before any statement is executed.
+func parseYAMLFixture(name string, contents []byte) (*fixture, error) {
+	fixture := &fixture{Name: name, Rows: make(map[string][]fixtureRow)}
+	table := ""
+	var row fixtureRow
+	rowIndent := -1
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+
+		switch {
+		case indent == 0: // a new table
+			if !strings.HasSuffix(trimmed, ":") {
+				return nil, fmt.Errorf("migrate: expected table name on line %d, got '%s'", number, trimmed)
+			}
+
+			table = strings.TrimSpace(strings.TrimSuffix(trimmed, ":"))
+			if !regexTable.MatchString(table) {
+				return nil, fmt.Errorf("migrate: invalid table name '%s' on line %d", table, number)
+			} else if _, ok := fixture.Rows[table]; ok {
+				return nil, fmt.Errorf("migrate: table '%s' appears more than once, on line %d", table, number)
+			}
+			fixture.Tables = append(fixture.Tables, table)
+			fixture.Rows[table] = make([]fixtureRow, 0)
+			row = nil
+		case table == "":
+			return nil, fmt.Errorf("migrate: expected table name on line %d, got '%s'", number, trimmed)
+		case strings.HasPrefix(trimmed, "- ") || trimmed == "-": // a new row
+			row = make(fixtureRow)
+			fixture.Rows[table] = append(fixture.Rows[table], row)
+
+			rest := strings.TrimLeft(trimmed[1:], " ")
+			if rest == "" {
+				rowIndent = -1 // Columns begin on the following line
+				continue
+			}
+
+			trimmed, indent, rowIndent = rest, len(line)-len(rest), len(line)-len(rest)
+			fallthrough
+		default: // a column of the current row
+			if row == nil {
+				return nil, fmt.Errorf("migrate: expected row beginning with '-' on line %d", number)
+			} else if rowIndent < 0 {
+				rowIndent = indent
+			} else if indent != rowIndent {
+				return nil, fmt.Errorf("migrate: inconsistent indentation on line %d", number)
+			}
+
+			colon := strings.Index(trimmed, ":")
+			if colon <= 0 {
+				return nil, fmt.Errorf("migrate: expected 'column: value' on line %d, got '%s'", number, trimmed)
+			}
+
+			column := strings.TrimSpace(trimmed[:colon])
+			if !regexColumn.MatchString(column) {
+				return nil, fmt.Errorf("migrate: invalid column name '%s' for table '%s' on line %d", column,
+					table, number)
+			}
+
+			value, err := parseYAMLScalar(strings.TrimSpace(trimmed[colon+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("migrate: got invalid value for column '%s' on line %d:\n%s", column,
+					number, err)
+			}
+			row[column] = value
+		}
+	}
+
+	return fixture, scanner.Err()
+}
+
+// parseYAMLScalar parses a single YAML scalar value.
+func parseYAMLScalar(value string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return nil, fmt.Errorf("migrate: unterminated quoted value %s", value)
+		}
+		return strings.Replace(value[1:len(value)-1], "''", "'", -1), nil
+	case value == "" || value == "~" || value == "null":
+		return nil, nil
+	case value == "true" || value == "false":
+		return value == "true", nil
+	}
+
+	if integer, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return integer, nil
+	} else if float, err := strconv.ParseFloat(value, 64); err == nil {
+		return float, nil
+	}
+
+	// Strip any trailing comment from an unquoted value
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		return parseYAMLScalar(strings.TrimSpace(value[:comment]))
+	}
+
+	return value, nil
+}
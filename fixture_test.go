@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestLoadFixtures ensures that YAML and SQL fixtures are loaded in an order
+// which respects foreign keys, and that loading them again replaces the rows
+// previously loaded.
+func TestLoadFixtures(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if _, err := db.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+		db.SetMaxOpenConns(1) // Foreign keys are enabled per connection
+
+		instance, err := NewInstance(db, "testing/fixtures/schema", WithFixtures("testing/fixtures/data"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		for i := 0; i < 2; i++ {
+			if err := instance.LoadFixtures("blog", "extra"); err != nil {
+				t.Fatal("Instance.LoadFixtures: got error:\n", err)
+			}
+		}
+
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM posts;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if count != 3 {
+			t.Errorf("Instance.LoadFixtures: got %d posts expected 3", count)
+		}
+
+		var name, title string
+		var admin bool
+		if err := db.QueryRow(`SELECT Name, Admin FROM users WHERE ID = 1;`).Scan(&name, &admin); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if name != "Jane O'Neil" || !admin {
+			t.Errorf("Instance.LoadFixtures: got user '%s' (admin %t) expected 'Jane O'Neil' (admin true)", name,
+				admin)
+		}
+		if err := db.QueryRow(`SELECT Admin FROM users WHERE ID = 2;`).Scan(&admin); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if admin {
+			t.Error("Instance.LoadFixtures: expected SQL fixture to update user 2")
+		}
+		if err := db.QueryRow(`SELECT Title FROM posts WHERE ID = 2;`).Scan(&title); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if title != "It's here" {
+			t.Errorf("Instance.LoadFixtures: got title '%s' expected \"It's here\"", title)
+		}
+
+		expectError(t, "Instance.LoadFixtures", "missing fixture", func() error {
+			return instance.LoadFixtures("nothing")
+		}, "no fixture named 'nothing'")
+		expectError(t, "Instance.LoadFixtures", "malformed fixture", func() error {
+			return instance.LoadFixtures("broken")
+		}, "expected row beginning with '-' on line 2")
+		expectError(t, "Instance.LoadFixtures", "invalid table name", func() error {
+			return instance.LoadFixtures("blog", "hostile")
+		}, "invalid table name 'posts; DROP TABLE users; --' on line 1")
+		expectError(t, "Instance.LoadFixtures", "invalid column name", func() error {
+			return instance.LoadFixtures("columns")
+		}, "invalid column name 'name) VALUES (3); DROP TABLE users; --' for table 'users' on line 3")
+
+		if err := db.QueryRow(`SELECT COUNT(*) FROM users;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error after invalid fixtures:\n", err)
+		} else if count != 2 {
+			t.Errorf("Instance.LoadFixtures: got %d users after invalid fixtures expected 2", count)
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/fixtures/schema")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		expectError(t, "Instance.LoadFixtures", "no fixtures directory", func() error {
+			return instance.LoadFixtures("blog")
+		}, "use WithFixtures")
+	})
+}
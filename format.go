@@ -0,0 +1,191 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Format identifies an encoding in which WriteStatus and WriteHistory write
+// their output.
+type Format string
+
+// The formats available to WriteStatus and WriteHistory.
+const (
+	FormatTable Format = "table" // Aligned columns, for humans
+	FormatJSON  Format = "json"
+	FormatYAML  Format = "yaml"
+)
+
+// ParseFormat returns the Format named, such as the value of a `--format`
+// flag, or an error if it is unknown.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatTable, FormatJSON, FormatYAML:
+		return format, nil
+	}
+
+	return "", NewFatalf("ParseFormat: got unknown format '%s', expected table, json, or yaml", name)
+}
+
+// WriteStatus writes the status of every available migration, as returned by
+// Status, to w in the format provided, so that deployment scripts may consume
+// it without parsing text intended for humans.
+func (instance *Instance) WriteStatus(w io.Writer, format Format) error {
+	statuses := instance.Status()
+	if format != FormatTable {
+		return writeFormatted(w, format, statuses, "Instance.WriteStatus")
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tNAME\tAPPLIED\tPARTS")
+	for _, status := range statuses {
+		parts := make([]string, 0, len(status.Parts))
+		for _, part := range status.Parts {
+			if part.State == PartNone || part.State == PartApplied {
+				parts = append(parts, part.Name)
+			} else {
+				parts = append(parts, fmt.Sprintf("%s (%s)", part.Name, part.State))
+			}
+		}
+
+		fmt.Fprintf(table, "%d\t%s\t%t\t%s\n", status.Version, status.Name, status.Applied,
+			strings.Join(parts, ", "))
+	}
+
+	if err := table.Flush(); err != nil {
+		return NewFatalf("Instance.WriteStatus: got error while writing table:\n%s", err)
+	}
+
+	return nil
+}
+
+// WriteHistory writes every entry recorded in the history, as returned by
+// History, to w in the format provided.
+func (instance *Instance) WriteHistory(w io.Writer, format Format) error {
+	history, err := instance.History()
+	if err != nil {
+		return err
+	}
+
+	if format != FormatTable {
+		return writeFormatted(w, format, history, "Instance.WriteHistory")
+	}
+
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "APPLIED AT\tVERSION\tPART\tDIRECTION\tACTOR\tREASON")
+	for _, entry := range history {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\n", entry.AppliedAt.Format(time.RFC3339), entry.Version,
+			entry.Part, entry.Direction, entry.Actor, entry.Reason)
+	}
+
+	if err := table.Flush(); err != nil {
+		return NewFatalf("Instance.WriteHistory: got error while writing table:\n%s", err)
+	}
+
+	return nil
+}
+
+// writeFormatted writes value to w as JSON or YAML, naming the function from
+// which it was called in any error.
+func writeFormatted(w io.Writer, format Format, value interface{}, caller string) error {
+	var err error
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(value)
+	case FormatYAML:
+		var builder strings.Builder
+		writeYAML(&builder, reflect.ValueOf(value), "")
+		_, err = io.WriteString(w, builder.String())
+	default:
+		return NewFatalf("%s: got unknown format '%s'", caller, format)
+	}
+
+	if err != nil {
+		return NewFatalf("%s: got error while writing %s:\n%s", caller, format, err)
+	}
+
+	return nil
+}
+
+// writeYAML writes value to builder as YAML, indented by indent. Structs are
+// written with the keys of their JSON tags in the order of their fields, so
+// that the YAML and JSON output hold the same keys. Only the kinds of value
+// held by MigrationStatus and HistoryEntry are supported.
+func writeYAML(builder *strings.Builder, value reflect.Value, indent string) {
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.Len() == 0 {
+			builder.WriteString(indent + "[]\n")
+			return
+		}
+
+		for i := 0; i < value.Len(); i++ {
+			// Write the first line of each item after the dash, and the remainder beneath it
+			var item strings.Builder
+			writeYAML(&item, value.Index(i), indent+"  ")
+			builder.WriteString(indent + "- " + strings.TrimPrefix(item.String(), indent+"  "))
+		}
+	case reflect.Struct:
+		valueType := value.Type()
+		for i := 0; i < valueType.NumField(); i++ {
+			field := valueType.Field(i)
+			key := strings.Split(field.Tag.Get("json"), ",")[0]
+			if key == "-" || field.PkgPath != "" {
+				continue
+			} else if key == "" {
+				key = field.Name
+			}
+
+			writeYAMLField(builder, key, value.Field(i), indent)
+		}
+	case reflect.Map:
+		keys := make([]string, 0, value.Len())
+		for _, key := range value.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			writeYAMLField(builder, strconv.Quote(key), value.MapIndex(reflect.ValueOf(key)), indent)
+		}
+	}
+}
+
+// writeYAMLField writes a single key and its value to builder as YAML,
+// indented by indent.
+func writeYAMLField(builder *strings.Builder, key string, value reflect.Value, indent string) {
+	if t, ok := value.Interface().(time.Time); ok {
+		builder.WriteString(indent + key + ": " + t.Format(time.RFC3339Nano) + "\n")
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		builder.WriteString(indent + key + ": " + strconv.Quote(value.String()) + "\n")
+	case reflect.Int:
+		builder.WriteString(indent + key + ": " + strconv.FormatInt(value.Int(), 10) + "\n")
+	case reflect.Bool:
+		builder.WriteString(indent + key + ": " + strconv.FormatBool(value.Bool()) + "\n")
+	case reflect.Slice, reflect.Map:
+		if value.Len() == 0 {
+			empty := "[]"
+			if value.Kind() == reflect.Map {
+				empty = "{}"
+			}
+			builder.WriteString(indent + key + ": " + empty + "\n")
+			return
+		}
+
+		builder.WriteString(indent + key + ":\n")
+		writeYAML(builder, value, indent+"  ")
+	}
+}
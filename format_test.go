@@ -0,0 +1,134 @@
+package migrate
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteStatus ensures that the status of every migration is written in
+// each format.
+func TestWriteStatus(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		var table strings.Builder
+		if err := instance.WriteStatus(&table, FormatTable); err != nil {
+			t.Fatal("Instance.WriteStatus: got error:\n", err)
+		}
+		expected := "VERSION  NAME       APPLIED  PARTS\n" +
+			"1        version_1  true     billing.sql\n" +
+			"2        version_2  false    invoices.sql\n"
+		if table.String() != expected {
+			t.Errorf("Instance.WriteStatus: got table:\n%s\nexpected:\n%s", table.String(), expected)
+		}
+
+		var encoded strings.Builder
+		if err := instance.WriteStatus(&encoded, FormatJSON); err != nil {
+			t.Fatal("Instance.WriteStatus: got error:\n", err)
+		}
+		var decoded []MigrationStatus
+		if err := json.Unmarshal([]byte(encoded.String()), &decoded); err != nil {
+			t.Fatal("json.Unmarshal: got error:\n", err)
+		} else if len(decoded) != 2 || !decoded[0].Applied || decoded[0].Parts[0].Meta["ticket"] != "PROJ-123" {
+			t.Errorf("Instance.WriteStatus: got JSON '%#v' expected status of both versions", decoded)
+		}
+		if !strings.Contains(encoded.String(), `"applied": true`) {
+			t.Errorf("Instance.WriteStatus: expected snake case keys in JSON, got:\n%s", encoded.String())
+		}
+
+		var yaml strings.Builder
+		if err := instance.WriteStatus(&yaml, FormatYAML); err != nil {
+			t.Fatal("Instance.WriteStatus: got error:\n", err)
+		}
+		expected = `- version: 1
+  name: "version_1"
+  applied: true
+  parts:
+    - name: "billing.sql"
+      meta:
+        "author": "jane"
+        "description": "add billing tables"
+        "ticket": "PROJ-123"
+      state: "applied"
+- version: 2
+  name: "version_2"
+  applied: false
+  parts:
+    - name: "invoices.sql"
+      meta: {}
+      state: ""
+`
+		if yaml.String() != expected {
+			t.Errorf("Instance.WriteStatus: got YAML:\n%s\nexpected:\n%s", yaml.String(), expected)
+		}
+	})
+}
+
+// TestWriteHistory ensures that the history is written in each format.
+func TestWriteHistory(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		instance, err := NewInstance(db, "testing/meta", WithClock(fixedClock(start)), WithActor("jane"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		var table strings.Builder
+		if err := instance.WriteHistory(&table, FormatTable); err != nil {
+			t.Fatal("Instance.WriteHistory: got error:\n", err)
+		}
+		appliedAt := start.Local().Format(time.RFC3339)
+		if lines := strings.Split(table.String(), "\n"); len(lines) != 3 ||
+			!strings.HasPrefix(lines[1], appliedAt+"  1        billing.sql  up         jane") {
+			t.Errorf("Instance.WriteHistory: got table:\n%s", table.String())
+		}
+
+		var yaml strings.Builder
+		if err := instance.WriteHistory(&yaml, FormatYAML); err != nil {
+			t.Fatal("Instance.WriteHistory: got error:\n", err)
+		}
+		if !strings.Contains(yaml.String(), "- version: 1\n  part: \"billing.sql\"\n  direction: \"up\"\n") ||
+			!strings.Contains(yaml.String(), "  actor: \"jane\"\n  reason: \"\"\n") {
+			t.Errorf("Instance.WriteHistory: got YAML:\n%s", yaml.String())
+		}
+	})
+
+	if format, err := ParseFormat("JSON"); err != nil || format != FormatJSON {
+		t.Errorf("ParseFormat: got '%s' and error '%v' expected 'json'", format, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat: expected error for unknown format")
+	}
+}
+
+// fixedClock is a Clock which always reads the same time, while its timers
+// run in real time.
+type fixedClock time.Time
+
+func (clock fixedClock) Now() time.Time {
+	return time.Time(clock)
+}
+
+func (clock fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (clock fixedClock) NewTicker(d time.Duration) Ticker {
+	return systemClock{}.NewTicker(d)
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"text/template"
+)
+
+// Version identifies a set of migrations by its latest version and the
+// checksum of every migration within it, allowing code to be pinned to the
+// migrations it was built against with Generate and AssertCodeMatches.
+type Version struct {
+	Latest   int
+	Checksum string // Hex-encoded digest of the checksum of every migration
+}
+
+// generated is the template from which the Go file written by Generate is
+// rendered.
+var generated = template.Must(template.New("generated").Parse(`// Code generated by migrate.Generate; DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/octacian/migrate"
+
+const (
+	// MigrationsLatest is the latest migration version this package was built against.
+	MigrationsLatest = {{.Version.Latest}}
+	// MigrationsChecksum is the checksum of the migrations this package was built against.
+	MigrationsChecksum = "{{.Version.Checksum}}"
+)
+
+// Migrations identifies the migrations this package was built against, to be
+// passed to Instance.AssertCodeMatches at startup.
+var Migrations = migrate.Version{Latest: MigrationsLatest, Checksum: MigrationsChecksum}
+`))
+
+// Generate loads the migrations within root, applying any options provided,
+// and writes a Go file to path within the package named pkg, declaring the
+// latest migration version and the checksum of the migrations. Generate is
+// intended to be run with `go generate` in the package which applies the
+// migrations, for example:
+//
+//	//go:generate go run ./cmd/generate-migrations
+//
+// where the command calls Generate. The Migrations variable declared in the
+// generated file should then be passed to Instance.AssertCodeMatches at
+// startup, detecting a binary built against a different set of migrations.
+func Generate(root, path, pkg string, options ...Option) error {
+	instance, err := inspect(root, options...)
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	if err := generated.Execute(&buffer, struct {
+		Package string
+		Version Version
+	}{pkg, instance.CodeVersion()}); err != nil {
+		return NewFatalf("Generate: got error while rendering Go file:\n%s", err)
+	}
+
+	source, err := format.Source(buffer.Bytes())
+	if err != nil {
+		return NewFatalf("Generate: got error while formatting Go file, is '%s' a valid package name?\n%s", pkg,
+			err)
+	}
+
+	if err := ioutil.WriteFile(path, source, 0644); err != nil {
+		return NewFatalf("Generate: got error while writing Go file:\n%s", err)
+	}
+
+	return nil
+}
+
+// CodeVersion returns the Version identifying the migrations held by the
+// Instance. As the checksum is derived from the Checksum of each part,
+// reformatting a part does not alter it.
+func (instance *Instance) CodeVersion() Version {
+	hash := instance.loader.algorithm.orDefault().New()
+	versions := instance.List()
+	for _, version := range versions {
+		fmt.Fprintf(hash, "%d %s\n", version, instance.migrations[version].Checksum())
+	}
+
+	return Version{Latest: instance.latest(), Checksum: hex.EncodeToString(hash.Sum(nil))}
+}
+
+// AssertCodeMatches returns an *ErrCodeMismatch if the migrations held by the
+// Instance differ from those identified by generated, typically the
+// Migrations variable written by Generate. It is intended to be called at
+// startup, detecting a binary built against a different set of migrations
+// than those it is about to apply.
+func (instance *Instance) AssertCodeMatches(generated Version) error {
+	if actual := instance.CodeVersion(); actual != generated {
+		return &ErrCodeMismatch{Generated: generated, Actual: actual}
+	}
+
+	return nil
+}
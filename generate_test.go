@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "migrations.go")
+	if err := Generate("testing/meta", path, "app"); err != nil {
+		t.Fatal("Generate: got error:\n", err)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal("ioutil.ReadFile: got error:\n", err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), path, contents, 0)
+	if err != nil {
+		t.Fatalf("parser.ParseFile: got error parsing generated file:\n%s\n%s", err, contents)
+	} else if file.Name.Name != "app" {
+		t.Errorf("Generate: got package '%s' expected 'app'", file.Name.Name)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		version := instance.CodeVersion()
+		if version.Latest != 2 || len(version.Checksum) != 64 {
+			t.Errorf("Instance.CodeVersion: got '%#v' expected latest version 2 and a checksum", version)
+		}
+
+		for _, expected := range []string{"MigrationsLatest = 2", `"` + version.Checksum + `"`} {
+			if !strings.Contains(string(contents), expected) {
+				t.Errorf("Generate: expected '%s' in generated file, got:\n%s", expected, contents)
+			}
+		}
+
+		if err := instance.AssertCodeMatches(version); err != nil {
+			t.Error("Instance.AssertCodeMatches: got error:\n", err)
+		}
+
+		err = instance.AssertCodeMatches(Version{Latest: 1, Checksum: version.Checksum})
+		if !errors.Is(err, CodeCodeMismatch) {
+			t.Errorf("Instance.AssertCodeMatches: expected code mismatch, got:\n%v", err)
+		}
+	})
+
+	expectError(t, "Generate", "an invalid package name", func() error {
+		return Generate("testing/meta", path, "not a package")
+	}, "valid package name")
+}
@@ -1,8 +1,10 @@
 module github.com/octacian/migrate
 
-go 1.12
+go 1.14
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.10.0
-	github.com/octacian/metadb v1.0.0
 )
@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Grants may be implemented by a Dialect to return the statements which grant
+// privileges on an object to a role and which change the owner of an object,
+// as used by the `-- @migrate/grant` and `-- @migrate/owner` directives. The
+// kind of object is TABLE, VIEW, MATERIALIZED VIEW, or SEQUENCE, or empty if
+// object was named by a directive and so may itself begin with its kind. An
+// empty statement is returned if the database has no such statement, in
+// which case the directive is ignored.
+type Grants interface {
+	Grant(privileges, kind, object, role string) string
+	Owner(kind, object, role string) string
+}
+
+// Grant describes privileges granted to a role with `-- @migrate/grant`.
+type Grant struct {
+	Privileges string // Privileges granted, such as `SELECT, INSERT`
+	Object     string // Object on which they are granted, or empty for every object created by the part
+	Role       string // Role to which they are granted, before mapping with WithRoles
+	Line       int    // Line of the part file on which the directive appears
+}
+
+// Grant implements the Grants interface for dialect.
+func (dialect *dialect) Grant(privileges, kind, object, role string) string {
+	if !dialect.grants {
+		return ""
+	}
+
+	// Views are granted privileges as tables
+	switch {
+	case !dialect.grantKinds || kind == "":
+		kind = ""
+	case kind != "SEQUENCE":
+		kind = "TABLE "
+	default:
+		kind += " "
+	}
+
+	return "GRANT " + privileges + " ON " + kind + object + " TO " + role + ";"
+}
+
+// Owner implements the Grants interface for dialect.
+func (dialect *dialect) Owner(kind, object, role string) string {
+	if !dialect.owners {
+		return ""
+	}
+
+	return "ALTER " + kind + " " + object + " OWNER TO " + role + ";"
+}
+
+// regexGrant matches the argument of a `-- @migrate/grant` directive,
+// capturing the privileges, the object if any, and the role.
+var regexGrant = regexp.MustCompile(`(?i)^(.+?)(?:\s+ON\s+(.+?))?\s+TO\s+(\S+)$`)
+
+// parseGrant adds the grant provided with `-- @migrate/grant` to part.
+func parseGrant(part *Part, argument string, line int) error {
+	matches := regexGrant.FindStringSubmatch(argument)
+	if matches == nil {
+		return fmt.Errorf("migrate: expected '<privileges> [ON <object>] TO <role>', got '%s'", argument)
+	}
+
+	part.Grants = append(part.Grants, Grant{Privileges: matches[1], Object: matches[2], Role: matches[3],
+		Line: line})
+	return nil
+}
+
+// regexCreated matches a statement which creates an object which may be
+// granted privileges or owned, capturing its kind and name.
+var regexCreated = regexp.MustCompile(`(?i)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY|UNLOGGED)\s+)?` +
+	`(TABLE|VIEW|MATERIALIZED\s+VIEW|SEQUENCE)\s+(?:IF\s+NOT\s+EXISTS\s+)?([^\s(;]+)`)
+
+// createdObject is an object created by a part.
+type createdObject struct {
+	kind, name string
+}
+
+// createdObjects returns the objects created by statements, in the order in
+// which they are created.
+func createdObjects(statements []Statement) []createdObject {
+	objects := make([]createdObject, 0)
+	for _, statement := range statements {
+		_, body := splitLeadingComments(statement.SQL)
+		if matches := regexCreated.FindStringSubmatch(body); matches != nil {
+			kind := strings.Join(strings.Fields(strings.ToUpper(matches[1])), " ")
+			objects = append(objects, createdObject{kind: kind, name: matches[2]})
+		}
+	}
+
+	return objects
+}
+
+// role returns the name of the role provided within the environment of the
+// Instance, as mapped with WithRoles.
+func (instance *Instance) role(name string) string {
+	if mapped, ok := instance.roles[name]; ok {
+		return mapped
+	}
+
+	return name
+}
+
+// grantStatements returns the statements which apply the owner and grants of
+// part to the objects which it creates, or to those which its grants name,
+// with the Grants of the Dialect. No statements are returned if the Dialect
+// does not implement Grants.
+func (instance *Instance) grantStatements(part *Part) []Statement {
+	dialect, ok := instance.dialect.(Grants)
+	if !ok || part.Owner == "" && len(part.Grants) == 0 {
+		return nil
+	}
+
+	statements := make([]Statement, 0)
+	add := func(sql string, line int) {
+		if sql != "" {
+			statements = append(statements, Statement{SQL: sql, Line: line})
+		}
+	}
+
+	objects := createdObjects(part.UpStatements)
+	if part.Owner != "" {
+		for _, object := range objects {
+			add(dialect.Owner(object.kind, object.name, instance.role(part.Owner)), part.ownerLine)
+		}
+	}
+
+	for _, grant := range part.Grants {
+		if grant.Object != "" {
+			add(dialect.Grant(grant.Privileges, "", grant.Object, instance.role(grant.Role)), grant.Line)
+			continue
+		}
+
+		for _, object := range objects {
+			add(dialect.Grant(grant.Privileges, object.kind, object.name, instance.role(grant.Role)), grant.Line)
+		}
+	}
+
+	return statements
+}
@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// grantDialect is a Dialect which records grants and owners in the grants
+// table rather than applying them.
+type grantDialect struct {
+	Dialect
+}
+
+// Grant implements the Grants interface for grantDialect.
+func (grantDialect) Grant(privileges, kind, object, role string) string {
+	return "INSERT INTO grants (Statement) VALUES ('" + privileges + " " + kind + " " + object + " " + role + "');"
+}
+
+// Owner implements the Grants interface for grantDialect.
+func (grantDialect) Owner(kind, object, role string) string {
+	return "INSERT INTO grants (Statement) VALUES ('OWNER " + kind + " " + object + " " + role + "');"
+}
+
+// grantPart is a part which creates objects and grants privileges on them.
+const grantPart = "-- @migrate/owner app\n" +
+	"-- @migrate/grant SELECT TO readonly\n" +
+	"-- @migrate/grant USAGE ON SCHEMA reporting TO readonly\n" +
+	"-- @migrate/up\n" +
+	"CREATE TABLE grants(Statement VARCHAR(255));\n" +
+	"CREATE SEQUENCE IF NOT EXISTS invoice_numbers;\n" +
+	"-- @migrate/down\n" +
+	"DROP TABLE grants;\n"
+
+// TestGrants ensures that the owner and grants of a part are applied to the
+// objects which it creates with the statements of the Dialect, mapping roles
+// with WithRoles.
+func TestGrants(t *testing.T) {
+	part, err := parsePart("grants.sql", []byte(grantPart))
+	if err != nil {
+		t.Fatal("parsePart: got error:\n", err)
+	}
+
+	expected := []Grant{{Privileges: "SELECT", Role: "readonly", Line: 2},
+		{Privileges: "USAGE", Object: "SCHEMA reporting", Role: "readonly", Line: 3}}
+	if !reflect.DeepEqual(part.Grants, expected) || part.Owner != "app" {
+		t.Errorf("parsePart: got grants '%#v' and owner '%s' expected '%#v' and app", part.Grants, part.Owner,
+			expected)
+	}
+
+	cases := []struct {
+		dialect  Dialect
+		expected []string
+	}{
+		{Postgres, []string{"ALTER TABLE grants OWNER TO app_staging;",
+			"ALTER SEQUENCE invoice_numbers OWNER TO app_staging;",
+			"GRANT SELECT ON TABLE grants TO readonly;", "GRANT SELECT ON SEQUENCE invoice_numbers TO readonly;",
+			"GRANT USAGE ON SCHEMA reporting TO readonly;"}},
+		{MySQL, []string{"GRANT SELECT ON grants TO readonly;", "GRANT SELECT ON invoice_numbers TO readonly;",
+			"GRANT USAGE ON SCHEMA reporting TO readonly;"}},
+		{SQLite, []string{}},
+	}
+	for _, c := range cases {
+		instance := &Instance{dialect: c.dialect, roles: map[string]string{"app": "app_staging"}}
+		statements := make([]string, 0)
+		for _, statement := range instance.grantStatements(part) {
+			statements = append(statements, statement.SQL)
+		}
+
+		if !reflect.DeepEqual(statements, c.expected) {
+			t.Errorf("Instance.grantStatements: got %q for %s expected %q", statements, c.dialect.Name(),
+				c.expected)
+		}
+	}
+
+	root := CopyTree(t, "testing/meta")
+	contents := strings.Replace(grantPart, "CREATE SEQUENCE IF NOT EXISTS invoice_numbers;",
+		"CREATE VIEW recent AS SELECT * FROM grants;", 1)
+	if err := ioutil.WriteFile(filepath.Join(root, "version_1", "grants.sql"), []byte(contents), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithDialect(grantDialect{SQLite}),
+			WithRoles(map[string]string{"readonly": "reporting"}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		var recorded []string
+		if err := query(db, `SELECT Statement FROM grants ORDER BY rowid;`, nil, func(rows *sql.Rows) error {
+			var statement string
+			err := rows.Scan(&statement)
+			recorded = append(recorded, statement)
+			return err
+		}); err != nil {
+			t.Fatal("query: got error:\n", err)
+		}
+
+		expected := []string{"OWNER TABLE grants app", "OWNER VIEW recent app", "SELECT TABLE grants reporting",
+			"SELECT VIEW recent reporting", "USAGE  SCHEMA reporting reporting"}
+		if !reflect.DeepEqual(recorded, expected) {
+			t.Errorf("Instance.Goto: got grants %q expected %q", recorded, expected)
+		}
+	})
+
+	malformed := map[string]string{
+		"malformed grant": "-- @migrate/grant SELECT readonly\n",
+		"no object":       "-- @migrate/grant SELECT TO readonly\n",
+		"owner of none":   "-- @migrate/owner app\n",
+	}
+	for name, directive := range malformed {
+		expectError(t, "parsePart", name, func() error {
+			_, err := parsePart("grants.sql", []byte(directive+"-- @migrate/up\nSELECT 1;\n"+
+				"-- @migrate/down\nSELECT 1;\n"))
+			return err
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"sync"
+	"time"
+)
+
+// Heartbeat is emitted periodically while a single migration part is being
+// applied, allowing callers to distinguish a long-running migration from one
+// which has hung.
+type Heartbeat struct {
+	Version int           // Version of the migration being applied
+	Part    string        // Name of the part being applied
+	Elapsed time.Duration // Time elapsed since the part began applying
+	Time    time.Time     // Time at which the heartbeat was emitted
+
+	// Err holds any error which occurred while refreshing the migration lock
+	// or recording the heartbeat in the metadata table. SQLite does not allow
+	// either while a transaction is writing to the database, so neither is
+	// attempted while a part is applied within a transaction on SQLite, and
+	// the heartbeat is only emitted.
+	Err error
+}
+
+// startHeartbeat begins emitting heartbeats for the part specified in a
+// separate goroutine, returning a function which stops the heartbeat and
+// waits for the goroutine to exit. If heartbeats are disabled, the returned
+// function does nothing. Whether the part is applied within a transaction is
+// indicated by transaction, in which case the heartbeats do not write to a
+// SQLite database, which would wait on the transaction until it timed out.
+func (instance *Instance) startHeartbeat(version int, part string, transaction bool) func() {
+	if instance.heartbeat <= 0 {
+		return func() {}
+	}
+	write := !transaction || instance.dialect.Name() != "sqlite"
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		start := instance.clock.Now()
+		ticker := instance.clock.NewTicker(instance.heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C():
+				now := instance.clock.Now()
+				beat := Heartbeat{Version: version, Part: part, Elapsed: now.Sub(start), Time: now}
+
+				if write {
+					beat.Err = instance.refreshLock()
+				}
+				if write && instance.heartbeatRow {
+					if err := instance.meta.Set(instance.metaKey("migrateHeartbeat"), int(now.Unix())); beat.Err == nil {
+						beat.Err = err
+					}
+				}
+
+				instance.say(MessageHeartbeat, MessageData{Version: version, Part: part,
+					Elapsed: beat.Elapsed.Round(time.Second)})
+
+				if instance.onHeartbeat != nil {
+					instance.onHeartbeat(beat)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tickingClock is a Clock which always reads the same time, and whose tickers
+// tick only when a test sends on ticks.
+type tickingClock struct {
+	fixedClock
+	ticks chan time.Time
+}
+
+func (clock tickingClock) NewTicker(time.Duration) Ticker {
+	return tickingTicker(clock.ticks)
+}
+
+// tickingTicker is the Ticker of a tickingClock.
+type tickingTicker chan time.Time
+
+func (ticker tickingTicker) C() <-chan time.Time {
+	return ticker
+}
+
+func (ticker tickingTicker) Stop() {}
+
+// TestHeartbeat ensures that heartbeats are emitted while a part is being
+// applied, that they are written to Output, that they stop once the part has
+// finished applying, and that they do not write to SQLite while a transaction
+// is writing to it.
+func TestHeartbeat(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		clock := tickingClock{fixedClock(time.Unix(1700000000, 0)), make(chan time.Time)}
+		beats := make([]Heartbeat, 0)
+		instance, err := NewInstance(db, "testing/working", WithHeartbeatRow(), WithClock(clock),
+			WithHeartbeat(time.Second, func(beat Heartbeat) {
+				beats = append(beats, beat)
+			}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		output := &strings.Builder{}
+		instance.Output = output
+
+		// Each tick is only sent once the heartbeat before it has been emitted
+		stop := instance.startHeartbeat(2, "test.sql", false)
+		clock.ticks <- time.Time{}
+		clock.ticks <- time.Time{}
+		stop()
+
+		if len(beats) != 2 {
+			t.Fatalf("Instance.startHeartbeat: got %d heartbeats expected 2", len(beats))
+		}
+		if beats[0].Version != 2 || beats[0].Part != "test.sql" {
+			t.Errorf("Instance.startHeartbeat: got heartbeat for version %d part '%s' expected version 2 "+
+				"part 'test.sql'", beats[0].Version, beats[0].Part)
+		}
+		if beats[0].Err != nil {
+			t.Error("Instance.startHeartbeat: got error while recording heartbeat:\n", beats[0].Err)
+		}
+		if !strings.Contains(output.String(), "Still applying 'test.sql'") {
+			t.Errorf("Instance.startHeartbeat: expected heartbeat in output, got:\n%s", output.String())
+		}
+		if !instance.meta.Exists("migrateHeartbeat") {
+			t.Error("Instance.startHeartbeat: expected heartbeat to be recorded in metadata")
+		}
+
+		select {
+		case clock.ticks <- time.Time{}:
+			t.Error("Instance.startHeartbeat: expected no heartbeats after stopping")
+		default:
+		}
+
+		// While a transaction is writing to SQLite, the heartbeat neither waits on it nor fails
+		transaction, err := db.Begin()
+		if err != nil {
+			t.Fatal("sql.DB.Begin: got error:\n", err)
+		}
+		defer transaction.Rollback()
+		if _, err := transaction.Exec(`CREATE TABLE writing(ID INT);`); err != nil {
+			t.Fatal("sql.Tx.Exec: got error:\n", err)
+		}
+
+		stop = instance.startHeartbeat(2, "test.sql", true)
+		clock.ticks <- time.Time{}
+		stop()
+
+		if len(beats) != 3 {
+			t.Fatalf("Instance.startHeartbeat: got %d heartbeats expected 3", len(beats))
+		} else if beats[2].Err != nil {
+			t.Error("Instance.startHeartbeat: got error within transaction:\n", beats[2].Err)
+		}
+	})
+}
+
+// TestHeartbeatGoto ensures that heartbeats are written to Output and passed
+// to every Reporter while a slow part is applied by Goto, alongside the
+// messages of the run itself.
+func TestHeartbeatGoto(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	if err := ioutil.WriteFile(filepath.Join(root, "version_1", "slow.sql"), []byte("-- @migrate/up\n"+
+		"WITH RECURSIVE counter(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM counter WHERE x < 200000) "+
+		"SELECT COUNT(*) FROM counter;\n-- @migrate/down\nSELECT 1;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		// The slow part sends a tick while it is applied, which is received once its heartbeat is due
+		clock := tickingClock{fixedClock(time.Unix(1700000000, 0)), make(chan time.Time)}
+		executor := JobFunc(func(ctx context.Context, statement string) (int64, error) {
+			if strings.Contains(statement, "RECURSIVE") {
+				clock.ticks <- time.Time{}
+			}
+
+			res, err := db.ExecContext(ctx, statement)
+			if err != nil {
+				return 0, err
+			}
+			return res.RowsAffected()
+		})
+
+		// Without a transaction, SQLite allows each heartbeat to refresh the lock while the part is applied
+		instance, err := NewInstance(db, root, WithHeartbeat(time.Second, nil), WithoutTransaction(),
+			WithClock(clock), WithExecutor(executor))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		output := &strings.Builder{}
+		instance.Output = output
+
+		var heartbeats int
+		instance.AddReporter(ReporterFunc(func(event Event) {
+			if event.Message == MessageHeartbeat {
+				heartbeats++
+			}
+		}))
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if heartbeats != 1 || !strings.Contains(output.String(), "Still applying 'slow.sql'") {
+			t.Errorf("Instance.Goto: got %d heartbeats and output:\n%s\nexpected heartbeats for 'slow.sql'",
+				heartbeats, output.String())
+		}
+	})
+}
@@ -0,0 +1,298 @@
+package migrate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// compressedPrefix marks SQL text stored in the history compressed with gzip
+// and encoded with base64, as recorded with WithStoredSQL(true).
+const compressedPrefix = "gzip:"
+
+// HistoryEntry records a single part applied to the database, in either
+// direction, along with the metadata the part held at the time.
+type HistoryEntry struct {
+	Version   int               `json:"version"`
+	Part      string            `json:"part"`
+	Direction string            `json:"direction"`
+	Meta      map[string]string `json:"meta"`
+	AppliedAt time.Time         `json:"applied_at"`
+	Actor     string            `json:"actor"`  // Person or pipeline which applied the part, as provided with WithActor
+	Reason    string            `json:"reason"` // Reason for which the part was applied, as provided with WithReason
+
+	// ID identifies the entry, and RunID the run which applied the part, as
+	// generated by the IDGenerator of the Instance or provided with WithRunID.
+	// Both are empty for entries which predate them.
+	ID    string `json:"id"`
+	RunID string `json:"run_id"`
+
+	// StartedAt is when the run which applied the part began, and AppliedAt
+	// when the part finished. StartedAt is zero for entries which predate it.
+	StartedAt time.Time `json:"started_at"`
+
+	// Build, Revision, and Host identify the binary which applied the part: the
+	// path and version of its main module, the revision of version control from
+	// which it was built, and the host on which it ran. Each is empty if unknown.
+	Build    string `json:"build"`
+	Revision string `json:"revision"`
+	Host     string `json:"host"`
+
+	// SQL holds the statements of the part as executed, one after another with
+	// their line breaks kept, if recorded with WithStoredSQL
+	SQL string `json:"sql,omitempty"`
+}
+
+// createHistory creates the table in which every part applied is recorded,
+// named table, if it does not already exist. Each entry is numbered one
+// greater than the entry before it in its Sequence column, by which entries
+// recorded at the same time are ordered, as a column which increments
+// automatically is declared differently by every database.
+func createHistory(exec execer, table string) error {
+	_, err := exec.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + table + `(
+			Version INT NOT NULL,
+			Part VARCHAR(255) NOT NULL,
+			Direction VARCHAR(4) NOT NULL,
+			Meta TEXT NOT NULL,
+			AppliedAt BIGINT NOT NULL,
+			Actor VARCHAR(255) NOT NULL DEFAULT '',
+			Reason VARCHAR(1000) NOT NULL DEFAULT '',
+			Statements TEXT,
+			StartedAt BIGINT NOT NULL DEFAULT 0,
+			Build VARCHAR(255) NOT NULL DEFAULT '',
+			Revision VARCHAR(64) NOT NULL DEFAULT '',
+			Host VARCHAR(255) NOT NULL DEFAULT '',
+			ID VARCHAR(64) NOT NULL DEFAULT '',
+			RunID VARCHAR(255) NOT NULL DEFAULT '',
+			Sequence BIGINT NOT NULL DEFAULT 0
+		);
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Add the columns introduced after the table was first created to existing tables
+	for _, column := range []string{"Actor VARCHAR(255) NOT NULL DEFAULT ''",
+		"Reason VARCHAR(1000) NOT NULL DEFAULT ''", "StartedAt BIGINT NOT NULL DEFAULT 0",
+		"Build VARCHAR(255) NOT NULL DEFAULT ''", "Revision VARCHAR(64) NOT NULL DEFAULT ''",
+		"Host VARCHAR(255) NOT NULL DEFAULT ''", "ID VARCHAR(64) NOT NULL DEFAULT ''",
+		"RunID VARCHAR(255) NOT NULL DEFAULT ''", "Sequence BIGINT NOT NULL DEFAULT 0"} {
+		name := strings.Fields(column)[0]
+		if !columnExists(exec, table, name) {
+			if _, err := exec.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + `;`); err != nil {
+				return err
+			}
+		}
+	}
+
+	// TEXT columns may not have a default on every database, so the SQL text is left NULL when not recorded
+	if !columnExists(exec, table, "Statements") {
+		if _, err := exec.Exec(`ALTER TABLE ` + table + ` ADD COLUMN Statements TEXT;`); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// columnExists reports whether the column named exists within the table.
+func columnExists(exec execer, table, column string) bool {
+	rows, err := exec.QueryContext(context.Background(), `SELECT `+column+` FROM `+table+` WHERE 1 = 0;`)
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+	return true
+}
+
+// recordHistory adds an entry to the history noting that a part of a
+// migration version was applied in the direction specified by the run
+// described by report, along with the ID, start, actor, and reason of the run,
+// the binary and host which applied it, and the SQL text of the part if it is
+// stored. The metadata and SQL text are encrypted if WithEncryption is in use.
+func (instance *Instance) recordHistory(exec execer, version int, part *Part, direction string,
+	report *RunReport) error {
+	meta := part.Meta
+	if meta == nil {
+		meta = make(map[string]string)
+	}
+
+	encoded, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("migrate: failed to encode metadata of part '%s':\n%s", part.Name, err)
+	}
+
+	var statements interface{}
+	if instance.storeSQL {
+		text, err := encodeSQL(part.statementText(direction), instance.compressSQL)
+		if err != nil {
+			return fmt.Errorf("migrate: failed to compress SQL text of part '%s':\n%s", part.Name, err)
+		}
+
+		// Compress before encrypting, as ciphertext does not compress
+		if statements, err = encryptValue(instance.encrypter, text); err != nil {
+			return fmt.Errorf("migrate: failed to encrypt SQL text of part '%s':\n%s", part.Name, err)
+		}
+	}
+
+	stored, err := encryptValue(instance.encrypter, string(encoded))
+	if err != nil {
+		return fmt.Errorf("migrate: failed to encrypt metadata of part '%s':\n%s", part.Name, err)
+	}
+
+	build := readProvenance()
+	table := instance.table("migrate_history")
+	if _, err := exec.Exec(instance.rebind(`INSERT INTO `+table+` (Version, Part, Direction, Meta, AppliedAt, `+
+		`Actor, Reason, Statements, StartedAt, Build, Revision, Host, ID, RunID, Sequence) SELECT ?, ?, ?, ?, ?, `+
+		`?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(MAX(Sequence), 0) + 1 FROM `+table+`;`), version, part.Name, direction, stored,
+		instance.clock.Now().UnixNano(), instance.actor, instance.reason, statements, report.StartedAt.UnixNano(),
+		build.build, build.revision, build.host, instance.newID(), report.RunID); err != nil {
+		return fmt.Errorf("migrate: failed to record part '%s' of version %d in history:\n%s", part.Name,
+			version, err)
+	}
+
+	return nil
+}
+
+// encodeSQL returns the SQL text provided as it is stored in the history,
+// compressed with gzip and encoded with base64 behind compressedPrefix if
+// compress is true.
+func encodeSQL(text string, compress bool) (string, error) {
+	if !compress {
+		return text, nil
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(text)); err != nil {
+		return "", err
+	} else if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return compressedPrefix + base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// decodeSQL returns the SQL text stored in the history, decompressing it if
+// it was stored compressed.
+func decodeSQL(stored string) (string, error) {
+	if !strings.HasPrefix(stored, compressedPrefix) {
+		return stored, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, compressedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	text, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
+
+// History returns every entry recorded in the history, from oldest to newest.
+func (instance *Instance) History() ([]HistoryEntry, error) {
+	if instance.readOnly && !tableExists(instance.db, instance.table("migrate_history")) {
+		return []HistoryEntry{}, nil
+	}
+
+	return readHistory(instance.db, instance.table("migrate_history"), instance.encrypter)
+}
+
+// readHistory returns every entry recorded in the history table of the
+// database provided, from oldest to newest, decrypting the values stored
+// encrypted with encrypter.
+func readHistory(db *sql.DB, table string, encrypter Encrypter) ([]HistoryEntry, error) {
+	// A read-only Instance may read a table created before the actor, reason, SQL text, provenance, IDs, and
+	// sequence numbers were recorded
+	columns := "Actor, Reason"
+	if !columnExists(db, table, "Actor") {
+		columns = "'', ''"
+	}
+	if columnExists(db, table, "Statements") {
+		columns += ", COALESCE(Statements, '')"
+	} else {
+		columns += ", ''"
+	}
+	if columnExists(db, table, "StartedAt") {
+		columns += ", StartedAt, Build, Revision, Host"
+	} else {
+		columns += ", 0, '', '', ''"
+	}
+	if columnExists(db, table, "RunID") {
+		columns += ", ID, RunID"
+	} else {
+		columns += ", '', ''"
+	}
+	order := "AppliedAt"
+	if columnExists(db, table, "Sequence") {
+		order += ", Sequence"
+	}
+
+	rows, err := db.Query(`SELECT Version, Part, Direction, Meta, AppliedAt, ` + columns +
+		` FROM ` + table + ` ORDER BY ` + order + `;`)
+	if err != nil {
+		return nil, NewFatalf("Instance.History: got error while reading history:\n%s", err)
+	}
+	defer rows.Close()
+
+	entries := make([]HistoryEntry, 0)
+	for rows.Next() {
+		var entry HistoryEntry
+		var meta, statements string
+		var appliedAt, startedAt int64
+		if err := rows.Scan(&entry.Version, &entry.Part, &entry.Direction, &meta, &appliedAt, &entry.Actor,
+			&entry.Reason, &statements, &startedAt, &entry.Build, &entry.Revision, &entry.Host, &entry.ID,
+			&entry.RunID); err != nil {
+			return nil, NewFatalf("Instance.History: got error while reading history:\n%s", err)
+		}
+
+		if meta, err = decryptValue(encrypter, meta); err != nil {
+			return nil, NewFatalf("Instance.History: got error while decrypting metadata of part '%s':\n%s",
+				entry.Part, err)
+		} else if statements, err = decryptValue(encrypter, statements); err != nil {
+			return nil, NewFatalf("Instance.History: got error while decrypting SQL text of part '%s':\n%s",
+				entry.Part, err)
+		}
+
+		if err := json.Unmarshal([]byte(meta), &entry.Meta); err != nil {
+			return nil, NewFatalf("Instance.History: got error while decoding metadata of part '%s':\n%s",
+				entry.Part, err)
+		}
+
+		if entry.SQL, err = decodeSQL(statements); err != nil {
+			return nil, NewFatalf("Instance.History: got error while decompressing SQL text of part '%s':\n%s",
+				entry.Part, err)
+		}
+
+		entry.AppliedAt = time.Unix(0, appliedAt)
+		if startedAt != 0 {
+			entry.StartedAt = time.Unix(0, startedAt)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, NewFatalf("Instance.History: got error while reading history:\n%s", err)
+	}
+
+	return entries, nil
+}
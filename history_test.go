@@ -0,0 +1,200 @@
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHistory ensures that every part applied is recorded in the history
+// along with its metadata, in the order in which it was applied even when
+// every part is recorded at the same time.
+func TestHistory(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta", WithClock(fixedClock(time.Unix(1700000000, 0))))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		}
+
+		expected := []HistoryEntry{
+			{Version: 1, Part: "billing.sql", Direction: "up"},
+			{Version: 2, Part: "invoices.sql", Direction: "up"},
+			{Version: 2, Part: "invoices.sql", Direction: "down"},
+		}
+		if len(history) != len(expected) {
+			t.Fatalf("Instance.History: got %d entries expected %d", len(history), len(expected))
+		}
+
+		var sequence int
+		if err := db.QueryRow(`SELECT MAX(Sequence) FROM migrate_history;`).Scan(&sequence); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if sequence != len(expected) {
+			t.Errorf("Instance.History: got last sequence number %d expected %d", sequence, len(expected))
+		}
+
+		for key, entry := range expected {
+			if history[key].Version != entry.Version || history[key].Part != entry.Part ||
+				history[key].Direction != entry.Direction {
+				t.Errorf("Instance.History: got entry %d '%#v' expected '%#v'", key, history[key], entry)
+			}
+			if history[key].StartedAt.IsZero() || history[key].StartedAt.After(history[key].AppliedAt) {
+				t.Errorf("Instance.History: got started at %s and applied at %s for entry %d", history[key].StartedAt,
+					history[key].AppliedAt, key)
+			}
+			if hostname, _ := os.Hostname(); history[key].Host != hostname {
+				t.Errorf("Instance.History: got host '%s' for entry %d expected '%s'", history[key].Host, key, hostname)
+			}
+		}
+
+		if history[0].Meta["ticket"] != "PROJ-123" {
+			t.Errorf("Instance.History: got metadata '%#v' expected ticket 'PROJ-123'", history[0].Meta)
+		}
+		if len(history[1].Meta) != 0 {
+			t.Errorf("Instance.History: got metadata '%#v' expected none", history[1].Meta)
+		}
+	})
+}
+
+// TestHistoryActor ensures that the actor and reason of a run are recorded in
+// the history, the report, and the events passed to a Logger, including when
+// the history table predates them.
+func TestHistoryActor(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if _, err := db.Exec(`CREATE TABLE migrate_history(Version INT NOT NULL, Part VARCHAR(255) NOT NULL, ` +
+			`Direction VARCHAR(4) NOT NULL, Meta TEXT NOT NULL, AppliedAt BIGINT NOT NULL);`); err != nil {
+			t.Fatal("db.Exec: got error:\n", err)
+		}
+
+		logger := &recordingLogger{}
+		instance, err := NewInstance(db, "testing/meta", WithActor("ci/deploy#42"),
+			WithReason("release 1.4"), WithLogger(logger))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		}
+		for key, entry := range history {
+			if entry.Actor != "ci/deploy#42" || entry.Reason != "release 1.4" {
+				t.Errorf("Instance.History: got actor '%s' and reason '%s' for entry %d", entry.Actor,
+					entry.Reason, key)
+			}
+		}
+
+		if report := instance.Report(); report.Actor != "ci/deploy#42" || report.Reason != "release 1.4" {
+			t.Errorf("Instance.Report: got actor '%s' and reason '%s'", report.Actor, report.Reason)
+		}
+
+		fields := logger.fields[len(logger.fields)-1]
+		if last := fields[len(fields)-3 : len(fields)-1]; last[0] != (Field{"actor", "ci/deploy#42"}) ||
+			last[1] != (Field{"reason", "release 1.4"}) {
+			t.Errorf("Logger: got fields '%v' expected actor and reason", fields)
+		}
+	})
+}
+
+// TestHistorySQL ensures that the SQL text of each part is recorded in the
+// history with WithStoredSQL as executed, compressed if requested, and not
+// otherwise.
+func TestHistorySQL(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		RunWithDB(func(db *sql.DB) {
+			instance, err := NewInstance(db, "testing/meta", WithStoredSQL(compress))
+			if err != nil {
+				t.Fatal("NewInstance: got error:\n", err)
+			}
+			instance.Output = &strings.Builder{}
+
+			if err := instance.Latest(); err != nil {
+				t.Fatal("Instance.Latest: got error:\n", err)
+			}
+			if err := instance.Goto(1); err != nil {
+				t.Fatal("Instance.Goto: got error:\n", err)
+			}
+
+			history, err := instance.History()
+			if err != nil {
+				t.Fatal("Instance.History: got error:\n", err)
+			}
+			if sql := history[1].SQL; !strings.Contains(sql, "CREATE TABLE invoices") {
+				t.Errorf("Instance.History: got SQL '%s' expected CREATE TABLE invoices", sql)
+			}
+			if sql := history[2].SQL; !strings.Contains(sql, "DROP TABLE invoices") {
+				t.Errorf("Instance.History: got SQL '%s' expected DROP TABLE invoices", sql)
+			}
+
+			var stored string
+			if err := db.QueryRow(`SELECT Statements FROM migrate_history WHERE Direction = 'down';`).
+				Scan(&stored); err != nil {
+				t.Fatal("db.QueryRow: got error:\n", err)
+			}
+			if compressed := strings.HasPrefix(stored, compressedPrefix); compressed != compress {
+				t.Errorf("WithStoredSQL: got stored text '%s' with compress %t", stored, compress)
+			}
+		})
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/stored", WithStoredSQL(false))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		}
+		expected := "CREATE TABLE orders(\nID INT PRIMARY KEY,\nTotal INT NOT NULL\n);\n" +
+			"INSERT INTO orders (ID, Total)\nSELECT 1, 10\nWHERE 1 = 1;"
+		if history[0].SQL != expected {
+			t.Errorf("Instance.History: got SQL '%s' expected '%s'", history[0].SQL, expected)
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		}
+		if history[0].SQL != "" {
+			t.Errorf("Instance.History: got SQL '%s' expected none without WithStoredSQL", history[0].SQL)
+		}
+	})
+}
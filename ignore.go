@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile is the name of the file, placed at the root of an instance
+// directory, which lists patterns matching files and directories to ignore.
+const IgnoreFile = ".migrateignore"
+
+// ignorer holds patterns matching files and directories which should not be
+// loaded. A pattern containing a slash is matched against the path relative
+// to the instance directory, such as `version_2/*_wip.sql`, while any other
+// pattern is matched against the name alone, such as `*~`. Patterns use the
+// syntax of path.Match.
+type ignorer []string
+
+// readIgnoreFile returns the patterns listed in the IgnoreFile within root, if
+// it exists. Blank lines and lines beginning with `#` are ignored.
+func readIgnoreFile(root string) (ignorer, error) {
+	file, err := os.Open(filepath.Join(root, IgnoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := make(ignorer, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+
+	return patterns, scanner.Err()
+}
+
+// match returns true if the slash-separated path provided, relative to the
+// instance directory, matches any of the patterns.
+func (patterns ignorer) match(relative string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+
+		subject := path.Base(relative)
+		if strings.Contains(pattern, "/") {
+			subject = relative
+		}
+
+		if matched, _ := path.Match(pattern, subject); matched {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// TestIgnore ensures that files and directories matching the patterns in the
+// ignore file or provided with WithIgnore are not loaded.
+func TestIgnore(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		expectError(t, "NewInstance", "stray part file", func() error {
+			_, err := NewInstance(db, "testing/ignore")
+			return err
+		}, "scratch.sql")
+
+		instance, err := NewInstance(db, "testing/ignore", WithIgnore("version_2/scratch.sql"))
+		if err != nil {
+			t.Fatal("NewInstance: got error with ignored files:\n", err)
+		}
+
+		if len(instance.List()) != 2 {
+			t.Errorf("Instance.List: got '%v' expected 2 versions", instance.List())
+		}
+		for _, version := range instance.List() {
+			if parts := instance.migrations[version].Parts; len(parts) != 1 || parts[0].Name != "test.sql" {
+				t.Errorf("NewInstance: got %d parts in version %d expected only 'test.sql'", len(parts), version)
+			}
+		}
+	})
+
+	patterns := ignorer{"*~", "version_2/*_wip.sql", "docs/"}
+	for path, expected := range map[string]bool{
+		"version_1/test.sql~":     true,
+		"version_2/next_wip.sql":  true,
+		"version_3/next_wip.sql":  false,
+		"docs":                    true,
+		"version_1/test.sql":      false,
+		"version_1/docs/test.sql": false,
+	} {
+		if patterns.match(path) != expected {
+			t.Errorf("ignorer.match: got %t with '%s' expected %t", !expected, path, expected)
+		}
+	}
+}
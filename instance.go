@@ -1,16 +1,20 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
 	"time"
 
-	"github.com/octacian/metadb"
+	"github.com/octacian/migrate/backfill"
 )
 
 // ErrNoVersion is returned by Goto when the requested version does not exist.
@@ -25,6 +29,11 @@ func (err *ErrNoVersion) Error() string {
 		"'%d', does not exist", err.Version, err.Target)
 }
 
+// Is reports whether target is CodeNoVersion.
+func (err *ErrNoVersion) Is(target error) bool {
+	return target == CodeNoVersion
+}
+
 // ErrNoMigrations is returned by Goto and Latest when there are no more
 // migrations to apply.
 type ErrNoMigrations struct {
@@ -37,16 +46,108 @@ func (err *ErrNoMigrations) Error() string {
 		err.Version, err.Version)
 }
 
+// Is reports whether target is CodeNoMigrations.
+func (err *ErrNoMigrations) Is(target error) bool {
+	return target == CodeNoMigrations
+}
+
 // Instance represents a single collective set of migrations. With the
 // exception of the Output field, instance is not intended to be directly
 // created and manipulated, but rather managed by NewInstance and a variety of
 // methods.
 type Instance struct {
 	db         *sql.DB
-	meta       *metadb.Instance
+	ownsDB     bool
+	closed     bool
+	meta       *metaStore
 	migrations map[int]*Migration
+	options    []Option // Options provided to NewInstance, with which RehearseAgainst loads its sandbox
+
+	heartbeat    time.Duration
+	onHeartbeat  func(Heartbeat)
+	heartbeatRow bool
+
+	noTransaction  bool
+	acknowledgeDDL bool
+
+	holder    string
+	staleLock time.Duration
+	clock     Clock
+
+	actor  string
+	reason string
+
+	ids       IDGenerator // Generator of the IDs of runs and history entries, set with WithIDGenerator
+	runID     string      // ID of every run, set with WithRunID, or empty to generate one per run
+	activeRun string      // ID of the run in progress, attached to every Event and log line
+
+	report *RunReport
+
+	dialect    Dialect
+	idempotent bool
+
+	root           string
+	keyring        Keyring
+	strictLockFile bool
+
+	allowFutureSchema bool
+	denyDown          bool
+	maxJump           int
+
+	versionTimeout time.Duration
+	dataTimeout    time.Duration
+
+	lockTimeout time.Duration // Time DDL waits for a lock before being retried, set with WithLockTimeout
+	lockRetries int
+	lockBackoff time.Duration
+
+	roles map[string]string // Roles named by grant and owner directives mapped to those of the environment
+
+	includeTags []string // Tags of the parts applied, if any, set with WithTags
+	excludeTags []string // Tags of the parts not applied, set with WithoutTags
+
+	rowLimit         int64 // Most rows a statement may be estimated to touch, or 0 for no limit
+	rowLimitOverride bool
+
+	deferredRetries int
+	deferredBackoff time.Duration
+	minVersion      int // Lowest version loaded, set with WithVersionRange
+	maxVersion      int // Highest version loaded, or 0 for no limit
+
+	executor Executor
+	fixtures string
+	secrets  SecretProvider
+	external *sql.Tx // Transaction of the caller of GotoInTx, within which the run is applied
+
+	storeSQL    bool // Whether the SQL text of each part is recorded in the history
+	compressSQL bool
+	encrypter   Encrypter // Encrypter of the values recorded in the history, or nil to store them as is
+
+	expvar   bool
+	readOnly bool
+	logger   Logger
+
+	reporters []Reporter
+	plugins   plugins // Extensions registered with the package when the Instance was created
+
+	versionKey string
+	legacyKeys []string
+	schema     string
+	scope      string
+
+	createDatabase bool
+	allowClean     bool
+
+	outputFormats map[Message]string
+	noColor       bool
+	verbose       bool
+	formats       map[Message]*template.Template
+	sayMutex      sync.Mutex // Serialises messages written by a run and by its heartbeats
+
+	loader loader
 
 	// Output controls the destination for messages emitted by the Instance.
+	// Further destinations may be added with AddReporter.
 	Output io.Writer
 }
 
@@ -55,32 +156,161 @@ type Instance struct {
 // as an individual Migration. Within these sub-directories can be any number
 // of files, each representing a single Part. NewInstance returns a pointer to
 // an Instance if successful. NewInstance returns an error if there is a gap
-// between two migration versions or if any other error occurs.
-func NewInstance(db *sql.DB, root string) (*Instance, error) {
+// between two migration versions or if any other error occurs. Any options
+// provided are applied to the Instance before the directory is read.
+func NewInstance(db *sql.DB, root string, options ...Option) (*Instance, error) {
 	if db == nil {
 		return nil, NewFatalf("NewInstance: got nil database handle")
 	}
 
-	meta, err := metadb.NewInstance(db)
+	root, err := fetchSource(root)
 	if err != nil {
-		return nil, NewFatalf("NewInstance: got error while creating metadb instance:\n%s", err)
+		return nil, NewFatalf("NewInstance: %s", err)
+	}
+
+	plugins := registered()
+	instance := &Instance{
+		db:         db,
+		migrations: make(map[int]*Migration, 0),
+		Output:     os.Stdout,
+		holder:     newHolder(),
+		clock:      systemClock{},
+		ids:        randomIDs{},
+		dialect:    detectDialect(db),
+		root:       filepath.Clean(root),
+		plugins:    plugins,
+		reporters:  plugins.reporters,
+		options:    options,
+	}
+	for _, option := range options {
+		option(instance)
+	}
+
+	// Databases without interactive transactions, such as BigQuery, are always migrated without them
+	if !supportsTransactions(instance.dialect) {
+		instance.noTransaction = true
+	}
+
+	if schema := instance.schema; schema != "" && !regexSchema.MatchString(schema) {
+		return nil, NewFatalf("NewInstance: invalid schema name '%s'", schema)
+	}
+
+	if err := instance.enterScope(); err != nil {
+		return nil, NewFatalf("NewInstance: %s", err)
+	}
+
+	if instance.readOnly {
+		// Use the metadata table without creating it, treating its absence as version 0
+		instance.meta = instance.newMetaStore()
+	} else if err := instance.bootstrap(); err != nil {
+		return nil, err
+	}
+
+	if err := instance.load(); err != nil {
+		return nil, err
+	}
+
+	for _, hooks := range instance.plugins.hooks {
+		if hooks.Created == nil {
+			continue
+		}
+
+		if err := hooks.Created(instance); err != nil {
+			return nil, NewFatalf("NewInstance: got error from hook:\n%s", err)
+		}
 	}
 
-	instance := &Instance{db: db, meta: meta, migrations: make(map[int]*Migration, 0), Output: os.Stdout}
+	if instance.expvar {
+		publishExpvar(instance)
+	}
 
-	directories, err := ioutil.ReadDir(root)
+	return instance, nil
+}
+
+// Inspect loads and validates the migrations within root exactly as
+// NewInstance does, applying any options provided, but without a database.
+// Inspect returns nil if the migrations are valid, allowing a tree to be
+// checked, such as in CI, without access to any database.
+func Inspect(root string, options ...Option) error {
+	_, err := inspect(root, options...)
+	return err
+}
+
+// inspect implements Inspect, returning an Instance without a database which
+// holds the migrations loaded.
+func inspect(root string, options ...Option) (*Instance, error) {
+	root, err := fetchSource(root)
 	if err != nil {
+		return nil, NewFatalf("Inspect: %s", err)
+	}
+
+	instance := &Instance{
+		migrations: make(map[int]*Migration, 0),
+		Output:     os.Stdout,
+		clock:      systemClock{},
+		dialect:    Generic,
+		root:       filepath.Clean(root),
+	}
+	for _, option := range options {
+		option(instance)
+	}
+
+	if err := instance.enterScope(); err != nil {
+		return nil, NewFatalf("Inspect: %s", err)
+	}
+
+	if err := instance.load(); err != nil {
 		return nil, err
 	}
 
+	return instance, nil
+}
+
+// load reads and validates the migrations within the instance directory,
+// once the options of the Instance have been applied.
+func (instance *Instance) load() error {
+	root := instance.root
+
+	var err error
+	if instance.formats, err = parseFormats(instance.outputFormats, !instance.noColor); err != nil {
+		return NewFatalf("NewInstance: %s", err)
+	}
+
+	patterns, err := readIgnoreFile(root)
+	if err != nil {
+		return NewFatalf("NewInstance: got error while reading ignore file:\n%s", err)
+	}
+	instance.loader.ignore = append(instance.loader.ignore, patterns...)
+
+	directories, err := readDir(root)
+	if err != nil {
+		return err
+	}
+
 	for _, directory := range directories {
-		if !directory.IsDir() {
+		if !directory.IsDir() || instance.loader.ignore.match(directory.Name()) ||
+			directory.Name() == backfill.Directory || isScope(filepath.Join(root, directory.Name())) {
 			continue
 		}
 
-		migration, err := NewMigration(path.Join(root, directory.Name()))
+		// Skip versions outside of the range provided with WithVersionRange without reading their parts
+		name := directory.Name()
+		if version, err := strconv.Atoi(strings.TrimPrefix(name, "version_")); err == nil &&
+			strings.HasPrefix(name, "version_") && !instance.inRange(version) {
+			continue
+		}
+
+		migration, err := instance.loader.migration(filepath.Join(root, directory.Name()))
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		if existing, ok := instance.migrations[migration.Version]; ok {
+			return &ErrDuplicateVersion{
+				Version:   migration.Version,
+				Paths:     [2]string{existing.Path, migration.Path},
+				Checksums: [2]string{existing.Checksum(), migration.Checksum()},
+			}
 		}
 
 		instance.migrations[migration.Version] = migration
@@ -88,7 +318,7 @@ func NewInstance(db *sql.DB, root string) (*Instance, error) {
 
 	// if no migrations were added, return an error
 	if len(instance.migrations) == 0 {
-		return nil, NewFatalf("NewInstance: no migrations found in '%s'", root)
+		return NewFatalf("NewInstance: no migrations found in '%s'", root)
 	}
 
 	keys := make([]int, 0)
@@ -97,95 +327,391 @@ func NewInstance(db *sql.DB, root string) (*Instance, error) {
 	}
 	sort.Ints(keys)
 
-	lastVersion := 0
+	lastVersion := instance.first() - 1
 	// Check for gaps in migration version
 	for _, key := range keys {
 		if key != lastVersion+1 {
-			return nil, NewFatalf("NewInstance: found gap between migration version %d and %d", lastVersion, key)
+			return &ErrGap{From: lastVersion, To: key}
 		}
 		lastVersion++
 	}
 
+	if instance.keyring != nil {
+		if instance.partial() {
+			return NewFatalf("NewInstance: cannot verify the signature of migrations loaded with WithVersionRange")
+		} else if err := instance.verifySignature(instance.keyring); err != nil {
+			return err
+		}
+	}
+
+	if instance.strictLockFile {
+		if err := instance.verifyLockFile(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OpenInstance opens a database handle with the driver and data source name
+// provided and passes it to NewInstance along with root and any options. The
+// Instance returned owns the database handle, which is closed by Close. If
+// WithCreateDatabase is provided, the database is first created if it does
+// not already exist.
+func OpenInstance(driver, dsn, root string, options ...Option) (*Instance, error) {
+	probe := &Instance{}
+	for _, option := range options {
+		option(probe)
+	}
+
+	if probe.createDatabase {
+		if _, err := CreateDatabase(driver, dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, NewFatalf("OpenInstance: got error while opening database:\n%s", err)
+	}
+
+	instance, err := NewInstance(db, root, options...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	instance.ownsDB = true
 	return instance, nil
 }
 
+// Close releases the migration lock if it is still held by the Instance and,
+// if the Instance was created by OpenInstance, closes its database handle. No
+// further migrations may be applied once an Instance has been closed. Calling
+// Close more than once has no effect.
+func (instance *Instance) Close() error {
+	if instance.closed {
+		return nil
+	}
+	instance.closed = true
+	unpublishExpvar(instance)
+
+	for _, hooks := range instance.plugins.hooks {
+		if hooks.Closed != nil {
+			hooks.Closed(instance)
+		}
+	}
+
+	if !instance.readOnly {
+		if err := instance.unlock(); err != nil {
+			return err
+		}
+	}
+
+	if instance.ownsDB {
+		if err := instance.db.Close(); err != nil {
+			return NewFatalf("Instance.Close: got error while closing database:\n%s", err)
+		}
+	}
+
+	return nil
+}
+
 // Version returns an integer representing which Migration the database is
-// currently on. Version panics if the metadata entry in which the version is
-// stored exists but cannot be fetched for some reason.
+// currently on. If no version has been recorded under the key provided with
+// WithVersionKey, the legacy keys provided alongside it are read in turn.
+// Version panics if the metadata entry in which the version is stored exists
+// but cannot be fetched for some reason.
 func (instance *Instance) Version() int {
-	res, err := instance.meta.Get("migrateVersion")
-	if err != nil {
-		if _, ok := err.(*metadb.ErrNoEntry); ok {
-			return 0
+	if instance.uninitialized() {
+		return 0
+	}
+
+	for _, key := range instance.versionKeys() {
+		res, err := instance.meta.Get(key)
+		if err != nil {
+			if _, ok := err.(*errNoEntry); ok {
+				continue
+			}
+
+			panic(fmt.Sprint("Instance.Version: got error:\n", err))
 		}
 
-		panic(fmt.Sprint("Instance.Version: got error:\n", err))
+		return res
 	}
 
-	return res.(int)
+	return 0
 }
 
 // List returns a slice of integers holding the version numbers of all
-// available Migrations.
+// available Migrations, or only those within the range provided with
+// WithVersionRange.
 func (instance *Instance) List() []int {
 	versions := make([]int, 0)
-	for i := 1; i <= len(instance.migrations); i++ {
+	for i := instance.first(); i <= instance.latest(); i++ {
 		versions = append(versions, i)
 	}
 	return versions
 }
 
+// first returns the lowest migration version held by the Instance.
+func (instance *Instance) first() int {
+	if instance.minVersion > 1 {
+		return instance.minVersion
+	}
+	return 1
+}
+
+// latest returns the highest migration version held by the Instance.
+func (instance *Instance) latest() int {
+	return instance.first() + len(instance.migrations) - 1
+}
+
+// partial reports whether only a range of the migrations within the instance
+// directory was loaded, as provided with WithVersionRange.
+func (instance *Instance) partial() bool {
+	return instance.minVersion > 1 || instance.maxVersion > 0
+}
+
+// inRange reports whether version lies within the range provided with
+// WithVersionRange, or true if no range was provided.
+func (instance *Instance) inRange(version int) bool {
+	return version >= instance.minVersion && (instance.maxVersion <= 0 || version <= instance.maxVersion)
+}
+
+// Dirty returns true if a migration run without a transaction was
+// interrupted, leaving the database somewhere between two versions. A dirty
+// database must be brought back to a known version using Resume before any
+// further migrations can be applied.
+func (instance *Instance) Dirty() bool {
+	if instance.uninitialized() {
+		return false
+	}
+
+	return instance.meta.Exists(instance.metaKey("migrateTarget"))
+}
+
+// Report returns the RunReport describing the most recent call to Goto,
+// Latest, or Resume, or nil if none has been made.
+func (instance *Instance) Report() *RunReport {
+	return instance.report
+}
+
 // Goto applies any migrations necessary to bring the database schema to the
 // state defined by the migration version specified. Goto employs transactions,
 // ensuring that if anything fails, the database is automatically reverted to
-// how it was before Goto was called.
+// how it was before Goto was called. If WithoutTransaction is in use, every
+// part applied is instead recorded in a journal, and an interrupted Goto may
+// be continued by calling Resume. If any part fails to apply, an *ErrApply is
+// returned holding a RunReport which describes the failure.
 func (instance *Instance) Goto(target int) error {
+	return instance.GotoContext(context.Background(), target)
+}
+
+// GotoContext behaves exactly as Goto, except that the statements of each
+// part are executed with the context provided. If ctx is cancelled, the
+// statement being executed is cancelled and the run fails as though the
+// statement had returned an error.
+func (instance *Instance) GotoContext(ctx context.Context, target int) error {
+	return instance.run(ctx, target, false, "")
+}
+
+// Resume continues a migration run which was interrupted while running
+// without a transaction, skipping any parts of the interrupted version which
+// the journal shows were already applied and then continuing on to the
+// version originally requested. Resume returns an error if the database is
+// not dirty.
+func (instance *Instance) Resume() error {
+	return instance.run(context.Background(), 0, true, "")
+}
+
+// run implements Goto, Force, and Resume, using resume to indicate whether the
+// journal should be consulted for parts already applied to the first version,
+// in which case the target version recorded by the interrupted run is used.
+// Policies named by overrides are not enforced, nor are any policies enforced
+// when resuming, as the interrupted run has already been permitted. Parts
+// marked deferred, and parts of the kind deferred if any, are queued for
+// RunDeferred rather than applied when migrating up.
+func (instance *Instance) run(ctx context.Context, target int, resume bool, deferred Kind,
+	overrides ...Override) (err error) {
+	if instance.closed {
+		return NewFatalf("Instance.Goto: instance has been closed")
+	} else if instance.readOnly {
+		return NewFatalf("Instance.Goto: instance is read-only")
+	}
+
+	// Within the transaction of the caller of GotoInTx, the run is isolated by that transaction instead
+	if instance.external == nil {
+		if err := instance.lock(); err != nil {
+			return err
+		}
+
+		defer func() {
+			if unlockErr := instance.unlock(); unlockErr != nil && err == nil {
+				err = unlockErr
+			}
+		}()
+	}
+
+	dirty := instance.Dirty()
+	recorded := 0
+	if dirty {
+		res, err := instance.meta.Get(instance.metaKey("migrateTarget"))
+		if err != nil {
+			return NewFatalf("Instance.Goto: got error while fetching target version:\n%s", err)
+		}
+		recorded = res
+	}
+
+	if resume {
+		if !dirty {
+			return NewFatalf("Instance.Resume: no interrupted migration to resume")
+		}
+		target = recorded
+	} else if dirty {
+		return &ErrDirty{Version: instance.Version(), Target: recorded}
+	}
+
 	currentVersion := instance.Version()
-	todo := make([]*Migration, 0)
-	direction := "up"
-	jump := 1
-	start := time.Now()
+	if instance.external != nil {
+		if currentVersion, _, err = instance.readVersion(instance.external); err != nil {
+			return err
+		}
+	}
+	if latest := instance.latest(); currentVersion > latest {
+		return &ErrFutureSchema{Version: currentVersion, Latest: latest}
+	}
 
-	addToTodo := func(i int) error {
-		midway, ok := instance.migrations[i]
-		if !ok {
-			return &ErrNoVersion{Version: i, Target: target}
+	report := &RunReport{From: currentVersion, Target: target, Actor: instance.actor, Reason: instance.reason}
+	instance.report = report
+	start := instance.clock.Now()
+	report.StartedAt = start
+	defer instance.beginRun(report)()
+	defer func() {
+		report.Duration = instance.since(start)
+		if _, ok := err.(*ErrNoMigrations); !ok {
+			report.Err = err
 		}
-		todo = append(todo, midway)
-		return nil
+
+		fields := []Field{{"from", report.From}, {"target", report.Target}, {"version", report.Version},
+			{"outcome", report.Outcome.String()}, {"duration", report.Duration}}
+		if report.Actor != "" {
+			fields = append(fields, Field{"actor", report.Actor})
+		}
+		if report.Reason != "" {
+			fields = append(fields, Field{"reason", report.Reason})
+		}
+		if report.Err != nil {
+			instance.log(LevelError, "run failed", append(fields, Field{"error", report.Err})...)
+		} else if err == nil {
+			instance.log(LevelInfo, "run finished", fields...)
+		}
+
+		// Only runs which began applying migrations have a direction
+		if report.Direction != "" {
+			instance.notify(report)
+		}
+	}()
+
+	// if the requested version is the same as the current version, there is nothing to apply
+	if target == currentVersion {
+		if resume { // if resuming a run which completed every version, simply mark it as finished
+			return instance.finish(report, start)
+		}
+
+		return &ErrNoMigrations{target}
 	}
 
-	// if requested version is greater than the current version, migrate up
-	if target > currentVersion {
-		for i := currentVersion + 1; i <= target; i++ {
-			if err := addToTodo(i); err != nil {
-				return err
-			}
+	todo, direction, err := instance.plan(currentVersion, target)
+	if err != nil {
+		return err
+	}
+	jump := len(todo)
+
+	if !resume {
+		if err := instance.checkPolicies(currentVersion, target, direction, overrides); err != nil {
+			return err
+		} else if err := instance.checkPlugins(currentVersion, target, direction); err != nil {
+			return err
 		}
+	}
 
-		jump = target - currentVersion
-	} else if target < currentVersion { // else if requested version is less than the current version, migrate down
-		for i := currentVersion; i > target; i-- {
-			if err := addToTodo(i); err != nil {
-				return err
+	// if migrating down, ensure that every part to be reverted is reversible
+	if direction == "down" {
+		for _, migration := range todo {
+			for _, part := range migration.Parts {
+				if part.Irreversible {
+					return &ErrIrreversible{Version: migration.Version, Part: part.Name}
+				}
 			}
 		}
+	}
 
-		direction = "down"
-		jump = currentVersion - target
-	} else { // else, specified version is the same as the current version, return an error
-		return &ErrNoMigrations{target}
+	// if resuming, fetch the parts of the interrupted version which were already applied
+	completed := make(map[string]bool)
+	if resume {
+		var err error
+		if completed, err = instance.journal(todo[0].Version, direction); err != nil {
+			return NewFatalf("Instance.Goto: got error while reading journal:\n%s", err)
+		}
+	}
+
+	// if migrating down, fetch the states of the parts to be reverted, as those skipped need not be
+	states := make(map[int]map[string]PartState)
+	if direction == "down" {
+		var err error
+		if states, err = instance.partStates(); err != nil {
+			return NewFatalf("Instance.Goto: got error while reading part states:\n%s", err)
+		}
 	}
 
 	if jump > 1 {
-		fmt.Fprintf(instance.Output, "\033[1mmigrate: Preparing to migrate over %d version(s)...\033[0m\n", jump)
+		instance.say(MessagePreparing, MessageData{Jump: jump})
 	}
 
-	transaction, err := instance.db.Begin()
-	if err != nil {
-		return NewFatalf("Instance.Goto: got error while starting a transaction:\n%s", err)
+	report.Direction = direction
+
+	// if DDL will be committed implicitly, warn that the run cannot be rolled back completely
+	if !instance.noTransaction && !instance.acknowledgeDDL && !supportsTransactionalDDL(instance.dialect) {
+		if count := countDDL(todo, direction); count > 0 {
+			instance.say(MessageNonTransactionalDDL, MessageData{Dialect: instance.dialect.Name(), Statements: count})
+			instance.log(LevelWarn, "non-transactional ddl", Field{"dialect", instance.dialect.Name()},
+				Field{"statements", count})
+		}
 	}
 
+	var exec execer
+	var transaction *sql.Tx
+	if instance.external != nil {
+		if _, err := instance.external.Exec(`SAVEPOINT migrate_run;`); err != nil {
+			return NewFatalf("Instance.GotoInTx: got error while creating savepoint:\n%s", err)
+		}
+		transaction, exec = instance.external, instance.external
+	} else if instance.noTransaction {
+		if err := instance.meta.Set(instance.metaKey("migrateTarget"), target); err != nil {
+			return NewFatalf("Instance.Goto: got error while recording target version:\n%s", err)
+		}
+
+		var release func()
+		if exec, release, err = instance.pin(ctx); err != nil {
+			return NewFatalf("Instance.Goto: got error while setting search path:\n%s", err)
+		}
+		defer release()
+	} else {
+		var err error
+		if transaction, err = instance.begin(); err != nil {
+			return NewFatalf("Instance.Goto: got error while starting a transaction:\n%s", err)
+		}
+		exec = transaction
+	}
+
+	journal, pending := instance.table("migrate_journal"), instance.table("migrate_pending")
+	settled := make([]settledVersion, 0, len(todo))
+
+	// Release the timeout of the version being applied once it finishes, or if the run is aborted midway
+	cancelVersion := func() {}
+	defer func() { cancelVersion() }()
+
 	// Loop through and apply migrations
 	for key, migration := range todo {
 		fromVersion := currentVersion + key
@@ -195,52 +721,473 @@ func (instance *Instance) Goto(target int) error {
 			toVersion--
 		}
 
-		fmt.Fprintf(instance.Output, "\033[1mmigrate: Beginning migration %s from version %d to %d...\033[0m\n",
-			direction, fromVersion, toVersion)
+		instance.say(MessageBeginning, MessageData{Version: migration.Version, Direction: direction,
+			From: fromVersion, To: toVersion})
+		instance.log(LevelInfo, "migration started", Field{"version", migration.Version},
+			Field{"direction", direction}, Field{"from", fromVersion}, Field{"to", toVersion})
+
+		// if not continuing an interrupted version, discard any stale journal entries
+		if key > 0 || !resume {
+			completed = make(map[string]bool)
+			if err := instance.clearJournal(exec, journal, migration.Version); err != nil {
+				return instance.abort(transaction, err)
+			}
+		}
+
+		// if a per-version timeout is configured, bound the time spent applying this version
+		versionCtx := ctx
+		if instance.versionTimeout > 0 {
+			var cancel context.CancelFunc
+			versionCtx, cancel = context.WithTimeout(ctx, instance.versionTimeout)
+			cancelVersion = cancel
+		}
 
-		applied := make([]int, 0)
-		failed := make([]int, 0)
+		applied := 0
+		failed := 0
+		analyze := make([]string, 0)
 		// Apply all migration parts as per direction
-		for key, part := range migration.Parts {
-			var err error
-			if direction == "up" {
-				_, err = transaction.Exec(part.Up)
-			} else {
-				_, err = transaction.Exec(part.Down)
+		for _, part := range migration.Parts {
+			// if the context was cancelled or timed out, fail the part rather than applying it
+			if err := versionCtx.Err(); err != nil {
+				report.add(migration.Version, part, "", nil, err)
+				failed++
+				break
+			}
+
+			if completed[part.Name] {
+				instance.say(MessageSkipped, MessageData{Version: migration.Version, Part: part.Name,
+					Reason: "already applied"})
+				instance.log(LevelDebug, "part skipped", Field{"version", migration.Version},
+					Field{"part", part.Name}, Field{"direction", direction}, Field{"reason", "already applied"})
+				continue
+			}
+
+			// if the part is deferred or excluded by its tags, queue it for RunDeferred rather than applying it
+			excluded := instance.excludedBy(part)
+			if direction == "up" && (isDeferred(part, deferred) || excluded != "") {
+				if err := instance.deferPart(exec, migration.Version, part.Name); err != nil {
+					return instance.abort(transaction, err)
+				} else if err := instance.recordPart(exec, journal, migration.Version, part.Name, direction); err != nil {
+					return instance.abort(transaction, err)
+				} else if err := instance.setPartState(exec, migration.Version, part.Name, PartDeferred,
+					report.StartedAt); err != nil {
+					return instance.abort(transaction, err)
+				}
+
+				reason := "deferred until RunDeferred"
+				if excluded != "" {
+					reason = excluded + ", " + reason
+				}
+
+				instance.say(MessageSkipped, MessageData{Version: migration.Version, Part: part.Name,
+					Reason: reason})
+				instance.log(LevelInfo, "part skipped", Field{"version", migration.Version},
+					Field{"part", part.Name}, Field{"direction", direction}, Field{"reason", "deferred"})
+				report.addSkipped(migration.Version, part, "", reason)
+				continue
+			}
+
+			// if the part was deferred and never applied, there is nothing to revert
+			if direction == "down" && (part.Deferred || part.Kind == KindData || len(part.Tags) > 0) {
+				if queued, err := instance.clearPending(exec, pending, migration.Version, part.Name); err != nil {
+					return instance.abort(transaction, err)
+				} else if queued {
+					if err := instance.recordPart(exec, journal, migration.Version, part.Name, direction); err != nil {
+						return instance.abort(transaction, err)
+					} else if err := instance.setPartState(exec, migration.Version, part.Name, PartNone,
+						report.StartedAt); err != nil {
+						return instance.abort(transaction, err)
+					}
+
+					instance.say(MessageSkipped, MessageData{Version: migration.Version, Part: part.Name,
+						Reason: "never applied"})
+					instance.log(LevelInfo, "part skipped", Field{"version", migration.Version},
+						Field{"part", part.Name}, Field{"direction", direction}, Field{"reason", "pending"})
+					report.addSkipped(migration.Version, part, "", "never applied")
+					continue
+				}
+			}
+
+			// if the guard query of the part skipped it, there is nothing to revert
+			if direction == "down" && states[migration.Version][part.Name] == PartSkipped {
+				if err := instance.recordPart(exec, journal, migration.Version, part.Name, direction); err != nil {
+					return instance.abort(transaction, err)
+				} else if err := instance.setPartState(exec, migration.Version, part.Name, PartNone,
+					report.StartedAt); err != nil {
+					return instance.abort(transaction, err)
+				}
+
+				instance.say(MessageSkipped, MessageData{Version: migration.Version, Part: part.Name,
+					Reason: "skipped when applied"})
+				instance.log(LevelInfo, "part skipped", Field{"version", migration.Version},
+					Field{"part", part.Name}, Field{"direction", direction}, Field{"reason", "guard"})
+				report.addSkipped(migration.Version, part, "", "skipped when applied")
+				continue
+			}
+
+			sql := part.statementText(direction)
+
+			// if the part has a guard query which returns a truthy value, skip it
+			if direction == "up" && part.SkipIf != "" {
+				skip, err := evaluateGuard(versionCtx, exec, part.SkipIf)
+				if err != nil {
+					instance.say(MessageFailed, MessageData{Version: migration.Version, Part: part.Name, Err: err})
+					instance.log(LevelError, "part failed", Field{"version", migration.Version},
+						Field{"part", part.Name}, Field{"direction", direction}, Field{"error", err})
+					report.add(migration.Version, part, part.SkipIf, nil, err)
+					failed++
+					if transaction == nil {
+						break
+					}
+					continue
+				}
+
+				if skip {
+					if err := instance.recordPart(exec, journal, migration.Version, part.Name, direction); err != nil {
+						return instance.abort(transaction, err)
+					} else if err := instance.setPartState(exec, migration.Version, part.Name,
+						PartSkipped, report.StartedAt); err != nil {
+						return instance.abort(transaction, err)
+					}
+
+					instance.say(MessageSkipped, MessageData{Version: migration.Version, Part: part.Name,
+						Reason: "guard query returned true"})
+					instance.log(LevelInfo, "part skipped", Field{"version", migration.Version},
+						Field{"part", part.Name}, Field{"direction", direction}, Field{"reason", "guard"})
+					report.addSkipped(migration.Version, part, part.SkipIf, "guard query returned true")
+					continue
+				}
 			}
 
+			stopHeartbeat := instance.startHeartbeat(migration.Version, part.Name, transaction != nil)
+			rows, err := instance.applyPart(versionCtx, exec, transaction != nil, migration.Version, part, direction)
+			stopHeartbeat()
+
+			// if an optional part failed without affecting the rest of the run, carry on
+			if _, fatal := err.(*ErrFatal); err != nil && part.Optional && !fatal {
+				instance.say(MessageOptionalFailed, MessageData{Version: migration.Version, Part: part.Name, Err: err})
+				instance.log(LevelWarn, "optional part failed", Field{"version", migration.Version},
+					Field{"part", part.Name}, Field{"direction", direction}, Field{"error", err})
+				report.addOptional(migration.Version, part, sql, rows, err)
+				if direction == "up" {
+					if err := instance.setPartState(exec, migration.Version, part.Name, PartFailed, report.StartedAt); err != nil {
+						return instance.abort(transaction, err)
+					}
+				}
+				continue
+			}
+
+			report.add(migration.Version, part, sql, rows, err)
+
 			// if an error was returned, application of the part failed
 			if err != nil {
-				fmt.Fprintf(instance.Output, "\033[31;1m- Failed to apply '%s': %s\033[0m\n", part.Name, err)
-				failed = append(failed, key)
+				instance.say(MessageFailed, MessageData{Version: migration.Version, Part: part.Name, Err: err})
+				instance.log(LevelError, "part failed", Field{"version", migration.Version},
+					Field{"part", part.Name}, Field{"direction", direction}, Field{"error", err})
+				failed++
+
+				// without a transaction, later parts must not be applied on top of the failed part
+				if transaction == nil {
+					break
+				}
 				continue
 			}
 
-			applied = append(applied, key)
-			fmt.Fprintf(instance.Output, "- Applied '%s'\n", part.Name)
+			if err := instance.recordPart(exec, journal, migration.Version, part.Name, direction); err != nil {
+				return instance.abort(transaction, err)
+			}
+
+			if err := instance.recordHistory(exec, migration.Version, part, direction, report); err != nil {
+				return instance.abort(transaction, err)
+			}
+
+			state := PartApplied
+			if direction == "down" {
+				state = PartNone
+			}
+			if err := instance.setPartState(exec, migration.Version, part.Name, state, report.StartedAt); err != nil {
+				return instance.abort(transaction, err)
+			}
+
+			applied++
+			if direction == "up" {
+				analyze = append(analyze, part.Analyze...)
+			}
+			instance.say(MessageApplied, MessageData{Version: migration.Version, Part: part.Name})
+			instance.sayRows(migration.Version, part, direction, rows)
+			instance.log(LevelInfo, "part applied", Field{"version", migration.Version}, Field{"part", part.Name},
+				Field{"direction", direction})
+		}
+
+		timedOut := failed > 0 && versionCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+		report.Interrupted = failed > 0 && ctx.Err() == context.Canceled
+		cancelVersion()
+
+		var failure error = &ErrApply{report}
+		if timedOut {
+			failure = &ErrTimeout{Version: migration.Version, Timeout: instance.versionTimeout, Report: report}
+			instance.say(MessageTimedOut, MessageData{Version: migration.Version, Timeout: instance.versionTimeout})
 		}
 
 		// if any migration parts failed, cancel transaction and exit
-		if len(failed) > 0 {
-			fmt.Fprintf(instance.Output, "\n\033[1mmigrate: %d parts failed to apply, reverting %d successfully "+
-				"applied parts...\033[0m\n", len(failed), len(applied))
+		if failed > 0 {
+			report.Version = fromVersion
+			if transaction == nil {
+				instance.say(MessageLeftDirty, MessageData{Version: migration.Version, Failed: failed})
+
+				report.Outcome = LeftDirty
+				return failure
+			}
+
+			instance.say(MessageReverting, MessageData{Version: migration.Version, Failed: failed,
+				Applied: len(report.Applied)})
+
+			report.Outcome = RolledBack
+			report.Version = currentVersion
+			if err := instance.rollback(transaction); err != nil {
+				report.Outcome = Unknown
+				report.RollbackErr = err
+			}
+
+			return failure
+		}
+
+		if err := instance.clearJournal(exec, journal, migration.Version); err != nil {
+			return instance.abort(transaction, err)
+		}
+
+		// if not using a transaction, record progress after each version
+		if transaction == nil {
+			if err := instance.recordVersion(exec, fromVersion, toVersion); err != nil {
+				return err
+			}
+		}
+
+		instance.say(MessageVersionApplied, MessageData{Version: migration.Version, Applied: applied})
+
+		// Statistics are refreshed once the version is committed, as is the case at once without a transaction
+		if transaction == nil {
+			instance.afterVersion(ctx, migration.Version, direction, analyze)
+		} else {
+			settled = append(settled, settledVersion{migration.Version, analyze})
+		}
+	}
+
+	if transaction != nil {
+		if err := instance.recordVersion(exec, currentVersion, target); err != nil {
+			report.Outcome = RolledBack
+			report.Version = currentVersion
+			if err := instance.rollback(transaction); err != nil {
+				report.Outcome = Unknown
+				report.RollbackErr = err
+			}
+
+			return err
+		}
+
+		if err := instance.commit(transaction); err != nil {
+			return NewFatalf("Instance.Goto: got error while committing transaction:\n%s", err)
+		}
+
+		for _, version := range settled {
+			instance.afterVersion(ctx, version.version, direction, version.tables)
+		}
+	}
+
+	return instance.finish(report, start)
+}
+
+// plan returns the migrations which must be applied, in order, to bring the
+// database from the current version to the target version, along with the
+// direction in which they must be applied.
+func (instance *Instance) plan(current, target int) ([]*Migration, string, error) {
+	todo := make([]*Migration, 0)
+	direction := "up"
+
+	addToTodo := func(i int) error {
+		midway, ok := instance.migrations[i]
+		if !ok && instance.partial() && i < instance.first() {
+			return NewFatalf("Instance.Goto: version %d was not loaded, as it is below the range provided with "+
+				"WithVersionRange", i)
+		} else if !ok {
+			return &ErrNoVersion{Version: i, Target: target}
+		}
+		todo = append(todo, midway)
+		return nil
+	}
+
+	// if requested version is greater than the current version, migrate up
+	if target > current {
+		for i := current + 1; i <= target; i++ {
+			if err := addToTodo(i); err != nil {
+				return nil, "", err
+			}
+		}
+	} else { // else, migrate down
+		for i := current; i > target; i-- {
+			if err := addToTodo(i); err != nil {
+				return nil, "", err
+			}
+		}
+
+		direction = "down"
+	}
+
+	return todo, direction, nil
+}
+
+// countDDL returns the number of statements among the migrations provided
+// which alter the schema of the database when applied in direction.
+func countDDL(migrations []*Migration, direction string) int {
+	count := 0
+	for _, migration := range migrations {
+		for _, part := range migration.Parts {
+			statements := part.UpStatements
+			if direction == "down" {
+				statements = part.DownStatements
+			}
+
+			for _, statement := range statements {
+				if isDDL(statement.SQL) {
+					count++
+				}
+			}
+		}
+	}
+
+	return count
+}
+
+// applyPart applies the statements of a part in the direction specified. If
+// the part is optional and a transaction is in use, the part is wrapped in a
+// savepoint so that its failure may be undone without aborting the
+// transaction. Any error while managing the savepoint is an *ErrFatal.
+func (instance *Instance) applyPart(ctx context.Context, exec execer, transactional bool, version int,
+	part *Part, direction string) ([]int64, error) {
+	statements := part.UpStatements
+	if direction == "down" {
+		statements = part.DownStatements
+	} else if grants := instance.grantStatements(part); len(grants) > 0 {
+		statements = append(statements[:len(statements):len(statements)], grants...)
+	}
 
-			transaction.Rollback()
-			return NewFatalf("Instance.Goto: got error while applying migrations")
+	if !part.Optional || !transactional {
+		return instance.applyStatements(ctx, exec, transactional, version, part, statements)
+	}
+
+	if _, err := exec.Exec(`SAVEPOINT migrate_optional;`); err != nil {
+		return nil, NewFatalf("Instance.Goto: got error while creating savepoint for '%s':\n%s", part.Name, err)
+	}
+
+	rows, err := instance.applyStatements(ctx, exec, transactional, version, part, statements)
+	if err != nil {
+		if _, rollbackErr := exec.Exec(`ROLLBACK TO SAVEPOINT migrate_optional;`); rollbackErr != nil {
+			return rows, NewFatalf("Instance.Goto: got error while rolling back to savepoint for '%s':\n%s",
+				part.Name, rollbackErr)
 		}
 
-		fmt.Fprintf(instance.Output, "\033[1mmigrate: Successfully applied %d migration part(s)\n", len(applied))
+		return rows, err
+	}
+
+	if _, err := exec.Exec(`RELEASE SAVEPOINT migrate_optional;`); err != nil {
+		return rows, NewFatalf("Instance.Goto: got error while releasing savepoint for '%s':\n%s", part.Name,
+			err)
+	}
+
+	return rows, nil
+}
+
+// abort rolls back the transaction provided, if any, and returns an ErrFatal
+// wrapping err.
+func (instance *Instance) abort(transaction *sql.Tx, err error) error {
+	if transaction != nil {
+		instance.rollback(transaction)
+	}
+
+	return NewFatalf("Instance.Goto: got error while applying migrations:\n%s", err)
+}
+
+// recordVersion records version as the version of the database through
+// exec, within the transaction of the run if there is one. An
+// *ErrConcurrentModification is returned instead if the recorded version is no
+// longer expected, the version read when the run was planned, such that
+// another process must have migrated the database in the meantime. A version
+// read from a legacy key is moved to the key provided with WithVersionKey.
+func (instance *Instance) recordVersion(exec execer, expected, version int) error {
+	actual, key, err := instance.readVersion(exec)
+	if err != nil {
+		return err
+	} else if actual != expected {
+		return &ErrConcurrentModification{Expected: expected, Actual: actual}
 	}
 
-	if err := transaction.Commit(); err != nil {
-		return NewFatalf("Instance.Goto: got error while committing transaction:\n%s", err)
+	// Insert the version if it has never been recorded, with a value type of 1 for int as metaStore would
+	table := instance.metadataTable()
+	if key != instance.versionKeys()[0] {
+		if _, err := exec.Exec(instance.rebind(`INSERT INTO `+table+` (Name, Value, ValueType) VALUES (?, ?, 1);`),
+			instance.versionKeys()[0], version); err != nil {
+			return NewFatalf("Instance.Goto: got error while recording migrate version:\n%s", err)
+		} else if key == "" {
+			return nil
+		}
+
+		if _, err := exec.Exec(instance.rebind(`DELETE FROM `+table+` WHERE Name = ?;`), key); err != nil {
+			return NewFatalf("Instance.Goto: got error while removing legacy version key '%s':\n%s", key, err)
+		}
+		return nil
 	}
 
-	if err := instance.meta.Set("migrateVersion", target); err != nil {
+	res, err := exec.Exec(instance.rebind(`UPDATE `+table+` SET Value = ? WHERE Name = ? AND Value = ?;`), version,
+		key, actual)
+	if err != nil {
 		return NewFatalf("Instance.Goto: got error while updating migrate version:\n%s", err)
+	} else if affected, err := res.RowsAffected(); err == nil && affected != 1 {
+		actual, _, _ = instance.readVersion(exec)
+		return &ErrConcurrentModification{Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// readVersion reads the version of the database through exec, along with the
+// key under which it was recorded, which is empty if no version has been
+// recorded under any of the keys returned by versionKeys.
+func (instance *Instance) readVersion(exec execer) (int, string, error) {
+	for _, key := range instance.versionKeys() {
+		var value string
+		err := exec.QueryRow(instance.rebind(`SELECT Value FROM `+instance.metadataTable()+` WHERE Name = ?;`),
+			key).Scan(&value)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return 0, "", NewFatalf("Instance.Goto: got error while reading migrate version:\n%s", err)
+		}
+
+		version, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, "", NewFatalf("Instance.Goto: got malformed migrate version '%s'", value)
+		}
+
+		return version, key, nil
+	}
+
+	return 0, "", nil
+}
+
+// finish marks the database as no longer dirty after a successful run, which
+// has already recorded the version reached, and reports the time taken since
+// start.
+func (instance *Instance) finish(report *RunReport, start time.Time) error {
+	report.Outcome = Succeeded
+	report.Version = report.Target
+
+	if instance.meta.Exists(instance.metaKey("migrateTarget")) {
+		if err := instance.meta.Delete(instance.metaKey("migrateTarget")); err != nil {
+			return NewFatalf("Instance.Goto: got error while clearing target version:\n%s", err)
+		}
 	}
 
-	fmt.Fprintf(instance.Output, "\n\033[1mmigrate: Successfully applied migrations in %s\033[0m\n", time.Since(start))
+	instance.say(MessageFinished, MessageData{Duration: instance.since(start)})
 
 	return nil
 }
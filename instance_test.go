@@ -2,7 +2,11 @@ package migrate
 
 import (
 	"database/sql"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -43,6 +47,9 @@ func TestNewInstance(t *testing.T) {
 			func() error { _, e := NewInstance(db, "testing/nothing"); return e }, "no migrations found")
 		expectError(t, "NewInstance", "migration version gap",
 			func() error { _, e := NewInstance(db, "testing/gap"); return e }, "found gap between")
+		expectError(t, "NewInstance", "duplicate migration version",
+			func() error { _, e := NewInstance(db, "testing/duplicate"); return e }, "two migrations claiming version 1",
+			"'testing/duplicate/version_01' (checksum", "'testing/duplicate/version_1' (checksum")
 
 		if instance, err := NewInstance(db, "testing/bad"); err != nil {
 			t.Error("NewInstance: got error:\n", err)
@@ -129,3 +136,186 @@ func TestWorkingInstance(t *testing.T) {
 		}
 	})
 }
+
+// TestOpenInstance ensures that an Instance created by OpenInstance owns its
+// database handle and closes it along with the Instance, while an Instance
+// created by NewInstance leaves its handle open.
+func TestOpenInstance(t *testing.T) {
+	expectError(t, "OpenInstance", "unknown driver", func() error {
+		_, err := OpenInstance("nothing", TestDBPath, "testing/working")
+		return err
+	}, "error while opening database")
+
+	instance, err := OpenInstance("sqlite3", TestDBPath, "testing/working")
+	if err != nil {
+		t.Fatal("OpenInstance: got error:\n", err)
+	}
+	instance.Output = &strings.Builder{}
+
+	if err := instance.Latest(); err != nil {
+		t.Fatal("Instance.Latest: got error:\n", err)
+	}
+	if err := instance.Close(); err != nil {
+		t.Fatal("Instance.Close: got error:\n", err)
+	}
+	if err := instance.Close(); err != nil {
+		t.Error("Instance.Close: got error when closed twice:\n", err)
+	}
+	if err := instance.db.Ping(); err == nil {
+		t.Error("Instance.Close: expected database handle to be closed")
+	}
+	expectError(t, "Instance.Goto", "closed instance", func() error { return instance.Goto(0) }, "has been closed")
+
+	if err := os.Remove(TestDBPath); err != nil {
+		t.Fatal("os.Remove: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		if err := instance.Close(); err != nil {
+			t.Fatal("Instance.Close: got error:\n", err)
+		}
+		if err := db.Ping(); err != nil {
+			t.Error("Instance.Close: expected database handle provided to NewInstance to remain open:\n", err)
+		}
+	})
+}
+
+// TestSymlinks ensures that migration directories and part files reached
+// through symbolic links are loaded, and that paths use the separator of the
+// operating system.
+func TestSymlinks(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	elsewhere := CopyTree(t, "testing/working")
+
+	// Link version_4 to a directory outside of the instance directory, holding
+	// a link to a part file
+	target := filepath.Join(elsewhere, "version_3")
+	if err := os.Rename(filepath.Join(target, "test.sql"), filepath.Join(elsewhere, "part.sql")); err != nil {
+		t.Fatal("os.Rename: got error:\n", err)
+	}
+	if err := os.Symlink(filepath.Join(elsewhere, "part.sql"), filepath.Join(target, "test.sql")); err != nil {
+		t.Skip("os.Symlink: symbolic links are not supported:\n", err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, "version_4")); err != nil {
+		t.Fatal("os.Symlink: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		if len(instance.List()) != 4 {
+			t.Fatalf("Instance.List: got '%v' expected the linked version_4 to be loaded", instance.List())
+		}
+		if path := instance.migrations[4].Parts[0].Path; path != filepath.Join(root, "version_4", "test.sql") {
+			t.Errorf("NewInstance: got part path '%s' expected '%s'", path,
+				filepath.Join(root, "version_4", "test.sql"))
+		}
+	})
+
+	if err := os.Symlink(filepath.Join(elsewhere, "nothing"), filepath.Join(root, "version_5")); err != nil {
+		t.Fatal("os.Symlink: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		if _, err := NewInstance(db, root); !os.IsNotExist(err) {
+			t.Errorf("NewInstance: got '%v' expected error with broken link", err)
+		}
+	})
+}
+
+// TestVersionRange ensures that only the range of versions provided is
+// loaded, and that migrating outside of it is refused.
+func TestVersionRange(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		// Versions outside of the range are never read
+		if err := ioutil.WriteFile(filepath.Join(root, "version_1", "broken.sql"), []byte("broken"),
+			0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		instance, err = NewInstance(db, root, WithVersionRange(2, 0), WithStrictLockFile())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if versions := instance.List(); !reflect.DeepEqual(versions, []int{2, 3}) {
+			t.Errorf("Instance.List: got '%v' expected '[2 3]'", versions)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.EnsureUpToDate(); err != nil {
+			t.Error("Instance.EnsureUpToDate: got error:\n", err)
+		}
+
+		expectError(t, "Instance.Goto", "version below range", func() error { return instance.Goto(0) },
+			"version 1 was not loaded")
+		expectError(t, "Instance.WriteLockFile", "partial tree", instance.WriteLockFile, "WithVersionRange")
+
+		instance, err = NewInstance(db, root, WithVersionRange(2, 2))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		if err := instance.EnsureUpToDate(); err == nil {
+			t.Error("Instance.EnsureUpToDate: expected error with database above range")
+		}
+	})
+}
+
+// TestConcurrentModification ensures that a run fails without committing if
+// the recorded version changes while it is applying migrations.
+func TestConcurrentModification(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/concurrent")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		err = instance.Latest()
+		if concurrentErr, ok := err.(*ErrConcurrentModification); !ok {
+			t.Fatalf("Instance.Latest: got '%v' expected *ErrConcurrentModification", err)
+		} else if concurrentErr.Expected != 0 || concurrentErr.Actual != 7 {
+			t.Errorf("Instance.Latest: got expected version %d and actual %d expected 0 and 7",
+				concurrentErr.Expected, concurrentErr.Actual)
+		} else if !errors.Is(err, CodeConcurrentModification) {
+			t.Error("Instance.Latest: expected error to match CodeConcurrentModification")
+		}
+
+		if report := instance.Report(); report.Outcome != RolledBack {
+			t.Errorf("Instance.Report: got outcome '%s' expected rolled back", report.Outcome)
+		}
+		if version := instance.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d expected 0", version)
+		}
+		if tableExists(db, "tampered") {
+			t.Error("Instance.Latest: expected run to be rolled back")
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Schema describes the tables of a database, as read by Introspect.
+type Schema struct {
+	Tables []Table
+}
+
+// Table describes a single table of a database.
+type Table struct {
+	Name    string
+	Comment string
+	Columns []Column
+	Indexes []Index
+}
+
+// Column describes a single column of a table.
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  string // Expression of the default value, or empty if there is none
+	Comment  string
+}
+
+// Index describes a single index of a table.
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns string // Comma-separated columns or expressions indexed
+}
+
+// Introspect reads the tables of the database along with their columns and
+// indexes, omitting those which record the state of migrations. Comments are
+// included where the database supports them. An error is returned if the
+// Dialect of the Instance does not know how to introspect the database.
+func (instance *Instance) Introspect() (*Schema, error) {
+	dialect, ok := instance.dialect.(*dialect)
+	if !ok || dialect.tables == "" {
+		return nil, NewFatalf("Instance.Introspect: dialect '%s' does not support introspection",
+			instance.dialect.Name())
+	}
+
+	schema := &Schema{}
+	err := query(instance.db, dialect.tables, nil, func(rows *sql.Rows) error {
+		var table Table
+		if err := rows.Scan(&table.Name, &table.Comment); err != nil {
+			return err
+		}
+
+		if table.Name != "metadata" && !strings.HasPrefix(table.Name, "migrate_") {
+			schema.Tables = append(schema.Tables, table)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, NewFatalf("Instance.Introspect: got error while listing tables:\n%s", err)
+	}
+
+	for i := range schema.Tables {
+		table := &schema.Tables[i]
+		err := query(instance.db, dialect.columns, []interface{}{table.Name}, func(rows *sql.Rows) error {
+			var column Column
+			if err := rows.Scan(&column.Name, &column.Type, &column.Nullable, &column.Default,
+				&column.Comment); err != nil {
+				return err
+			}
+
+			table.Columns = append(table.Columns, column)
+			return nil
+		})
+		if err != nil {
+			return nil, NewFatalf("Instance.Introspect: got error while listing columns of table '%s':\n%s",
+				table.Name, err)
+		}
+
+		// Warehouses such as BigQuery and Snowflake have no indexes to list
+		if dialect.indexes == "" {
+			continue
+		}
+
+		err = query(instance.db, dialect.indexes, []interface{}{table.Name}, func(rows *sql.Rows) error {
+			var index Index
+			var columns sql.NullString
+			if err := rows.Scan(&index.Name, &index.Unique, &columns); err != nil {
+				return err
+			}
+
+			index.Columns = columns.String
+			table.Indexes = append(table.Indexes, index)
+			return nil
+		})
+		if err != nil {
+			return nil, NewFatalf("Instance.Introspect: got error while listing indexes of table '%s':\n%s",
+				table.Name, err)
+		}
+	}
+
+	return schema, nil
+}
+
+// query executes the query provided and passes each row returned to fn.
+func query(db *sql.DB, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
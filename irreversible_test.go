@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestIrreversible ensures that migrating down past a version holding a part
+// marked as irreversible is refused without reverting anything.
+func TestIrreversible(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/irreversible")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		err = instance.Goto(0)
+		if irreversible, ok := err.(*ErrIrreversible); !ok {
+			t.Fatalf("Instance.Goto: expected error of type *ErrIrreversible, got:\n%v", err)
+		} else if irreversible.Version != 1 || irreversible.Part != "test.sql" {
+			t.Errorf("ErrIrreversible: got version %d part '%s' expected version 1 part 'test.sql'",
+				irreversible.Version, irreversible.Part)
+		}
+
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM test;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if count != 1 || instance.Version() != 1 {
+			t.Errorf("Instance.Goto: got %d rows at version %d expected nothing to be reverted", count,
+				instance.Version())
+		}
+	})
+}
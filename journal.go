@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx, allowing migration SQL
+// and journal entries to be written with or without a transaction.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// rebind rewrites the `?` placeholders of a query with which migrate records
+// its state as those of the Dialect.
+func (instance *Instance) rebind(query string) string {
+	return rebind(query, numberedPlaceholders(instance.dialect))
+}
+
+// createJournal creates the table in which the parts applied by an in-progress
+// migration are recorded, named table, if it does not already exist.
+func createJournal(exec execer, table string) error {
+	_, err := exec.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + table + `(
+			Version INT NOT NULL,
+			Name VARCHAR(255) NOT NULL,
+			Direction VARCHAR(4) NOT NULL,
+			PRIMARY KEY (Version, Name)
+		);
+	`)
+	return err
+}
+
+// clearJournal removes all journal entries recorded for a migration version.
+func (instance *Instance) clearJournal(exec execer, table string, version int) error {
+	if _, err := exec.Exec(instance.rebind(`DELETE FROM `+table+` WHERE Version = ?;`), version); err != nil {
+		return fmt.Errorf("migrate: failed to clear journal for version %d:\n%s", version, err)
+	}
+
+	return nil
+}
+
+// recordPart adds a journal entry noting that a part of a migration version
+// was successfully applied in the direction specified.
+func (instance *Instance) recordPart(exec execer, table string, version int, name, direction string) error {
+	if _, err := exec.Exec(instance.rebind(`INSERT INTO `+table+` (Version, Name, Direction) `+
+		`VALUES (?, ?, ?);`), version, name, direction); err != nil {
+		return fmt.Errorf("migrate: failed to record part '%s' of version %d in journal:\n%s", name, version, err)
+	}
+
+	return nil
+}
+
+// journal returns the names of all parts of a migration version recorded as
+// having been applied in the direction specified.
+func (instance *Instance) journal(version int, direction string) (map[string]bool, error) {
+	rows, err := instance.db.Query(instance.rebind(`SELECT Name FROM `+instance.table("migrate_journal")+
+		` WHERE Version = ? AND Direction = ?;`), version, direction)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read journal for version %d:\n%s", version, err)
+	}
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("migrate: failed to read journal for version %d:\n%s", version, err)
+		}
+		applied[name] = true
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestResume ensures that a migration run without a transaction leaves the
+// database dirty when a part fails, that Goto refuses to run against a dirty
+// database, and that Resume continues from the first unapplied part.
+func TestResume(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/resume", WithoutTransaction())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		output := &strings.Builder{}
+		instance.Output = output
+
+		expectError(t, "Instance.Resume", "clean database", instance.Resume, "no interrupted migration")
+		expectError(t, "Instance.Latest", "missing prerequisite table", instance.Latest, "database is dirty")
+
+		if !instance.Dirty() {
+			t.Fatal("Instance.Dirty: expected database to be dirty after failed run")
+		}
+		if version := instance.Version(); version != 1 {
+			t.Errorf("Instance.Version: got '%d' expected '1' after failed run", version)
+		}
+		if applied, err := instance.journal(2, "up"); err != nil {
+			t.Error("Instance.journal: got error:\n", err)
+		} else if len(applied) != 1 || !applied["a.sql"] {
+			t.Errorf("Instance.journal: got '%#v' expected only 'a.sql' to be applied", applied)
+		}
+
+		expectError(t, "Instance.Goto", "dirty database", func() error { return instance.Goto(0) }, "Resume")
+
+		if _, err := db.Exec(`CREATE TABLE prerequisite(ID INT PRIMARY KEY);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		output.Reset()
+		if err := instance.Resume(); err != nil {
+			t.Fatal("Instance.Resume: got error:\n", err)
+		}
+		if !strings.Contains(output.String(), "Skipped 'a.sql'") {
+			t.Errorf("Instance.Resume: expected 'a.sql' to be skipped, got:\n%s", output.String())
+		}
+		if instance.Dirty() {
+			t.Error("Instance.Dirty: expected database to be clean after resuming")
+		}
+		if version := instance.Version(); version != 2 {
+			t.Errorf("Instance.Version: got '%d' expected '2' after resuming", version)
+		}
+		if applied, err := instance.journal(2, "up"); err != nil {
+			t.Error("Instance.journal: got error:\n", err)
+		} else if len(applied) != 0 {
+			t.Errorf("Instance.journal: got '%#v' expected journal to be cleared", applied)
+		}
+
+		if err := instance.Goto(0); err != nil {
+			t.Error("Instance.Goto: got error:\n", err)
+		}
+	})
+}
+
+// TestResumeHalt ensures that a migration run without a transaction stops at
+// the first part which fails, neither applying, journaling, nor reporting the
+// later parts of the version, and that Resume then applies them.
+func TestResumeHalt(t *testing.T) {
+	root := CopyTree(t, "testing/resume")
+	if err := ioutil.WriteFile(filepath.Join(root, "version_2", "c.sql"), []byte("-- @migrate/up\n\n"+
+		"CREATE TABLE c(ID INT PRIMARY KEY);\n\n-- @migrate/down\n\nDROP TABLE c;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithoutTransaction())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		err = instance.Latest()
+		applyErr, ok := err.(*ErrApply)
+		if !ok {
+			t.Fatalf("Instance.Latest: got error '%v' expected *ErrApply", err)
+		}
+
+		report := applyErr.Report
+		if report.Outcome != LeftDirty || len(report.Failed) != 1 || report.Failed[0].Part != "b.sql" {
+			t.Errorf("Instance.Latest: got outcome '%s' and failed parts %#v expected only 'b.sql' to fail",
+				report.Outcome, report.Failed)
+		}
+		for _, result := range report.Applied {
+			if result.Part == "c.sql" {
+				t.Error("Instance.Latest: expected 'c.sql' not to be reported as applied after 'b.sql' failed")
+			}
+		}
+		if tableExists(db, "c") {
+			t.Error("Instance.Latest: expected 'c.sql' not to be applied after 'b.sql' failed")
+		}
+		if applied, err := instance.journal(2, "up"); err != nil {
+			t.Error("Instance.journal: got error:\n", err)
+		} else if len(applied) != 1 || !applied["a.sql"] {
+			t.Errorf("Instance.journal: got '%#v' expected only 'a.sql' to be applied", applied)
+		}
+
+		if _, err := db.Exec(`CREATE TABLE prerequisite(ID INT PRIMARY KEY);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		} else if err := instance.Resume(); err != nil {
+			t.Fatal("Instance.Resume: got error:\n", err)
+		} else if !tableExists(db, "b") || !tableExists(db, "c") {
+			t.Error("Instance.Resume: expected 'b.sql' and 'c.sql' to be applied")
+		}
+	})
+}
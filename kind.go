@@ -0,0 +1,38 @@
+package migrate
+
+import "context"
+
+// Kind classifies a part by the changes which it makes, set with the
+// `-- @migrate/kind <kind>` directive. Parts are KindSchema unless marked
+// otherwise.
+type Kind string
+
+// The kinds of part.
+const (
+	KindSchema Kind = "schema" // Alters the structure of the database
+	KindData   Kind = "data"   // Alters only the rows held, such as a backfill
+)
+
+// LatestSchema applies any new migrations available exactly as Latest does,
+// except that parts of KindData are deferred rather than applied, so that
+// heavy backfills need not delay a deploy. Deferred parts are queued in the
+// database and applied by a later call to LatestData or RunDeferred, such as
+// from a post-deploy job. Migrating down past a version whose parts are still
+// queued skips them, as they were never applied.
+func (instance *Instance) LatestSchema() error {
+	return instance.run(context.Background(), instance.latest(), false, KindData)
+}
+
+// LatestData applies every part queued by LatestSchema or an earlier run, as
+// RunDeferred does. It is intended to be called from a post-deploy job once
+// LatestSchema has applied the schema changes.
+func (instance *Instance) LatestData() error {
+	return instance.RunDeferred(context.Background())
+}
+
+// PlanSchema returns every statement which LatestSchema would execute, as
+// Plan does for Goto, such that parts of KindData are left out along with
+// those marked deferred.
+func (instance *Instance) PlanSchema() ([]PlannedStatement, error) {
+	return instance.planStatements(instance.Version(), instance.latest(), KindData)
+}
@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestDataParts ensures that data parts are deferred by LatestSchema and
+// applied by LatestData.
+func TestDataParts(t *testing.T) {
+	slug := func(db *sql.DB) string {
+		var slug sql.NullString
+		if err := db.QueryRow(`SELECT Slug FROM users WHERE ID = 1;`).Scan(&slug); err != nil {
+			t.Fatal("db.QueryRow: got error:\n", err)
+		}
+		return slug.String
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/data")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if kind := instance.migrations[1].Parts[1].Kind; kind != KindData {
+			t.Errorf("Part.Kind: got '%s' expected 'data'", kind)
+		}
+		if kind := instance.migrations[1].Parts[0].Kind; kind != KindSchema {
+			t.Errorf("Part.Kind: got '%s' expected 'schema'", kind)
+		}
+
+		if err := instance.LatestSchema(); err != nil {
+			t.Fatal("Instance.LatestSchema: got error:\n", err)
+		}
+		if version := instance.Version(); version != 2 {
+			t.Errorf("Instance.Version: got %d expected 2", version)
+		}
+		if value := slug(db); value != "" {
+			t.Errorf("Instance.LatestSchema: got slug '%s' expected data part to be deferred", value)
+		}
+		if skipped := instance.Report().Skipped; len(skipped) != 1 || skipped[0].Part != "2_backfill.sql" {
+			t.Errorf("Instance.Report: got skipped parts '%v' expected '2_backfill.sql'", skipped)
+		}
+
+		pending, err := instance.Deferred()
+		if err != nil {
+			t.Fatal("Instance.Deferred: got error:\n", err)
+		} else if len(pending) != 1 || pending[0].Version != 1 || pending[0].Part != "2_backfill.sql" {
+			t.Errorf("Instance.Deferred: got '%v' expected part '2_backfill.sql' of version 1", pending)
+		}
+
+		if err := instance.LatestData(); err != nil {
+			t.Fatal("Instance.LatestData: got error:\n", err)
+		}
+		if value := slug(db); value != "jane" {
+			t.Errorf("Instance.LatestData: got slug '%s' expected 'jane'", value)
+		}
+		if pending, _ := instance.Deferred(); len(pending) != 0 {
+			t.Errorf("Instance.Deferred: got '%v' expected none after LatestData", pending)
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		} else if last := history[len(history)-1]; last.Part != "2_backfill.sql" {
+			t.Errorf("Instance.History: got last entry '%#v' expected '2_backfill.sql'", last)
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/data")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.LatestSchema(); err != nil {
+			t.Fatal("Instance.LatestSchema: got error:\n", err)
+		}
+
+		// The pending data part is never reverted
+		if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if skipped := instance.Report().Skipped; len(skipped) != 1 || skipped[0].Part != "2_backfill.sql" {
+			t.Errorf("Instance.Report: got skipped parts '%v' expected '2_backfill.sql'", skipped)
+		}
+		if pending, _ := instance.Deferred(); len(pending) != 0 {
+			t.Errorf("Instance.Deferred: got '%v' expected none after reverting", pending)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if value := slug(db); value != "jane" {
+			t.Errorf("Instance.Latest: got slug '%s' expected data part to be applied", value)
+		}
+	})
+
+	_, err := parsePart("kind.sql", []byte("-- @migrate/kind backfill\n-- @migrate/up\nSELECT 1;\n"+
+		"-- @migrate/down\nSELECT 1;\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown kind 'backfill'") {
+		t.Errorf("parsePart: got '%v' expected unknown kind error", err)
+	}
+}
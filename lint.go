@@ -0,0 +1,245 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// regexIdentifier matches a possibly quoted and schema-qualified identifier.
+const regexIdentifier = `((?:[\w$]+|"[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\])(?:\.(?:[\w$]+|"[^"]+"|` + "`[^`]+`" +
+	`|\[[^\]]+\]))?)`
+
+var (
+	// regexCreate matches a statement which creates an object, capturing its
+	// kind and name, along with the table on which an index or trigger is
+	// created if any.
+	regexCreate = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:(?:TEMP|TEMPORARY|UNIQUE)\s+)?` +
+		`(TABLE|INDEX|VIEW|TRIGGER|SEQUENCE|SCHEMA|TYPE|FUNCTION)\s+(?:IF\s+NOT\s+EXISTS\s+)?` + regexIdentifier +
+		`(?:.*?\bON\s+` + regexIdentifier + `)?`)
+	// regexDrop matches a statement which drops one or more objects,
+	// capturing their kind and the list of names.
+	regexDrop = regexp.MustCompile(`(?is)^DROP\s+(TABLE|INDEX|VIEW|TRIGGER|SEQUENCE|SCHEMA|TYPE|FUNCTION)\s+` +
+		`(?:IF\s+EXISTS\s+)?(.+?)(?:\s+(?:CASCADE|RESTRICT))?\s*;?\s*$`)
+	// regexAddColumn matches a statement which adds a column to a table.
+	regexAddColumn = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?` + regexIdentifier +
+		`\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?` + regexIdentifier)
+	// regexDropColumn matches a statement which drops a column from a table.
+	regexDropColumn = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?` + regexIdentifier +
+		`\s+DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?` + regexIdentifier)
+)
+
+// schemaObject identifies an object of the schema created or dropped by a
+// statement.
+type schemaObject struct {
+	kind  string // Kind of object, such as "table" or "column"
+	name  string // Name of the object, with a column qualified by its table
+	table string // Table to which an index, trigger, or column belongs, if any
+}
+
+// String returns a description of the object, such as "table 'users'".
+func (object schemaObject) String() string {
+	return fmt.Sprintf("%s '%s'", object.kind, object.name)
+}
+
+// Lint inspects the SQL of every part held by the Instance, returning a
+// Diagnostic for every likely mistake found. Lint does not access the
+// database. Lint checks that the down migration of each reversible part
+// plausibly reverses its up migration, by comparing the tables, columns,
+// indexes, and other objects which each creates and drops. For example, a
+// part whose up migration creates three tables but whose down migration drops
+// only two is reported. The comparison is a heuristic based upon the text of
+// each statement, and may be fooled by unusual SQL.
+//
+// Lint also checks the names of parts across the whole tree, reporting parts
+// which share a name with a part of another version but reference none of the
+// same tables, parts whose numeric prefixes would be applied out of order as
+// parts are applied in lexicographic order, and trees which mix zero-padded
+// and unpadded numeric prefixes.
+func (instance *Instance) Lint() []Diagnostic {
+	diagnostics := make([]Diagnostic, 0)
+	instance.lint(func(check, remediation, format string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{Check: check, Message: fmt.Sprintf(format, args...),
+			Remediation: remediation})
+	})
+
+	return diagnostics
+}
+
+// lint runs the checks made by Lint, passing each problem found to add.
+func (instance *Instance) lint(add func(check, remediation, format string, args ...interface{})) {
+	for _, version := range instance.List() {
+		for _, part := range instance.migrations[version].Parts {
+			if part.Irreversible {
+				continue
+			}
+
+			upCreated, upDropped := schemaChanges(part.UpStatements)
+			downCreated, downDropped := schemaChanges(part.DownStatements)
+
+			for _, object := range upCreated {
+				if !reverted(object, downDropped) {
+					add("down-coverage", "Drop the object in the down migration, or mark the part "+
+						"irreversible if it cannot be reverted.", "part '%s' of version %d creates %s in its up "+
+						"migration, which its down migration does not drop", part.Name, version, object)
+				}
+			}
+
+			for _, object := range upDropped {
+				if !reverted(object, downCreated) {
+					add("down-coverage", "Recreate the object in the down migration, or mark the part "+
+						"irreversible if it cannot be reverted.", "part '%s' of version %d drops %s in its up "+
+						"migration, which its down migration does not recreate", part.Name, version, object)
+				}
+			}
+		}
+	}
+
+	instance.lintNames(add)
+}
+
+// regexPrefix matches the numeric prefix of a part name, such as `02` within
+// `02_users.sql`.
+var regexPrefix = regexp.MustCompile(`^(\d+)[^\d]`)
+
+// lintNames checks the names of the parts held by the Instance, passing each
+// problem found to add.
+func (instance *Instance) lintNames(add func(check, remediation, format string, args ...interface{})) {
+	type occurrence struct {
+		version int
+		part    *Part
+	}
+
+	named := make(map[string]occurrence)
+	var padded, unpadded *occurrence
+	for _, version := range instance.List() {
+		var previous *Part
+		previousNumber := -1
+		for _, part := range instance.migrations[version].Parts {
+			if first, ok := named[part.Name]; !ok {
+				named[part.Name] = occurrence{version, part}
+			} else if !related(first.part, part) {
+				add("part-name", "Rename one of the parts to describe its contents, so that each name identifies "+
+					"a single change.", "part '%s' of version %d references none of the tables "+
+					"referenced by the part of the same name in version %d", part.Name, version, first.version)
+			}
+
+			matches := regexPrefix.FindStringSubmatch(part.Name)
+			if matches == nil {
+				continue
+			}
+
+			if len(matches[1]) > 1 && matches[1][0] == '0' {
+				if padded == nil {
+					padded = &occurrence{version, part}
+				}
+			} else if unpadded == nil {
+				unpadded = &occurrence{version, part}
+			}
+
+			number, _ := strconv.Atoi(matches[1])
+			if number < previousNumber {
+				add("part-order", "Zero-pad the numeric prefixes of the parts so that their lexicographic and "+
+					"numeric order agree.", "part '%s' of version %d is applied before part '%s', as parts are "+
+					"applied in lexicographic rather than numeric order", previous.Name, version, part.Name)
+			}
+			previous, previousNumber = part, number
+		}
+	}
+
+	if padded != nil && unpadded != nil {
+		add("part-padding", "Use numeric prefixes of a single width throughout the tree.", "part names mix "+
+			"zero-padded numeric prefixes, such as '%s' of version %d, with unpadded numeric prefixes, such as "+
+			"'%s' of version %d", padded.part.Name, padded.version, unpadded.part.Name, unpadded.version)
+	}
+}
+
+// reverted reports whether object is among the objects provided, or belongs
+// to a table among them.
+func reverted(object schemaObject, objects []schemaObject) bool {
+	for _, other := range objects {
+		if other.kind == object.kind && other.name == object.name {
+			return true
+		} else if other.kind == "table" && object.table != "" && other.name == object.table {
+			return true
+		}
+	}
+
+	return false
+}
+
+// schemaChanges returns the objects created and dropped by the statements
+// provided.
+func schemaChanges(statements []Statement) ([]schemaObject, []schemaObject) {
+	created := make([]schemaObject, 0)
+	dropped := make([]schemaObject, 0)
+	for _, statement := range statements {
+		_, sql := splitLeadingComments(statement.SQL)
+		if matches := regexCreate.FindStringSubmatch(sql); matches != nil {
+			object := schemaObject{kind: strings.ToLower(matches[1]), name: identifier(matches[2])}
+			if object.kind == "index" || object.kind == "trigger" {
+				object.table = identifier(matches[3])
+			}
+			created = append(created, object)
+		} else if matches := regexDrop.FindStringSubmatch(sql); matches != nil {
+			for _, name := range strings.Split(matches[2], ",") {
+				// Discard anything following the name, such as the table of a MySQL index
+				if fields := strings.Fields(name); len(fields) > 0 {
+					dropped = append(dropped, schemaObject{kind: strings.ToLower(matches[1]),
+						name: identifier(fields[0])})
+				}
+			}
+		} else if matches := regexAddColumn.FindStringSubmatch(sql); matches != nil && !isConstraint(matches[2]) {
+			table := identifier(matches[1])
+			created = append(created, schemaObject{kind: "column", name: table + "." + identifier(matches[2]),
+				table: table})
+		} else if matches := regexDropColumn.FindStringSubmatch(sql); matches != nil &&
+			!isConstraint(matches[2]) {
+			table := identifier(matches[1])
+			dropped = append(dropped, schemaObject{kind: "column", name: table + "." + identifier(matches[2]),
+				table: table})
+		}
+	}
+
+	return created, dropped
+}
+
+// isConstraint reports whether the word following ADD or DROP within an ALTER
+// TABLE statement introduces something other than a column.
+func isConstraint(word string) bool {
+	switch strings.ToUpper(word) {
+	case "CONSTRAINT", "PRIMARY", "FOREIGN", "UNIQUE", "CHECK", "INDEX", "KEY":
+		return true
+	}
+
+	return false
+}
+
+// identifier returns the identifier provided without quotes and lowercased,
+// so that differently quoted references to the same object compare equal.
+func identifier(name string) string {
+	return strings.ToLower(strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "").Replace(name))
+}
+
+// regexTableReference matches a reference to a table within a statement,
+// capturing its name.
+var regexTableReference = regexp.MustCompile(`(?i)\b(?:TABLE|INTO|FROM|UPDATE|JOIN|ON)\s+` +
+	`(?:IF\s+(?:NOT\s+)?EXISTS\s+)?` + regexIdentifier)
+
+// related reports whether two parts reference any of the same tables, as
+// parts sharing a name commonly evolve the same table across versions.
+func related(a, b *Part) bool {
+	tables := make(map[string]bool)
+	for _, matches := range regexTableReference.FindAllStringSubmatch(a.statementText("up")+"\n"+a.statementText("down"), -1) {
+		tables[identifier(matches[1])] = true
+	}
+
+	for _, matches := range regexTableReference.FindAllStringSubmatch(b.statementText("up")+"\n"+b.statementText("down"), -1) {
+		if tables[identifier(matches[1])] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/lint")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		messages := make([]string, 0)
+		for _, diagnostic := range instance.Lint() {
+			if diagnostic.Check != "down-coverage" {
+				t.Errorf("Instance.Lint: got unexpected check '%s'", diagnostic.Check)
+			}
+			messages = append(messages, diagnostic.Message)
+		}
+
+		expected := []string{
+			"part 'tables.sql' of version 1 creates table 'users' in its up migration, which its down migration " +
+				"does not drop",
+			"part 'columns.sql' of version 2 creates column 'users.phone' in its up migration, which its down " +
+				"migration does not drop",
+			"part 'columns.sql' of version 2 drops index 'posts_user' in its up migration, which its down " +
+				"migration does not recreate",
+		}
+		if !reflect.DeepEqual(messages, expected) {
+			t.Errorf("Instance.Lint: got '%#v' expected '%#v'", messages, expected)
+		}
+
+		checks := make(map[string]int)
+		for _, diagnostic := range instance.Doctor() {
+			checks[diagnostic.Check]++
+		}
+		if checks["down-coverage"] != 3 {
+			t.Errorf("Instance.Doctor: got %d down-coverage diagnostics expected 3", checks["down-coverage"])
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		if diagnostics := instance.Lint(); len(diagnostics) != 0 {
+			t.Errorf("Instance.Lint: got '%v' expected no diagnostics", diagnostics)
+		}
+	})
+}
+
+// TestLintNames ensures that confusing part names across the tree are
+// reported.
+func TestLintNames(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/names")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		messages := make(map[string]string)
+		for _, diagnostic := range instance.Lint() {
+			if _, ok := messages[diagnostic.Check]; ok {
+				t.Errorf("Instance.Lint: got more than one '%s' diagnostic", diagnostic.Check)
+			}
+			messages[diagnostic.Check] = diagnostic.Message
+		}
+
+		expected := map[string]string{
+			"part-name": "part '2_posts.sql' of version 3 references none of the tables referenced by the part of " +
+				"the same name in version 1",
+			"part-order": "part '10_index.sql' of version 2 is applied before part '2_tags.sql', as parts are " +
+				"applied in lexicographic rather than numeric order",
+			"part-padding": "part names mix zero-padded numeric prefixes, such as '01_cleanup.sql' of version 3, " +
+				"with unpadded numeric prefixes, such as '1_users.sql' of version 1",
+		}
+		if !reflect.DeepEqual(messages, expected) {
+			t.Errorf("Instance.Lint: got '%#v' expected '%#v'", messages, expected)
+		}
+	})
+
+	newPart := func(up, down string) *Part {
+		part, err := parsePart("part.sql", []byte("-- @migrate/up\n"+up+"\n-- @migrate/down\n"+down))
+		if err != nil {
+			t.Fatal("parsePart: got error:\n", err)
+		}
+		return part
+	}
+
+	a := newPart("CREATE TABLE users(ID INT PRIMARY KEY);", "DROP TABLE users;")
+	b := newPart("ALTER TABLE \"Users\" ADD COLUMN Name TEXT;", "ALTER TABLE users DROP COLUMN Name;")
+	c := newPart("INSERT INTO posts(ID) VALUES (1);", "DELETE FROM posts WHERE ID = 1;")
+	d := newPart("UPDATE accounts SET Active = 1\nFROM\nusers;", "SELECT 1;")
+	if !related(a, b) || related(a, c) || !related(a, d) {
+		t.Errorf("related: got %t, %t, and %t expected true, false, and true", related(a, b), related(a, c),
+			related(a, d))
+	}
+}
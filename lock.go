@@ -0,0 +1,87 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// createLock creates the table holding the migration lock, named table, if it
+// does not already exist. The table only ever holds a single row, present
+// while some process is applying migrations.
+func createLock(exec execer, table string) error {
+	_, err := exec.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + table + `(
+			ID INT PRIMARY KEY,
+			Holder VARCHAR(255) NOT NULL,
+			Heartbeat BIGINT NOT NULL
+		);
+	`)
+	return err
+}
+
+// newHolder returns a string identifying the current process as the holder of
+// the migration lock.
+func newHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s:%d:%x", hostname, os.Getpid(), time.Now().UnixNano())
+}
+
+// lock acquires the migration lock, returning an *ErrLocked if it is already
+// held by another process. If WithStaleLock is in use, a lock which has not
+// been refreshed within the configured duration is taken over.
+func (instance *Instance) lock() error {
+	now := instance.clock.Now().Unix()
+	if _, err := instance.db.Exec(instance.rebind(`INSERT INTO `+instance.table("migrate_lock")+
+		` (ID, Holder, Heartbeat) VALUES (1, ?, ?);`), instance.holder, now); err == nil {
+		return nil
+	}
+
+	var holder string
+	var heartbeat int64
+	if err := instance.db.QueryRow(`SELECT Holder, Heartbeat FROM `+instance.table("migrate_lock")+
+		` WHERE ID = 1;`).Scan(&holder, &heartbeat); err != nil {
+		return NewFatalf("Instance.Goto: got error while acquiring migration lock:\n%s", err)
+	}
+
+	refreshed := time.Unix(heartbeat, 0)
+	if instance.staleLock > 0 && instance.since(refreshed) > instance.staleLock {
+		// Only take over the lock if it has not been refreshed in the meantime
+		res, err := instance.db.Exec(instance.rebind(`UPDATE `+instance.table("migrate_lock")+` SET Holder = ?, `+
+			`Heartbeat = ? WHERE ID = 1 AND Holder = ? AND Heartbeat = ?;`), instance.holder, now, holder, heartbeat)
+		if err != nil {
+			return NewFatalf("Instance.Goto: got error while taking over stale migration lock:\n%s", err)
+		} else if affected, err := res.RowsAffected(); err == nil && affected == 1 {
+			instance.say(MessageStaleLock, MessageData{Holder: holder})
+			return nil
+		}
+	}
+
+	return &ErrLocked{Holder: holder, Heartbeat: refreshed}
+}
+
+// refreshLock records the current time as the most recent heartbeat of the
+// migration lock held by the Instance.
+func (instance *Instance) refreshLock() error {
+	if _, err := instance.db.Exec(instance.rebind(`UPDATE `+instance.table("migrate_lock")+
+		` SET Heartbeat = ? WHERE ID = 1 AND Holder = ?;`),
+		instance.clock.Now().Unix(), instance.holder); err != nil {
+		return fmt.Errorf("migrate: failed to refresh migration lock:\n%s", err)
+	}
+
+	return nil
+}
+
+// unlock releases the migration lock held by the Instance.
+func (instance *Instance) unlock() error {
+	if _, err := instance.db.Exec(instance.rebind(`DELETE FROM `+instance.table("migrate_lock")+
+		` WHERE ID = 1 AND Holder = ?;`), instance.holder); err != nil {
+		return NewFatalf("Instance.Goto: got error while releasing migration lock:\n%s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLock ensures that migrations cannot be applied while the migration lock
+// is held by another process, that the lock is released after each run, and
+// that a stale lock is only taken over if WithStaleLock is in use.
+func TestLock(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if _, err := db.Exec(`INSERT INTO migrate_lock (ID, Holder, Heartbeat) VALUES (1, 'other', ?);`,
+			time.Now().Add(-time.Hour).Unix()); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		err = instance.Latest()
+		if lockErr, ok := err.(*ErrLocked); !ok {
+			t.Fatalf("Instance.Latest: expected error of type *ErrLocked, got:\n%s", err)
+		} else if lockErr.Holder != "other" {
+			t.Errorf("ErrLocked: got holder '%s' expected 'other'", lockErr.Holder)
+		}
+
+		stale, err := NewInstance(db, "testing/working", WithStaleLock(time.Minute))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		stale.Output = &strings.Builder{}
+
+		if err := stale.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error with stale lock:\n", err)
+		}
+
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM migrate_lock;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if count != 0 {
+			t.Errorf("Instance.Latest: got %d lock rows after run expected 0", count)
+		}
+
+		if err := instance.Goto(0); errors.Is(err, CodeLocked) {
+			t.Error("Instance.Goto: got error after lock was released:\n", err)
+		}
+	})
+}
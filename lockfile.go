@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// LockFile is the name of the file, placed at the root of an instance
+// directory, which pins the checksum of every part.
+const LockFile = "migrate.lock"
+
+// WriteLockFile records the checksum of every part held by the Instance in
+// the LockFile at the root of the instance directory, replacing any existing
+// LockFile. Each line holds the path of a part followed by its Checksum, which
+// is compared when verifying the LockFile, and its RawChecksum, which is
+// recorded for reference, each prefixed by the name of the ChecksumAlgorithm
+// with which it was computed, while each asset is recorded by its Checksum
+// alone. The LockFile should be regenerated whenever new migrations are added
+// and committed alongside them, and may be regenerated after switching
+// algorithms with WithChecksumAlgorithm, though a LockFile written with any
+// known algorithm continues to be verified.
+func (instance *Instance) WriteLockFile() error {
+	if instance.partial() {
+		return NewFatalf("Instance.WriteLockFile: cannot write lock file for migrations loaded with " +
+			"WithVersionRange")
+	}
+
+	algorithm := instance.loader.algorithm.orDefault().Name
+	var builder strings.Builder
+	for _, version := range instance.List() {
+		migration := instance.migrations[version]
+		for _, part := range migration.Parts {
+			fmt.Fprintf(&builder, "%s/%s %s:%s %s:%s\n", migration.Name, part.Name, algorithm, part.Checksum,
+				algorithm, part.RawChecksum)
+		}
+		for _, asset := range migration.Assets {
+			fmt.Fprintf(&builder, "%s/%s %s:%s\n", migration.Name, asset.Name, algorithm, asset.Checksum)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(instance.root, LockFile), []byte(builder.String()),
+		0644); err != nil {
+		return NewFatalf("Instance.WriteLockFile: got error while writing lock file:\n%s", err)
+	}
+
+	return nil
+}
+
+// verifyLockFile compares the checksum of every part held by the Instance to
+// that recorded in the LockFile, returning an *ErrChecksumMismatch for the
+// first part which has been modified or removed.
+func (instance *Instance) verifyLockFile() error {
+	actual := make(map[string]*Part)
+	for _, version := range instance.List() {
+		migration := instance.migrations[version]
+		for _, part := range migration.Parts {
+			actual[migration.Name+"/"+part.Name] = part
+		}
+		for _, asset := range migration.Assets {
+			actual[migration.Name+"/"+asset.Name] = asset.lockEntry()
+		}
+	}
+
+	return instance.compareLockFile(actual)
+}
+
+// compareLockFile compares the checksums of the parts provided, keyed by the
+// path of each part relative to the instance directory, to those recorded in
+// the LockFile, returning an *ErrChecksumMismatch for the first part which has
+// been modified or removed. Parts not yet recorded in the LockFile are
+// ignored. A LockFile written before checksums were normalized records only
+// the raw checksum of each part, which is then compared instead. A checksum
+// recorded with an algorithm other than that of the Instance is recomputed
+// with the algorithm recorded.
+func (instance *Instance) compareLockFile(actual map[string]*Part) error {
+	contents, err := ioutil.ReadFile(filepath.Join(instance.root, LockFile))
+	if err != nil {
+		return NewFatalf("NewInstance: got error while reading lock file, generate one with "+
+			"Instance.WriteLockFile:\n%s", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		} else if len(fields) != 2 && len(fields) != 3 {
+			return NewFatalf("NewInstance: got malformed line in lock file: '%s'", scanner.Text())
+		}
+
+		// Parts of versions outside the range provided with WithVersionRange were never loaded
+		if instance.partial() {
+			var version int
+			if _, err := fmt.Sscanf(fields[0], "version_%d/", &version); err == nil && !instance.inRange(version) {
+				continue
+			}
+		}
+
+		name, expected := splitDigest(fields[1])
+		algorithm, known := instance.lookupAlgorithm(name)
+		if !known {
+			return NewFatalf("NewInstance: got unknown checksum algorithm '%s' in lock file, provide it with "+
+				"WithChecksumAlgorithm", name)
+		}
+
+		checksum := ""
+		if part, ok := actual[fields[0]]; ok {
+			normalized, raw := part.Checksum, part.RawChecksum
+			if algorithm.Name != instance.loader.algorithm.orDefault().Name {
+				normalized, raw = algorithm.checksums(part.contents)
+			}
+
+			checksum = normalized
+			if len(fields) == 2 {
+				checksum = raw
+			}
+		}
+
+		if checksum != expected {
+			return &ErrChecksumMismatch{Part: fields[0], Expected: expected, Actual: checksum}
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,174 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestLockFile ensures that NewInstance refuses to load a tree in which a
+// part has been modified or removed since the LockFile was written, while
+// still allowing new parts to be added.
+func TestLockFile(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	extra := filepath.Join(root, "version_2", "extra.sql")
+	if err := ioutil.WriteFile(extra, []byte("-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 1;\n"),
+		0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		expectError(t, "NewInstance", "missing lock file", func() error {
+			_, err := NewInstance(db, root, WithStrictLockFile())
+			return err
+		}, "generate one with Instance.WriteLockFile")
+
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+
+		if _, err := NewInstance(db, root, WithStrictLockFile()); err != nil {
+			t.Error("NewInstance: got error with matching lock file:\n", err)
+		}
+
+		if err := os.MkdirAll(filepath.Join(root, "version_4"), 0755); err != nil {
+			t.Fatal("os.MkdirAll: got error:\n", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, "version_4", "new.sql"),
+			[]byte("-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 1;\n"), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		if _, err := NewInstance(db, root, WithStrictLockFile()); err != nil {
+			t.Error("NewInstance: got error with new part not in lock file:\n", err)
+		}
+
+		path := filepath.Join(root, "version_2", "test.sql")
+		if err := ioutil.WriteFile(path, []byte("-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 1;\n"),
+			0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		_, err = NewInstance(db, root, WithStrictLockFile())
+		if mismatch, ok := err.(*ErrChecksumMismatch); !ok {
+			t.Errorf("NewInstance: expected error of type *ErrChecksumMismatch with modified part, got:\n%s", err)
+		} else if mismatch.Part != "version_2/test.sql" || !errors.Is(err, CodeChecksumMismatch) {
+			t.Errorf("NewInstance: got mismatch for part '%s' expected 'version_2/test.sql'", mismatch.Part)
+		}
+
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+		if err := os.Remove(extra); err != nil {
+			t.Fatal("os.Remove: got error:\n", err)
+		}
+
+		expectError(t, "NewInstance", "removed part", func() error {
+			_, err := NewInstance(db, root, WithStrictLockFile())
+			return err
+		}, "'version_2/extra.sql' has been removed")
+	})
+}
+
+// TestLockFileReformatted ensures that reformatting a part does not trip the
+// LockFile, while LockFiles recording only raw checksums are still honored.
+func TestLockFileReformatted(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	path := filepath.Join(root, "version_2", "test.sql")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte("-- @migrate/up\r\n-- Rename columns\r\nALTER TABLE test\r\n"+
+			"  RENAME first_name TO FirstName;\r\nALTER TABLE test RENAME last_name TO LastName;\r\n\r\n"+
+			"--@migrate/down\r\nALTER TABLE test RENAME FirstName TO first_name; /* undo */\r\n"+
+			"ALTER TABLE test RENAME LastName TO last_name;\r\n"), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		if _, err := NewInstance(db, root, WithStrictLockFile()); err != nil {
+			t.Error("NewInstance: got error with reformatted part:\n", err)
+		}
+
+		legacy := []byte("version_2/test.sql " + instance.migrations[2].Parts[0].RawChecksum + "\n")
+		if err := ioutil.WriteFile(filepath.Join(root, LockFile), legacy, 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		if _, err := NewInstance(db, root, WithStrictLockFile()); !errors.Is(err, CodeChecksumMismatch) {
+			t.Errorf("NewInstance: expected checksum mismatch against raw checksum, got:\n%v", err)
+		}
+	})
+}
+
+// TestLockFileAlgorithm ensures that a LockFile written with one checksum
+// algorithm is still verified after switching to another.
+func TestLockFileAlgorithm(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+
+		instance, err = NewInstance(db, root, WithChecksumAlgorithm(SHA512), WithStrictLockFile())
+		if err != nil {
+			t.Fatal("NewInstance: got error with lock file written with SHA-256:\n", err)
+		}
+		if checksum := instance.migrations[2].Parts[0].Checksum; len(checksum) != 128 {
+			t.Errorf("Part.Checksum: got '%s' expected a SHA-512 digest", checksum)
+		}
+		if checksum := instance.migrations[2].Checksum(); len(checksum) != 128 {
+			t.Errorf("Migration.Checksum: got '%s' expected a SHA-512 digest", checksum)
+		}
+
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(root, LockFile))
+		if err != nil {
+			t.Fatal("ioutil.ReadFile: got error:\n", err)
+		} else if !strings.Contains(string(contents), "version_2/test.sql sha512:") {
+			t.Errorf("Instance.WriteLockFile: got:\n%s\nexpected SHA-512 digests", contents)
+		}
+
+		if _, err := NewInstance(db, root, WithStrictLockFile()); err != nil {
+			t.Error("NewInstance: got error with lock file written with SHA-512:\n", err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(root, "version_2", "test.sql"),
+			[]byte("-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 1;\n"), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+		if _, err := NewInstance(db, root, WithStrictLockFile()); !errors.Is(err, CodeChecksumMismatch) {
+			t.Errorf("NewInstance: expected checksum mismatch with modified part, got:\n%v", err)
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(root, LockFile), []byte("version_1/test.sql md5:abc\n"),
+			0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+		expectError(t, "NewInstance", "unknown algorithm", func() error {
+			_, err := NewInstance(db, root, WithStrictLockFile())
+			return err
+		}, "unknown checksum algorithm 'md5'")
+	})
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LockTimeout may be implemented by a Dialect to bound the time a statement
+// waits to acquire a lock, as used by WithLockTimeout. LockTimeout returns the
+// statement which sets the timeout for the current transaction only if local
+// is true, or for the session otherwise, and a timeout of zero restores the
+// default. An empty statement is returned if the database has no such
+// setting. IsLockTimeout reports whether err was returned by a statement
+// cancelled because the timeout elapsed.
+type LockTimeout interface {
+	LockTimeout(timeout time.Duration, local bool) string
+	IsLockTimeout(err error) bool
+}
+
+// LockTimeout implements the LockTimeout interface for dialect.
+func (dialect *dialect) LockTimeout(timeout time.Duration, local bool) string {
+	if !dialect.lockTimeout {
+		return ""
+	}
+
+	set := "SET "
+	if local {
+		set = "SET LOCAL "
+	}
+	if timeout <= 0 {
+		return set + "lock_timeout TO DEFAULT;"
+	}
+
+	return fmt.Sprintf("%slock_timeout = '%dms';", set, timeout/time.Millisecond)
+}
+
+// IsLockTimeout implements the LockTimeout interface for dialect, matching the
+// SQLSTATE or message of the error returned by Postgres.
+func (dialect *dialect) IsLockTimeout(err error) bool {
+	if !dialect.lockTimeout || err == nil {
+		return false
+	}
+
+	message := err.Error()
+	return strings.Contains(message, "55P03") || strings.Contains(message, "lock timeout")
+}
+
+// execStatement executes a single statement of a part with executor. If
+// WithLockTimeout is in use and the statement is DDL, the time it waits for
+// locks is bounded as configured, and a statement cancelled because the
+// timeout elapsed is retried after backing off. Within a transaction, each
+// attempt is made within a savepoint, so that a cancelled attempt does not
+// abort the transaction.
+func (instance *Instance) execStatement(ctx context.Context, exec execer, executor Executor, transactional bool,
+	version int, part *Part, statement string) (sql.Result, error) {
+	dialect, ok := instance.dialect.(LockTimeout)
+	if instance.lockTimeout <= 0 || !ok || !isDDL(statement) ||
+		dialect.LockTimeout(instance.lockTimeout, transactional) == "" {
+		return executor.ExecContext(ctx, statement)
+	}
+
+	// Outside of a transaction, the timeout must be set on the connection which executes the statement
+	if db, ok := exec.(*sql.DB); ok && !transactional {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return nil, NewFatalf("Instance.Goto: got error while connecting to database:\n%s", err)
+		}
+		defer conn.Close()
+
+		exec = connExecer{conn}
+		if instance.executor == nil {
+			executor = exec
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if transactional {
+			if _, err := exec.Exec(`SAVEPOINT migrate_lock_timeout;`); err != nil {
+				return nil, NewFatalf("Instance.Goto: got error while creating savepoint for '%s':\n%s",
+					part.Name, err)
+			}
+		}
+
+		if _, err := exec.Exec(dialect.LockTimeout(instance.lockTimeout, transactional)); err != nil {
+			return nil, NewFatalf("Instance.Goto: got error while setting lock timeout:\n%s", err)
+		}
+
+		res, err := executor.ExecContext(ctx, statement)
+		if transactional && err != nil {
+			if _, rollbackErr := exec.Exec(`ROLLBACK TO SAVEPOINT migrate_lock_timeout;`); rollbackErr != nil {
+				return nil, NewFatalf("Instance.Goto: got error while rolling back to savepoint for '%s':\n%s",
+					part.Name, rollbackErr)
+			}
+		} else if transactional {
+			if _, releaseErr := exec.Exec(`RELEASE SAVEPOINT migrate_lock_timeout;`); releaseErr != nil {
+				return nil, NewFatalf("Instance.Goto: got error while releasing savepoint for '%s':\n%s",
+					part.Name, releaseErr)
+			}
+		}
+
+		if _, resetErr := exec.Exec(dialect.LockTimeout(0, transactional)); resetErr != nil {
+			return nil, NewFatalf("Instance.Goto: got error while resetting lock timeout:\n%s", resetErr)
+		}
+
+		if err == nil || !dialect.IsLockTimeout(err) || attempt >= instance.lockRetries {
+			return res, err
+		}
+
+		backoff := instance.lockBackoff << uint(attempt)
+		instance.say(MessageLockRetry, MessageData{Version: version, Part: part.Name, Timeout: instance.lockTimeout,
+			Duration: backoff})
+		instance.log(LevelWarn, "lock timeout", Field{"version", version}, Field{"part", part.Name},
+			Field{"attempt", attempt + 1}, Field{"backoff", backoff})
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-instance.clock.After(backoff):
+		}
+	}
+}
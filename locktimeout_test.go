@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// errTestLockTimeout is returned by lockedExecutor for the DDL it refuses.
+var errTestLockTimeout = errors.New("canceling statement due to lock timeout")
+
+// lockDialect is a Dialect which reports errTestLockTimeout as a lock timeout.
+type lockDialect struct {
+	Dialect
+}
+
+// LockTimeout implements the LockTimeout interface for lockDialect.
+func (lockDialect) LockTimeout(timeout time.Duration, local bool) string {
+	return "SELECT 1;"
+}
+
+// IsLockTimeout implements the LockTimeout interface for lockDialect.
+func (lockDialect) IsLockTimeout(err error) bool {
+	return err == errTestLockTimeout
+}
+
+// lockedExecutor is an Executor which fails the first DDL statements it is
+// given with errTestLockTimeout, and otherwise does nothing.
+type lockedExecutor struct {
+	failures   int
+	statements int
+}
+
+// ExecContext implements the Executor interface for lockedExecutor.
+func (executor *lockedExecutor) ExecContext(ctx context.Context, query string,
+	args ...interface{}) (sql.Result, error) {
+	executor.statements++
+	if isDDL(query) && executor.failures > 0 {
+		executor.failures--
+		return nil, errTestLockTimeout
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+// TestLockTimeout ensures that DDL cancelled by a lock timeout is retried as
+// configured with WithLockTimeout, and that the statements setting the
+// timeout are those expected of Postgres.
+func TestLockTimeout(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		executor := &lockedExecutor{failures: 2}
+		instance, err := NewInstance(db, "testing/meta", WithDialect(lockDialect{SQLite}),
+			WithExecutor(executor), WithLockTimeout(time.Second, 2, time.Millisecond))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error with retries remaining:\n", err)
+		}
+		if retries := strings.Count(output.String(), "retrying"); retries != 2 || executor.statements != 3 {
+			t.Errorf("Instance.Goto: got %d retries of %d statements expected 2 retries of 3", retries,
+				executor.statements)
+		}
+
+		executor.failures = 2
+		instance.lockRetries = 1
+		expectError(t, "Instance.Goto", "retries exhausted", func() error { return instance.Goto(2) },
+			"lock timeout")
+		if version := instance.Version(); version != 1 {
+			t.Errorf("Instance.Version: got %d expected 1 once retries were exhausted", version)
+		}
+	})
+
+	cases := []struct {
+		timeout  time.Duration
+		local    bool
+		expected string
+	}{
+		{1500 * time.Millisecond, true, "SET LOCAL lock_timeout = '1500ms';"},
+		{time.Second, false, "SET lock_timeout = '1000ms';"},
+		{0, true, "SET LOCAL lock_timeout TO DEFAULT;"},
+	}
+	for _, c := range cases {
+		if statement := Postgres.(LockTimeout).LockTimeout(c.timeout, c.local); statement != c.expected {
+			t.Errorf("Dialect.LockTimeout: got '%s' expected '%s'", statement, c.expected)
+		}
+	}
+	if !Postgres.(LockTimeout).IsLockTimeout(errors.New("ERROR: canceling statement (SQLSTATE 55P03)")) {
+		t.Error("Dialect.IsLockTimeout: expected SQLSTATE 55P03 to be a lock timeout")
+	}
+	if SQLite.(LockTimeout).LockTimeout(time.Second, true) != "" {
+		t.Error("Dialect.LockTimeout: expected no statement from sqlite dialect")
+	}
+}
@@ -0,0 +1,59 @@
+package migrate
+
+// Level is the severity of an event passed to a Logger.
+type Level int
+
+const (
+	// LevelDebug is used for events of interest only while debugging.
+	LevelDebug Level = iota
+	// LevelInfo is used for the routine progress of a run.
+	LevelInfo
+	// LevelWarn is used for failures which did not abort a run, such as the
+	// failure of an optional part.
+	LevelWarn
+	// LevelError is used for failures which aborted a run.
+	LevelError
+)
+
+// String implements the fmt.Stringer interface for Level.
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Field is a single named value attached to an event passed to a Logger.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Logger receives structured events describing the progress of every run
+// made by an Instance, in addition to the messages written to Output. Each
+// event consists of a short constant message, such as "part applied", and
+// fields such as the version and part concerned, so that events may be
+// filtered and aggregated by a logging stack. Adapters for popular logging
+// packages are provided in the adapters directory, those for logrus and
+// zerolog as modules of their own which depend on those packages.
+type Logger interface {
+	Log(level Level, message string, fields ...Field)
+}
+
+// log passes an event to the Logger of the Instance, if any, followed by the
+// ID of the run in progress as the field run_id.
+func (instance *Instance) log(level Level, message string, fields ...Field) {
+	if instance.logger == nil {
+		return
+	} else if instance.activeRun != "" {
+		fields = append(fields, Field{"run_id", instance.activeRun})
+	}
+
+	instance.logger.Log(level, message, fields...)
+}
@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingLogger records each event passed to it in the form "level message".
+type recordingLogger struct {
+	events []string
+	fields [][]Field
+}
+
+func (logger *recordingLogger) Log(level Level, message string, fields ...Field) {
+	logger.events = append(logger.events, fmt.Sprintf("%s %s", level, message))
+	logger.fields = append(logger.fields, fields)
+}
+
+func TestLogger(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		logger := &recordingLogger{}
+		instance, err := NewInstance(db, "testing/meta", WithLogger(logger))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		expected := []string{"info migration started"}
+		for _, part := range instance.migrations[1].Parts {
+			for range part.UpStatements {
+				expected = append(expected, "debug statement applied")
+			}
+			expected = append(expected, "info part applied")
+		}
+		expected = append(expected, "info run finished")
+
+		if !reflect.DeepEqual(logger.events, expected) {
+			t.Errorf("Instance.Goto: got events '%#v' expected '%#v'", logger.events, expected)
+		}
+
+		if fields := logger.fields[1]; len(fields) != 6 || fields[2] != (Field{"statement", 1}) ||
+			fields[3] != (Field{"verb", "CREATE"}) || fields[4] != (Field{"rows_affected", int64(0)}) {
+			t.Errorf("Instance.Goto: got fields '%#v' for applied statement", fields)
+		}
+		if fields := logger.fields[len(expected)-2]; len(fields) != 4 || fields[0] != (Field{"version", 1}) ||
+			fields[1].Key != "part" || fields[2] != (Field{"direction", "up"}) ||
+			fields[3] != (Field{"run_id", instance.Report().RunID}) {
+			t.Errorf("Instance.Goto: got fields '%#v' for applied part", fields)
+		}
+
+		logger.events = nil
+		if err := instance.Goto(1); err == nil {
+			t.Fatal("Instance.Goto: expected error")
+		} else if len(logger.events) != 0 {
+			t.Errorf("Instance.Goto: got events '%#v' expected none when no migrations apply", logger.events)
+		}
+	})
+}
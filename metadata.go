@@ -0,0 +1,85 @@
+package migrate
+
+import "database/sql"
+
+// DefaultVersionKey is the name of the metadata entry in which the version of
+// the database is recorded unless another is provided with WithVersionKey. It
+// is prefixed by the scope provided with WithScope, if any.
+const DefaultVersionKey = "migrateVersion"
+
+// versionKeys returns the key under which the version of the database is
+// recorded, followed by any legacy keys from which it may instead be read.
+func (instance *Instance) versionKeys() []string {
+	key := instance.versionKey
+	if key == "" {
+		key = instance.metaKey(DefaultVersionKey)
+	}
+
+	return append([]string{key}, instance.legacyKeys...)
+}
+
+// MigrateMetadata moves the version recorded in the metadata entry fromKey to
+// the entry toKey within a single transaction, such that a deployment which
+// recorded its version under the global DefaultVersionKey may move to a key of
+// its own, as provided with WithVersionKey, without losing its version. An
+// error is returned if nothing is recorded under fromKey, or if a different
+// version is already recorded under toKey. Alternatively, DefaultVersionKey
+// may be provided to WithVersionKey as a legacy key, in which case the version
+// is moved by the next run.
+func (instance *Instance) MigrateMetadata(fromKey, toKey string) (err error) {
+	if instance.closed {
+		return NewFatalf("Instance.MigrateMetadata: instance has been closed")
+	} else if instance.readOnly {
+		return NewFatalf("Instance.MigrateMetadata: instance is read-only")
+	} else if fromKey == toKey {
+		return NewFatalf("Instance.MigrateMetadata: cannot move '%s' to itself", fromKey)
+	}
+
+	if err := instance.lock(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if unlockErr := instance.unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	transaction, err := instance.db.Begin()
+	if err != nil {
+		return NewFatalf("Instance.MigrateMetadata: got error while starting a transaction:\n%s", err)
+	}
+	defer transaction.Rollback()
+
+	var from, to string
+	table := instance.metadataTable()
+	query := instance.rebind(`SELECT Value FROM ` + table + ` WHERE Name = ?;`)
+	if err := transaction.QueryRow(query, fromKey).Scan(&from); err == sql.ErrNoRows {
+		return NewFatalf("Instance.MigrateMetadata: no metadata entry named '%s'", fromKey)
+	} else if err != nil {
+		return NewFatalf("Instance.MigrateMetadata: got error while reading '%s':\n%s", fromKey, err)
+	}
+
+	err = transaction.QueryRow(query, toKey).Scan(&to)
+	if err == nil && to != from {
+		return NewFatalf("Instance.MigrateMetadata: metadata entry '%s' already holds '%s', expected '%s'", toKey,
+			to, from)
+	} else if err == sql.ErrNoRows {
+		if _, err := transaction.Exec(instance.rebind(`INSERT INTO `+table+` (Name, Value, ValueType) SELECT ?, `+
+			`Value, ValueType FROM `+table+` WHERE Name = ?;`), toKey, fromKey); err != nil {
+			return NewFatalf("Instance.MigrateMetadata: got error while writing '%s':\n%s", toKey, err)
+		}
+	} else if err != nil {
+		return NewFatalf("Instance.MigrateMetadata: got error while reading '%s':\n%s", toKey, err)
+	}
+
+	if _, err := transaction.Exec(instance.rebind(`DELETE FROM `+table+` WHERE Name = ?;`), fromKey); err != nil {
+		return NewFatalf("Instance.MigrateMetadata: got error while removing '%s':\n%s", fromKey, err)
+	}
+
+	if err := transaction.Commit(); err != nil {
+		return NewFatalf("Instance.MigrateMetadata: got error while committing transaction:\n%s", err)
+	}
+
+	return nil
+}
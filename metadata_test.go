@@ -0,0 +1,71 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestVersionKey ensures that the version is recorded under the key provided,
+// read from legacy keys, and moved by MigrateMetadata.
+func TestVersionKey(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		exists := func(key string) bool {
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM metadata WHERE Name = ?;`, key).Scan(&count); err != nil {
+				t.Fatal("db.QueryRow: got error:\n", err)
+			}
+			return count == 1
+		}
+
+		legacy, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		legacy.Output = &strings.Builder{}
+		if err := legacy.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		instance, err := NewInstance(db, "testing/meta", WithVersionKey("billingVersion", DefaultVersionKey))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+		if version := instance.Version(); version != 1 {
+			t.Errorf("Instance.Version: got %d expected 1 read from legacy key", version)
+		}
+
+		if err := instance.Goto(2); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if !exists("billingVersion") || exists(DefaultVersionKey) {
+			t.Error("Instance.Goto: expected version to be moved from legacy key to 'billingVersion'")
+		}
+		if version := legacy.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d expected 0 under default key", version)
+		}
+
+		if err := instance.MigrateMetadata("billingVersion", "tenantVersion"); err != nil {
+			t.Fatal("Instance.MigrateMetadata: got error:\n", err)
+		}
+		tenant, err := NewInstance(db, "testing/meta", WithVersionKey("tenantVersion"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		if version := tenant.Version(); version != 2 || exists("billingVersion") {
+			t.Errorf("Instance.MigrateMetadata: got version %d expected 2 moved to 'tenantVersion'", version)
+		}
+
+		expectError(t, "Instance.MigrateMetadata", "missing key", func() error {
+			return instance.MigrateMetadata("billingVersion", "tenantVersion")
+		}, "no metadata entry named 'billingVersion'")
+
+		if err := legacy.meta.Set(DefaultVersionKey, 1); err != nil {
+			t.Fatal("metaStore.Set: got error:\n", err)
+		}
+		expectError(t, "Instance.MigrateMetadata", "conflicting key", func() error {
+			return instance.MigrateMetadata(DefaultVersionKey, "tenantVersion")
+		}, "already holds '2'")
+	})
+}
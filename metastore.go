@@ -0,0 +1,119 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// metadataTable is the format of the statement which creates the metadata
+// table in which migrate records the version of the database, given its name,
+// as created by the metadb package with which earlier releases recorded it. A
+// Dialect whose database does not accept its types, such as PostgreSQL,
+// provides its own.
+const metadataTable = `
+	CREATE TABLE IF NOT EXISTS %s(
+		ID INT AUTO_INCREMENT PRIMARY KEY,
+		Name VARCHAR(255) NOT NULL UNIQUE,
+		Value BLOB NOT NULL,
+		ValueType TINYINT NOT NULL
+		-- 0 = bool, 1 = int, 2 = float64, 3 = string
+	);
+`
+
+// errNoEntry is returned by metaStore.Get when no entry exists by the name
+// requested.
+type errNoEntry struct {
+	name string
+}
+
+// Error implements the error interface for errNoEntry.
+func (err *errNoEntry) Error() string {
+	return fmt.Sprintf("migrate: no metadata entry named '%s'", err.name)
+}
+
+// metaStore reads and writes the entries of the metadata table, each holding
+// an int, in the same format as the metadb package, such that databases
+// migrated by earlier releases are read alike. Unlike metadb, its queries are
+// written with the placeholders of the Dialect.
+type metaStore struct {
+	db       *sql.DB
+	numbered bool
+	table    string // Name of the metadata table, qualified with its schema
+}
+
+// newMetaStore returns a metaStore for the metadata table of the Instance.
+func (instance *Instance) newMetaStore() *metaStore {
+	return &metaStore{db: instance.db, numbered: numberedPlaceholders(instance.dialect),
+		table: instance.metadataTable()}
+}
+
+// createMetadata creates the metadata table through exec if it does not
+// already exist.
+func (instance *Instance) createMetadata(exec execer) error {
+	statement := metadataTable
+	if dialect, ok := instance.dialect.(*dialect); ok && dialect.metadata != "" {
+		statement = dialect.metadata
+	}
+
+	_, err := exec.Exec(fmt.Sprintf(statement, instance.metadataTable()))
+	return err
+}
+
+// Exists reports whether an entry exists by the name provided. Exists panics
+// if the metadata table cannot be read.
+func (store *metaStore) Exists(name string) bool {
+	var found string
+	err := store.db.QueryRow(rebind(`SELECT Name FROM `+store.table+` WHERE Name = ?;`, store.numbered),
+		name).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false
+	} else if err != nil {
+		panic(fmt.Sprintf("migrate: got error while reading metadata entry '%s':\n%s", name, err))
+	}
+
+	return true
+}
+
+// Get returns the int held by the entry named, or an *errNoEntry if no such
+// entry exists.
+func (store *metaStore) Get(name string) (int, error) {
+	var value string
+	err := store.db.QueryRow(rebind(`SELECT Value FROM `+store.table+` WHERE Name = ?;`, store.numbered),
+		name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, &errNoEntry{name}
+	} else if err != nil {
+		return 0, err
+	}
+
+	res, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("migrate: got malformed value '%s' for metadata entry '%s'", value, name)
+	}
+
+	return res, nil
+}
+
+// Set records value in the entry named, creating it if it does not exist.
+func (store *metaStore) Set(name string, value int) error {
+	query, args := `INSERT INTO `+store.table+` (Name, Value, ValueType) VALUES (?, ?, 1);`, []interface{}{name, value}
+	if store.Exists(name) {
+		query, args = `UPDATE `+store.table+` SET Value = ?, ValueType = 1 WHERE Name = ?;`, []interface{}{value, name}
+	}
+
+	if _, err := store.db.Exec(rebind(query, store.numbered), args...); err != nil {
+		return fmt.Errorf("migrate: failed to record metadata entry '%s':\n%s", name, err)
+	}
+
+	return nil
+}
+
+// Delete removes the entry named, if it exists.
+func (store *metaStore) Delete(name string) error {
+	if _, err := store.db.Exec(rebind(`DELETE FROM `+store.table+` WHERE Name = ?;`, store.numbered), name); err != nil {
+		return fmt.Errorf("migrate: failed to remove metadata entry '%s':\n%s", name, err)
+	}
+
+	return nil
+}
@@ -18,13 +18,27 @@ schema version represented by the migration.
 An arbitrary number of parts may be placed within a single migration directory.
 Unlike instances and migrations, parts are simply SQL files. They follow no
 particular naming conventions, the only requirement being that they end with
-the `.sql` file extension. Their contents, however, must be organized in a
+the `.sql` file extension. Parts ending with `.sql.tmpl` are instead rendered
+with text/template, using the data provided with WithTemplateData, before
+being parsed. Their contents, however, must be organized in a
 specific manner, documented in the Part Structure section below.
 
+The parts of a migration are applied in ascending order of file name,
+compared byte by byte, whichever direction the migration is applied in and
+whatever order the file system lists them in, so that `10_index.sql` is
+applied before `2_table.sql` and `Z.sql` before `a.sql`. Parts whose names
+differ only in case are refused, as they would collide on case-insensitive
+file systems. The order in which a run would apply parts is returned by
+PlanParts.
+
 The lowest allowed schema/migration version is `1`, `0` is reserved to
 represent the initial state of the database before any migrations are applied.
 Gaps between version numbers are also not allowed and will raise an error.
 
+Files and directories which should not be loaded, such as editor backups or
+work in progress, may be listed in a `.migrateignore` file at the root of the
+instance directory, one pattern per line, or provided with WithIgnore.
+
 For example:
 
 	migrate/
@@ -53,7 +67,44 @@ The first line of the file must be either `-- @migrate/up` or
 occur in any order and more than once.
 
 All that is required is that the first line of each file begin with one of
-these tags and that there be at least one of each.
+these tags and that there be at least one of each. Parts which cannot be
+reverted may instead use `-- @migrate/irreversible` in place of a downward
+section, in which case migrating down past their version is refused.
+
+Parts may also be marked with `-- @migrate/optional`, in which case their
+failure is reported in the RunReport but does not abort the run. When a
+transaction is in use, an optional part is applied within a savepoint so that
+any of its statements which did succeed are undone.
+
+A part marked with `-- @migrate/skip-if <query>` is skipped when migrating up
+if its guard query returns a truthy value, making it possible to write
+migrations which tolerate databases partially provisioned by other tooling:
+
+	-- @migrate/skip-if SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'example'
+
+Metadata describing a part, such as its author or the ticket it implements,
+may be provided as key=value pairs with `-- @migrate/meta`. Values containing
+spaces must be quoted. Metadata is available through Part.Meta, `Status`, and
+every entry of the `History` recorded as parts are applied:
+
+	-- @migrate/meta author=jane ticket=PROJ-123 description="add billing tables"
+
+Each section is split into individual statements at every semicolon which
+does not fall within a quoted string, a comment, or the body of a trigger,
+procedure, or function. Statements are applied one at a time, so that when
+one fails the error identifies exactly which statement and on which line of
+the file.
+
+Within the upward section, `-- @migrate/load-csv <file> INTO <table>` inserts
+every row of a CSV file within the same version directory into a table, at
+that point among the statements of the part. The first line of the file names
+the columns, and empty fields are inserted as NULL:
+
+	-- @migrate/load-csv countries.csv INTO countries
+
+Files loaded this way, and any other files matching a pattern provided with
+`WithAssets`, such as a README, are Assets of their migration: they are
+validated and checksummed along with its parts, but never executed.
 
 Basics
 
@@ -85,6 +136,21 @@ With an instance created, migrate can now manage the database schema:
 
 `Goto` may also be used to migrate the schema to any existing version,
 regardless of whether up or down relative to the current.
+
+Interrupted Migrations
+
+Some databases, such as MySQL, implicitly commit most DDL statements, meaning
+that a failed migration cannot be rolled back. For such databases the
+WithoutTransaction option applies parts directly and records each one in a
+journal. If a part fails the database is left dirty, and once the underlying
+issue is resolved `Resume` continues from the first part not yet applied:
+
+	instance, err := migrate.NewInstance(database, "migrate", migrate.WithoutTransaction())
+	...
+	if err := instance.Latest(); err != nil && instance.Dirty() {
+		// Fix the failing part, then
+		err = instance.Resume()
+	}
 */
 package migrate
 
@@ -2,7 +2,9 @@ package migrate
 
 import (
 	"database/sql"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -43,3 +45,45 @@ func RunWithDB(fn func(*sql.DB)) {
 		panic(err)
 	}
 }
+
+// CopyTree copies the instance directory at root into a new temporary
+// directory, returning its path. The directory is removed once the test
+// completes.
+func CopyTree(t *testing.T, root string) string {
+	destination, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+
+	t.Cleanup(func() {
+		os.RemoveAll(destination)
+	})
+
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(destination, relative)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return ioutil.WriteFile(target, contents, 0644)
+	})
+	if err != nil {
+		t.Fatal("CopyTree: got error:\n", err)
+	}
+
+	return destination
+}
@@ -0,0 +1,136 @@
+// Package containers runs migrations against databases started in containers,
+// such as with testcontainers-go, giving realistic integration tests of an
+// instance directory in a single call. The package does not itself depend on
+// any container runtime; instead a Starter adapts whichever is in use. The
+// starters module, github.com/octacian/migrate/migratetest/containers/starters,
+// provides Starters for Postgres and MySQL built on testcontainers-go:
+//
+//	containers.Run(t, starters.Postgres("postgres:16-alpine"), "postgres", "migrations",
+//		func(db *sql.DB, instance *migrate.Instance) {
+//			// Test against the migrated database
+//		})
+package containers
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"testing"
+
+	"github.com/octacian/migrate"
+)
+
+// Container is a running database container.
+type Container interface {
+	// DSN returns the data source name with which to connect to the database.
+	DSN(ctx context.Context) (string, error)
+	// Terminate stops and removes the container.
+	Terminate(ctx context.Context) error
+}
+
+// Starter starts a new database container.
+type Starter func(ctx context.Context) (Container, error)
+
+// terminator is implemented by containers which may be terminated without
+// options. The containers of testcontainers-go take options, and are adapted
+// by the starters module instead.
+type terminator interface {
+	Terminate(ctx context.Context) error
+}
+
+// adapted implements Container for a container which does not provide a DSN
+// method of its own.
+type adapted struct {
+	terminator
+	dsn func(ctx context.Context) (string, error)
+}
+
+// DSN implements the Container interface for adapted.
+func (container adapted) DSN(ctx context.Context) (string, error) {
+	return container.dsn(ctx)
+}
+
+// Adapt returns a Container which terminates container and which uses dsn to
+// determine the data source name, as container runtimes provide the
+// connection string in a different form for every database.
+func Adapt(container interface {
+	Terminate(ctx context.Context) error
+}, dsn func(ctx context.Context) (string, error)) Container {
+	return adapted{terminator: container, dsn: dsn}
+}
+
+// Run starts a container with start, connects to it with the driver provided,
+// applies every migration in root, and then calls fn with the database handle
+// and Instance. The container is terminated once fn returns or the test
+// fails, and any failure to start, connect, or migrate fails the test.
+func Run(t testing.TB, start Starter, driver, root string, fn func(*sql.DB, *migrate.Instance),
+	options ...migrate.Option) {
+	t.Helper()
+	ctx := context.Background()
+
+	container, err := start(ctx)
+	if err != nil {
+		t.Fatal("containers.Run: got error while starting container:\n", err)
+	}
+	defer func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Error("containers.Run: got error while terminating container:\n", err)
+		}
+	}()
+
+	dsn, err := container.DSN(ctx)
+	if err != nil {
+		t.Fatal("containers.Run: got error while fetching data source name:\n", err)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal("containers.Run: got error while opening database:\n", err)
+	}
+	defer db.Close()
+
+	instance, err := migrate.NewInstance(db, root, options...)
+	if err != nil {
+		t.Fatal("containers.Run: got error while loading migrations:\n", err)
+	}
+	instance.Output = ioutil.Discard
+
+	if err := instance.Latest(); err != nil {
+		t.Fatal("containers.Run: got error while applying migrations:\n", err)
+	}
+
+	fn(db, instance)
+}
+
+// RehearseAgainst starts a container with start, connects to it with the
+// driver provided, and calls Instance.RehearseAgainst with it as the sandbox,
+// such that the migrations are applied to a copy of the schema of the
+// database in a throwaway container before the database itself is brought to
+// the target version. The container is terminated once the run is complete.
+func RehearseAgainst(ctx context.Context, start Starter, driver string, instance *migrate.Instance,
+	target int) (report *migrate.RunReport, err error) {
+	container, err := start(ctx)
+	if err != nil {
+		return nil, migrate.NewFatalf("containers.RehearseAgainst: got error while starting container:\n%s", err)
+	}
+	defer func() {
+		if terminateErr := container.Terminate(ctx); terminateErr != nil && err == nil {
+			err = migrate.NewFatalf("containers.RehearseAgainst: got error while terminating container:\n%s",
+				terminateErr)
+		}
+	}()
+
+	dsn, err := container.DSN(ctx)
+	if err != nil {
+		return nil, migrate.NewFatalf("containers.RehearseAgainst: got error while fetching data source name:\n%s",
+			err)
+	}
+
+	sandbox, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, migrate.NewFatalf("containers.RehearseAgainst: got error while opening database:\n%s", err)
+	}
+	defer sandbox.Close()
+
+	return instance.RehearseAgainst(sandbox, target)
+}
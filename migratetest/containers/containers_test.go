@@ -0,0 +1,105 @@
+package containers
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/octacian/migrate"
+)
+
+// file stands in for a container, holding a SQLite database within a
+// temporary directory.
+type file struct {
+	directory string
+}
+
+// Terminate removes the temporary directory.
+func (file *file) Terminate(ctx context.Context) error {
+	return os.RemoveAll(file.directory)
+}
+
+// TestRun ensures that Run applies every migration before calling the test
+// function and terminates the container afterward.
+func TestRun(t *testing.T) {
+	var started *file
+	start := func(ctx context.Context) (Container, error) {
+		directory, err := ioutil.TempDir("", "containers")
+		if err != nil {
+			return nil, err
+		}
+
+		started = &file{directory: directory}
+		return Adapt(started, func(ctx context.Context) (string, error) {
+			return filepath.Join(directory, "test.sqlite"), nil
+		}), nil
+	}
+
+	called := false
+	Run(t, start, "sqlite3", "../../testing/working", func(db *sql.DB, instance *migrate.Instance) {
+		called = true
+		if version := instance.Version(); version != 3 {
+			t.Errorf("Instance.Version: got %d expected 3", version)
+		}
+		if _, err := db.Exec(`SELECT FirstName FROM new_test;`); err != nil {
+			t.Error("sql.DB.Exec: got error with migrated table:\n", err)
+		}
+	})
+
+	if !called {
+		t.Error("Run: expected test function to be called")
+	}
+	if _, err := os.Stat(started.directory); !os.IsNotExist(err) {
+		t.Error("Run: expected container to be terminated")
+	}
+}
+
+// TestRehearseAgainst ensures that RehearseAgainst rehearses the migrations
+// within a container before applying them to the database, and terminates
+// the container afterward.
+func TestRehearseAgainst(t *testing.T) {
+	directory, err := ioutil.TempDir("", "containers")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	db, err := sql.Open("sqlite3", filepath.Join(directory, "target.sqlite"))
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer db.Close()
+
+	instance, err := migrate.NewInstance(db, "../../testing/working")
+	if err != nil {
+		t.Fatal("migrate.NewInstance: got error:\n", err)
+	}
+	instance.Output = ioutil.Discard
+
+	var started *file
+	start := func(ctx context.Context) (Container, error) {
+		sandbox, err := ioutil.TempDir(directory, "sandbox")
+		if err != nil {
+			return nil, err
+		}
+
+		started = &file{directory: sandbox}
+		return Adapt(started, func(ctx context.Context) (string, error) {
+			return filepath.Join(sandbox, "test.sqlite"), nil
+		}), nil
+	}
+
+	if _, err := RehearseAgainst(context.Background(), start, "sqlite3", instance, 3); err != nil {
+		t.Fatal("RehearseAgainst: got error:\n", err)
+	}
+	if version := instance.Version(); version != 3 {
+		t.Errorf("Instance.Version: got %d expected 3", version)
+	}
+	if _, err := os.Stat(started.directory); !os.IsNotExist(err) {
+		t.Error("RehearseAgainst: expected container to be terminated")
+	}
+}
@@ -0,0 +1,76 @@
+// Package starters provides containers.Starter implementations which start
+// Postgres and MySQL databases with testcontainers-go. It is a module of its
+// own so that the testcontainers-go dependency is only required by those who
+// use it, and starting a container requires a running Docker daemon:
+//
+//	containers.Run(t, starters.Postgres("postgres:16-alpine"), "postgres", "migrations",
+//		func(db *sql.DB, instance *migrate.Instance) {
+//			// Test against the migrated database
+//		})
+//
+// The drivers for both databases are registered by importing this package.
+package starters
+
+import (
+	"context"
+
+	_ "github.com/go-sql-driver/mysql" // Registers the "mysql" driver
+	_ "github.com/lib/pq"              // Registers the "postgres" driver
+	"github.com/octacian/migrate/migratetest/containers"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/mysql"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// started implements containers.Container for a testcontainers-go container,
+// whose Terminate method takes options which containers.Adapt does not accept.
+type started struct {
+	container testcontainers.Container
+	dsn       func(ctx context.Context) (string, error)
+}
+
+// DSN implements the containers.Container interface for started.
+func (container started) DSN(ctx context.Context) (string, error) {
+	return container.dsn(ctx)
+}
+
+// Terminate implements the containers.Container interface for started.
+func (container started) Terminate(ctx context.Context) error {
+	return container.container.Terminate(ctx)
+}
+
+// Postgres returns a Starter which runs the Postgres image provided, such as
+// "postgres:16-alpine", to be connected to with the "postgres" driver.
+func Postgres(image string) containers.Starter {
+	return func(ctx context.Context) (containers.Container, error) {
+		container, err := postgres.Run(ctx, image, postgres.BasicWaitStrategies())
+		if err != nil {
+			if container != nil {
+				container.Terminate(ctx)
+			}
+			return nil, err
+		}
+
+		return started{container: container, dsn: func(ctx context.Context) (string, error) {
+			return container.ConnectionString(ctx, "sslmode=disable")
+		}}, nil
+	}
+}
+
+// MySQL returns a Starter which runs the MySQL image provided, such as
+// "mysql:8.0", to be connected to with the "mysql" driver.
+func MySQL(image string) containers.Starter {
+	return func(ctx context.Context) (containers.Container, error) {
+		container, err := mysql.Run(ctx, image)
+		if err != nil {
+			if container != nil {
+				container.Terminate(ctx)
+			}
+			return nil, err
+		}
+
+		return started{container: container, dsn: func(ctx context.Context) (string, error) {
+			return container.ConnectionString(ctx)
+		}}, nil
+	}
+}
@@ -0,0 +1,41 @@
+package starters
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/octacian/migrate"
+	"github.com/octacian/migrate/migratetest/containers"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// TestStarters ensures that the migrations of the conformance tree are
+// applied to a database in each container started, skipping the test when no
+// Docker daemon is available.
+func TestStarters(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	starters := map[string]struct {
+		start  containers.Starter
+		driver string
+	}{
+		"postgres": {Postgres("postgres:16-alpine"), "postgres"},
+		"mysql":    {MySQL("mysql:8.0"), "mysql"},
+	}
+
+	for name, starter := range starters {
+		t.Run(name, func(t *testing.T) {
+			containers.Run(t, starter.start, starter.driver, "../../../testing/conformance",
+				func(db *sql.DB, instance *migrate.Instance) {
+					if version := instance.Version(); version != 2 {
+						t.Errorf("containers.Run: got version %d expected 2", version)
+					}
+
+					var count int
+					if err := db.QueryRow(`SELECT COUNT(*) FROM accounts;`).Scan(&count); err != nil {
+						t.Error("sql.DB.QueryRow: got error:\n", err)
+					}
+				})
+		})
+	}
+}
@@ -0,0 +1,136 @@
+// Package migratetest accelerates tests which require a migrated database.
+// Rather than applying every migration for each test, Template migrates a
+// template database once and then provides each test with its own clone.
+package migratetest
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/octacian/migrate"
+)
+
+// Source creates template databases and clones of them. SQLite and Postgres
+// are the available sources.
+type Source interface {
+	// id identifies the server or directory holding the template databases
+	// of the source, such that sources sharing an id share their templates.
+	id() string
+	// prepare creates the template database identified by key, if it does not
+	// already exist, calling apply to migrate it.
+	prepare(key string, apply func(*sql.DB) error) error
+	// clone returns a handle to a new copy of the template database
+	// identified by key, removing the copy once the test completes.
+	clone(t testing.TB, key string) (*sql.DB, error)
+}
+
+var (
+	mutex    sync.Mutex
+	prepared = make(map[string]error)
+)
+
+// Template returns a handle to a database holding every migration in root,
+// cloned from a template database which is migrated only once per process
+// for each distinct source, instance directory, and set of options. The
+// template is rebuilt whenever any file within root changes, and options
+// which change the migrations applied, such as WithVersionRange,
+// WithTemplateData, WithSchema, or WithScope, are migrated into templates of
+// their own as identified by migrate.Fingerprint. The test fails if an option
+// cannot be fingerprinted, such as WithSecrets. The handle is closed and the
+// clone removed once the test completes.
+func Template(t testing.TB, source Source, root string, options ...migrate.Option) *sql.DB {
+	t.Helper()
+
+	key, err := templateKey(root, options...)
+	if err != nil {
+		t.Fatal("migratetest.Template: got error while reading instance directory:\n", err)
+	}
+	mutex.Lock()
+	err, ok := prepared[source.id()+":"+key]
+	if !ok {
+		err = source.prepare(key, func(db *sql.DB) error {
+			instance, err := migrate.NewInstance(db, root, options...)
+			if err != nil {
+				return err
+			}
+			instance.Output = ioutil.Discard
+
+			return instance.Latest()
+		})
+		prepared[source.id()+":"+key] = err
+	}
+	mutex.Unlock()
+
+	if err != nil {
+		t.Fatal("migratetest.Template: got error while preparing template database:\n", err)
+	}
+
+	db, err := source.clone(t, key)
+	if err != nil {
+		t.Fatal("migratetest.Template: got error while cloning template database:\n", err)
+	}
+
+	return db
+}
+
+// templateKey returns a short hash identifying the template database migrated
+// from the files within root with the options provided.
+func templateKey(root string, options ...migrate.Option) (string, error) {
+	tree, err := treeKey(root)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint, err := migrate.Fingerprint(root, options...)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(tree + ":" + fingerprint))
+	return hex.EncodeToString(hash[:])[:16], nil
+}
+
+// treeKey returns a short hash of the path and contents of every file within
+// root, identifying the migrations which it holds.
+func treeKey(root string) (string, error) {
+	hash := sha256.New()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(hash, "%s\n", filepath.ToSlash(relative))
+		_, err = io.Copy(hash, file)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	absolute, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	hash.Write([]byte(absolute))
+
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
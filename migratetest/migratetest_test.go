@@ -0,0 +1,76 @@
+package migratetest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/octacian/migrate"
+)
+
+// TestSQLiteTemplate ensures that every test receives its own migrated clone
+// of a single template database.
+func TestSQLiteTemplate(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migratetest")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	source := SQLite{Directory: directory}
+	for i := 0; i < 2; i++ {
+		t.Run("clone", func(t *testing.T) {
+			db := Template(t, source, "../testing/working")
+
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM new_test;`).Scan(&count); err != nil {
+				t.Fatal("sql.DB.QueryRow: got error with migrated table:\n", err)
+			} else if count != 0 {
+				t.Errorf("Template: got %d rows expected a fresh clone", count)
+			}
+
+			if _, err := db.Exec(`INSERT INTO new_test (ID) VALUES (1);`); err != nil {
+				t.Fatal("sql.DB.Exec: got error:\n", err)
+			}
+		})
+	}
+
+	if files, err := ioutil.ReadDir(directory); err != nil {
+		t.Fatal("ioutil.ReadDir: got error:\n", err)
+	} else if len(files) != 1 {
+		t.Errorf("Template: got %d files expected a single template database", len(files))
+	}
+}
+
+// TestTemplateOptions ensures that options which change the migrations
+// applied are migrated into a template of their own, and that options which
+// cannot be fingerprinted are refused.
+func TestTemplateOptions(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migratetest")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	source := SQLite{Directory: directory}
+	db := Template(t, source, "../testing/working")
+	if _, err := db.Exec(`SELECT * FROM new_test;`); err != nil {
+		t.Error("Template: got error with table of version 3:\n", err)
+	}
+
+	db = Template(t, source, "../testing/working", migrate.WithVersionRange(1, 1))
+	if _, err := db.Exec(`SELECT * FROM test;`); err != nil {
+		t.Error("Template: got error with table of version 1 using WithVersionRange:\n", err)
+	}
+
+	if files, err := ioutil.ReadDir(directory); err != nil {
+		t.Fatal("ioutil.ReadDir: got error:\n", err)
+	} else if len(files) != 2 {
+		t.Errorf("Template: got %d files expected a template database for each version range", len(files))
+	}
+
+	if _, err := templateKey("../testing/working", migrate.WithSecrets(migrate.EnvSecrets{})); err == nil {
+		t.Error("templateKey: expected error with WithSecrets")
+	}
+}
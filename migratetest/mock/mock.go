@@ -0,0 +1,170 @@
+// Package mock pins the exact sequence of queries which migrate sends to a
+// database, so that migrations may be asserted against a mock database
+// provided by DATA-DOG/go-sqlmock rather than a real one. Along with the
+// statements of each part, as returned by migrate.PlanNew, the expectations
+// include every query with which migrate creates its tables, takes the
+// migration lock, and records the version, journal, history, and part states
+// of the run:
+//
+//	db, expect, err := mock.New()
+//	planned, err := migrate.PlanNew("migrations", 3)
+//	mock.ExpectNewInstance(expect)
+//	instance, err := migrate.NewInstance(db, "migrations")
+//	err = mock.ExpectGoto(expect, planned)
+//	err = instance.Goto(3)
+//	err = expect.ExpectationsWereMet()
+//
+// The expectations are those of a new database, without a version, migrated
+// up within a transaction with the Generic dialect, which is that detected for
+// a database provided by go-sqlmock, and without WithSchema or WithScope.
+// Parts which are guarded with `-- @migrate/skip-if` execute a guard query
+// which cannot be expected, and parts which are optional, deferred, or
+// excluded by their tags execute other queries again, so ExpectGoto returns an
+// error for guarded parts and they should not otherwise be used with it.
+package mock
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/octacian/migrate"
+)
+
+// tables holds the statements with which NewInstance creates the tables of
+// migrate, in order, with whitespace collapsed as by sqlmock.QueryMatcherEqual.
+var tables = []string{
+	"CREATE TABLE IF NOT EXISTS metadata( ID INT AUTO_INCREMENT PRIMARY KEY, Name VARCHAR(255) NOT NULL UNIQUE, " +
+		"Value BLOB NOT NULL, ValueType TINYINT NOT NULL -- 0 = bool, 1 = int, 2 = float64, 3 = string );",
+	"CREATE TABLE IF NOT EXISTS migrate_journal( Version INT NOT NULL, Name VARCHAR(255) NOT NULL, " +
+		"Direction VARCHAR(4) NOT NULL, PRIMARY KEY (Version, Name) );",
+	"CREATE TABLE IF NOT EXISTS migrate_lock( ID INT PRIMARY KEY, Holder VARCHAR(255) NOT NULL, " +
+		"Heartbeat BIGINT NOT NULL );",
+	"CREATE TABLE IF NOT EXISTS migrate_history( Version INT NOT NULL, Part VARCHAR(255) NOT NULL, " +
+		"Direction VARCHAR(4) NOT NULL, Meta TEXT NOT NULL, AppliedAt BIGINT NOT NULL, " +
+		"Actor VARCHAR(255) NOT NULL DEFAULT '', Reason VARCHAR(1000) NOT NULL DEFAULT '', Statements TEXT, " +
+		"StartedAt BIGINT NOT NULL DEFAULT 0, Build VARCHAR(255) NOT NULL DEFAULT '', " +
+		"Revision VARCHAR(64) NOT NULL DEFAULT '', Host VARCHAR(255) NOT NULL DEFAULT '', " +
+		"ID VARCHAR(64) NOT NULL DEFAULT '', RunID VARCHAR(255) NOT NULL DEFAULT '', " +
+		"Sequence BIGINT NOT NULL DEFAULT 0 );",
+	"CREATE TABLE IF NOT EXISTS migrate_pending( Version INT NOT NULL, Part VARCHAR(255) NOT NULL, " +
+		"QueuedAt BIGINT NOT NULL, Attempts INT NOT NULL, LastError TEXT NOT NULL );",
+	"CREATE TABLE IF NOT EXISTS migrate_parts( Version INT NOT NULL, Part VARCHAR(255) NOT NULL, " +
+		"State VARCHAR(16) NOT NULL, UpdatedAt BIGINT NOT NULL, PRIMARY KEY (Version, Part) );",
+}
+
+// historyColumns holds the columns of the history table which NewInstance
+// checks for once the table exists, adding any which a history table created
+// by an earlier release lacks.
+var historyColumns = []string{"Actor", "Reason", "StartedAt", "Build", "Revision", "Host", "ID", "RunID",
+	"Sequence", "Statements"}
+
+// New returns a mock database whose queries are compared to those expected
+// exactly, other than whitespace, as the expectations of this package
+// require.
+func New() (*sql.DB, sqlmock.Sqlmock, error) {
+	return sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+}
+
+// ExpectNewInstance expects the queries with which migrate.NewInstance
+// creates its tables within a new database.
+func ExpectNewInstance(mock sqlmock.Sqlmock) {
+	for index, table := range tables {
+		mock.ExpectExec(table).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		// The columns of the history table are checked once it has been created
+		if index == 3 {
+			for _, column := range historyColumns {
+				mock.ExpectQuery("SELECT " + column + " FROM migrate_history WHERE 1 = 0;").
+					WillReturnRows(sqlmock.NewRows([]string{column}))
+			}
+		}
+	}
+}
+
+// ExpectGoto expects the queries with which Instance.Goto applies the
+// statements planned, as returned by migrate.PlanNew, to a new database. An
+// error is returned if any part planned is guarded.
+func ExpectGoto(mock sqlmock.Sqlmock, planned []migrate.PlannedStatement) error {
+	if len(planned) == 0 {
+		return errors.New("mock: expected at least one statement to be planned")
+	}
+	for _, statement := range planned {
+		if statement.Guarded {
+			return errors.New("mock: cannot expect the guard query of part '" + statement.Part + "'")
+		}
+	}
+
+	mock.ExpectExec("INSERT INTO migrate_lock (ID, Holder, Heartbeat) VALUES (1, ?, ?);").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectEntry(mock, "migrateTarget", "Name")
+	expectEntry(mock, "migrateVersion", "Value")
+	mock.ExpectBegin()
+
+	target := 0
+	for index, statement := range planned {
+		// The journal of each version is cleared before its first part is applied
+		first := index == 0 || planned[index-1].Version != statement.Version
+		if first {
+			mock.ExpectExec("DELETE FROM migrate_journal WHERE Version = ?;").WithArgs(statement.Version).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+
+		args := make([]driver.Value, len(statement.Args))
+		for i, arg := range statement.Args {
+			args[i] = arg
+		}
+		mock.ExpectExec(statement.SQL).WithArgs(args...).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		// Each part is recorded once its last statement has been applied, and the journal of each version
+		// is cleared once its last part has been recorded
+		last := index == len(planned)-1 || planned[index+1].Version != statement.Version
+		if last || planned[index+1].Part != statement.Part {
+			expectRecord(mock, statement.Version, statement.Part)
+		}
+		if last {
+			mock.ExpectExec("DELETE FROM migrate_journal WHERE Version = ?;").WithArgs(statement.Version).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		target = statement.Version
+	}
+
+	expectEntry(mock, "migrateVersion", "Value")
+	mock.ExpectExec("INSERT INTO metadata (Name, Value, ValueType) VALUES (?, ?, 1);").
+		WithArgs("migrateVersion", target).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectEntry(mock, "migrateTarget", "Name")
+	mock.ExpectExec("DELETE FROM migrate_lock WHERE ID = 1 AND Holder = ?;").WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	return nil
+}
+
+// expectEntry expects the column named of the metadata entry named to be read,
+// finding no such entry.
+func expectEntry(mock sqlmock.Sqlmock, name, column string) {
+	mock.ExpectQuery("SELECT " + column + " FROM metadata WHERE Name = ?;").WithArgs(name).
+		WillReturnRows(sqlmock.NewRows([]string{column}))
+}
+
+// expectRecord expects a part of a version to be recorded as applied in the
+// journal, the history, and the part states.
+func expectRecord(mock sqlmock.Sqlmock, version int, part string) {
+	mock.ExpectExec("INSERT INTO migrate_journal (Version, Name, Direction) VALUES (?, ?, ?);").
+		WithArgs(version, part, "up").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	history := []driver.Value{version, part, "up"}
+	for i := 0; i < 11; i++ {
+		history = append(history, sqlmock.AnyArg())
+	}
+	mock.ExpectExec("INSERT INTO migrate_history (Version, Part, Direction, Meta, AppliedAt, Actor, Reason, " +
+		"Statements, StartedAt, Build, Revision, Host, ID, RunID, Sequence) SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, " +
+		"?, ?, ?, ?, COALESCE(MAX(Sequence), 0) + 1 FROM migrate_history;").WithArgs(history...).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectExec("DELETE FROM migrate_parts WHERE Version = ? AND Part = ?;").WithArgs(version, part).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO migrate_parts (Version, Part, State, UpdatedAt) VALUES (?, ?, ?, ?);").
+		WithArgs(version, part, "applied", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+}
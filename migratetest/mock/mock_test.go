@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/octacian/migrate"
+)
+
+// TestExpect ensures that the queries sent by NewInstance and Goto are
+// exactly those expected, including the statements of parts loading CSV
+// files along with their arguments.
+func TestExpect(t *testing.T) {
+	for root, target := range map[string]int{"../../testing/working": 3, "../../testing/assets": 1} {
+		db, expect, err := New()
+		if err != nil {
+			t.Fatal("New: got error:\n", err)
+		}
+		defer db.Close()
+
+		planned, err := migrate.PlanNew(root, target)
+		if err != nil {
+			t.Fatal("migrate.PlanNew: got error:\n", err)
+		}
+
+		ExpectNewInstance(expect)
+		instance, err := migrate.NewInstance(db, root)
+		if err != nil {
+			t.Fatal("migrate.NewInstance: got error:\n", err)
+		}
+		instance.Output = ioutil.Discard
+
+		if err := ExpectGoto(expect, planned); err != nil {
+			t.Fatal("ExpectGoto: got error:\n", err)
+		}
+		if err := instance.Goto(target); err != nil {
+			t.Fatalf("Instance.Goto: got error for %s:\n%s", root, err)
+		}
+		if err := expect.ExpectationsWereMet(); err != nil {
+			t.Errorf("Sqlmock.ExpectationsWereMet: got error for %s:\n%s", root, err)
+		}
+	}
+
+	if err := ExpectGoto(nil, []migrate.PlannedStatement{{Part: "guarded.sql", Guarded: true}}); err == nil {
+		t.Error("ExpectGoto: expected error with a guarded part")
+	}
+}
@@ -0,0 +1,236 @@
+package migratetest
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// SQLite is a Source which copies the file holding the template database for
+// every clone.
+type SQLite struct {
+	Driver    string // Name of the database driver, "sqlite3" if empty
+	Directory string // Directory in which to keep template databases, os.TempDir() if empty
+}
+
+// driver returns the name of the database driver to use.
+func (source SQLite) driver() string {
+	if source.Driver == "" {
+		return "sqlite3"
+	}
+
+	return source.Driver
+}
+
+// id implements the Source interface for SQLite.
+func (source SQLite) id() string {
+	return "sqlite:" + source.driver() + ":" + source.Directory
+}
+
+// path returns the path of the template database identified by key.
+func (source SQLite) path(key string) string {
+	directory := source.Directory
+	if directory == "" {
+		directory = os.TempDir()
+	}
+
+	return filepath.Join(directory, "migratetest-"+sanitize(key)+".sqlite")
+}
+
+// prepare implements the Source interface for SQLite, migrating a new
+// database and then moving it into place so that an interrupted run never
+// leaves behind a partially migrated template.
+func (source SQLite) prepare(key string, apply func(*sql.DB) error) error {
+	path := source.path(key)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	temporary := fmt.Sprintf("%s.%d", path, os.Getpid())
+	defer os.Remove(temporary)
+
+	db, err := sql.Open(source.driver(), temporary)
+	if err != nil {
+		return err
+	}
+
+	if err := apply(db); err != nil {
+		db.Close()
+		return err
+	}
+
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, path)
+}
+
+// clone implements the Source interface for SQLite.
+func (source SQLite) clone(t testing.TB, key string) (*sql.DB, error) {
+	directory, err := ioutil.TempDir("", "migratetest")
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(directory, "clone.sqlite")
+	if err := copyFile(source.path(key), path); err != nil {
+		os.RemoveAll(directory)
+		return nil, err
+	}
+
+	db, err := sql.Open(source.driver(), path)
+	if err != nil {
+		os.RemoveAll(directory)
+		return nil, err
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		os.RemoveAll(directory)
+	})
+
+	return db, nil
+}
+
+// Postgres is a Source which clones the template database for every test with
+// `CREATE DATABASE ... TEMPLATE`. The user connected as must be permitted to
+// create databases.
+type Postgres struct {
+	Driver string // Name of the database driver, "postgres" if empty
+	// DSN returns the data source name with which to connect to the database
+	// named. An empty name should connect to a maintenance database, such as
+	// `postgres`, from which databases are created and dropped.
+	DSN func(database string) string
+}
+
+// clones counts the databases cloned by Postgres, giving each a unique name.
+var clones int64
+
+// driver returns the name of the database driver to use.
+func (source Postgres) driver() string {
+	if source.Driver == "" {
+		return "postgres"
+	}
+
+	return source.Driver
+}
+
+// id implements the Source interface for Postgres, identifying the server
+// by the data source name of its maintenance database.
+func (source Postgres) id() string {
+	return "postgres:" + source.driver() + ":" + source.DSN("")
+}
+
+// name returns the name of the template database identified by key.
+func (source Postgres) name(key string) string {
+	return "migratetest_" + sanitize(key)
+}
+
+// prepare implements the Source interface for Postgres.
+func (source Postgres) prepare(key string, apply func(*sql.DB) error) error {
+	maintenance, err := sql.Open(source.driver(), source.DSN(""))
+	if err != nil {
+		return err
+	}
+	defer maintenance.Close()
+
+	name := source.name(key)
+	var exists bool
+	if err := maintenance.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1);`,
+		name).Scan(&exists); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	// Migrate a temporary database, renaming it once complete
+	temporary := fmt.Sprintf("%s_%d", name, os.Getpid())
+	if _, err := maintenance.Exec(`CREATE DATABASE ` + temporary + `;`); err != nil {
+		return err
+	}
+
+	db, err := sql.Open(source.driver(), source.DSN(temporary))
+	if err != nil {
+		return err
+	}
+
+	err = apply(db)
+	db.Close()
+	if err == nil {
+		_, err = maintenance.Exec(`ALTER DATABASE ` + temporary + ` RENAME TO ` + name + `;`)
+	}
+
+	if err != nil {
+		maintenance.Exec(`DROP DATABASE IF EXISTS ` + temporary + `;`)
+	}
+
+	return err
+}
+
+// clone implements the Source interface for Postgres.
+func (source Postgres) clone(t testing.TB, key string) (*sql.DB, error) {
+	maintenance, err := sql.Open(source.driver(), source.DSN(""))
+	if err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("%s_%d_%d", source.name(key), os.Getpid(), atomic.AddInt64(&clones, 1))
+	if _, err := maintenance.Exec(`CREATE DATABASE ` + name + ` TEMPLATE ` + source.name(key) + `;`); err != nil {
+		maintenance.Close()
+		return nil, err
+	}
+
+	db, err := sql.Open(source.driver(), source.DSN(name))
+	if err != nil {
+		maintenance.Close()
+		return nil, err
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		maintenance.Exec(`DROP DATABASE IF EXISTS ` + name + `;`)
+		maintenance.Close()
+	})
+
+	return db, nil
+}
+
+// copyFile copies the file at source to destination.
+func copyFile(source, destination string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// sanitize returns key with every character which is not a lowercase letter
+// or digit replaced with an underscore, so that it may be used in file and
+// database names.
+func sanitize(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToLower(key))
+}
@@ -2,9 +2,10 @@ package migrate
 
 import (
 	"io/ioutil"
-	"path"
+	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // Migration represents a single migration, most importantly containing its
@@ -14,14 +15,26 @@ type Migration struct {
 	Path    string
 	Version int
 	Parts   []*Part
+	// Assets holds the files of the migration which are not parts but are
+	// loaded by a part or match a pattern provided with WithAssets, ordered
+	// by name.
+	Assets []*Asset
+
+	algorithm ChecksumAlgorithm // Algorithm with which Checksum is computed
 }
 
 // NewMigration takes a directory path and parses the version number contained
 // within the directory name component. It loops through this directory
-// checking for files with the .sql extension, parsing them into Parts.
-// NewMigration returns a pointer to a Migration if successful and an error if
-// anything goes wrong.
+// checking for files with the .sql extension, parsing them into Parts, which
+// are ordered by name. NewMigration returns a pointer to a Migration if
+// successful and an error if anything goes wrong.
 func NewMigration(root string) (*Migration, error) {
+	return loader{}.migration(root)
+}
+
+// migration implements NewMigration, skipping any files whose path relative
+// to the instance directory matches the ignore patterns of the loader.
+func (loader loader) migration(root string) (*Migration, error) {
 	_, name := filepath.Split(root)
 	if len(name) < 9 || name[:8] != "version_" {
 		return nil, NewFatalf("NewMigration: expected migration directory name to be formatted as "+
@@ -41,19 +54,19 @@ func NewMigration(root string) (*Migration, error) {
 	}
 
 	root = filepath.Clean(root)
-	migration := &Migration{Name: name, Path: root, Version: version}
+	migration := &Migration{Name: name, Path: root, Version: version, algorithm: loader.algorithm}
 
-	files, err := ioutil.ReadDir(root)
+	files, err := readDir(root)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, file := range files {
-		// if the file has a .sql extension, add it to the Migration
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".sql" {
-			filePath := path.Join(root, file.Name())
+		// if the file has a .sql or .sql.tmpl extension, add it to the Migration
+		if !file.IsDir() && isPartFile(file.Name()) && !loader.ignore.match(name+"/"+file.Name()) {
+			filePath := filepath.Join(root, file.Name())
 
-			part, err := NewPart(filePath)
+			part, err := loader.part(filePath)
 			if err != nil {
 				return nil, err
 			}
@@ -67,5 +80,65 @@ func NewMigration(root string) (*Migration, error) {
 		return nil, NewFatalf("NewMigration: no migration parts found in '%s'", root)
 	}
 
+	if err := sortParts(migration); err != nil {
+		return nil, err
+	}
+
+	// Any other file loaded by a part or matching WithAssets is an asset, checksummed but not executed
+	loaded := loadedFiles(migration.Parts)
+	for _, file := range files {
+		if file.IsDir() || isPartFile(file.Name()) || loader.ignore.match(name+"/"+file.Name()) ||
+			!loader.isAsset(name, file.Name(), loaded) {
+			continue
+		}
+
+		asset, err := loader.asset(filepath.Join(root, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration.Assets = append(migration.Assets, asset)
+	}
+
+	if err := resolveLoads(migration); err != nil {
+		return nil, err
+	}
+
 	return migration, nil
 }
+
+// Checksum returns the hex-encoded digest of the names and checksums of every
+// part and asset of the Migration, identifying its contents as a whole. The
+// digest is computed with the algorithm provided with WithChecksumAlgorithm,
+// or SHA-256 by default.
+func (migration *Migration) Checksum() string {
+	var builder strings.Builder
+	for _, part := range migration.Parts {
+		builder.WriteString(part.Name + " " + part.Checksum + "\n")
+	}
+	for _, asset := range migration.Assets {
+		builder.WriteString(asset.Name + " " + asset.Checksum + "\n")
+	}
+
+	return migration.algorithm.digest([]byte(builder.String()))
+}
+
+// readDir returns the entries of the directory named, sorted by name, as
+// ioutil.ReadDir does except that symbolic links are followed, so that a link
+// to a directory is reported as a directory.
+func readDir(name string) ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, entry := range entries {
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if entries[i], err = os.Stat(filepath.Join(name, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return entries, nil
+}
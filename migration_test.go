@@ -42,6 +42,10 @@ func TestWorkingMigration(t *testing.T) {
 		if migration.Parts[0].Down != version1DownSQL {
 			t.Errorf("NewMigration.Parts: got down part:\n%s\n\nexpected:\n%s", migration.Parts[0].Down, version1DownSQL)
 		}
+		if len(migration.Parts[0].Checksum) != 64 {
+			t.Errorf("NewMigration.Parts: got checksum '%s' expected hex-encoded SHA-256 digest",
+				migration.Parts[0].Checksum)
+		}
 	}
 }
 
@@ -74,3 +78,23 @@ func TestBadMigrationPath(t *testing.T) {
 func TestNoParts(t *testing.T) {
 	mExpectError(t, "empty migration directories", "no migration parts", "testing/empty/version_1")
 }
+
+// TestMigrationChecksum ensures that the checksum of a migration is derived
+// from the contents of its parts.
+func TestMigrationChecksum(t *testing.T) {
+	first, err := NewMigration("testing/working/version_1")
+	if err != nil {
+		t.Fatal("NewMigration: got error:\n", err)
+	}
+	second, err := NewMigration("testing/working/version_2")
+	if err != nil {
+		t.Fatal("NewMigration: got error:\n", err)
+	}
+
+	if len(first.Checksum()) != 64 {
+		t.Errorf("Migration.Checksum: got '%s' expected 64 hex characters", first.Checksum())
+	}
+	if first.Checksum() == second.Checksum() {
+		t.Error("Migration.Checksum: got identical checksums for different migrations")
+	}
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import "strings"
+
+// punctuation holds the characters around which whitespace is insignificant
+// in the canonical form of SQL.
+const punctuation = "(),;"
+
+// normalizeSQL returns the canonical form of the contents of a part file, in
+// which comments are removed, runs of whitespace including line endings are
+// collapsed into a single space, and whitespace around punctuation is
+// removed. Directives such as `-- @migrate/up` are retained, each on a line of
+// its own, while words keep their case and quoted strings, identifiers, and
+// dollar-quoted bodies are left untouched, so that reformatting a part does
+// not alter its canonical form while any other change does.
+func normalizeSQL(contents string) string {
+	var builder strings.Builder
+	space := false // Whether whitespace separates the previous token from the next
+	last := byte('\n')
+
+	emit := func(token string) {
+		if space && last != '\n' && !strings.ContainsRune(punctuation, rune(last)) &&
+			!strings.ContainsRune(punctuation, rune(token[0])) {
+			builder.WriteByte(' ')
+		}
+		builder.WriteString(token)
+		space = false
+		last = token[len(token)-1]
+	}
+
+	for i := 0; i < len(contents); {
+		switch rest := contents[i:]; {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r' || rest[0] == '\f':
+			space = true
+			i++
+		case strings.HasPrefix(rest, "--"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+
+			if matches := regexPartDir.FindStringSubmatch(strings.TrimSpace(rest[:end])); matches != nil {
+				if last != '\n' {
+					builder.WriteByte('\n')
+				}
+				builder.WriteString(strings.TrimSpace("-- @migrate/"+matches[1]+" "+
+					strings.Join(strings.Fields(matches[2]), " ")) + "\n")
+				space = false
+				last = '\n'
+			} else {
+				space = true
+			}
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				i = len(contents)
+			} else {
+				i += end + 4
+			}
+			space = true
+		case rest[0] == '\'' || rest[0] == '"' || rest[0] == '`':
+			// Find the closing quote, treating doubled quotes as escaped
+			end := 1
+			for end < len(rest) {
+				if rest[end] == rest[0] {
+					if end+1 < len(rest) && rest[end+1] == rest[0] {
+						end += 2
+						continue
+					}
+					end++
+					break
+				}
+				end++
+			}
+			emit(rest[:end])
+			i += end
+		case rest[0] == '$' && dollarTag(rest) != "":
+			// Dollar-quoted bodies, such as those of functions, are kept exactly as written
+			tag := dollarTag(rest)
+			end := strings.Index(rest[len(tag):], tag)
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 2 * len(tag)
+			}
+			emit(rest[:end])
+			i += end
+		case strings.IndexByte(punctuation, rest[0]) >= 0:
+			emit(rest[:1])
+			i++
+		default:
+			end := 1
+			for end < len(rest) && !strings.ContainsRune(" \t\n\r\f'\"`"+punctuation, rune(rest[end])) &&
+				!strings.HasPrefix(rest[end:], "--") && !strings.HasPrefix(rest[end:], "/*") {
+				end++
+			}
+			emit(rest[:end])
+			i += end
+		}
+	}
+
+	return strings.TrimSpace(builder.String())
+}
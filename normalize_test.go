@@ -0,0 +1,40 @@
+package migrate
+
+import "testing"
+
+func TestNormalizeSQL(t *testing.T) {
+	same := [][2]string{
+		{"-- @migrate/up\nCREATE TABLE a(ID INT);\n", "--@migrate/up  \r\n\r\nCREATE TABLE a ( ID INT ) ;\r\n"},
+		{"-- @migrate/up\nSELECT 1; -- trailing\n/* block\ncomment */ SELECT 2;", "-- @migrate/up\nSELECT 1;SELECT 2;"},
+		{"-- @migrate/up\nSELECT 'Hello World';", "-- @migrate/up\n  SELECT\n\t'Hello World' ;"},
+	}
+	for _, pair := range same {
+		if a, b := normalizeSQL(pair[0]), normalizeSQL(pair[1]); a != b {
+			t.Errorf("normalizeSQL: expected '%q' and '%q' to normalize alike, got '%q' and '%q'", pair[0],
+				pair[1], a, b)
+		}
+	}
+
+	different := [][2]string{
+		{"-- @migrate/up\nSELECT 'Hello World';", "-- @migrate/up\nSELECT 'Hello  World';"},
+		{"-- @migrate/up\nSELECT \"Name\" FROM a;", "-- @migrate/up\nSELECT \"name\" FROM a;"},
+		{"-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 2;", "-- @migrate/up\nSELECT 1;SELECT 2;"},
+		{"-- @migrate/up\nSELECT 'a--b';", "-- @migrate/up\nSELECT 'a';"},
+		{"-- @migrate/up\nSELECT a FROM b;", "-- @migrate/up\nSELECT a FROM c;"},
+		{"-- @migrate/up\nSELECT Name FROM a;", "-- @migrate/up\nSELECT name FROM a;"},
+		{"-- @migrate/up\nCREATE FUNCTION f() RETURNS INT AS $$\nSELECT  1 -- one\n$$ LANGUAGE sql;",
+			"-- @migrate/up\nCREATE FUNCTION f() RETURNS INT AS $$ SELECT 1 $$ LANGUAGE sql;"},
+		{"-- @migrate/up\nSELECT $body$A$body$;", "-- @migrate/up\nSELECT $body$a$body$;"},
+	}
+	for _, pair := range different {
+		if a, b := normalizeSQL(pair[0]), normalizeSQL(pair[1]); a == b {
+			t.Errorf("normalizeSQL: expected '%q' and '%q' to normalize differently, both got '%q'", pair[0],
+				pair[1], a)
+		}
+	}
+
+	expected := "-- @migrate/up\nCREATE TABLE a(ID INT,Name TEXT);"
+	if got := normalizeSQL("-- @migrate/up\nCREATE TABLE a (\n  ID INT,\n  Name TEXT\n);\n"); got != expected {
+		t.Errorf("normalizeSQL: got '%q' expected '%q'", got, expected)
+	}
+}
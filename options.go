@@ -0,0 +1,549 @@
+package migrate
+
+import (
+	"text/template"
+	"time"
+)
+
+// Option configures optional behavior of an Instance. Options are passed to
+// NewInstance and are applied in order once the Instance has been created.
+type Option func(*Instance)
+
+// WithHeartbeat causes the Instance to emit a heartbeat every interval while
+// a single migration part is being applied, refreshing the migration lock,
+// writing a short notice to Output, and passing a Heartbeat to fn if it is not
+// nil. An interval of zero or less disables heartbeats.
+func WithHeartbeat(interval time.Duration, fn func(Heartbeat)) Option {
+	return func(instance *Instance) {
+		instance.heartbeat = interval
+		instance.onHeartbeat = fn
+	}
+}
+
+// WithHeartbeatRow causes each heartbeat to also record the current time in
+// the metadata entry named `migrateHeartbeat`, allowing external monitors and
+// other replicas to observe that a long migration is still making progress.
+// WithHeartbeatRow has no effect unless heartbeats are enabled.
+func WithHeartbeatRow() Option {
+	return func(instance *Instance) {
+		instance.heartbeatRow = true
+	}
+}
+
+// WithoutTransaction causes migrations to be applied directly rather than
+// within a transaction, recording each part applied in a journal so that an
+// interrupted run may be continued with Resume. The run stops at the first
+// part which fails, leaving the later parts of its version unapplied. This is
+// intended for databases such as MySQL which implicitly commit most DDL
+// statements, rendering transactions ineffective.
+func WithoutTransaction() Option {
+	return func(instance *Instance) {
+		instance.noTransaction = true
+	}
+}
+
+// WithStaleLock allows the Instance to take over a migration lock which has
+// not been refreshed within the duration provided, on the assumption that the
+// process holding it has died. The lock is refreshed with every heartbeat, so
+// the duration should comfortably exceed the heartbeat interval.
+func WithStaleLock(after time.Duration) Option {
+	return func(instance *Instance) {
+		instance.staleLock = after
+	}
+}
+
+// WithDialect sets the Dialect of the database, overriding the dialect
+// otherwise detected from the database driver.
+func WithDialect(dialect Dialect) Option {
+	return func(instance *Instance) {
+		instance.dialect = dialect
+	}
+}
+
+// WithIdempotent causes every statement to be rewritten into an idempotent
+// form, where the Dialect knows of one, before it is applied. For example,
+// `CREATE TABLE` becomes `CREATE TABLE IF NOT EXISTS`. A statement which has
+// no such form but which the Dialect guards, as described by Guards, is
+// instead skipped once applying it would change nothing, as with `DROP INDEX`
+// in MySQL. This is intended for environments where re-applying a version
+// must be harmless.
+func WithIdempotent() Option {
+	return func(instance *Instance) {
+		instance.idempotent = true
+	}
+}
+
+// WithSignatureVerification causes NewInstance to refuse to load the
+// migration tree unless it has been signed with Instance.Sign by one of the
+// keys in the keyring, ensuring that the SQL applied is exactly that which was
+// reviewed and signed.
+func WithSignatureVerification(keyring Keyring) Option {
+	return func(instance *Instance) {
+		instance.keyring = keyring
+	}
+}
+
+// WithStrictLockFile causes NewInstance to refuse to load the migration tree
+// if any part has been modified or removed since its checksum was recorded in
+// the LockFile with Instance.WriteLockFile, catching accidental edits to
+// migrations which may already have been applied elsewhere.
+func WithStrictLockFile() Option {
+	return func(instance *Instance) {
+		instance.strictLockFile = true
+	}
+}
+
+// WithAllowFutureSchema causes EnsureUpToDate to accept a database whose
+// recorded version is newer than the latest migration known to the Instance,
+// for deployments in which every migration is known to remain compatible with
+// the previous release. Migrations still cannot be applied to such a
+// database, as the SQL needed to revert the unknown versions is unavailable.
+func WithAllowFutureSchema() Option {
+	return func(instance *Instance) {
+		instance.allowFutureSchema = true
+	}
+}
+
+// WithDenyDown causes Goto to refuse to migrate down with an *ErrPolicy, as is
+// often required of production databases. A downward run may still be made
+// deliberately by calling Force with OverrideDenyDown.
+func WithDenyDown() Option {
+	return func(instance *Instance) {
+		instance.denyDown = true
+	}
+}
+
+// WithMaxJump causes Goto and Latest to refuse with an *ErrPolicy any run
+// which would apply more than max versions at once, guarding against an
+// Instance accidentally pointed at the wrong database. A larger run may still
+// be made deliberately by calling Force with OverrideMaxJump. A max of zero or
+// less disables the limit.
+func WithMaxJump(max int) Option {
+	return func(instance *Instance) {
+		instance.maxJump = max
+	}
+}
+
+// WithVersionTimeout limits the time spent applying any single version. If a
+// version takes longer than timeout, the statement being executed is
+// cancelled, the run fails exactly as if a part had failed, and an
+// *ErrTimeout is returned. A timeout of zero or less disables the limit.
+func WithVersionTimeout(timeout time.Duration) Option {
+	return func(instance *Instance) {
+		instance.versionTimeout = timeout
+	}
+}
+
+// WithDataTimeout limits the time spent applying any single deferred part
+// with RunDeferred or LatestData, in place of the limit of WithVersionTimeout,
+// which does not apply to deferred parts. If a part takes longer than
+// timeout, the statement being executed is cancelled and the part fails. A
+// timeout of zero or less disables the limit, which is the default as
+// backfills may take hours.
+func WithDataTimeout(timeout time.Duration) Option {
+	return func(instance *Instance) {
+		instance.dataTimeout = timeout
+	}
+}
+
+// WithLockTimeout bounds the time each DDL statement waits to acquire a lock
+// to timeout, where the dialect implements LockTimeout, as with Postgres. A
+// statement cancelled because the timeout elapsed is retried up to retries
+// times, waiting backoff before the first retry and twice as long before each
+// retry thereafter. DDL such as `ALTER TABLE` waits behind any long-running
+// query on the table while blocking every query queued after it, so failing
+// fast and retrying keeps a busy application responsive while a migration is
+// deployed. Within a transaction, each attempt is made within a savepoint.
+func WithLockTimeout(timeout time.Duration, retries int, backoff time.Duration) Option {
+	return func(instance *Instance) {
+		instance.lockTimeout, instance.lockRetries, instance.lockBackoff = timeout, retries, backoff
+	}
+}
+
+// WithRowLimit estimates the number of rows which each statement modifying
+// data, such as UPDATE or DELETE, would touch immediately before applying it,
+// by explaining it with the Dialect where supported, and refuses to apply any
+// statement estimated to touch more than limit rows with an *ErrRowLimit. This
+// protects production from a full-table rewrite hidden within a migration.
+// Dialects which cannot explain statements, such as SQLite, are not limited.
+func WithRowLimit(limit int64) Option {
+	return func(instance *Instance) {
+		instance.rowLimit = limit
+	}
+}
+
+// WithRowLimitOverride applies statements estimated to touch more rows than
+// WithRowLimit allows regardless, writing a warning to Output for each rather
+// than refusing to apply it, once a large change has been reviewed.
+func WithRowLimitOverride() Option {
+	return func(instance *Instance) {
+		instance.rowLimitOverride = true
+	}
+}
+
+// WithDeferredRetries causes RunDeferred to retry a deferred part which fails
+// to apply up to retries more times, waiting for backoff before each retry,
+// before giving up and leaving the part queued.
+func WithDeferredRetries(retries int, backoff time.Duration) Option {
+	return func(instance *Instance) {
+		instance.deferredRetries, instance.deferredBackoff = retries, backoff
+	}
+}
+
+// WithChecksumAlgorithm computes the checksums of parts and migrations with
+// algorithm rather than SHA256, such as SHA512. A FIPS-validated
+// implementation of SHA-256 may be supplied under the name "sha256", yielding
+// exactly the same digests. The name of the algorithm is recorded alongside
+// every digest in the LockFile, so that a LockFile written with a previous
+// algorithm is still verified, by recomputing the checksums with it, until it
+// is regenerated with WriteLockFile. Signatures made with Sign cover the
+// checksums themselves, so the tree must be signed again after switching.
+func WithChecksumAlgorithm(algorithm ChecksumAlgorithm) Option {
+	return func(instance *Instance) {
+		instance.loader.algorithm = algorithm
+	}
+}
+
+// WithVersionKey records the version of the database in the metadata entry
+// named key rather than DefaultVersionKey, such that several independently
+// migrated trees may share a database. If no version has been recorded under
+// key, the version is read from each of the legacy keys in turn, and is moved
+// to key by the next run, allowing a deployment to move from
+// DefaultVersionKey without losing its version. MigrateMetadata may instead
+// be used to move the version immediately.
+func WithVersionKey(key string, legacy ...string) Option {
+	return func(instance *Instance) {
+		instance.versionKey, instance.legacyKeys = key, legacy
+	}
+}
+
+// WithSchema causes migrations to be applied within the schema named, such as
+// "tenant_a", allowing a single database to host several independently
+// migrated schemas. The tables in which migrate records its state, including
+// the metadata table holding the version and target of the schema, are
+// qualified with the schema, so that they are never resolved through the
+// search path. Where the dialect implements SearchPath, as with Postgres, the
+// search path of the connection applying a run is set to the schema, so that
+// migrations need not qualify the names they use. Postgres searches the
+// schema ahead of the path already set. The schema must already exist.
+func WithSchema(schema string) Option {
+	return func(instance *Instance) {
+		instance.schema = schema
+	}
+}
+
+// WithScope causes only the migrations within the subdirectory of root named
+// scope, such as "reporting", to be applied, as a unit versioned apart from
+// the rest of root, such that the schema of a single tree may be owned by
+// several teams. The tables in which migrate records its state are suffixed
+// with the scope, and the metadata entries recording the version and target
+// of the scope are prefixed by it, unless another key is provided with
+// WithVersionKey. The subdirectory holds version directories as root does,
+// and is skipped by an Instance of root without a scope. The scope must be
+// made of letters, digits, and underscores.
+func WithScope(scope string) Option {
+	return func(instance *Instance) {
+		instance.scope = scope
+	}
+}
+
+// WithCreateDatabase causes OpenInstance to create the database to which it
+// connects with CreateDatabase if it does not already exist. It has no effect
+// on NewInstance, which is provided a database handle.
+func WithCreateDatabase() Option {
+	return func(instance *Instance) {
+		instance.createDatabase = true
+	}
+}
+
+// WithAllowClean permits Clean to drop every object within the database. As
+// Clean is destructive, it is disabled unless this option is provided, which
+// should be limited to development and CI databases.
+func WithAllowClean() Option {
+	return func(instance *Instance) {
+		instance.allowClean = true
+	}
+}
+
+// WithExecutor causes the statements of every part to be executed by the
+// Executor provided rather than by the database. The database is still used
+// to record which migrations have been applied, so that the ordering,
+// rollback, and error handling of a run may be tested against a fake Executor
+// without applying any migration SQL.
+func WithExecutor(executor Executor) Option {
+	return func(instance *Instance) {
+		instance.executor = executor
+	}
+}
+
+// WithFixtures sets the directory from which LoadFixtures reads fixtures. The
+// directory should not be placed within the instance directory, where it
+// would be mistaken for a migration.
+func WithFixtures(directory string) Option {
+	return func(instance *Instance) {
+		instance.fixtures = directory
+	}
+}
+
+// WithIgnore causes NewInstance to skip any part file or migration directory
+// matching one of the patterns provided, in addition to those listed in the
+// IgnoreFile. A pattern containing a slash, such as `version_2/*_wip.sql`, is
+// matched against the path relative to the instance directory, while any
+// other pattern, such as `*~`, is matched against the name alone.
+func WithIgnore(patterns ...string) Option {
+	return func(instance *Instance) {
+		instance.loader.ignore = append(instance.loader.ignore, patterns...)
+	}
+}
+
+// WithAssets loads every file within a migration directory matching one of
+// the patterns provided, such as `*.md`, as an Asset of the migration, so that
+// it is validated and checksummed along with the parts of the migration
+// without being executed. Patterns are matched as those of WithIgnore. Files
+// loaded by a part with `-- @migrate/load-csv` are always loaded as assets,
+// while any other file which is not a part is ignored.
+func WithAssets(patterns ...string) Option {
+	return func(instance *Instance) {
+		instance.loader.assets = append(instance.loader.assets, patterns...)
+	}
+}
+
+// WithTags applies only those tagged parts marked with at least one of the
+// tags provided by `-- @migrate/tags`, such as `heavy` within a dedicated
+// pipeline step. Parts without tags are always applied. A tagged part which is
+// not applied is queued as though marked `-- @migrate/deferred`, so that it is
+// listed by Deferred and applied later by RunDeferred.
+func WithTags(tags ...string) Option {
+	return func(instance *Instance) {
+		instance.includeTags = append(instance.includeTags, tags...)
+	}
+}
+
+// WithoutTags does not apply parts marked with any of the tags provided by
+// `-- @migrate/tags`, such as skipping `heavy` parts on developer laptops,
+// even if they are also marked with a tag provided with WithTags. Such parts
+// are queued as with WithTags, to be applied later by RunDeferred.
+func WithoutTags(tags ...string) Option {
+	return func(instance *Instance) {
+		instance.excludeTags = append(instance.excludeTags, tags...)
+	}
+}
+
+// WithRoles maps the roles named by `-- @migrate/grant` and
+// `-- @migrate/owner` directives to the roles of the environment in which the
+// Instance runs, such that a tree granting SELECT to `readonly` may grant it
+// to `reporting_staging` in one environment and `reporting` in another. Roles
+// which are not mapped are used as written.
+func WithRoles(roles map[string]string) Option {
+	return func(instance *Instance) {
+		if instance.roles == nil {
+			instance.roles = make(map[string]string)
+		}
+		for name, role := range roles {
+			instance.roles[name] = role
+		}
+	}
+}
+
+// WithTemplateData sets the data with which part files ending with the
+// TemplateExtension are rendered, such as the number of shard tables which a
+// template should generate.
+func WithTemplateData(data interface{}) Option {
+	return func(instance *Instance) {
+		instance.loader.data = data
+	}
+}
+
+// WithTemplateFuncs makes the functions provided available to part files
+// ending with the TemplateExtension, in addition to the built-in functions.
+func WithTemplateFuncs(funcs template.FuncMap) Option {
+	return func(instance *Instance) {
+		instance.loader.funcs = funcs
+	}
+}
+
+// WithExpvar publishes the state of the Instance with the expvar package, so
+// that it is served at /debug/vars alongside other variables. The variables
+// `migrate.current_version`, `migrate.pending`, `migrate.last_run_duration_ms`,
+// and `migrate.last_error` are published, each read from Stats when requested.
+// As expvar variables are global, only the Instance most recently created with
+// WithExpvar is published, until it is closed.
+func WithExpvar() Option {
+	return func(instance *Instance) {
+		instance.expvar = true
+	}
+}
+
+// WithLogger causes the Instance to pass structured events describing the
+// progress of every run to logger, in addition to writing messages to Output.
+// Set Output to ioutil.Discard to rely upon the Logger alone.
+func WithLogger(logger Logger) Option {
+	return func(instance *Instance) {
+		instance.logger = logger
+	}
+}
+
+// WithOutputFormats overrides the format of each Message provided, in place of
+// its format in DefaultFormats, so that the messages written to Output may
+// match the style of other deployment tooling. A Message mapped to an empty
+// format is not written at all. NewInstance returns an error if a format
+// cannot be parsed or names an unknown Message.
+func WithOutputFormats(formats map[Message]string) Option {
+	return func(instance *Instance) {
+		if instance.outputFormats == nil {
+			instance.outputFormats = make(map[Message]string, len(formats))
+		}
+		for message, format := range formats {
+			instance.outputFormats[message] = format
+		}
+	}
+}
+
+// WithoutColor causes the color functions available to formats to write
+// nothing, stripping terminal escape codes from the messages written to
+// Output.
+func WithoutColor() Option {
+	return func(instance *Instance) {
+		instance.noColor = true
+	}
+}
+
+// WithVerbose causes a MessageStatement to be written to Output for every
+// statement other than DDL once its part has been applied, noting the number
+// of rows which it affected, such that a data migration reports
+// "UPDATE touched 1,238,112 row(s)" rather than a bare "Applied". The number
+// of rows affected by each statement is recorded in the RunReport and passed
+// to any Logger regardless.
+func WithVerbose() Option {
+	return func(instance *Instance) {
+		instance.verbose = true
+	}
+}
+
+// WithNonTransactionalDDL acknowledges that the database commits DDL
+// statements implicitly, as MySQL does, so that a failed run cannot be
+// entirely rolled back. Without it, a warning is written to Output and passed
+// to any Logger before every run which executes DDL within a transaction
+// against such a database. Consider WithoutTransaction instead, which records
+// progress so that a failed run may be resumed.
+func WithNonTransactionalDDL() Option {
+	return func(instance *Instance) {
+		instance.acknowledgeDDL = true
+	}
+}
+
+// WithReadOnly causes the Instance to never modify the database, so that it
+// may be safely pointed at production with a read-only connection, such as an
+// SQLite database opened with `mode=ro` or a PostgreSQL role granted only
+// SELECT. NewInstance does not create the tables in which migrate records its
+// state, treating a database without them as being at version 0, and every
+// method which would modify the database, such as Goto or LoadFixtures,
+// returns an error. Status, Stats, History, Doctor, Lint, Plan, and the like
+// remain available.
+func WithReadOnly() Option {
+	return func(instance *Instance) {
+		instance.readOnly = true
+	}
+}
+
+// WithSecrets resolves every `{{secret "name"}}` placeholder within the SQL of
+// a part with the provider given, such as EnvSecrets, FileSecrets, or a
+// SecretFunc reading from Vault. Placeholders are resolved immediately before
+// each statement is executed, so the values of secrets never appear in Output,
+// events passed to a Logger, the statements returned by Plan, or errors. A
+// part containing a placeholder fails to apply if no provider is given.
+func WithSecrets(provider SecretProvider) Option {
+	return func(instance *Instance) {
+		instance.secrets = provider
+	}
+}
+
+// WithActor records the person or pipeline responsible for every run of the
+// Instance, such as a username or CI job, alongside each part in the History.
+// The actor is also included in the RunReport and in the events passed to any
+// Logger when a run finishes, so that every change is attributable.
+func WithActor(name string) Option {
+	return func(instance *Instance) {
+		instance.actor = name
+	}
+}
+
+// WithReason records why the Instance is being run, such as a ticket or
+// release, alongside each part in the History. The reason is also included in
+// the RunReport and in the events passed to any Logger when a run finishes.
+func WithReason(text string) Option {
+	return func(instance *Instance) {
+		instance.reason = text
+	}
+}
+
+// WithRunID causes every run of the Instance to be identified by id, such as
+// the run ID of the deployment pipeline, rather than by an ID generated for
+// each run, such that migration activity may be joined with the rest of the
+// telemetry of a deployment. The ID is attached to every Event, log line, and
+// history entry of a run, and included in its RunReport.
+func WithRunID(id string) Option {
+	return func(instance *Instance) {
+		instance.runID = id
+	}
+}
+
+// WithIDGenerator causes the Instance to generate the IDs of its runs and
+// history entries with generator rather than as random UUIDs.
+func WithIDGenerator(generator IDGenerator) Option {
+	return func(instance *Instance) {
+		instance.ids = generator
+	}
+}
+
+// WithStoredSQL records the SQL text of every part applied alongside it in
+// the History, exactly as written in the part before secrets are resolved, so
+// that what ran remains known even once the tree has changed or is gone. If
+// compress is true, the text is stored compressed with gzip, and decompressed
+// again by History.
+func WithStoredSQL(compress bool) Option {
+	return func(instance *Instance) {
+		instance.storeSQL = true
+		instance.compressSQL = compress
+	}
+}
+
+// WithEncryption encrypts the metadata and SQL text of every part recorded in
+// the History with encrypter before they are stored, such as an Encrypter
+// returned by NewAESEncrypter, and decrypts them again when the History or
+// Changelog is read. The version, part, direction, actor, and reason of each
+// entry remain unencrypted so that the history may still be queried. Entries
+// recorded before encryption was enabled are read as they are, but entries
+// recorded encrypted cannot be read without it.
+func WithEncryption(encrypter Encrypter) Option {
+	return func(instance *Instance) {
+		instance.encrypter = encrypter
+	}
+}
+
+// WithClock causes the Instance to read the current time from clock rather
+// than the system, and to wait on its timers, such as a fake.Clock which
+// advances only when told to. This allows the durations written to Output and
+// the timestamps recorded in the History to be asserted exactly in tests, and
+// heartbeats, retries, and polls to happen only as the test advances the
+// Clock.
+func WithClock(clock Clock) Option {
+	return func(instance *Instance) {
+		instance.clock = clock
+	}
+}
+
+// WithVersionRange causes NewInstance to load and validate only the
+// migrations from version min through max, or through the latest version if
+// max is 0, skipping the parts of every other version without reading them.
+// It is intended for trees with a long history, of which only the tail can
+// possibly be pending. Goto returns an error if migrating would require a
+// version below min, and a database at a version above max is treated as
+// ahead of the known migrations. WriteLockFile and Sign require the whole tree
+// and return an error, as does NewInstance if WithSignatureVerification is
+// also in use.
+func WithVersionRange(min, max int) Option {
+	return func(instance *Instance) {
+		instance.minVersion, instance.maxVersion = min, max
+	}
+}
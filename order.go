@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"sort"
+	"strings"
+)
+
+// sortParts orders the parts of a migration by name, comparing names byte by
+// byte, such that the order in which parts are applied depends only upon
+// their names rather than upon the order in which the file system lists
+// them. An error is returned if two parts have names which differ only in
+// case, as only one of them could be checked out on a case-insensitive file
+// system, such as those of macOS and Windows.
+func sortParts(migration *Migration) error {
+	sort.SliceStable(migration.Parts, func(i, j int) bool {
+		return migration.Parts[i].Name < migration.Parts[j].Name
+	})
+
+	folded := make(map[string]string, len(migration.Parts))
+	for _, part := range migration.Parts {
+		key := strings.ToLower(part.Name)
+		if existing, ok := folded[key]; ok {
+			return NewFatalf("NewMigration: parts '%s' and '%s' of '%s' differ only in case, and would collide on "+
+				"a case-insensitive file system", existing, part.Name, migration.Path)
+		}
+		folded[key] = part.Name
+	}
+
+	return nil
+}
+
+// PlannedPart is a single part which a run would apply.
+type PlannedPart struct {
+	Version   int
+	Part      string
+	Path      string
+	Direction string
+}
+
+// PlanParts returns every part which Goto would apply to bring the database
+// from its current version to the target version, in the order in which they
+// would be applied. Versions are applied in ascending order when migrating up
+// and descending order when migrating down, while the parts of each version
+// are always applied in ascending order of name, compared byte by byte, so a
+// run applies parts in the same order on every platform. Parts which would be
+// deferred or skipped by a guard query are included.
+func (instance *Instance) PlanParts(target int) ([]PlannedPart, error) {
+	current := instance.Version()
+	planned := make([]PlannedPart, 0)
+	if target == current {
+		return planned, nil
+	}
+
+	todo, direction, err := instance.plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range todo {
+		for _, part := range migration.Parts {
+			planned = append(planned, PlannedPart{Version: migration.Version, Part: part.Name, Path: part.Path,
+				Direction: direction})
+		}
+	}
+
+	return planned, nil
+}
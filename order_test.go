@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSortParts ensures that parts are ordered by name byte by byte whatever
+// the order in which they were read, and that names which differ only in
+// case are rejected.
+func TestSortParts(t *testing.T) {
+	migration := &Migration{Path: "version_1", Parts: []*Part{{Name: "b.sql"}, {Name: "Z.sql"}, {Name: "a.sql"},
+		{Name: "10_c.sql"}, {Name: "2_d.sql"}}}
+	if err := sortParts(migration); err != nil {
+		t.Fatal("sortParts: got error:\n", err)
+	}
+
+	names := make([]string, len(migration.Parts))
+	for i, part := range migration.Parts {
+		names[i] = part.Name
+	}
+	if joined := strings.Join(names, " "); joined != "10_c.sql 2_d.sql Z.sql a.sql b.sql" {
+		t.Errorf("sortParts: got order '%s' expected '10_c.sql 2_d.sql Z.sql a.sql b.sql'", joined)
+	}
+
+	migration.Parts = append(migration.Parts, &Part{Name: "A.sql"})
+	expectError(t, "sortParts", "names differing only in case", func() error { return sortParts(migration) },
+		"'A.sql' and 'a.sql'")
+}
+
+// TestPlanParts ensures that the parts of a run are planned in the order in
+// which they are applied, in either direction.
+func TestPlanParts(t *testing.T) {
+	root := CopyTree(t, "testing/meta")
+	for _, name := range []string{"payments.sql", "accounts.sql"} {
+		table := strings.TrimSuffix(name, ".sql")
+		if err := ioutil.WriteFile(filepath.Join(root, "version_1", name), []byte("-- @migrate/up\nCREATE TABLE "+
+			table+"(ID INT);\n-- @migrate/down\nDROP TABLE "+table+";\n"), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		describe := func(planned []PlannedPart) string {
+			names := make([]string, len(planned))
+			for i, part := range planned {
+				names[i] = part.Part
+			}
+			return strings.Join(names, " ")
+		}
+
+		planned, err := instance.PlanParts(2)
+		if err != nil {
+			t.Fatal("Instance.PlanParts: got error:\n", err)
+		}
+		expected := "accounts.sql billing.sql payments.sql invoices.sql"
+		if order := describe(planned); order != expected {
+			t.Errorf("Instance.PlanParts: got '%s' expected '%s'", order, expected)
+		}
+		if planned[0].Path != filepath.Join(root, "version_1", "accounts.sql") {
+			t.Errorf("Instance.PlanParts: got path '%s' expected that of 'accounts.sql'", planned[0].Path)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		planned, err = instance.PlanParts(0)
+		if err != nil {
+			t.Fatal("Instance.PlanParts: got error:\n", err)
+		}
+		expected = "invoices.sql accounts.sql billing.sql payments.sql"
+		if order := describe(planned); order != expected || planned[0].Direction != "down" {
+			t.Errorf("Instance.PlanParts: got '%s' expected '%s' down", order, expected)
+		}
+	})
+
+	if err := os.Rename(filepath.Join(root, "version_1", "payments.sql"),
+		filepath.Join(root, "version_1", "Accounts.sql")); err != nil {
+		t.Fatal("os.Rename: got error:\n", err)
+	}
+	expectError(t, "Inspect", "names differing only in case", func() error { return Inspect(root) },
+		"differ only in case")
+}
@@ -0,0 +1,197 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Message identifies one of the messages written to the Output of an
+// Instance, each of which is rendered from a format which may be overridden
+// with WithOutputFormats.
+type Message string
+
+// The messages written to Output. The fields of MessageData set for each are
+// noted alongside.
+const (
+	MessagePreparing      Message = "preparing"       // Jump
+	MessageBeginning      Message = "beginning"       // Direction, From, To
+	MessageApplied        Message = "applied"         // Version, Part
+	MessageStatement      Message = "statement"       // Version, Part, Verb, Rows
+	MessageSkipped        Message = "skipped"         // Version, Part, Reason
+	MessageFailed         Message = "failed"          // Version, Part, Err
+	MessageOptionalFailed Message = "optional-failed" // Version, Part, Err
+	MessageTimedOut       Message = "timed-out"       // Version, Timeout
+	MessageLeftDirty      Message = "left-dirty"      // Failed
+	MessageReverting      Message = "reverting"       // Failed, Applied
+	MessageVersionApplied Message = "version-applied" // Version, Applied
+	MessageFinished       Message = "finished"        // Duration
+	MessageHeartbeat      Message = "heartbeat"       // Version, Part, Elapsed
+	MessageStaleLock      Message = "stale-lock"      // Holder
+	MessageDiagnostic     Message = "diagnostic"      // Diagnostic
+	MessageHealthy        Message = "healthy"         // none
+	MessageCleaned        Message = "cleaned"         // Statements
+	MessageLockRetry      Message = "lock-retry"      // Version, Part, Timeout, Duration
+	MessageRowLimit       Message = "row-limit"       // Version, Part, Verb, Rows
+	MessageBackfillBatch  Message = "backfill-batch"  // Part, Applied, Rows
+	MessageBackfilled     Message = "backfilled"      // Part, Rows, Duration
+	MessageAnalyzed       Message = "analyzed"        // Version, Table
+	MessageAnalyzeFailed  Message = "analyze-failed"  // Version, Table, Err
+
+	MessageNonTransactionalDDL Message = "non-transactional-ddl" // Dialect, Statements
+)
+
+// MessageData holds the values with which a Message is rendered. Only those
+// fields relevant to the Message are set.
+type MessageData struct {
+	Version    int
+	Part       string
+	Direction  string
+	From       int
+	To         int
+	Jump       int // Number of versions to be migrated over
+	Applied    int // Number of parts applied
+	Failed     int // Number of parts which failed to apply
+	Reason     string
+	Err        error
+	Timeout    time.Duration
+	Duration   time.Duration
+	Elapsed    time.Duration
+	Holder     string
+	Dialect    string
+	Statements int    // Number of statements concerned
+	Verb       string // Keyword with which a statement begins, such as UPDATE
+	Rows       int64  // Number of rows affected by a statement
+	Diagnostic Diagnostic
+	Table      string // Table concerned, such as one analyzed
+}
+
+// DefaultFormats holds the format of every Message written to Output unless
+// overridden with WithOutputFormats. Formats are text/template templates
+// executed with a MessageData, in which the functions bold, red, yellow, and
+// reset write the terminal escape codes for each color, or nothing if
+// WithoutColor is in use, and thousands writes a number with commas between
+// each group of three digits. MessageStatement is only written if WithVerbose
+// is in use.
+var DefaultFormats = map[Message]string{
+	MessagePreparing: "{{bold}}migrate: Preparing to migrate over {{.Jump}} version(s)...{{reset}}\n",
+	MessageBeginning: "{{bold}}migrate: Beginning migration {{.Direction}} from version {{.From}} to {{.To}}..." +
+		"{{reset}}\n",
+	MessageApplied:        "- Applied '{{.Part}}'\n",
+	MessageStatement:      "  - {{.Verb}} touched {{thousands .Rows}} row(s)\n",
+	MessageSkipped:        "- Skipped '{{.Part}}', {{.Reason}}\n",
+	MessageFailed:         "{{red}}- Failed to apply '{{.Part}}': {{.Err}}{{reset}}\n",
+	MessageOptionalFailed: "{{yellow}}- Failed to apply optional '{{.Part}}': {{.Err}}{{reset}}\n",
+	MessageTimedOut:       "{{red}}- Timed out after {{.Timeout}} applying version {{.Version}}{{reset}}\n",
+	MessageLeftDirty: "\n{{bold}}migrate: {{.Failed}} parts failed to apply, call Resume once the issue is " +
+		"resolved to continue from the first unapplied part{{reset}}\n",
+	MessageReverting: "\n{{bold}}migrate: {{.Failed}} parts failed to apply, reverting {{.Applied}} successfully " +
+		"applied parts...{{reset}}\n",
+	MessageVersionApplied: "{{bold}}migrate: Successfully applied {{.Applied}} migration part(s){{reset}}\n",
+	MessageFinished:       "\n{{bold}}migrate: Successfully applied migrations in {{.Duration}}{{reset}}\n",
+	MessageHeartbeat:      "- Still applying '{{.Part}}' ({{.Elapsed}} elapsed)...\n",
+	MessageStaleLock:      "{{bold}}migrate: Took over stale lock held by '{{.Holder}}'{{reset}}\n",
+	MessageDiagnostic:     "- {{.Diagnostic}}\n",
+	MessageHealthy:        "{{bold}}migrate: No problems found{{reset}}\n",
+	MessageCleaned:        "{{bold}}migrate: Dropped {{.Statements}} object(s), now at version 0{{reset}}\n",
+	MessageLockRetry: "{{yellow}}- Waited {{.Timeout}} for a lock in '{{.Part}}', retrying in {{.Duration}}..." +
+		"{{reset}}\n",
+	MessageRowLimit: "{{yellow}}- {{.Verb}} in '{{.Part}}' is estimated to touch {{thousands .Rows}} row(s), " +
+		"more than the limit, applying as overridden{{reset}}\n",
+	MessageBackfillBatch: "- Backfill '{{.Part}}' committed batch {{.Applied}}, {{thousands .Rows}} row(s) " +
+		"so far\n",
+	MessageBackfilled: "{{bold}}migrate: Backfill '{{.Part}}' finished with {{thousands .Rows}} row(s) in " +
+		"{{.Duration}}{{reset}}\n",
+	MessageAnalyzed: "- Analyzed '{{.Table}}'\n",
+	MessageAnalyzeFailed: "{{yellow}}- Failed to analyze '{{.Table}}', its statistics may be stale: {{.Err}}" +
+		"{{reset}}\n",
+	MessageNonTransactionalDDL: "{{yellow}}migrate: Warning: {{.Dialect}} commits DDL implicitly, so " +
+		"{{.Statements}} DDL statement(s) about to run cannot be rolled back if the run fails{{reset}}\n",
+}
+
+// colors holds the terminal escape codes written by the color functions
+// available to formats.
+var colors = map[string]string{
+	"bold":   "\033[1m",
+	"red":    "\033[31;1m",
+	"yellow": "\033[33;1m",
+	"reset":  "\033[0m",
+}
+
+// parseFormats parses the formats of every Message, using those provided in
+// place of the DefaultFormats.
+func parseFormats(formats map[Message]string, color bool) (map[Message]*template.Template, error) {
+	funcs := make(template.FuncMap, len(colors))
+	for name, code := range colors {
+		if !color {
+			code = ""
+		}
+		funcs[name] = func(code string) func() string {
+			return func() string { return code }
+		}(code)
+	}
+
+	funcs["thousands"] = thousands
+
+	parsed := make(map[Message]*template.Template, len(DefaultFormats))
+	for message, format := range DefaultFormats {
+		if override, ok := formats[message]; ok {
+			format = override
+		}
+
+		tmpl, err := template.New(string(message)).Funcs(funcs).Parse(format)
+		if err != nil {
+			return nil, fmt.Errorf("got error while parsing format of message '%s':\n%s", message, err)
+		}
+		parsed[message] = tmpl
+	}
+
+	for message := range formats {
+		if _, ok := DefaultFormats[message]; !ok {
+			return nil, fmt.Errorf("got format for unknown message '%s'", message)
+		}
+	}
+
+	return parsed, nil
+}
+
+// say writes the Message specified to Output, rendered with data, and passes
+// it to every Reporter. Messages are written one at a time, as heartbeats are
+// written from a goroutine of their own while a part is applied.
+func (instance *Instance) say(message Message, data MessageData) {
+	instance.sayMutex.Lock()
+	defer instance.sayMutex.Unlock()
+
+	var text strings.Builder
+	if err := instance.formats[message].Execute(&text, data); err != nil {
+		fmt.Fprintf(&text, "migrate: got error while rendering message '%s':\n%s\n", message, err)
+	}
+	io.WriteString(instance.Output, text.String())
+
+	if len(instance.reporters) > 0 {
+		event := Event{Message: message, Data: data, Text: text.String(), Time: instance.clock.Now(),
+			RunID: instance.activeRun}
+		for _, reporter := range instance.reporters {
+			reporter.Report(event)
+		}
+	}
+}
+
+// thousands formats a number with commas between each group of three digits,
+// such as 1,238,112.
+func thousands(number int64) string {
+	digits := strconv.FormatInt(number, 10)
+	sign := ""
+	if number < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+
+	return sign + digits
+}
@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestOutputFormats(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta", WithoutColor(), WithOutputFormats(map[Message]string{
+			MessageApplied:   "{{red}}applied {{.Version}}/{{.Part}}{{reset}}\n",
+			MessageBeginning: "",
+		}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		output := &strings.Builder{}
+		instance.Output = output
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		got := output.String()
+		if strings.Contains(got, "\033[") {
+			t.Errorf("Instance.Goto: got escape codes in output '%q' expected none", got)
+		}
+
+		if strings.Contains(got, "Beginning migration") {
+			t.Errorf("Instance.Goto: got output '%q' expected beginning message to be omitted", got)
+		}
+
+		if !strings.Contains(got, "applied 1/"+instance.migrations[1].Parts[0].Name+"\n") {
+			t.Errorf("Instance.Goto: got output '%q' expected custom applied message", got)
+		}
+
+		if !strings.Contains(got, "migrate: Successfully applied") {
+			t.Errorf("Instance.Goto: got output '%q' expected default finished message", got)
+		}
+	})
+}
+
+func TestOutputFormatsInvalid(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		expectError(t, "NewInstance", "an unparseable format", func() error {
+			_, err := NewInstance(db, "testing/meta", WithOutputFormats(map[Message]string{
+				MessageApplied: "{{.Part",
+			}))
+			return err
+		}, "format of message 'applied'")
+
+		expectError(t, "NewInstance", "an unknown message", func() error {
+			_, err := NewInstance(db, "testing/meta", WithOutputFormats(map[Message]string{"unknown": "x"}))
+			return err
+		}, "unknown message 'unknown'")
+	})
+}
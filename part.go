@@ -2,14 +2,33 @@ package migrate
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-var regexPartDir = regexp.MustCompile(`^--\s?@migrate/(up|down)$`)
+var regexPartDir = regexp.MustCompile(`^--\s?@migrate/([a-z-]+)(?:\s+(.*))?$`)
+
+// directives maps the name of each directive which may be used within a part
+// file to whether it requires an argument.
+var directives = map[string]bool{
+	"up":           false,
+	"down":         false,
+	"irreversible": false,
+	"optional":     false,
+	"skip-if":      true,
+	"meta":         true,
+	"kind":         true,
+	"deferred":     false,
+	"load-csv":     true,
+	"tags":         true,
+	"grant":        true,
+	"owner":        true,
+	"analyze":      true,
+}
 
 // Part is one out of many other pieces that make up a Migration, separating
 // migrate up and migrate down SQL as extracted from the file which holds it.
@@ -18,40 +37,177 @@ type Part struct {
 	Path string
 	Up   string
 	Down string
+	// Checksum is the hex-encoded digest of the canonical form of the part
+	// file, after rendering if it is a template, in which comments and
+	// whitespace are normalized, so that reformatting the part leaves its
+	// Checksum unchanged. The digest is SHA-256 unless another algorithm is
+	// provided with WithChecksumAlgorithm.
+	Checksum string
+	// RawChecksum is the hex-encoded digest of the part file exactly as read,
+	// after rendering if it is a template.
+	RawChecksum string
+
+	// Irreversible is true if the part is marked with `-- @migrate/irreversible`
+	// rather than providing downward migration SQL.
+	Irreversible bool
+	// Optional is true if the part is marked with `-- @migrate/optional`, in
+	// which case its failure is reported but does not abort the run.
+	Optional bool
+	// Kind is KindData if the part is marked with `-- @migrate/kind data`, in
+	// which case LatestSchema defers it to LatestData, or KindSchema otherwise.
+	Kind Kind
+	// Deferred is true if the part is marked with `-- @migrate/deferred`, in
+	// which case it is queued rather than applied when migrating up, to be
+	// applied later by RunDeferred.
+	Deferred bool
+	// Tags holds the tags provided with `-- @migrate/tags a,b`, by which runs
+	// may include or exclude the part with WithTags and WithoutTags.
+	Tags []string
+	// Grants holds the privileges granted with `-- @migrate/grant`, and Owner
+	// the role provided with `-- @migrate/owner`, applied to the objects which
+	// the part creates once its upward migration has been applied.
+	Grants []Grant
+	Owner  string
+	// Analyze holds the tables provided with `-- @migrate/analyze a,b`, whose
+	// statistics are refreshed once the version of the part has been applied.
+	Analyze []string
+	// SkipIf holds the guard query provided with `-- @migrate/skip-if <query>`.
+	// If the query returns a truthy value the part is skipped when migrating up.
+	SkipIf string
+	// Meta holds the key-value pairs provided with `-- @migrate/meta`, such
+	// as the author of the part or the ticket which it implements.
+	Meta map[string]string
+
+	// UpStatements and DownStatements hold the individual statements which
+	// make up the up and down SQL, applied one at a time.
+	UpStatements   []Statement
+	DownStatements []Statement
+
+	contents  []byte // Contents of the part file, from which checksums may be recomputed
+	ownerLine int    // Line of the part file on which the owner directive appears
 }
 
 // NewPart takes a file path and parses its contents, separating migrate up and
-// migrate down SQL and returning a Part.
+// migrate down SQL and returning a Part. If the path ends with the
+// TemplateExtension, the file is first rendered as a template without data.
 func NewPart(path string) (*Part, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	return loader{}.part(path)
+}
+
+// statementText returns the statements of the part to be applied in
+// direction, each on its own line and with its own line breaks kept, as
+// executed. Unlike Up and Down, whose lines are joined without a separator,
+// the text reads as the SQL of the part file.
+func (part *Part) statementText(direction string) string {
+	statements := part.UpStatements
+	if direction == "down" {
+		statements = part.DownStatements
 	}
 
-	defer func() {
-		if err := file.Close(); err != nil {
-			panic(fmt.Sprint("Migration.AddPart: got error while closing part file:\n", err))
-		}
-	}()
+	lines := make([]string, len(statements))
+	for index, statement := range statements {
+		lines[index] = statement.SQL
+	}
 
+	return strings.Join(lines, "\n")
+}
+
+// parsePart parses the contents of the part file at path, separating migrate
+// up and migrate down SQL and returning a Part.
+func parsePart(path string, contents []byte) (*Part, error) {
 	errNoMarker := NewFatalf("Migration.AddFile: expected part file '%s' to begin with a comment "+
 		"denoting whether the following SQL represents an upward or downward migration "+
 		"(for example: '-- @migrate/up' or '@migrate/down')", path)
 
-	upSQL := ""
-	downSQL := ""
+	_, filename := filepath.Split(path)
+	checksum, raw := SHA256.checksums(contents)
+	part := &Part{Name: filename, Path: path, Kind: KindSchema, Checksum: checksum, RawChecksum: raw,
+		contents: contents}
+	upStatements := make([]Statement, 0)
+	upLines := make([]sourceLine, 0)
+	downLines := make([]sourceLine, 0)
 	which := -1
-	scanner := bufio.NewScanner(file)
+	number := 0
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
 	for scanner.Scan() {
+		number++
 		text := strings.TrimSpace(scanner.Text())
 		matches := regexPartDir.FindStringSubmatch(text)
 
 		// if matches were found, check them
 		if len(matches) > 1 {
-			if matches[1] == "up" {
+			directive, argument := matches[1], strings.TrimSpace(matches[2])
+			if takesArgument, ok := directives[directive]; !ok {
+				return nil, NewFatalf("Migration.AddFile: unknown directive '%s' in part file '%s'", directive, path)
+			} else if takesArgument && argument == "" {
+				return nil, NewFatalf("Migration.AddFile: directive '%s' in part file '%s' requires an argument",
+					directive, path)
+			} else if !takesArgument && argument != "" {
+				return nil, NewFatalf("Migration.AddFile: directive '%s' in part file '%s' takes no arguments",
+					directive, path)
+			}
+
+			switch directive {
+			case "up":
 				which = 0
-			} else if matches[1] == "down" {
+			case "down":
+				which = 1
+			case "irreversible":
 				which = 1
+				part.Irreversible = true
+			case "optional":
+				part.Optional = true
+			case "deferred":
+				part.Deferred = true
+			case "skip-if":
+				part.SkipIf = argument
+			case "kind":
+				if part.Kind = Kind(argument); part.Kind != KindSchema && part.Kind != KindData {
+					return nil, NewFatalf("Migration.AddFile: unknown kind '%s' in part file '%s', expected "+
+						"'schema' or 'data'", argument, path)
+				}
+			case "load-csv":
+				if which != 0 {
+					return nil, NewFatalf("Migration.AddFile: directive 'load-csv' in part file '%s' may only "+
+						"appear within the upward migration", path)
+				}
+
+				load, err := parseLoadCSV(path, argument)
+				if err != nil {
+					return nil, err
+				}
+
+				// The statements preceding the directive are applied before the file is loaded
+				upStatements = append(upStatements, splitStatements(upLines)...)
+				upStatements = append(upStatements, Statement{SQL: text, Line: number, CSV: load})
+				upLines = upLines[:0]
+				part.Up += text
+			case "tags":
+				if err := parseTags(part, argument); err != nil {
+					return nil, NewFatalf("Migration.AddFile: got error while parsing tags in part file '%s':\n%s",
+						path, err)
+				}
+			case "grant":
+				if err := parseGrant(part, argument, number); err != nil {
+					return nil, NewFatalf("Migration.AddFile: got error while parsing grant in part file '%s':\n%s",
+						path, err)
+				}
+			case "owner":
+				if strings.ContainsAny(argument, " \t") {
+					return nil, NewFatalf("Migration.AddFile: expected a single role as the owner in part file "+
+						"'%s', got '%s'", path, argument)
+				}
+				part.Owner, part.ownerLine = argument, number
+			case "analyze":
+				if err := parseAnalyze(part, argument); err != nil {
+					return nil, NewFatalf("Migration.AddFile: got error while parsing tables to analyze in part "+
+						"file '%s':\n%s", path, err)
+				}
+			case "meta":
+				if err := parseMeta(part, argument); err != nil {
+					return nil, NewFatalf("Migration.AddFile: got error while parsing metadata in part file "+
+						"'%s':\n%s", path, err)
+				}
 			}
 
 			continue
@@ -63,15 +219,17 @@ func NewPart(path string) (*Part, error) {
 
 		switch which {
 		case 0: // if 0, append to upSQL
-			upSQL += text
+			part.Up += text
+			upLines = append(upLines, sourceLine{number, text})
 		case 1: // if 1, append to downSQL
-			downSQL += text
+			part.Down += text
+			downLines = append(downLines, sourceLine{number, text})
 		default: // otherwise, return error
 			return nil, errNoMarker
 		}
 	}
 
-	if err = scanner.Err(); err != nil {
+	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
 
@@ -79,14 +237,86 @@ func NewPart(path string) (*Part, error) {
 		return nil, errNoMarker
 	}
 
-	if upSQL == "" {
+	if part.Up == "" {
 		return nil, NewFatalf("Migration.AddFile: file '%s' contains no upward migration data", path)
 	}
 
-	if downSQL == "" {
+	if part.Irreversible && part.Down != "" {
+		return nil, NewFatalf("Migration.AddFile: file '%s' is irreversible but contains downward migration data",
+			path)
+	} else if !part.Irreversible && part.Down == "" {
 		return nil, NewFatalf("Migration.AddFile: file '%s' contains no downward migration data", path)
 	}
 
-	_, filename := filepath.Split(path)
-	return &Part{Name: filename, Path: path, Up: upSQL, Down: downSQL}, nil
+	part.UpStatements = append(upStatements, splitStatements(upLines)...)
+	part.DownStatements = splitStatements(downLines)
+
+	// Grants and owners without an object apply to those created, so the part must create at least one
+	if len(createdObjects(part.UpStatements)) == 0 {
+		for _, grant := range part.Grants {
+			if grant.Object == "" {
+				return nil, NewFatalf("Migration.AddFile: grant on line %d of part file '%s' names no object, "+
+					"and the part creates none", grant.Line, path)
+			}
+		}
+		if part.Owner != "" {
+			return nil, NewFatalf("Migration.AddFile: owner on line %d of part file '%s' applies to the objects "+
+				"created by the part, but it creates none", part.ownerLine, path)
+		}
+	}
+
+	return part, nil
+}
+
+// parseMeta parses the space-separated key=value pairs provided to the meta
+// directive into the Meta of a part. Values containing spaces must be quoted
+// in the manner of a Go string literal.
+func parseMeta(part *Part, argument string) error {
+	if part.Meta == nil {
+		part.Meta = make(map[string]string)
+	}
+
+	for argument != "" {
+		equals := strings.IndexByte(argument, '=')
+		if equals <= 0 {
+			return fmt.Errorf("migrate: expected key=value pair, got '%s'", argument)
+		}
+
+		key := argument[:equals]
+		if strings.ContainsAny(key, " \t\"") {
+			return fmt.Errorf("migrate: invalid metadata key '%s'", key)
+		}
+
+		rest := argument[equals+1:]
+		value := rest
+		if strings.HasPrefix(rest, "\"") {
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++ // Skip escaped characters
+				}
+				end++
+			}
+
+			if end >= len(rest) {
+				return fmt.Errorf("migrate: unterminated quoted value for key '%s'", key)
+			}
+
+			quoted := rest[:end+1]
+			var err error
+			if value, err = strconv.Unquote(quoted); err != nil {
+				return fmt.Errorf("migrate: invalid quoted value for key '%s':\n%s", key, err)
+			}
+			rest = rest[len(quoted):]
+		} else if space := strings.IndexAny(rest, " \t"); space >= 0 {
+			value, rest = rest[:space], rest[space:]
+		} else {
+			rest = ""
+		}
+
+		part.Meta[key] = value
+		argument = strings.TrimSpace(rest)
+	}
+
+	return nil
 }
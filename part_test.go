@@ -1,6 +1,9 @@
 package migrate
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 var pExpectError = newExpectError(func(args ...interface{}) error {
 	_, err := NewPart("testing/" + args[0].(string))
@@ -15,4 +18,40 @@ func TestBadParts(t *testing.T) {
 		"to begin with a comment denoting", "bad_parts/no_markers.sql")
 	pExpectError(t, "no upward migration SQL", "no upward migration data", "bad_parts/no_upward.sql")
 	pExpectError(t, "no downward migration SQL", "no downward migration data", "bad_parts/no_downward.sql")
+	pExpectError(t, "unknown directives", "unknown directive 'sometimes'", "bad_parts/unknown_directive.sql")
+}
+
+// TestPartMeta ensures that metadata directives are parsed into Part.Meta,
+// and that malformed metadata returns an appropriate error.
+func TestPartMeta(t *testing.T) {
+	part, err := NewPart("testing/meta/version_1/billing.sql")
+	if err != nil {
+		t.Fatal("NewPart: got error:\n", err)
+	}
+
+	expected := map[string]string{"author": "jane", "ticket": "PROJ-123", "description": "add billing tables"}
+	if len(part.Meta) != len(expected) {
+		t.Errorf("NewPart.Meta: got '%#v' expected '%#v'", part.Meta, expected)
+	}
+	for key, value := range expected {
+		if part.Meta[key] != value {
+			t.Errorf("NewPart.Meta: got '%s' for key '%s' expected '%s'", part.Meta[key], key, value)
+		}
+	}
+
+	for argument, errContains := range map[string]string{
+		"author":              "expected key=value pair",
+		"=jane":               "expected key=value pair",
+		`description="a b`:    "unterminated quoted value",
+		`description="a\qb"`:  "invalid quoted value",
+		`"author"=jane a=b`:   "invalid metadata key",
+		`escaped="a \"b\" c"`: "",
+	} {
+		err := parseMeta(&Part{}, argument)
+		if errContains == "" && err != nil {
+			t.Errorf("parseMeta: got error with '%s':\n%s", argument, err)
+		} else if errContains != "" && (err == nil || !strings.Contains(err.Error(), errContains)) {
+			t.Errorf("parseMeta: expected error containing '%s' with '%s', got:\n%v", errContains, argument, err)
+		}
+	}
 }
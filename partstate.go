@@ -0,0 +1,96 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PartState is the state of a single part of a migration as recorded in the
+// database, which is kept for every part as it is applied, skipped, deferred,
+// or reverted, whether by Goto, RunDeferred, or Resume.
+type PartState string
+
+const (
+	// PartNone is the state of a part which is not applied, either as its
+	// version has not been applied or as it has been reverted. Parts of
+	// versions applied before states were recorded are also in this state.
+	PartNone PartState = ""
+	// PartApplied is the state of a part which has been applied.
+	PartApplied PartState = "applied"
+	// PartSkipped is the state of a part skipped as its guard query returned
+	// true.
+	PartSkipped PartState = "skipped"
+	// PartDeferred is the state of a part queued to be applied by RunDeferred,
+	// as it is deferred, a data part deferred by LatestSchema, or excluded by
+	// its tags.
+	PartDeferred PartState = "deferred"
+	// PartFailed is the state of an optional part which failed, such that its
+	// version was applied without it.
+	PartFailed PartState = "failed"
+)
+
+// createPartStates creates the table in which the state of every part is
+// recorded, named table, if it does not already exist.
+func createPartStates(exec execer, table string) error {
+	_, err := exec.Exec(`
+		CREATE TABLE IF NOT EXISTS ` + table + `(
+			Version INT NOT NULL,
+			Part VARCHAR(255) NOT NULL,
+			State VARCHAR(16) NOT NULL,
+			UpdatedAt BIGINT NOT NULL,
+			PRIMARY KEY (Version, Part)
+		);
+	`)
+	return err
+}
+
+// setPartState records state as the state of a part of a migration version,
+// replacing any state recorded before, as of the time provided, the start of
+// the run. The state PartNone removes the record of the part altogether.
+func (instance *Instance) setPartState(exec execer, version int, name string, state PartState,
+	at time.Time) error {
+	table := instance.table("migrate_parts")
+	if _, err := exec.Exec(instance.rebind(`DELETE FROM `+table+` WHERE Version = ? AND Part = ?;`), version,
+		name); err != nil {
+		return fmt.Errorf("migrate: failed to clear state of part '%s' of version %d:\n%s", name, version, err)
+	} else if state == PartNone {
+		return nil
+	}
+
+	if _, err := exec.Exec(instance.rebind(`INSERT INTO `+table+` (Version, Part, State, UpdatedAt) `+
+		`VALUES (?, ?, ?, ?);`), version, name, string(state), at.UnixNano()); err != nil {
+		return fmt.Errorf("migrate: failed to record state of part '%s' of version %d:\n%s", name, version, err)
+	}
+
+	return nil
+}
+
+// partStates returns the state recorded for every part, keyed by version and
+// then by the name of the part.
+func (instance *Instance) partStates() (map[int]map[string]PartState, error) {
+	states := make(map[int]map[string]PartState)
+	table := instance.table("migrate_parts")
+	if instance.uninitialized() || instance.readOnly && !tableExists(instance.db, table) {
+		return states, nil
+	}
+
+	err := query(instance.db, `SELECT Version, Part, State FROM `+table+`;`, nil, func(rows *sql.Rows) error {
+		var version int
+		var name, state string
+		if err := rows.Scan(&version, &name, &state); err != nil {
+			return err
+		}
+
+		if states[version] == nil {
+			states[version] = make(map[string]PartState)
+		}
+		states[version][name] = PartState(state)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read part states:\n%s", err)
+	}
+
+	return states, nil
+}
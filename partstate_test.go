@@ -0,0 +1,90 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPartStates ensures that the state of every part is recorded as it is
+// applied, skipped, deferred, or fails, that Status and Doctor report the
+// states, and that migrating down clears them.
+func TestPartStates(t *testing.T) {
+	root := CopyTree(t, "testing/meta")
+	for name, contents := range map[string]string{
+		"audit.sql": "-- @migrate/deferred\n-- @migrate/up\nCREATE TABLE audit(ID INT);\n" +
+			"-- @migrate/down\nDROP TABLE audit;\n",
+		"guarded.sql": "-- @migrate/skip-if SELECT 1\n-- @migrate/up\nSELECT 1;\n-- @migrate/down\nSELECT 1;\n",
+		"missing.sql": "-- @migrate/optional\n-- @migrate/up\nDELETE FROM missing;\n-- @migrate/down\nSELECT 1;\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(root, "version_2", name), []byte(contents), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		states := func() map[string]PartState {
+			states := make(map[string]PartState)
+			for _, status := range instance.Status() {
+				for _, part := range status.Parts {
+					states[part.Name] = part.State
+				}
+			}
+			return states
+		}
+
+		expected := map[string]PartState{"billing.sql": PartApplied, "invoices.sql": PartApplied,
+			"audit.sql": PartDeferred, "guarded.sql": PartSkipped, "missing.sql": PartFailed}
+		for name, state := range states() {
+			if state != expected[name] {
+				t.Errorf("Instance.Status: got state '%s' for '%s' expected '%s'", state, name, expected[name])
+			}
+		}
+
+		diagnostics := instance.Doctor()
+		if len(diagnostics) != 1 || diagnostics[0].Check != "optional-failed" {
+			t.Errorf("Instance.Doctor: got %v expected the failed optional part alone", diagnostics)
+		}
+
+		if err := instance.RunDeferred(context.Background()); err != nil {
+			t.Fatal("Instance.RunDeferred: got error:\n", err)
+		}
+		if state := states()["audit.sql"]; state != PartApplied {
+			t.Errorf("Instance.RunDeferred: got state '%s' for audit.sql expected applied", state)
+		}
+
+		if _, err := db.Exec(`INSERT INTO migrate_pending (Version, Part, QueuedAt, Attempts, LastError) ` +
+			`VALUES (2, 'audit.sql', 0, 0, '');`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+		if diagnostics := instance.Doctor(); len(diagnostics) != 2 ||
+			!strings.Contains(diagnostics[0].Message, "is recorded as applied, but is queued for RunDeferred") {
+			t.Errorf("Instance.Doctor: got %v expected the queued part to be reported", diagnostics)
+		}
+		if _, err := db.Exec(`DELETE FROM migrate_pending;`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		for name, state := range states() {
+			if expected := map[string]PartState{"billing.sql": PartApplied}[name]; state != expected {
+				t.Errorf("Instance.Goto: got state '%s' for '%s' expected '%s'", state, name, expected)
+			}
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package migrate
+
+// PlannedStatement is a single statement which a run would execute.
+type PlannedStatement struct {
+	Version   int
+	Part      string
+	Direction string
+	SQL       string
+	// Args holds the arguments of the statement, as with the INSERT statement
+	// planned for each row of a CSV file loaded with `-- @migrate/load-csv`.
+	Args []interface{}
+	// Guarded is true if the part has a guard query provided with
+	// `-- @migrate/skip-if`, or the Dialect guards the statement as described
+	// by Guards, in which case the statement is only executed if the guard
+	// query does not return a truthy value.
+	Guarded bool
+}
+
+// Plan returns every statement which Goto would execute to bring the database
+// from its current version to the target version, in the order in which they
+// would be executed and rewritten by the Dialect if WithIdempotent is in use,
+// including the grants and owners which follow the statements of a part. A
+// CSV file loaded with `-- @migrate/load-csv` is planned as the INSERT
+// statement which loads each of its rows.
+// The statements used to record which migrations have been applied, manage
+// savepoints, and evaluate guard queries are not included, as they are never
+// passed to an Executor. Nor are the statements of parts which Goto would
+// queue for RunDeferred rather than apply, including those excluded by
+// WithTags or WithoutTags, or of those it would not revert as they are still
+// queued. Plan is intended for asserting the statements
+// received by a mock database, such as one provided with WithExecutor.
+func (instance *Instance) Plan(target int) ([]PlannedStatement, error) {
+	return instance.planStatements(instance.Version(), target, "")
+}
+
+// PlanNew returns every statement which Goto would execute to bring a new
+// database to the target version with the migrations within root, as Plan
+// does for the database of an Instance. Like Inspect, PlanNew needs no
+// database, so the statements are rewritten as the Generic dialect would
+// unless another is provided with WithDialect. It allows the statements
+// received by a mock database to be expected before NewInstance is called.
+func PlanNew(root string, target int, options ...Option) ([]PlannedStatement, error) {
+	instance, err := inspect(root, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return instance.planStatements(0, target, "")
+}
+
+// planStatements returns every statement which a run would execute to bring
+// the database from the current version to the target version, as Plan does,
+// with parts of the kind deferred, if any, queued rather than applied.
+func (instance *Instance) planStatements(current, target int, deferred Kind) ([]PlannedStatement, error) {
+	planned := make([]PlannedStatement, 0)
+	if target == current {
+		return planned, nil
+	}
+
+	todo, direction, err := instance.plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// if migrating down, fetch the states of the parts to be reverted, as those skipped or queued need not be
+	states := make(map[int]map[string]PartState)
+	if direction == "down" {
+		if states, err = instance.partStates(); err != nil {
+			return nil, NewFatalf("Instance.Plan: got error while reading part states:\n%s", err)
+		}
+	}
+
+	for _, migration := range todo {
+		for _, part := range migration.Parts {
+			if direction == "down" && part.Irreversible {
+				return nil, &ErrIrreversible{Version: migration.Version, Part: part.Name}
+			} else if direction == "up" && (isDeferred(part, deferred) || instance.excludedBy(part) != "") {
+				continue // Queued for RunDeferred rather than applied, as when excluded by its tags
+			} else if state := states[migration.Version][part.Name]; state == PartSkipped || state == PartDeferred {
+				continue // Skipped by its guard query when applied, or still queued, so never reverted
+			}
+
+			statements := part.UpStatements
+			if direction == "down" {
+				statements = part.DownStatements
+			} else {
+				statements = append(statements[:len(statements):len(statements)], instance.grantStatements(part)...)
+			}
+
+			for _, statement := range statements {
+				if statement.CSV != nil {
+					loaded, err := instance.planCSV(migration.Version, part, statement.CSV)
+					if err != nil {
+						return nil, err
+					}
+
+					planned = append(planned, loaded...)
+					continue
+				}
+
+				sql := statement.SQL
+				if instance.idempotent {
+					sql = instance.dialect.Idempotent(sql)
+				}
+
+				planned = append(planned, PlannedStatement{Version: migration.Version, Part: part.Name,
+					Direction: direction, SQL: sql, Guarded: direction == "up" && part.SkipIf != "" ||
+						instance.statementGuard(sql) != ""})
+			}
+		}
+	}
+
+	return planned, nil
+}
+
+// planCSV returns the INSERT statements with which the CSV file described by
+// load is loaded by a part, one for each row, as executed by loadCSV.
+func (instance *Instance) planCSV(version int, part *Part, load *CSVLoad) ([]PlannedStatement, error) {
+	if load.records == nil {
+		return nil, NewFatalf("Instance.Plan: file '%s' was not loaded along with the migration of part '%s'",
+			load.File, part.Name)
+	}
+
+	query, err := instance.insertQuery(load.Table, load.records[0])
+	if err != nil {
+		return nil, NewFatalf("Instance.Plan: got error while planning part '%s':\n%s", part.Name, err)
+	}
+
+	planned := make([]PlannedStatement, 0, len(load.records)-1)
+	for _, record := range load.records[1:] {
+		planned = append(planned, PlannedStatement{Version: version, Part: part.Name, Direction: "up", SQL: query,
+			Args: csvArgs(record), Guarded: part.SkipIf != ""})
+	}
+
+	return planned, nil
+}
@@ -0,0 +1,155 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planExecutor is an Executor which records every statement passed to it,
+// along with its arguments, before executing it against db.
+type planExecutor struct {
+	db         *sql.DB
+	statements []string
+	args       [][]interface{}
+}
+
+// ExecContext implements the Executor interface for planExecutor.
+func (executor *planExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result,
+	error) {
+	executor.statements = append(executor.statements, query)
+	executor.args = append(executor.args, args)
+	return executor.db.ExecContext(ctx, query, args...)
+}
+
+// checkPlan ensures that the statements returned by plan are exactly those
+// executed by run against a new database migrated with the migrations within
+// root, and then that the statements planned to migrate back down to version 0
+// are exactly those executed by Goto.
+func checkPlan(t *testing.T, root string, plan func(*Instance) ([]PlannedStatement, error),
+	run func(*Instance) error, options ...Option) {
+	t.Helper()
+	RunWithDB(func(db *sql.DB) {
+		executor := &planExecutor{db: db}
+		instance, err := NewInstance(db, root, append(options, WithoutTransaction(), WithExecutor(executor))...)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		for _, step := range []struct {
+			plan func(*Instance) ([]PlannedStatement, error)
+			run  func(*Instance) error
+		}{
+			{plan, run},
+			{func(instance *Instance) ([]PlannedStatement, error) { return instance.Plan(0) },
+				func(instance *Instance) error { return instance.Goto(0) }},
+		} {
+			planned, err := step.plan(instance)
+			if err != nil {
+				t.Fatal("Instance.Plan: got error:\n", err)
+			}
+
+			executor.statements, executor.args = nil, nil
+			if err := step.run(instance); err != nil {
+				t.Fatal("Instance.Goto: got error:\n", err)
+			}
+
+			expected := make([]string, len(planned))
+			for index, statement := range planned {
+				expected[index] = statement.SQL
+				if len(statement.Args) > 0 && index < len(executor.args) &&
+					!reflect.DeepEqual(statement.Args, executor.args[index]) {
+					t.Errorf("Instance.Plan: got arguments %v planned for statement %d expected %v", statement.Args,
+						index+1, executor.args[index])
+				}
+			}
+			if !reflect.DeepEqual(executor.statements, expected) {
+				t.Errorf("Instance.Plan: got %q planned for %s expected %q as executed", expected, root,
+					executor.statements)
+			}
+		}
+	})
+}
+
+// TestPlan ensures that Plan lists the statements Goto would execute in order.
+func TestPlan(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		planned, err := instance.Plan(2)
+		if err != nil {
+			t.Fatal("Instance.Plan: got error:\n", err)
+		}
+		if len(planned) != 3 || planned[0].Version != 1 || planned[2].Version != 2 || planned[0].Direction != "up" {
+			t.Fatalf("Instance.Plan: got '%#v' expected 3 statements from versions 1 and 2", planned)
+		}
+		if !strings.HasPrefix(planned[1].SQL, "ALTER TABLE test RENAME first_name") {
+			t.Errorf("Instance.Plan: got '%s' expected the first statement of version 2", planned[1].SQL)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		if planned, err := instance.Plan(3); err != nil || len(planned) != 0 {
+			t.Errorf("Instance.Plan: got '%#v' and error '%v' expected no statements", planned, err)
+		}
+		if planned, err := instance.Plan(2); err != nil || len(planned) != 1 || planned[0].Direction != "down" {
+			t.Errorf("Instance.Plan: got '%#v' and error '%v' expected a single statement down", planned, err)
+		}
+		expectError(t, "Instance.Plan", "invalid version", func() error { _, err := instance.Plan(4); return err },
+			"does not exist")
+	})
+}
+
+// TestPlanDeferred ensures that Plan and PlanSchema leave out the parts which
+// Goto and LatestSchema queue for RunDeferred, and the parts still queued when
+// migrating down.
+func TestPlanDeferred(t *testing.T) {
+	checkPlan(t, "testing/deferred", func(instance *Instance) ([]PlannedStatement, error) {
+		return instance.Plan(2)
+	}, func(instance *Instance) error { return instance.Goto(2) })
+
+	checkPlan(t, "testing/data", (*Instance).PlanSchema, (*Instance).LatestSchema)
+}
+
+// TestPlanTags ensures that Plan leaves out the parts which Goto excludes by
+// their tags.
+func TestPlanTags(t *testing.T) {
+	plan := func(instance *Instance) ([]PlannedStatement, error) { return instance.Plan(1) }
+	apply := func(instance *Instance) error { return instance.Goto(1) }
+
+	checkPlan(t, "testing/tags", plan, apply, WithoutTags("heavy"))
+	checkPlan(t, "testing/tags", plan, apply, WithTags("stats"))
+}
+
+// TestPlanGrants ensures that Plan includes the grants and owners applied
+// after the statements of a part.
+func TestPlanGrants(t *testing.T) {
+	root := CopyTree(t, "testing/meta")
+	contents := strings.Replace(grantPart, "CREATE SEQUENCE IF NOT EXISTS invoice_numbers;",
+		"CREATE VIEW recent AS SELECT * FROM grants;", 1)
+	if err := ioutil.WriteFile(filepath.Join(root, "version_1", "grants.sql"), []byte(contents), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	checkPlan(t, root, func(instance *Instance) ([]PlannedStatement, error) { return instance.Plan(1) },
+		func(instance *Instance) error { return instance.Goto(1) }, WithDialect(grantDialect{SQLite}),
+		WithRoles(map[string]string{"readonly": "reporting"}))
+}
+
+// TestPlanCSV ensures that Plan includes the INSERT statement which loads each
+// row of a CSV file, along with its arguments.
+func TestPlanCSV(t *testing.T) {
+	checkPlan(t, "testing/assets", func(instance *Instance) ([]PlannedStatement, error) { return instance.Plan(1) },
+		func(instance *Instance) error { return instance.Goto(1) })
+}
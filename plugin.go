@@ -0,0 +1,223 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Source fetches a migration tree from a location other than the local
+// filesystem, such as object storage or a repository, as registered with
+// RegisterSource. Fetch returns the local directory from which the tree is
+// then read, which the Source owns.
+type Source interface {
+	Fetch(location string) (string, error)
+}
+
+// SourceFunc adapts an ordinary function to the Source interface.
+type SourceFunc func(location string) (string, error)
+
+// Fetch calls fn with the location provided.
+func (fn SourceFunc) Fetch(location string) (string, error) {
+	return fn(location)
+}
+
+// Policy is a check made before every run, as registered with RegisterPolicy.
+// Check returns an error describing why the run from one version to the
+// target, in the direction provided, is not permitted, or nil if it is. A run
+// refused by a Policy returns an *ErrPolicy naming it. Unlike the policies
+// built into the package, a Policy cannot be overridden with Force.
+type Policy interface {
+	Name() string
+	Check(from, target int, direction string) error
+}
+
+// Notifier is told of every run once it finishes, whether it succeeded or
+// not, as registered with RegisterNotifier, such as to post to a chat room.
+// Runs refused by a Policy or hook, or which found no migrations to apply, are
+// not notified.
+type Notifier interface {
+	Notify(report *RunReport)
+}
+
+// NotifierFunc adapts an ordinary function to the Notifier interface.
+type NotifierFunc func(report *RunReport)
+
+// Notify calls fn with the report provided.
+func (fn NotifierFunc) Notify(report *RunReport) {
+	fn(report)
+}
+
+// Hooks are called at points within the lifecycle of every Instance, as
+// registered with RegisterHooks. Any hook may be nil.
+type Hooks struct {
+	// Created is called once NewInstance has loaded the migrations of an
+	// Instance. An error fails NewInstance.
+	Created func(instance *Instance) error
+	// BeforeRun is called before a run from one version to the target applies
+	// anything, once the policies of the Instance have permitted it. An error
+	// aborts the run.
+	BeforeRun func(instance *Instance, from, target int) error
+	// AfterVersion is called once each version of a run has been applied in
+	// the direction provided and committed, after the statistics of the
+	// tables named with `-- @migrate/analyze` have been refreshed.
+	AfterVersion func(instance *Instance, version int, direction string)
+	// Closed is called when an Instance is closed.
+	Closed func(instance *Instance)
+}
+
+// registeredDialect is a Dialect registered with RegisterDialect.
+type registeredDialect struct {
+	match   string
+	dialect Dialect
+}
+
+// plugins holds the extensions registered with the functions of the package,
+// as copied to each Instance when it is created.
+type plugins struct {
+	policies  []Policy
+	notifiers []Notifier
+	hooks     []Hooks
+	reporters []Reporter
+}
+
+// registry holds every extension registered with the package.
+var registry = struct {
+	sync.RWMutex
+	plugins
+	sources  map[string]Source
+	dialects []registeredDialect
+}{sources: make(map[string]Source)}
+
+// RegisterSource registers a Source for the locations beginning with scheme
+// followed by `://`, such as `s3://bucket/migrations` for the scheme s3. The
+// root provided to NewInstance or Inspect is then fetched with the Source.
+// Registering a scheme a second time replaces the Source registered before.
+func RegisterSource(scheme string, source Source) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.sources[strings.ToLower(scheme)] = source
+}
+
+// RegisterDialect registers a Dialect to be detected for databases whose
+// driver type, such as `*godror.drv`, contains match, ignoring case. Dialects
+// registered are detected before those built into the package, the most
+// recently registered first. WithDialect still takes precedence.
+func RegisterDialect(match string, dialect Dialect) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.dialects = append([]registeredDialect{{strings.ToLower(match), dialect}}, registry.dialects...)
+}
+
+// RegisterReporter registers a Reporter to be added to every Instance
+// created from then on, before any added with AddReporter.
+func RegisterReporter(reporter Reporter) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.reporters = append(registry.reporters, reporter)
+}
+
+// RegisterPolicy registers a Policy to be checked before every run of each
+// Instance created from then on, after the policies configured with options.
+func RegisterPolicy(policy Policy) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.policies = append(registry.policies, policy)
+}
+
+// RegisterNotifier registers a Notifier to be told of every run of each
+// Instance created from then on.
+func RegisterNotifier(notifier Notifier) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.notifiers = append(registry.notifiers, notifier)
+}
+
+// RegisterHooks registers Hooks to be called throughout the lifecycle of each
+// Instance created from then on, in the order in which they were registered.
+func RegisterHooks(hooks Hooks) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.hooks = append(registry.hooks, hooks)
+}
+
+// registered returns a copy of the extensions registered with the package.
+func registered() plugins {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	return plugins{
+		policies:  append([]Policy(nil), registry.policies...),
+		notifiers: append([]Notifier(nil), registry.notifiers...),
+		hooks:     append([]Hooks(nil), registry.hooks...),
+		reporters: append([]Reporter(nil), registry.reporters...),
+	}
+}
+
+// registeredDialectFor returns the Dialect registered for the driver type
+// provided, or nil if there is none.
+func registeredDialectFor(driver string) Dialect {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	for _, registered := range registry.dialects {
+		if strings.Contains(driver, registered.match) {
+			return registered.dialect
+		}
+	}
+
+	return nil
+}
+
+// fetchSource returns the local directory holding the migration tree at root,
+// fetching it with the Source registered for its scheme if it has one. Roots
+// without a scheme are returned unchanged.
+func fetchSource(root string) (string, error) {
+	separator := strings.Index(root, "://")
+	if separator <= 0 {
+		return root, nil
+	}
+
+	registry.RLock()
+	source, ok := registry.sources[strings.ToLower(root[:separator])]
+	registry.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("migrate: no source registered for scheme '%s'", root[:separator])
+	}
+
+	directory, err := source.Fetch(root)
+	if err != nil {
+		return "", fmt.Errorf("migrate: got error while fetching '%s':\n%s", root, err)
+	}
+
+	return directory, nil
+}
+
+// checkPlugins returns an *ErrPolicy if a Policy registered refuses a run,
+// or the error returned by the BeforeRun hook which refused it.
+func (instance *Instance) checkPlugins(current, target int, direction string) error {
+	for _, policy := range instance.plugins.policies {
+		if err := policy.Check(current, target, direction); err != nil {
+			return &ErrPolicy{Policy: policy.Name(), Reason: err.Error()}
+		}
+	}
+
+	for _, hooks := range instance.plugins.hooks {
+		if hooks.BeforeRun == nil {
+			continue
+		}
+
+		if err := hooks.BeforeRun(instance, current, target); err != nil {
+			return NewFatalf("Instance.Goto: run refused by hook:\n%s", err)
+		}
+	}
+
+	return nil
+}
+
+// notify tells every Notifier registered of the run described by report.
+func (instance *Instance) notify(report *RunReport) {
+	for _, notifier := range instance.plugins.notifiers {
+		notifier.Notify(report)
+	}
+}
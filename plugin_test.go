@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// namedDialect is a Dialect which behaves as another under a different name.
+type namedDialect struct {
+	Dialect
+	name string
+}
+
+// Name implements the Dialect interface for namedDialect.
+func (dialect namedDialect) Name() string {
+	return dialect.name
+}
+
+// denyPolicy is a Policy which refuses to migrate down.
+type denyPolicy struct{}
+
+// Name implements the Policy interface for denyPolicy.
+func (denyPolicy) Name() string {
+	return "no-down"
+}
+
+// Check implements the Policy interface for denyPolicy.
+func (denyPolicy) Check(from, target int, direction string) error {
+	if direction == "down" {
+		return errors.New("migrating down is not permitted")
+	}
+
+	return nil
+}
+
+// TestPlugins ensures that the sources, dialects, reporters, policies,
+// notifiers, and hooks registered with the package extend every Instance
+// created once they are registered.
+func TestPlugins(t *testing.T) {
+	registry.Lock()
+	saved, savedSources, savedDialects := registry.plugins, registry.sources, registry.dialects
+	registry.sources = make(map[string]Source)
+	registry.Unlock()
+	defer func() {
+		registry.Lock()
+		registry.plugins, registry.sources, registry.dialects = saved, savedSources, savedDialects
+		registry.Unlock()
+	}()
+
+	var fetched, events, notified []string
+	RegisterSource("test", SourceFunc(func(location string) (string, error) {
+		fetched = append(fetched, location)
+		return "testing/" + strings.TrimPrefix(location, "test://"), nil
+	}))
+	RegisterDialect("SQLite3", namedDialect{SQLite, "plugged"})
+	RegisterReporter(ReporterFunc(func(event Event) {
+		events = append(events, string(event.Message))
+	}))
+	RegisterPolicy(denyPolicy{})
+	RegisterNotifier(NotifierFunc(func(report *RunReport) {
+		notified = append(notified, report.Outcome.String())
+	}))
+
+	var lifecycle []string
+	RegisterHooks(Hooks{
+		Created: func(instance *Instance) error {
+			lifecycle = append(lifecycle, "created")
+			return nil
+		},
+		BeforeRun: func(instance *Instance, from, target int) error {
+			if target == 1 {
+				return errors.New("version 1 is off limits")
+			}
+
+			lifecycle = append(lifecycle, "run")
+			return nil
+		},
+		Closed: func(instance *Instance) {
+			lifecycle = append(lifecycle, "closed")
+		},
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "test://meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if len(fetched) != 1 || instance.root != "testing/meta" {
+			t.Errorf("RegisterSource: got root '%s' after fetching %v expected testing/meta", instance.root, fetched)
+		}
+		if name := instance.dialect.Name(); name != "plugged" {
+			t.Errorf("RegisterDialect: got dialect '%s' expected plugged", name)
+		}
+
+		expectError(t, "Instance.Goto", "run refused by hook", func() error {
+			return instance.Goto(1)
+		}, "version 1 is off limits")
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		expectError(t, "Instance.Goto", "run refused by policy", func() error {
+			return instance.Goto(0)
+		}, "no-down policy in effect: migrating down is not permitted")
+
+		if err := instance.Close(); err != nil {
+			t.Fatal("Instance.Close: got error:\n", err)
+		}
+
+		if len(events) == 0 || events[len(events)-1] != string(MessageFinished) {
+			t.Errorf("RegisterReporter: got events %v expected the run to finish", events)
+		}
+		if strings.Join(notified, ",") != "succeeded" {
+			t.Errorf("RegisterNotifier: got outcomes %v expected the successful run alone", notified)
+		}
+		if strings.Join(lifecycle, ",") != "created,run,closed" {
+			t.Errorf("RegisterHooks: got lifecycle %v expected created, run, and closed", lifecycle)
+		}
+
+		expectError(t, "NewInstance", "unregistered scheme", func() error {
+			_, err := NewInstance(db, "s3://bucket/migrations")
+			return err
+		}, "no source registered for scheme 's3'")
+	})
+}
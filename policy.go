@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// Override names a policy which may be disregarded for a single run by
+// passing it to Force.
+type Override int
+
+const (
+	// OverrideDenyDown permits migrating down despite WithDenyDown.
+	OverrideDenyDown Override = iota + 1
+	// OverrideMaxJump permits applying more versions than WithMaxJump allows.
+	OverrideMaxJump
+)
+
+// ErrPolicy is returned by Goto and Latest when a run would violate a policy
+// configured on the Instance.
+type ErrPolicy struct {
+	Policy string
+	Reason string
+}
+
+// Error implements the error interface for ErrPolicy.
+func (err *ErrPolicy) Error() string {
+	return fmt.Sprintf("Instance.Goto: refusing to migrate, %s policy in effect: %s", err.Policy, err.Reason)
+}
+
+// Is reports whether target is CodePolicy.
+func (err *ErrPolicy) Is(target error) bool {
+	return target == CodePolicy
+}
+
+// Force behaves exactly as Goto, except that the policies named by overrides
+// are disregarded. It is intended for deliberate, supervised runs which the
+// policies configured on the Instance would otherwise forbid.
+func (instance *Instance) Force(target int, overrides ...Override) error {
+	return instance.run(context.Background(), target, false, "", overrides...)
+}
+
+// checkPolicies returns an *ErrPolicy if a run from the current version
+// towards the target version, in the direction specified, violates a policy
+// configured on the Instance which has not been overridden.
+func (instance *Instance) checkPolicies(current, target int, direction string, overrides []Override) error {
+	jump := target - current
+	if jump < 0 {
+		jump = -jump
+	}
+
+	overridden := make(map[Override]bool, len(overrides))
+	for _, override := range overrides {
+		overridden[override] = true
+	}
+
+	if instance.denyDown && direction == "down" && !overridden[OverrideDenyDown] {
+		return &ErrPolicy{Policy: "deny-down", Reason: fmt.Sprintf("migrating down from version %d to %d is "+
+			"not permitted, use Force with OverrideDenyDown to proceed", current, target)}
+	}
+
+	if instance.maxJump > 0 && jump > instance.maxJump && !overridden[OverrideMaxJump] {
+		return &ErrPolicy{Policy: "max-jump", Reason: fmt.Sprintf("migrating from version %d to %d would apply "+
+			"%d versions, more than the maximum of %d, use Force with OverrideMaxJump to proceed", current,
+			target, jump, instance.maxJump)}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestDenyDown ensures that WithDenyDown forbids migrating down unless the
+// policy is overridden with Force.
+func TestDenyDown(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working", WithDenyDown())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error with WithDenyDown:\n", err)
+		}
+
+		err = instance.Goto(1)
+		if !errors.Is(err, CodePolicy) {
+			t.Fatalf("Instance.Goto: got '%v' expected error matching '%s'", err, CodePolicy)
+		}
+		expectError(t, "Instance.Goto", "WithDenyDown", func() error { return err }, "deny-down",
+			"OverrideDenyDown")
+		if instance.Version() != 3 {
+			t.Errorf("Instance.Version: got %d expected 3 after refused run", instance.Version())
+		}
+
+		if err := instance.Force(1, OverrideDenyDown); err != nil {
+			t.Fatal("Instance.Force: got error:\n", err)
+		}
+		if instance.Version() != 1 {
+			t.Errorf("Instance.Version: got %d expected 1 after Force", instance.Version())
+		}
+	})
+}
+
+// TestMaxJump ensures that WithMaxJump forbids applying too many versions at
+// once unless the policy is overridden with Force.
+func TestMaxJump(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working", WithMaxJump(2))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		expectError(t, "Instance.Latest", "WithMaxJump", instance.Latest, "max-jump", "would apply 3 versions",
+			"maximum of 2")
+		if instance.Version() != 0 {
+			t.Errorf("Instance.Version: got %d expected 0 after refused run", instance.Version())
+		}
+
+		if err := instance.Goto(2); err != nil {
+			t.Fatal("Instance.Goto: got error within maximum jump:\n", err)
+		}
+		if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error within maximum jump:\n", err)
+		}
+
+		if err := instance.Force(3, OverrideMaxJump); err != nil {
+			t.Fatal("Instance.Force: got error:\n", err)
+		}
+		if instance.Version() != 3 {
+			t.Errorf("Instance.Version: got %d expected 3 after Force", instance.Version())
+		}
+	})
+}
@@ -0,0 +1,138 @@
+//go:build postgres
+// +build postgres
+
+package migrate
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// TestPostgres ensures that runs are recorded in a PostgreSQL database, both
+// in the default schema and within the schema provided with WithSchema, with
+// DDL bounded by WithLockTimeout. MIGRATE_TEST_POSTGRES must name an empty
+// database, such as "postgres://postgres@localhost/migrate?sslmode=disable",
+// and the test is run with `go test -tags postgres -run TestPostgres .`.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("MIGRATE_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("set MIGRATE_TEST_POSTGRES to run against PostgreSQL")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE SCHEMA tenant_a;`); err != nil {
+		t.Fatal("sql.DB.Exec: got error:\n", err)
+	}
+
+	instance, err := NewInstance(db, "testing/meta", WithLockTimeout(time.Second, 1, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal("NewInstance: got error:\n", err)
+	}
+	instance.Output = &strings.Builder{}
+	if instance.dialect != Postgres {
+		t.Fatalf("NewInstance: got '%s' dialect expected 'postgres'", instance.dialect.Name())
+	}
+
+	tenant, err := NewInstance(db, "testing/meta", WithSchema("tenant_a"))
+	if err != nil {
+		t.Fatal("NewInstance: got error:\n", err)
+	}
+	tenant.Output = &strings.Builder{}
+
+	if err := instance.Latest(); err != nil {
+		t.Fatal("Instance.Latest: got error:\n", err)
+	} else if err := tenant.Goto(1); err != nil {
+		t.Fatal("Instance.Goto: got error within schema:\n", err)
+	}
+
+	if version := instance.Version(); version != 2 {
+		t.Errorf("Instance.Version: got %d expected 2", version)
+	} else if version := tenant.Version(); version != 1 {
+		t.Errorf("Instance.Version: got %d expected 1 within schema", version)
+	}
+
+	if history, err := instance.History(); err != nil || len(history) != 2 {
+		t.Errorf("Instance.History: got %d entries and error '%v' expected 2", len(history), err)
+	} else if history, err := tenant.History(); err != nil || len(history) != 1 {
+		t.Errorf("Instance.History: got %d entries and error '%v' expected 1 within schema", len(history), err)
+	}
+
+	var billing sql.NullString
+	if err := db.QueryRow(`SELECT to_regclass('tenant_a.billing')::text;`).Scan(&billing); err != nil {
+		t.Fatal("sql.DB.QueryRow: got error:\n", err)
+	} else if !billing.Valid {
+		t.Error("Instance.Goto: expected billing to be created within tenant_a")
+	}
+
+	if err := tenant.Goto(0); err != nil {
+		t.Fatal("Instance.Goto: got error within schema:\n", err)
+	} else if err := instance.Goto(0); err != nil {
+		t.Fatal("Instance.Goto: got error:\n", err)
+	} else if instance.Version() != 0 || tenant.Version() != 0 || instance.Dirty() || tenant.Dirty() {
+		t.Error("Instance.Goto: expected both schemas to be clean at version 0")
+	}
+}
+
+// TestPostgresLockTimeout ensures that DDL waiting behind a lock held by
+// another transaction is cancelled once the timeout provided with
+// WithLockTimeout elapses, and applied once the lock is released.
+func TestPostgresLockTimeout(t *testing.T) {
+	dsn := os.Getenv("MIGRATE_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("set MIGRATE_TEST_POSTGRES to run against PostgreSQL")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE SCHEMA locked;`); err != nil {
+		t.Fatal("sql.DB.Exec: got error:\n", err)
+	}
+
+	instance, err := NewInstance(db, "testing/working", WithSchema("locked"),
+		WithLockTimeout(50*time.Millisecond, 1, 10*time.Millisecond))
+	if err != nil {
+		t.Fatal("NewInstance: got error:\n", err)
+	}
+	output := &strings.Builder{}
+	instance.Output = output
+
+	if err := instance.Goto(1); err != nil {
+		t.Fatal("Instance.Goto: got error:\n", err)
+	}
+
+	holder, err := db.Begin()
+	if err != nil {
+		t.Fatal("sql.DB.Begin: got error:\n", err)
+	} else if _, err := holder.Exec(`LOCK TABLE locked.test IN ACCESS EXCLUSIVE MODE;`); err != nil {
+		holder.Rollback()
+		t.Fatal("sql.Tx.Exec: got error:\n", err)
+	}
+
+	expectError(t, "Instance.Goto", "table locked by another transaction", func() error { return instance.Goto(2) },
+		"lock timeout")
+	if retries := strings.Count(output.String(), "retrying"); retries != 1 {
+		t.Errorf("Instance.Goto: got %d retries expected 1", retries)
+	}
+
+	if err := holder.Rollback(); err != nil {
+		t.Fatal("sql.Tx.Rollback: got error:\n", err)
+	} else if err := instance.Goto(2); err != nil {
+		t.Fatal("Instance.Goto: got error once lock was released:\n", err)
+	} else if version := instance.Version(); version != 2 {
+		t.Errorf("Instance.Version: got %d expected 2", version)
+	}
+}
@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// provenance identifies the binary and host applying migrations, as recorded
+// with each entry of the history.
+type provenance struct {
+	build    string // Path and version of the main module of the binary, such as `example.com/app@v1.4.0`
+	revision string // Revision of version control from which the binary was built, if recorded
+	host     string // Name of the host on which the binary runs
+}
+
+var (
+	currentProvenance provenance
+	provenanceOnce    sync.Once
+)
+
+// readProvenance returns the provenance of the running binary, read from its
+// build information and the hostname once and remembered thereafter. Any
+// information which is unavailable is left empty.
+func readProvenance() provenance {
+	provenanceOnce.Do(func() {
+		if hostname, err := os.Hostname(); err == nil {
+			currentProvenance.host = hostname
+		}
+
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+
+		if info.Main.Path != "" {
+			currentProvenance.build = info.Main.Path + "@" + info.Main.Version
+		}
+
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				currentProvenance.revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+
+		// A binary built from uncommitted changes is not the revision alone
+		if modified && currentProvenance.revision != "" {
+			currentProvenance.revision += "+dirty"
+		}
+	})
+
+	return currentProvenance
+}
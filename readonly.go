@@ -0,0 +1,20 @@
+package migrate
+
+import "database/sql"
+
+// uninitialized reports whether the Instance is read-only and the database
+// has never been migrated, such that the metadata table does not exist.
+func (instance *Instance) uninitialized() bool {
+	return instance.readOnly && !tableExists(instance.db, instance.metadataTable())
+}
+
+// tableExists reports whether the table named exists within the database.
+func tableExists(db *sql.DB, table string) bool {
+	rows, err := db.Query(`SELECT 1 FROM ` + table + ` WHERE 1 = 0;`)
+	if err != nil {
+		return false
+	}
+
+	rows.Close()
+	return true
+}
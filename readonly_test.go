@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestReadOnly ensures that an Instance created with WithReadOnly reports the
+// state of the database through a read-only connection without modifying it.
+func TestReadOnly(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if err := db.Ping(); err != nil {
+			t.Fatal("DB.Ping: got error:\n", err)
+		}
+
+		readOnly, err := sql.Open("sqlite3", "file:"+TestDBPath+"?mode=ro")
+		if err != nil {
+			t.Fatal("sql.Open: got error:\n", err)
+		}
+		defer readOnly.Close()
+
+		instance, err := NewInstance(readOnly, "testing/meta", WithReadOnly())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if version, dirty := instance.Version(), instance.Dirty(); version != 0 || dirty {
+			t.Errorf("Instance: got version %d and dirty %t expected 0 and false", version, dirty)
+		}
+
+		if err := instance.EnsureUpToDate(); err == nil {
+			t.Error("Instance.EnsureUpToDate: expected pending migrations")
+		}
+
+		if history, err := instance.History(); err != nil || len(history) != 0 {
+			t.Errorf("Instance.History: got '%v' and error '%v' expected no entries", history, err)
+		}
+
+		expectError(t, "Instance.Latest", "a read-only instance", instance.Latest, "instance is read-only")
+
+		var tables int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master;`).Scan(&tables); err != nil {
+			t.Fatal("DB.QueryRow: got error:\n", err)
+		} else if tables != 0 {
+			t.Errorf("NewInstance: got %d tables expected a read-only instance to create none", tables)
+		}
+
+		writable, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		writable.Output = &strings.Builder{}
+		if err := writable.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		if version := instance.Version(); version != 2 {
+			t.Errorf("Instance.Version: got %d expected 2", version)
+		}
+		if history, err := instance.History(); err != nil || len(history) != 2 {
+			t.Errorf("Instance.History: got '%v' and error '%v' expected 2 entries", history, err)
+		}
+
+		if err := instance.Close(); err != nil {
+			t.Error("Instance.Close: got error:\n", err)
+		}
+	})
+}
+
+// TestInspect ensures that Inspect validates a tree without a database.
+func TestInspect(t *testing.T) {
+	if err := Inspect("testing/meta"); err != nil {
+		t.Error("Inspect: got error:\n", err)
+	}
+
+	if _, ok := Inspect("testing/gap").(*ErrGap); !ok {
+		t.Error("Inspect: expected error of type *ErrGap")
+	}
+}
@@ -0,0 +1,205 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Rehearse executes every statement which Goto would execute to bring the
+// database to the target version within a transaction which is always rolled
+// back, reporting which parts would fail without changing anything. Each part
+// is executed within a savepoint, so a failing part is undone and the
+// rehearsal carries on with the next, which catches syntax errors, missing
+// tables and columns, and the like across every version at once. Guard queries
+// are evaluated, and deferred parts are skipped, exactly as with Goto.
+//
+// The RunReport returned lists the parts which would be applied, skipped, or
+// fail, and is also returned within an *ErrApply if any part would fail. As
+// the rehearsal relies upon rolling back DDL, an error is returned if the
+// Dialect commits DDL implicitly and any statement rehearsed is DDL. Note
+// that the statements hold their usual locks until the rehearsal is rolled
+// back, so a rehearsal against a busy database should be brief.
+func (instance *Instance) Rehearse(target int) (report *RunReport, err error) {
+	if instance.closed {
+		return nil, NewFatalf("Instance.Rehearse: instance has been closed")
+	} else if instance.readOnly {
+		return nil, NewFatalf("Instance.Rehearse: instance is read-only")
+	} else if instance.Dirty() {
+		return nil, NewFatalf("Instance.Rehearse: database is dirty, call Resume before rehearsing")
+	}
+
+	current := instance.Version()
+	if latest := instance.latest(); current > latest {
+		return nil, &ErrFutureSchema{Version: current, Latest: latest}
+	} else if target == current {
+		return nil, &ErrNoMigrations{target}
+	}
+
+	todo, direction, err := instance.plan(current, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if direction == "down" {
+		for _, migration := range todo {
+			for _, part := range migration.Parts {
+				if part.Irreversible {
+					return nil, &ErrIrreversible{Version: migration.Version, Part: part.Name}
+				}
+			}
+		}
+	}
+
+	if !supportsTransactionalDDL(instance.dialect) && countDDL(todo, direction) > 0 {
+		return nil, NewFatalf("Instance.Rehearse: %s commits DDL implicitly, so it cannot be rehearsed",
+			instance.dialect.Name())
+	}
+
+	queued := make(map[int]map[string]bool)
+	states := make(map[int]map[string]PartState)
+	if direction == "down" {
+		deferred, err := instance.Deferred()
+		if err != nil {
+			return nil, err
+		} else if states, err = instance.partStates(); err != nil {
+			return nil, NewFatalf("Instance.Rehearse: got error while reading part states:\n%s", err)
+		}
+
+		for _, part := range deferred {
+			if queued[part.Version] == nil {
+				queued[part.Version] = make(map[string]bool)
+			}
+			queued[part.Version][part.Part] = true
+		}
+	}
+
+	if err := instance.lock(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if unlockErr := instance.unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	transaction, err := instance.begin()
+	if err != nil {
+		return nil, NewFatalf("Instance.Rehearse: got error while starting a transaction:\n%s", err)
+	}
+
+	report = &RunReport{From: current, Target: target, Direction: direction, Outcome: RolledBack, Version: current,
+		Actor: instance.actor, Reason: instance.reason}
+	defer instance.beginRun(report)()
+	start := instance.clock.Now()
+	for _, migration := range todo {
+		ctx := context.Background()
+		if instance.versionTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, instance.versionTimeout)
+			defer cancel()
+		}
+
+		for _, part := range migration.Parts {
+			if reason := instance.rehearsalSkip(direction, migration.Version, part, queued, states); reason != "" {
+				report.addSkipped(migration.Version, part, "", reason)
+				continue
+			}
+
+			if err := instance.rehearsePart(ctx, transaction, report, migration.Version, part); err != nil {
+				transaction.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	report.Duration = instance.since(start)
+	if err := transaction.Rollback(); err != nil {
+		report.Outcome = Unknown
+		report.RollbackErr = err
+		return report, NewFatalf("Instance.Rehearse: got error while rolling back transaction:\n%s", err)
+	}
+
+	if len(report.Failed) > 0 {
+		report.Err = &ErrApply{report}
+		return report, report.Err
+	}
+
+	return report, nil
+}
+
+// rehearsalSkip returns the reason for which a rehearsal in direction skips a
+// part of the version specified without executing it, as a run would, given
+// the parts queued for RunDeferred and the state of every part, or an empty
+// string if the part is executed.
+func (instance *Instance) rehearsalSkip(direction string, version int, part *Part, queued map[int]map[string]bool,
+	states map[int]map[string]PartState) string {
+	if direction == "down" {
+		if queued[version][part.Name] {
+			return "never applied"
+		} else if states[version][part.Name] == PartSkipped {
+			return "skipped when applied"
+		}
+		return ""
+	}
+
+	if excluded := instance.excludedBy(part); excluded != "" {
+		return excluded + ", deferred until RunDeferred"
+	} else if part.Deferred {
+		return "deferred until RunDeferred"
+	}
+
+	return ""
+}
+
+// rehearsePart executes a single part of a rehearsal within a savepoint,
+// rolling back to the savepoint if the part fails and recording the result in
+// report. Any error while managing the savepoint is returned as an *ErrFatal.
+func (instance *Instance) rehearsePart(ctx context.Context, transaction *sql.Tx, report *RunReport, version int,
+	part *Part) error {
+	if _, err := transaction.Exec(`SAVEPOINT migrate_rehearsal;`); err != nil {
+		return NewFatalf("Instance.Rehearse: got error while creating savepoint for '%s':\n%s", part.Name, err)
+	}
+
+	sql := part.statementText(report.Direction)
+
+	var rows []int64
+	var err error
+	if report.Direction == "up" && part.SkipIf != "" {
+		var skip bool
+		if skip, err = evaluateGuard(ctx, transaction, part.SkipIf); err == nil && skip {
+			report.addSkipped(version, part, part.SkipIf, "guard query returned true")
+			if _, err := transaction.Exec(`RELEASE SAVEPOINT migrate_rehearsal;`); err != nil {
+				return NewFatalf("Instance.Rehearse: got error while releasing savepoint for '%s':\n%s", part.Name,
+					err)
+			}
+			return nil
+		} else if err != nil {
+			sql = part.SkipIf
+		}
+	}
+
+	if err == nil {
+		rows, err = instance.applyPart(ctx, transaction, true, version, part, report.Direction)
+	}
+	if _, fatal := err.(*ErrFatal); fatal {
+		return err
+	}
+
+	if err != nil && part.Optional {
+		report.addOptional(version, part, sql, rows, err)
+	} else {
+		report.add(version, part, sql, rows, err)
+	}
+
+	if err != nil {
+		if _, err := transaction.Exec(`ROLLBACK TO SAVEPOINT migrate_rehearsal;`); err != nil {
+			return NewFatalf("Instance.Rehearse: got error while rolling back to savepoint for '%s':\n%s",
+				part.Name, err)
+		}
+	} else if _, err := transaction.Exec(`RELEASE SAVEPOINT migrate_rehearsal;`); err != nil {
+		return NewFatalf("Instance.Rehearse: got error while releasing savepoint for '%s':\n%s", part.Name, err)
+	}
+
+	return nil
+}
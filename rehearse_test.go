@@ -0,0 +1,60 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestRehearse ensures that Rehearse reports every part which would fail
+// without changing the database.
+func TestRehearse(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/rehearse")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		report, err := instance.Rehearse(2)
+		if _, ok := err.(*ErrApply); !ok {
+			t.Fatalf("Instance.Rehearse: got '%v' expected *ErrApply", err)
+		}
+		if len(report.Applied) != 3 || report.Applied[2].Part != "3_posts.sql" {
+			t.Errorf("Instance.Rehearse: got applied parts '%v' expected every part but '2_index.sql'", report.Applied)
+		}
+		if len(report.Failed) != 1 || report.Failed[0].Part != "2_index.sql" {
+			t.Fatalf("Instance.Rehearse: got failed parts '%v' expected '2_index.sql'", report.Failed)
+		}
+		if statementErr, ok := report.Failed[0].Err.(*ErrStatement); !ok || statementErr.Index != 1 {
+			t.Errorf("Instance.Rehearse: got error '%v' expected the second statement to fail", report.Failed[0].Err)
+		}
+		if report.Outcome != RolledBack {
+			t.Errorf("Instance.Rehearse: got outcome '%s' expected 'rolled back'", report.Outcome)
+		}
+
+		if tableExists(db, "users") {
+			t.Error("Instance.Rehearse: expected table 'users' to be rolled back")
+		}
+		if version := instance.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d expected 0 after rehearsal", version)
+		}
+		if instance.Report() != nil {
+			t.Error("Instance.Report: expected rehearsal not to be reported as a run")
+		}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if report, err := instance.Rehearse(0); err != nil || len(report.Applied) != 1 {
+			t.Errorf("Instance.Rehearse: got report '%v' and error '%v' expected version 1 to revert", report, err)
+		}
+		if !tableExists(db, "users") {
+			t.Error("Instance.Rehearse: expected table 'users' to remain")
+		}
+		expectError(t, "Instance.Rehearse", "current version", func() error {
+			_, err := instance.Rehearse(1)
+			return err
+		}, "no migrations")
+	})
+}
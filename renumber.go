@@ -0,0 +1,352 @@
+package migrate
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReadMapping reads a renumbering mapping from the file at path. Each line of
+// the file holds an old version number followed by the new version number it
+// should become, separated by whitespace. Blank lines and lines beginning with
+// `#` are ignored.
+func ReadMapping(path string) (map[int]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mapping := make(map[int]int)
+	scanner := bufio.NewScanner(file)
+	number := 0
+	for scanner.Scan() {
+		number++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, NewFatalf("ReadMapping: expected line %d of '%s' to hold an old and a new version, got "+
+				"'%s'", number, path, line)
+		}
+
+		from, fromErr := strconv.Atoi(fields[0])
+		to, toErr := strconv.Atoi(fields[1])
+		if fromErr != nil || toErr != nil {
+			return nil, NewFatalf("ReadMapping: got invalid version number on line %d of '%s': '%s'", number,
+				path, line)
+		}
+
+		if _, ok := mapping[from]; ok {
+			return nil, NewFatalf("ReadMapping: version %d is mapped more than once in '%s'", from, path)
+		}
+		mapping[from] = to
+	}
+
+	return mapping, scanner.Err()
+}
+
+// CloseGaps returns a mapping which renumbers the migration directories
+// within root so that their versions begin at 1 with no gaps, preserving
+// their order. Versions which need not change are omitted.
+func CloseGaps(root string) (map[int]int, error) {
+	directories, err := versionDirectories(root)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0, len(directories))
+	for version := range directories {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	mapping := make(map[int]int)
+	for index, version := range versions {
+		if version != index+1 {
+			mapping[version] = index + 1
+		}
+	}
+
+	return mapping, nil
+}
+
+// Renumber renames the migration directories within root according to the
+// mapping provided, from old version to new version, and rewrites the
+// versions recorded in the database so that applied migrations remain
+// applied. Any LockFile is updated to match, while any SignatureFile is
+// invalidated and must be regenerated with Instance.Sign. The database must
+// not be dirty, and the migration directories must not be claimed by more
+// than one version once renumbered. As the version of the database marks
+// every version at or below it as applied, the mapping must keep every
+// version which has not been applied above every version which has. The
+// options with which the migrations are applied, such as WithSchema,
+// WithScope, and WithVersionKey, must be provided so that the tables and
+// metadata entries of the Instance are rewritten. The migration lock is held
+// while the database is rewritten.
+func Renumber(db *sql.DB, root string, mapping map[int]int, options ...Option) (err error) {
+	instance := &Instance{db: db, root: filepath.Clean(root), dialect: detectDialect(db), holder: newHolder(),
+		clock: systemClock{}, Output: ioutil.Discard}
+	for _, option := range options {
+		option(instance)
+	}
+
+	if schema := instance.schema; schema != "" && !regexSchema.MatchString(schema) {
+		return NewFatalf("Renumber: invalid schema name '%s'", schema)
+	} else if err := instance.enterScope(); err != nil {
+		return NewFatalf("Renumber: %s", err)
+	}
+	root = instance.root
+
+	directories, err := versionDirectories(root)
+	if err != nil {
+		return err
+	}
+
+	// Determine the version which will be held by every directory once renumbered
+	versions := make([]int, 0, len(directories))
+	for version := range directories {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	final := make(map[int]int)
+	for _, version := range versions {
+		if names := directories[version]; len(names) > 1 {
+			return NewFatalf("Renumber: found %d directories claiming version %d, rename all but one of them "+
+				"by hand first", len(names), version)
+		}
+
+		target := version
+		if to, ok := mapping[version]; ok {
+			target = to
+		}
+
+		if target <= 0 {
+			return NewFatalf("Renumber: cannot renumber version %d to %d, versions must be greater than 0",
+				version, target)
+		} else if other, ok := final[target]; ok {
+			return NewFatalf("Renumber: versions %d and %d would both become version %d", other, version,
+				target)
+		}
+		final[target] = version
+	}
+
+	for version := range mapping {
+		if _, ok := directories[version]; !ok {
+			return NewFatalf("Renumber: no migration directory found for version %d", version)
+		}
+	}
+
+	if instance.formats, err = parseFormats(instance.outputFormats, !instance.noColor); err != nil {
+		return NewFatalf("Renumber: %s", err)
+	}
+
+	meta := instance.newMetaStore()
+	if err := instance.createMetadata(db); err != nil {
+		return NewFatalf("Renumber: got error while creating metadata table:\n%s", err)
+	} else if err := createLock(db, instance.table("migrate_lock")); err != nil {
+		return NewFatalf("Renumber: got error while creating lock table:\n%s", err)
+	}
+
+	if err := instance.lock(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if unlockErr := instance.unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	if meta.Exists(instance.metaKey("migrateTarget")) {
+		return NewFatalf("Renumber: database is dirty, call Resume before renumbering")
+	}
+
+	current, key, err := instance.readVersion(db)
+	if err != nil {
+		return err
+	}
+
+	renumbered, err := renumberVersion(versions, mapping, current)
+	if err != nil {
+		return err
+	}
+
+	if err := createJournal(db, instance.table("migrate_journal")); err != nil {
+		return NewFatalf("Renumber: got error while creating journal table:\n%s", err)
+	} else if err := createHistory(db, instance.table("migrate_history")); err != nil {
+		return NewFatalf("Renumber: got error while creating history table:\n%s", err)
+	} else if err := createPending(db, instance.table("migrate_pending")); err != nil {
+		return NewFatalf("Renumber: got error while creating pending table:\n%s", err)
+	} else if err := createPartStates(db, instance.table("migrate_parts")); err != nil {
+		return NewFatalf("Renumber: got error while creating part state table:\n%s", err)
+	}
+
+	// Rewrite recorded versions in a single transaction, negating them first so
+	// that versions may be swapped without colliding
+	transaction, err := db.Begin()
+	if err != nil {
+		return NewFatalf("Renumber: got error while starting a transaction:\n%s", err)
+	}
+
+	for _, name := range []string{"migrate_history", "migrate_journal", "migrate_pending", "migrate_parts"} {
+		table := instance.table(name)
+		update := instance.rebind(`UPDATE ` + table + ` SET Version = ? WHERE Version = ?;`)
+		for from := range mapping {
+			if _, err := transaction.Exec(update, -from, from); err != nil {
+				transaction.Rollback()
+				return NewFatalf("Renumber: got error while rewriting %s:\n%s", table, err)
+			}
+		}
+
+		for from, to := range mapping {
+			if _, err := transaction.Exec(update, to, -from); err != nil {
+				transaction.Rollback()
+				return NewFatalf("Renumber: got error while rewriting %s:\n%s", table, err)
+			}
+		}
+	}
+
+	if key != "" && renumbered != current {
+		if _, err := transaction.Exec(instance.rebind(`UPDATE `+instance.metadataTable()+` SET Value = ? `+
+			`WHERE Name = ? AND Value = ?;`), renumbered, key, current); err != nil {
+			transaction.Rollback()
+			return NewFatalf("Renumber: got error while updating '%s':\n%s", key, err)
+		}
+	}
+
+	// Rename directories through temporary names for the same reason
+	renamed := make([][2]string, 0)
+	undo := func() {
+		for i := len(renamed) - 1; i >= 0; i-- {
+			os.Rename(renamed[i][1], renamed[i][0])
+		}
+	}
+	rename := func(from, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		renamed = append(renamed, [2]string{from, to})
+		return nil
+	}
+
+	for from := range mapping {
+		path := filepath.Join(root, directories[from][0])
+		if err := rename(path, path+".renumber"); err != nil {
+			undo()
+			transaction.Rollback()
+			return NewFatalf("Renumber: got error while renaming '%s':\n%s", path, err)
+		}
+	}
+
+	for from, to := range mapping {
+		path := filepath.Join(root, fmt.Sprintf("version_%d", to))
+		if err := rename(filepath.Join(root, directories[from][0])+".renumber", path); err != nil {
+			undo()
+			transaction.Rollback()
+			return NewFatalf("Renumber: got error while renaming version %d to '%s':\n%s", from, path, err)
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		undo()
+		return NewFatalf("Renumber: got error while committing transaction:\n%s", err)
+	}
+
+	return renumberLockFile(root, directories, mapping)
+}
+
+// renumberVersion returns the version of the database once the versions
+// provided are renumbered according to mapping: the highest version to which
+// any version at or below current is renumbered. An error is returned if a
+// version above current, which has not been applied, would be renumbered at
+// or below the returned version, as it would then be recorded as applied.
+func renumberVersion(versions []int, mapping map[int]int, current int) (int, error) {
+	renumber := func(version int) int {
+		if to, ok := mapping[version]; ok {
+			return to
+		}
+		return version
+	}
+
+	renumbered := 0
+	for _, version := range versions {
+		if version <= current && renumber(version) > renumbered {
+			renumbered = renumber(version)
+		}
+	}
+
+	for _, version := range versions {
+		if version > current && renumber(version) <= renumbered {
+			return 0, NewFatalf("Renumber: version %d has not been applied, so cannot become version %d, at or "+
+				"below version %d of the database once renumbered", version, renumber(version), renumbered)
+		}
+	}
+
+	return renumbered, nil
+}
+
+// renumberLockFile rewrites the paths recorded in the LockFile within root,
+// if it exists, to match the renumbered migration directories.
+func renumberLockFile(root string, directories map[int][]string, mapping map[int]int) error {
+	path := filepath.Join(root, LockFile)
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return NewFatalf("Renumber: got error while reading lock file:\n%s", err)
+	}
+
+	names := make(map[string]string)
+	for from, to := range mapping {
+		names[directories[from][0]] = fmt.Sprintf("version_%d", to)
+	}
+
+	lines := strings.Split(string(contents), "\n")
+	for i, line := range lines {
+		if slash := strings.IndexByte(line, '/'); slash > 0 {
+			if name, ok := names[line[:slash]]; ok {
+				lines[i] = name + line[slash:]
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return NewFatalf("Renumber: got error while writing lock file:\n%s", err)
+	}
+
+	return nil
+}
+
+// versionDirectories returns the names of the migration directories within
+// root keyed by the version which each claims.
+func versionDirectories(root string) (map[int][]string, error) {
+	entries, err := readDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	directories := make(map[int][]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, "version_") {
+			continue
+		}
+
+		if version, err := strconv.Atoi(name[8:]); err == nil {
+			directories[version] = append(directories[version], name)
+		}
+	}
+
+	return directories, nil
+}
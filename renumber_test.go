@@ -0,0 +1,163 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRenumber ensures that Renumber closes gaps between migration versions
+// while keeping applied migrations recorded as applied, including those of a
+// scope recorded in tables and metadata entries of its own.
+func TestRenumber(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	if err := os.Rename(filepath.Join(root, "version_3"), filepath.Join(root, "version_5")); err != nil {
+		t.Fatal("os.Rename: got error:\n", err)
+	}
+
+	mapping, err := CloseGaps(root)
+	if err != nil {
+		t.Fatal("CloseGaps: got error:\n", err)
+	}
+	if len(mapping) != 1 || mapping[5] != 3 {
+		t.Fatalf("CloseGaps: got '%v' expected version 5 to become 3", mapping)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		// Record versions as applied before the gap was introduced, as after a merge
+		if _, err := db.Exec(`CREATE TABLE migrate_history(Version INT NOT NULL, Part VARCHAR(255) NOT NULL, ` +
+			`Direction VARCHAR(4) NOT NULL, Meta TEXT NOT NULL, AppliedAt BIGINT NOT NULL);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+		if _, err := db.Exec(`INSERT INTO migrate_history VALUES (5, 'test.sql', 'up', '{}', 0);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		expectError(t, "Renumber", "colliding versions", func() error {
+			return Renumber(db, root, map[int]int{5: 2})
+		}, "versions 2 and 5 would both become version 2")
+
+		if err := Renumber(db, root, mapping); err != nil {
+			t.Fatal("Renumber: got error:\n", err)
+		}
+
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error after renumbering:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		}
+		if len(history) != 1 || history[0].Version != 3 {
+			t.Errorf("Instance.History: got '%v' expected version 5 to be rewritten to 3", history)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.WriteLockFile(); err != nil {
+			t.Fatal("Instance.WriteLockFile: got error:\n", err)
+		}
+
+		// Nothing is renumbered while another process holds the migration lock
+		if _, err := db.Exec(`INSERT INTO migrate_lock (ID, Holder, Heartbeat) VALUES (1, 'other', 0);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+		if _, ok := Renumber(db, root, map[int]int{2: 3, 3: 2}).(*ErrLocked); !ok {
+			t.Error("Renumber: expected error of type *ErrLocked while the migration lock is held")
+		}
+		if _, err := db.Exec(`DELETE FROM migrate_lock;`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		// Swap two applied versions, ensuring that both remain applied and the lock file follows
+		if err := Renumber(db, root, map[int]int{2: 3, 3: 2}); err != nil {
+			t.Fatal("Renumber: got error:\n", err)
+		}
+		if instance.Version() != 3 {
+			t.Errorf("Instance.Version: got %d expected 3 after renumbering", instance.Version())
+		}
+		if _, err := NewInstance(db, root, WithStrictLockFile()); err != nil {
+			t.Error("NewInstance: got error with renumbered lock file:\n", err)
+		}
+
+		// Swap the versions of a scope, leaving those of the rest of the tree alone
+		for version, table := range map[int]string{1: "reports", 2: "summaries"} {
+			directory := filepath.Join(root, "reporting", fmt.Sprintf("version_%d", version))
+			if err := os.MkdirAll(directory, 0755); err != nil {
+				t.Fatal("os.MkdirAll: got error:\n", err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(directory, table+".sql"), []byte("-- @migrate/up\n"+
+				"CREATE TABLE "+table+"(ID INT);\n-- @migrate/down\nDROP TABLE "+table+";\n"), 0644); err != nil {
+				t.Fatal("ioutil.WriteFile: got error:\n", err)
+			}
+		}
+
+		scoped, err := NewInstance(db, root, WithScope("reporting"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		scoped.Output = &strings.Builder{}
+
+		if err := scoped.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		// Moving version 2, which has not been applied, below version 1 would mark it as applied
+		expectError(t, "Renumber", "unapplied version moved below applied version", func() error {
+			return Renumber(db, root, map[int]int{1: 2, 2: 1}, WithScope("reporting"))
+		}, "version 2 has not been applied, so cannot become version 1")
+
+		if err := scoped.Goto(2); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if err := Renumber(db, root, map[int]int{1: 2, 2: 1}, WithScope("reporting")); err != nil {
+			t.Fatal("Renumber: got error:\n", err)
+		}
+		if scoped.Version() != 2 || instance.Version() != 3 {
+			t.Errorf("Instance.Version: got %d for scope and %d for parent expected 2 and 3", scoped.Version(),
+				instance.Version())
+		}
+
+		history, err = scoped.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		} else if len(history) != 2 || history[0].Part != "reports.sql" || history[0].Version != 2 {
+			t.Errorf("Instance.History: got '%v' for scope expected version 1 to be rewritten to 2", history)
+		}
+		if history, err := instance.History(); err != nil || history[len(history)-1].Version != 2 {
+			t.Errorf("Instance.History: got '%v' for parent expected it to be left alone", history)
+		}
+	})
+}
+
+// TestReadMapping ensures that mapping files are parsed as expected.
+func TestReadMapping(t *testing.T) {
+	path := filepath.Join(CopyTree(t, "testing/working"), "mapping")
+	write := func(contents string) {
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+	}
+
+	write("# old new\n4 2\n\n7 3\n")
+	if mapping, err := ReadMapping(path); err != nil {
+		t.Error("ReadMapping: got error:\n", err)
+	} else if len(mapping) != 2 || mapping[4] != 2 || mapping[7] != 3 {
+		t.Errorf("ReadMapping: got '%v' expected 4 => 2 and 7 => 3", mapping)
+	}
+
+	write("4 2 1\n")
+	expectError(t, "ReadMapping", "too many fields", func() error { _, err := ReadMapping(path); return err },
+		"line 1")
+	write("4 2\n4 3\n")
+	expectError(t, "ReadMapping", "version mapped twice", func() error { _, err := ReadMapping(path); return err },
+		"mapped more than once")
+}
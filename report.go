@@ -0,0 +1,219 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// excerptLength is the maximum number of characters of SQL included in a
+// PartResult.
+const excerptLength = 120
+
+// Outcome describes the state in which a database was left by a failed run.
+type Outcome int
+
+const (
+	// Succeeded indicates that every part was applied and the database is at
+	// the target version.
+	Succeeded Outcome = iota
+	// RolledBack indicates that the transaction was rolled back, leaving the
+	// database exactly as it was before the run began.
+	RolledBack
+	// LeftDirty indicates that the run was not using a transaction, leaving
+	// the database part way through a version until Resume is called.
+	LeftDirty
+	// Unknown indicates that the transaction could not be rolled back, and
+	// the state of the database should be checked by hand.
+	Unknown
+)
+
+// String implements the fmt.Stringer interface for Outcome.
+func (outcome Outcome) String() string {
+	switch outcome {
+	case Succeeded:
+		return "succeeded"
+	case RolledBack:
+		return "rolled back"
+	case LeftDirty:
+		return "dirty"
+	default:
+		return "unknown"
+	}
+}
+
+// PartResult records the result of applying a single part during a run.
+type PartResult struct {
+	Version   int
+	Part      string
+	Direction string
+	SQL       string // Excerpt of the SQL applied, truncated if overly long
+	Err       error  // Error returned while applying the part, if any
+	Reason    string // Why the part was skipped, for the parts of Skipped
+
+	// RowsAffected holds the number of rows affected by each statement of
+	// the part executed successfully, in order, or -1 for a statement whose
+	// driver does not report it.
+	RowsAffected []int64
+}
+
+// Rows returns the total number of rows affected by the statements of the
+// part, ignoring any statement whose driver does not report it.
+func (result PartResult) Rows() int64 {
+	total := int64(0)
+	for _, rows := range result.RowsAffected {
+		if rows > 0 {
+			total += rows
+		}
+	}
+
+	return total
+}
+
+// RunReport describes every part applied or failed during a single run, as
+// well as the state the database was left in.
+type RunReport struct {
+	From      int
+	Target    int
+	Direction string
+	Applied   []PartResult
+	Failed    []PartResult
+	Optional  []PartResult // Optional parts which failed without aborting the run
+	// Skipped holds the parts which the run did not apply, along with the
+	// reason for each: those whose guard query returned a truthy value, those
+	// deferred or excluded by their tags, and, when migrating down, those
+	// which were never applied or were skipped by their guard query.
+	Skipped []PartResult
+
+	Outcome     Outcome
+	Version     int           // Version the database was left at
+	RollbackErr error         // Error returned while rolling back, if Outcome is Unknown
+	StartedAt   time.Time     // Time at which the run started
+	Duration    time.Duration // Time taken by the run
+	Err         error         // Error returned by the run, if any
+	Interrupted bool          // Whether the run failed because its context was cancelled
+
+	Actor  string // Person or pipeline which started the run, as provided with WithActor
+	Reason string // Reason for which the run was started, as provided with WithReason
+	RunID  string // ID of the run, as provided with WithRunID or generated
+}
+
+// result returns a PartResult for a part of the version specified, including
+// the SQL of the failing statement rather than the entire part if err is an
+// *ErrStatement.
+func (report *RunReport) result(version int, part *Part, sql string, rows []int64, err error) PartResult {
+	if statementErr, ok := err.(*ErrStatement); ok {
+		sql = statementErr.SQL
+	}
+
+	return PartResult{Version: version, Part: part.Name, Direction: report.Direction, SQL: excerpt(sql), Err: err,
+		RowsAffected: rows}
+}
+
+// add records the result of applying a part to the report, appending it to
+// Failed if err is not nil or Applied otherwise.
+func (report *RunReport) add(version int, part *Part, sql string, rows []int64, err error) {
+	if err != nil {
+		report.Failed = append(report.Failed, report.result(version, part, sql, rows, err))
+	} else {
+		report.Applied = append(report.Applied, report.result(version, part, sql, rows, nil))
+	}
+}
+
+// addOptional records the failure of an optional part to the report.
+func (report *RunReport) addOptional(version int, part *Part, sql string, rows []int64, err error) {
+	report.Optional = append(report.Optional, report.result(version, part, sql, rows, err))
+}
+
+// addSkipped records that a part was skipped for the reason provided, along
+// with the SQL which decided it, such as a guard query, if any.
+func (report *RunReport) addSkipped(version int, part *Part, sql, reason string) {
+	result := report.result(version, part, sql, nil, nil)
+	result.Reason = reason
+	report.Skipped = append(report.Skipped, result)
+}
+
+// String returns a short description of the state the database was left in.
+func (report *RunReport) String() string {
+	switch report.Outcome {
+	case Succeeded:
+		return fmt.Sprintf("database migrated to version %d", report.Version)
+	case RolledBack:
+		return fmt.Sprintf("database rolled back to version %d", report.Version)
+	case LeftDirty:
+		return fmt.Sprintf("database is dirty at version %d, call Resume once the issue is resolved",
+			report.Version)
+	default:
+		return fmt.Sprintf("database state unknown, got error while rolling back:\n%s", report.RollbackErr)
+	}
+}
+
+// ErrApply is returned by Goto, Latest, and Resume when one or more migration
+// parts fail to apply, holding a RunReport describing the run.
+type ErrApply struct {
+	Report *RunReport
+}
+
+// Error implements the error interface for ErrApply.
+func (err *ErrApply) Error() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "Instance.Goto: got error while applying migrations, %d part(s) failed:",
+		len(err.Report.Failed))
+
+	for _, failed := range err.Report.Failed {
+		fmt.Fprintf(&builder, "\n- version %d '%s': %s", failed.Version, failed.Part, failed.Err)
+	}
+
+	fmt.Fprintf(&builder, "\n%s", err.Report)
+	return builder.String()
+}
+
+// Errors returns the error with which each part failed, in the order in which
+// the parts were applied.
+func (err *ErrApply) Errors() []error {
+	errs := make([]error, 0, len(err.Report.Failed))
+	for _, failed := range err.Report.Failed {
+		errs = append(errs, failed.Err)
+	}
+
+	return errs
+}
+
+// Unwrap returns the error with which the first part failed, if any. The
+// errors of every part which failed are returned by Errors.
+func (err *ErrApply) Unwrap() error {
+	if errs := err.Errors(); len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// Is reports whether the error with which any part failed matches target, as
+// reported by errors.Is, such that the ErrorCode of an error raised within any
+// of them, such as CodeRowLimit, is reported.
+func (err *ErrApply) Is(target error) bool {
+	for _, failed := range err.Errors() {
+		if errors.Is(failed, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excerpt returns the SQL provided, truncated to excerptLength characters.
+// The SQL is truncated on a character boundary, so that a character encoded
+// as several bytes is never split.
+func excerpt(sql string) string {
+	count := 0
+	for index := range sql {
+		if count == excerptLength {
+			return sql[:index] + "..."
+		}
+		count++
+	}
+
+	return sql
+}
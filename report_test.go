@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestRunReport ensures that a failed run returns an ErrApply holding a
+// RunReport which lists the failed part and the state of the database.
+func TestRunReport(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/resume")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		err = instance.Latest()
+		applyErr, ok := err.(*ErrApply)
+		if !ok {
+			t.Fatalf("Instance.Latest: expected error of type *ErrApply, got:\n%s", err)
+		}
+
+		report := applyErr.Report
+		if report.Outcome != RolledBack || report.Version != 0 {
+			t.Errorf("RunReport: got outcome '%s' at version %d expected 'rolled back' at version 0",
+				report.Outcome, report.Version)
+		}
+		if len(report.Applied) != 2 {
+			t.Errorf("RunReport.Applied: got %d parts expected 2", len(report.Applied))
+		}
+		if len(report.Failed) != 1 {
+			t.Fatalf("RunReport.Failed: got %d parts expected 1", len(report.Failed))
+		}
+
+		failed := report.Failed[0]
+		if failed.Version != 2 || failed.Part != "b.sql" || failed.Err == nil {
+			t.Errorf("RunReport.Failed: got '%#v' expected failure of 'b.sql' in version 2", failed)
+		}
+		if !strings.Contains(failed.SQL, "FROM prerequisite") {
+			t.Errorf("RunReport.Failed: got SQL excerpt '%s' expected failed part SQL", failed.SQL)
+		}
+
+		for _, str := range []string{"1 part(s) failed", "version 2 'b.sql'", "rolled back to version 0"} {
+			if !strings.Contains(err.Error(), str) {
+				t.Errorf("ErrApply.Error: expected substring '%s', got:\n%s", str, err)
+			}
+		}
+	})
+
+	if long := excerpt(strings.Repeat("a", excerptLength+10)); len(long) != excerptLength+3 {
+		t.Errorf("excerpt: got length %d expected %d", len(long), excerptLength+3)
+	}
+	if long := excerpt(strings.Repeat("é", excerptLength+10)); !utf8.ValidString(long) ||
+		utf8.RuneCountInString(long) != excerptLength+3 {
+		t.Errorf("excerpt: got '%s' expected %d characters", long, excerptLength+3)
+	}
+}
+
+// TestOptionalParts ensures that the failure of an optional part is recorded
+// in the run report without aborting the run, and that any statements of the
+// optional part which did succeed are undone.
+func TestOptionalParts(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/optional")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if instance.Report() != nil {
+			t.Error("Instance.Report: expected nil report before any run")
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error with failing optional part:\n", err)
+		}
+
+		report := instance.Report()
+		if report.Outcome != Succeeded || report.Version != 1 {
+			t.Errorf("Instance.Report: got outcome '%s' at version %d expected 'succeeded' at version 1",
+				report.Outcome, report.Version)
+		}
+		if len(report.Applied) != 2 || len(report.Failed) != 0 {
+			t.Errorf("Instance.Report: got %d applied and %d failed parts expected 2 and 0", len(report.Applied),
+				len(report.Failed))
+		}
+		if len(report.Optional) != 1 || report.Optional[0].Part != "b.sql" {
+			t.Errorf("Instance.Report: got optional failures '%#v' expected 'b.sql'", report.Optional)
+		}
+
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'b';`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error:\n", err)
+		} else if count != 0 {
+			t.Error("Instance.Latest: expected statements of failed optional part to be rolled back")
+		}
+
+		if err := instance.Goto(0); err != nil {
+			t.Error("Instance.Goto: got error:\n", err)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package migrate
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Event is passed to every Reporter added to an Instance each time a Message
+// is emitted, alongside the text written to Output.
+type Event struct {
+	Message Message
+	Data    MessageData
+	Text    string // Message rendered with its format, exactly as written to Output
+	Time    time.Time
+	RunID   string // ID of the run in progress, if any
+}
+
+// Reporter receives every Event emitted by an Instance, such that the progress
+// of a run may be consumed by several readers at once, such as a person
+// watching a terminal and a deployment system reading JSON. Reporters are
+// added with Instance.AddReporter and are called in the order in which they
+// were added, after the text of the Message has been written to Output.
+type Reporter interface {
+	Report(event Event)
+}
+
+// ReporterFunc adapts an ordinary function to the Reporter interface.
+type ReporterFunc func(event Event)
+
+// Report implements the Reporter interface for ReporterFunc.
+func (fn ReporterFunc) Report(event Event) {
+	fn(event)
+}
+
+// TextReporter returns a Reporter which writes the text of every Event to w,
+// such as os.Stderr, exactly as it is written to Output.
+func TextReporter(w io.Writer) Reporter {
+	return ReporterFunc(func(event Event) {
+		io.WriteString(w, event.Text)
+	})
+}
+
+// JSONReporter returns a Reporter which writes every Event to w as a JSON
+// object on a line of its own, such as to a file read by a deployment system.
+// Each object holds the message, the time at which it was emitted, and those
+// fields of MessageData which are set, keyed in snake case.
+func JSONReporter(w io.Writer) Reporter {
+	encoder := json.NewEncoder(w)
+	return ReporterFunc(func(event Event) {
+		encoder.Encode(newJSONEvent(event))
+	})
+}
+
+// ChannelReporter returns a Reporter which sends every Event on ch. The run
+// waits for each Event to be received, so ch should be buffered or drained
+// promptly.
+func ChannelReporter(ch chan<- Event) Reporter {
+	return ReporterFunc(func(event Event) {
+		ch <- event
+	})
+}
+
+// AddReporter adds a Reporter to receive every Event emitted by the Instance,
+// in addition to any Reporters already added and the text written to Output.
+// Set Output to ioutil.Discard to rely upon Reporters alone.
+func (instance *Instance) AddReporter(reporter Reporter) {
+	instance.reporters = append(instance.reporters, reporter)
+}
+
+// jsonEvent is the form in which JSONReporter encodes an Event.
+type jsonEvent struct {
+	Message    Message     `json:"message"`
+	Time       time.Time   `json:"time"`
+	RunID      string      `json:"run_id,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	Part       string      `json:"part,omitempty"`
+	Direction  string      `json:"direction,omitempty"`
+	From       int         `json:"from,omitempty"`
+	To         int         `json:"to,omitempty"`
+	Jump       int         `json:"jump,omitempty"`
+	Applied    int         `json:"applied,omitempty"`
+	Failed     int         `json:"failed,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+	Err        string      `json:"error,omitempty"`
+	Timeout    string      `json:"timeout,omitempty"`
+	Duration   string      `json:"duration,omitempty"`
+	Elapsed    string      `json:"elapsed,omitempty"`
+	Holder     string      `json:"holder,omitempty"`
+	Dialect    string      `json:"dialect,omitempty"`
+	Statements int         `json:"statements,omitempty"`
+	Verb       string      `json:"verb,omitempty"`
+	Rows       *int64      `json:"rows,omitempty"`
+	Diagnostic *Diagnostic `json:"diagnostic,omitempty"`
+}
+
+// newJSONEvent returns the jsonEvent for an Event, omitting any unset fields.
+func newJSONEvent(event Event) jsonEvent {
+	data := event.Data
+	encoded := jsonEvent{Message: event.Message, Time: event.Time, RunID: event.RunID, Version: data.Version,
+		Part: data.Part, Direction: data.Direction, From: data.From, To: data.To, Jump: data.Jump,
+		Applied: data.Applied, Failed: data.Failed, Reason: data.Reason, Holder: data.Holder, Dialect: data.Dialect,
+		Statements: data.Statements, Verb: data.Verb}
+
+	if data.Err != nil {
+		encoded.Err = data.Err.Error()
+	}
+	for _, duration := range []struct {
+		value time.Duration
+		field *string
+	}{{data.Timeout, &encoded.Timeout}, {data.Duration, &encoded.Duration}, {data.Elapsed, &encoded.Elapsed}} {
+		if duration.value != 0 {
+			*duration.field = duration.value.String()
+		}
+	}
+	if event.Message == MessageStatement {
+		encoded.Rows = &data.Rows
+	}
+	if data.Diagnostic != (Diagnostic{}) {
+		encoded.Diagnostic = &data.Diagnostic
+	}
+
+	return encoded
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReporters ensures that every Reporter added receives each message
+// alongside Output.
+func TestReporters(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		start := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		instance, err := NewInstance(db, "testing/meta", WithClock(fixedClock(start)))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		var text, encoded strings.Builder
+		events := make(chan Event, 10)
+		instance.AddReporter(TextReporter(&text))
+		instance.AddReporter(JSONReporter(&encoded))
+		instance.AddReporter(ChannelReporter(events))
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		close(events)
+
+		if text.String() != output.String() {
+			t.Errorf("TextReporter: got text:\n%s\nexpected text written to Output:\n%s", text.String(),
+				output.String())
+		}
+
+		messages := make([]Message, 0)
+		for event := range events {
+			messages = append(messages, event.Message)
+		}
+		if len(messages) != 4 || messages[1] != MessageApplied || messages[3] != MessageFinished {
+			t.Errorf("ChannelReporter: got messages '%v' expected beginning, applied, version-applied, finished",
+				messages)
+		}
+
+		lines := strings.Split(strings.TrimSpace(encoded.String()), "\n")
+		var applied map[string]interface{}
+		if len(lines) != 4 {
+			t.Fatalf("JSONReporter: got:\n%s\nexpected a line for each message", encoded.String())
+		} else if err := json.Unmarshal([]byte(lines[1]), &applied); err != nil {
+			t.Fatal("json.Unmarshal: got error:\n", err)
+		}
+		if applied["message"] != "applied" || applied["part"] != "billing.sql" || applied["version"] != float64(1) ||
+			applied["time"] != "2020-01-01T00:00:00Z" {
+			t.Errorf("JSONReporter: got '%v' for applied message", applied)
+		}
+		if _, ok := applied["direction"]; ok {
+			t.Errorf("JSONReporter: got '%v' expected unset fields to be omitted", applied)
+		}
+	})
+}
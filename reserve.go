@@ -0,0 +1,215 @@
+package migrate
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reservation records a version allocated to an owner, such as a developer or
+// a branch, by a Reserver.
+type Reservation struct {
+	Version    int       `json:"version"`
+	Owner      string    `json:"owner"`
+	ReservedAt time.Time `json:"reserved_at"`
+}
+
+// Reserver allocates versions to those writing migrations, such that
+// migrations written at once on separate branches are not numbered alike and
+// so do not collide when merged. Reserve allocates the first version after
+// both latest, the latest version of the tree in which the migration is
+// written, and every version reserved before, recording it against owner.
+// A Reserver may be provided to Scaffold, or used directly.
+type Reserver interface {
+	Reserve(latest int, owner string) (Reservation, error)
+}
+
+// lockWait is the longest time FileReserver waits for its lock file to be
+// released by another process.
+const lockWait = 5 * time.Second
+
+// FileReserver is a Reserver which records reservations in the file at Path,
+// one per line, such as on a volume shared by a team. Reservations are made
+// while holding a lock file alongside it, named after Path with the suffix
+// `.lock`, such that processes reserving at once are given separate versions.
+// The file and lock file are created as needed.
+type FileReserver struct {
+	Path string
+}
+
+// Reserve implements the Reserver interface for FileReserver.
+func (reserver FileReserver) Reserve(latest int, owner string) (Reservation, error) {
+	if owner == "" || strings.ContainsAny(owner, " \t\n") {
+		return Reservation{}, NewFatalf("FileReserver.Reserve: expected an owner without spaces, got '%s'", owner)
+	}
+
+	release, err := reserver.lock()
+	if err != nil {
+		return Reservation{}, err
+	}
+	defer release()
+
+	reservations, err := reserver.Reservations()
+	if err != nil {
+		return Reservation{}, err
+	}
+
+	reservation := Reservation{Version: latest + 1, Owner: owner, ReservedAt: time.Now().UTC()}
+	for _, existing := range reservations {
+		if existing.Version >= reservation.Version {
+			reservation.Version = existing.Version + 1
+		}
+	}
+
+	file, err := os.OpenFile(reserver.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return Reservation{}, NewFatalf("FileReserver.Reserve: got error while opening reservations:\n%s", err)
+	}
+
+	_, err = fmt.Fprintf(file, "%d %s %s\n", reservation.Version, reservation.Owner,
+		reservation.ReservedAt.Format(time.RFC3339))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Reservation{}, NewFatalf("FileReserver.Reserve: got error while recording reservation:\n%s", err)
+	}
+
+	return reservation, nil
+}
+
+// Reservations returns every reservation recorded by the FileReserver, in the
+// order in which they were made.
+func (reserver FileReserver) Reservations() ([]Reservation, error) {
+	reservations := make([]Reservation, 0)
+
+	file, err := os.Open(reserver.Path)
+	if os.IsNotExist(err) {
+		return reservations, nil
+	} else if err != nil {
+		return nil, NewFatalf("FileReserver.Reservations: got error while opening reservations:\n%s", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var reservation Reservation
+		var err error
+		if len(fields) != 3 {
+			err = fmt.Errorf("expected '<version> <owner> <time>'")
+		} else if reservation.Version, err = strconv.Atoi(fields[0]); err == nil {
+			reservation.Owner = fields[1]
+			reservation.ReservedAt, err = time.Parse(time.RFC3339, fields[2])
+		}
+		if err != nil {
+			return nil, NewFatalf("FileReserver.Reservations: malformed reservation on line %d:\n%s", number, err)
+		}
+
+		reservations = append(reservations, reservation)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, NewFatalf("FileReserver.Reservations: got error while reading reservations:\n%s", err)
+	}
+
+	return reservations, nil
+}
+
+// lock creates the lock file of the FileReserver, waiting up to lockWait for
+// another process to remove it, and returns a function which removes it.
+func (reserver FileReserver) lock() (func(), error) {
+	path := reserver.Path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		} else if !os.IsExist(err) {
+			return nil, NewFatalf("FileReserver.Reserve: got error while creating lock file:\n%s", err)
+		} else if time.Now().After(deadline) {
+			return nil, NewFatalf("FileReserver.Reserve: lock file '%s' held for over %s, remove it if no "+
+				"reservation is in progress", path, lockWait)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// ReservationHandler returns an http.Handler which reserves versions with
+// reserver for clients elsewhere, as with HTTPReserver, such that a team may
+// share a single small server in place of a shared volume. A POST request with
+// the query parameters latest and owner is answered with the Reservation made
+// as JSON.
+func ReservationHandler(reserver Reserver) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "reservations are made with POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		latest, err := strconv.Atoi(r.URL.Query().Get("latest"))
+		if err != nil {
+			http.Error(w, "expected the latest version as an integer", http.StatusBadRequest)
+			return
+		}
+
+		reservation, err := reserver.Reserve(latest, r.URL.Query().Get("owner"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reservation)
+	})
+}
+
+// HTTPReserver is a Reserver which reserves versions with the server at URL,
+// served by ReservationHandler. Client is used to make requests, or
+// http.DefaultClient if nil.
+type HTTPReserver struct {
+	URL    string
+	Client *http.Client
+}
+
+// Reserve implements the Reserver interface for HTTPReserver.
+func (reserver HTTPReserver) Reserve(latest int, owner string) (Reservation, error) {
+	client := reserver.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	query := url.Values{"latest": {strconv.Itoa(latest)}, "owner": {owner}}
+	response, err := client.Post(reserver.URL+"?"+query.Encode(), "text/plain", nil)
+	if err != nil {
+		return Reservation{}, NewFatalf("HTTPReserver.Reserve: got error while contacting server:\n%s", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(response.Body)
+		return Reservation{}, NewFatalf("HTTPReserver.Reserve: server responded with %s:\n%s", response.Status,
+			strings.TrimSpace(string(message)))
+	}
+
+	var reservation Reservation
+	if err := json.NewDecoder(response.Body).Decode(&reservation); err != nil {
+		return Reservation{}, NewFatalf("HTTPReserver.Reserve: got error while decoding reservation:\n%s", err)
+	}
+
+	return reservation, nil
+}
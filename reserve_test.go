@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestFileReserver ensures that versions reserved at once are each given a
+// separate version after both the latest version and those reserved before,
+// and that every reservation is recorded.
+func TestFileReserver(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	reserver := FileReserver{Path: filepath.Join(directory, "reservations")}
+
+	var mutex sync.Mutex
+	var group sync.WaitGroup
+	versions := make([]int, 0)
+	for i := 0; i < 8; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			reservation, err := reserver.Reserve(3, "feature/reports")
+			if err != nil {
+				t.Error("FileReserver.Reserve: got error:\n", err)
+				return
+			}
+
+			mutex.Lock()
+			versions = append(versions, reservation.Version)
+			mutex.Unlock()
+		}()
+	}
+	group.Wait()
+
+	sort.Ints(versions)
+	for i, version := range versions {
+		if version != i+4 {
+			t.Fatalf("FileReserver.Reserve: got versions %v expected 4 through 11", versions)
+		}
+	}
+
+	// A tree which has moved on past the reservations is reserved from its own latest version
+	if reservation, err := reserver.Reserve(20, "jane"); err != nil {
+		t.Fatal("FileReserver.Reserve: got error:\n", err)
+	} else if reservation.Version != 21 || reservation.Owner != "jane" || reservation.ReservedAt.IsZero() {
+		t.Errorf("FileReserver.Reserve: got '%#v' expected version 21 for jane", reservation)
+	}
+
+	reservations, err := reserver.Reservations()
+	if err != nil {
+		t.Fatal("FileReserver.Reservations: got error:\n", err)
+	} else if len(reservations) != 9 || reservations[8].Version != 21 {
+		t.Errorf("FileReserver.Reservations: got %v expected 9 reservations ending with 21", reservations)
+	}
+	if _, err := os.Stat(reserver.Path + ".lock"); !os.IsNotExist(err) {
+		t.Error("FileReserver.Reserve: expected lock file to be removed")
+	}
+
+	expectError(t, "FileReserver.Reserve", "owner with spaces", func() error {
+		_, err := reserver.Reserve(3, "jane doe")
+		return err
+	}, "expected an owner without spaces")
+}
+
+// TestHTTPReserver ensures that versions are reserved through a server
+// running ReservationHandler, and that Scaffold creates migrations numbered
+// as reserved.
+func TestHTTPReserver(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	server := httptest.NewServer(ReservationHandler(FileReserver{Path: filepath.Join(root, "reservations")}))
+	defer server.Close()
+
+	reserver := HTTPReserver{URL: server.URL}
+	if reservation, err := reserver.Reserve(3, "feature/users"); err != nil {
+		t.Fatal("HTTPReserver.Reserve: got error:\n", err)
+	} else if reservation.Version != 4 || reservation.Owner != "feature/users" {
+		t.Errorf("HTTPReserver.Reserve: got '%#v' expected version 4 for feature/users", reservation)
+	}
+
+	path, err := Scaffold{Reserver: reserver, Owner: "feature/posts"}.Create(root, "posts")
+	if err != nil {
+		t.Fatal("Scaffold.Create: got error:\n", err)
+	} else if !strings.HasSuffix(path, filepath.Join("version_5", "posts.sql")) {
+		t.Errorf("Scaffold.Create: got path '%s' expected the reserved version 5", path)
+	}
+
+	expectError(t, "HTTPReserver.Reserve", "rejected owner", func() error {
+		_, err := reserver.Reserve(3, "")
+		return err
+	}, "500 Internal Server Error", "expected an owner without spaces")
+}
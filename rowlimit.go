@@ -0,0 +1,161 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RowEstimate may be implemented by a Dialect to estimate the number of rows
+// which a statement modifying data would touch, as used by WithRowLimit.
+// Explain returns the query which explains statement without executing it, or
+// an empty string if the database cannot explain statements. EstimateRows
+// returns the number of rows estimated from the rows returned by that query.
+type RowEstimate interface {
+	Explain(statement string) string
+	EstimateRows(rows *sql.Rows) (int64, error)
+}
+
+// Explain implements the RowEstimate interface for dialect.
+func (dialect *dialect) Explain(statement string) string {
+	if dialect.explain == "" {
+		return ""
+	}
+
+	return dialect.explain + " " + statement
+}
+
+// EstimateRows implements the RowEstimate interface for dialect, returning the
+// largest number of rows estimated for any node of a plan in the JSON format
+// of Postgres, or for any table of a plan in the tabular format of MySQL.
+func (dialect *dialect) EstimateRows(rows *sql.Rows) (int64, error) {
+	if dialect.explainJSON {
+		var plan string
+		if !rows.Next() {
+			return 0, fmt.Errorf("migrate: expected plan, got no rows")
+		} else if err := rows.Scan(&plan); err != nil {
+			return 0, err
+		}
+
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(plan), &decoded); err != nil {
+			return 0, err
+		}
+
+		return planRows(decoded), rows.Err()
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	index := -1
+	for i, column := range columns {
+		if strings.EqualFold(column, "rows") {
+			index = i
+		}
+	}
+	if index < 0 {
+		return 0, fmt.Errorf("migrate: expected plan with a rows column, got columns %s",
+			strings.Join(columns, ", "))
+	}
+
+	estimate := int64(0)
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = new(sql.NullString)
+	}
+	for rows.Next() {
+		if err := rows.Scan(values...); err != nil {
+			return 0, err
+		}
+
+		if count, err := strconv.ParseInt(values[index].(*sql.NullString).String, 10, 64); err == nil &&
+			count > estimate {
+			estimate = count
+		}
+	}
+
+	return estimate, rows.Err()
+}
+
+// planRows returns the largest "Plan Rows" of any node within a plan decoded
+// from the JSON format of Postgres. The node which modifies a table may report
+// no rows itself, so the nodes which it reads from are considered as well.
+func planRows(node interface{}) int64 {
+	estimate := int64(0)
+	switch node := node.(type) {
+	case []interface{}:
+		for _, child := range node {
+			if rows := planRows(child); rows > estimate {
+				estimate = rows
+			}
+		}
+	case map[string]interface{}:
+		for key, value := range node {
+			if rows, ok := value.(float64); ok && key == "Plan Rows" && int64(rows) > estimate {
+				estimate = int64(rows)
+			} else if rows := planRows(value); rows > estimate {
+				estimate = rows
+			}
+		}
+	}
+
+	return estimate
+}
+
+// modifiesRows reports whether statement modifies the rows of a table.
+func modifiesRows(statement string) bool {
+	switch statementVerb(statement) {
+	case "UPDATE", "DELETE", "INSERT", "MERGE", "REPLACE":
+		return true
+	default:
+		return false
+	}
+}
+
+// checkRowLimit estimates the number of rows which statement would touch if
+// WithRowLimit is in use, returning an *ErrRowLimit if the estimate exceeds
+// the limit unless WithRowLimitOverride is also in use, in which case the
+// estimate is only reported. Statements which do not modify rows, and those
+// which the Dialect cannot explain, are not estimated.
+func (instance *Instance) checkRowLimit(ctx context.Context, exec execer, version int, part *Part,
+	statement string) error {
+	dialect, ok := instance.dialect.(RowEstimate)
+	if instance.rowLimit <= 0 || !ok || !modifiesRows(statement) {
+		return nil
+	}
+
+	query := dialect.Explain(statement)
+	if query == "" {
+		return nil
+	}
+
+	rows, err := exec.QueryContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("migrate: got error while estimating rows touched:\n%s", err)
+	}
+	defer rows.Close()
+
+	estimate, err := dialect.EstimateRows(rows)
+	if err != nil {
+		return fmt.Errorf("migrate: got error while estimating rows touched:\n%s", err)
+	} else if estimate <= instance.rowLimit {
+		return nil
+	}
+
+	verb := statementVerb(statement)
+	if !instance.rowLimitOverride {
+		return &ErrRowLimit{Version: version, Part: part.Name, Verb: verb, Estimate: estimate,
+			Limit: instance.rowLimit}
+	}
+
+	instance.say(MessageRowLimit, MessageData{Version: version, Part: part.Name, Verb: verb, Rows: estimate})
+	instance.log(LevelWarn, "row limit overridden", Field{"version", version}, Field{"part", part.Name},
+		Field{"verb", verb}, Field{"estimate", estimate}, Field{"limit", instance.rowLimit})
+	return nil
+}
@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// rowDialect is a Dialect which estimates the rows touched by a statement as
+// every row of the items table.
+type rowDialect struct {
+	Dialect
+}
+
+// Explain implements the RowEstimate interface for rowDialect.
+func (rowDialect) Explain(statement string) string {
+	return `SELECT COUNT(*) FROM items;`
+}
+
+// EstimateRows implements the RowEstimate interface for rowDialect.
+func (rowDialect) EstimateRows(rows *sql.Rows) (int64, error) {
+	var count int64
+	if !rows.Next() {
+		return 0, errors.New("no rows")
+	}
+
+	return count, rows.Scan(&count)
+}
+
+// TestRowLimit ensures that a statement estimated to touch more rows than the
+// limit configured with WithRowLimit is refused unless overridden.
+func TestRowLimit(t *testing.T) {
+	root := CopyTree(t, "testing/rowlimit")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithDialect(rowDialect{SQLite}), WithRowLimit(2))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error within limit:\n", err)
+		}
+		if err := instance.Latest(); !errors.Is(err, CodeRowLimit) {
+			t.Errorf("Instance.Latest: expected error with code %d above limit, got:\n%v", CodeRowLimit, err)
+		} else if strings.Count(err.Error(), "Instance.Goto:") != 1 {
+			t.Errorf("Instance.Latest: expected 'Instance.Goto:' only once in error, got:\n%s", err)
+		}
+		if version := instance.Version(); version != 1 {
+			t.Errorf("Instance.Version: got %d expected 1 once the limit was exceeded", version)
+		}
+
+		// The code is reported when the statement above the limit is not the first to fail
+		applyErr := &ErrApply{&RunReport{Failed: []PartResult{{Err: errors.New("failed")}, {Err: &ErrRowLimit{}}}}}
+		if !errors.Is(applyErr, CodeRowLimit) || len(applyErr.Errors()) != 2 ||
+			applyErr.Unwrap() != applyErr.Errors()[0] {
+			t.Errorf("ErrApply: expected code %d to be reported from both errors, got:\n%v", CodeRowLimit,
+				applyErr.Errors())
+		}
+
+		output := &strings.Builder{}
+		instance.Output = output
+		instance.rowLimitOverride = true
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error with override:\n", err)
+		}
+		if !strings.Contains(output.String(), "UPDATE in 'shift.sql' is estimated to touch 3 row(s)") {
+			t.Errorf("WithRowLimitOverride: got output '%s' expected warning", output.String())
+		}
+	})
+
+	plans, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal("sql.Open: got error:\n", err)
+	}
+	defer plans.Close()
+
+	cases := []struct {
+		dialect  Dialect
+		query    string
+		expected int64
+	}{
+		{Postgres, `SELECT '[{"Plan": {"Node Type": "ModifyTable", "Plan Rows": 0, "Plans": ` +
+			`[{"Node Type": "Seq Scan", "Plan Rows": 1200}]}}]';`, 1200},
+		{MySQL, `SELECT 1 AS id, 'SIMPLE' AS select_type, NULL AS "rows" UNION ALL SELECT 2, 'SIMPLE', '40';`, 40},
+	}
+	for _, c := range cases {
+		rows, err := plans.Query(c.query)
+		if err != nil {
+			t.Fatal("db.Query: got error:\n", err)
+		}
+
+		estimate, err := c.dialect.(RowEstimate).EstimateRows(rows)
+		rows.Close()
+		if err != nil {
+			t.Errorf("Dialect.EstimateRows: got error for %s:\n%s", c.dialect.Name(), err)
+		} else if estimate != c.expected {
+			t.Errorf("Dialect.EstimateRows: got %d for %s expected %d", estimate, c.dialect.Name(), c.expected)
+		}
+	}
+
+	if SQLite.(RowEstimate).Explain("UPDATE items SET ID = 1;") != "" {
+		t.Error("Dialect.Explain: expected no query from sqlite dialect")
+	}
+}
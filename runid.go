@@ -0,0 +1,62 @@
+package migrate
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// IDGenerator generates the identifiers with which the activity of an
+// Instance is correlated: the ID of every run, which is attached to each
+// Event, log line, history entry, and RunReport passed to a Notifier, and the
+// ID of every history entry. By default, random version 4 UUIDs are
+// generated; another IDGenerator may be provided with WithIDGenerator, such
+// as to generate IDs which sort by time.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts an ordinary function to the IDGenerator interface.
+type IDGeneratorFunc func() string
+
+// NewID implements the IDGenerator interface for IDGeneratorFunc.
+func (fn IDGeneratorFunc) NewID() string {
+	return fn()
+}
+
+// randomIDs is the default IDGenerator, generating random version 4 UUIDs.
+type randomIDs struct{}
+
+// NewID implements the IDGenerator interface for randomIDs.
+func (randomIDs) NewID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(fmt.Sprint("migrate: got error while generating ID:\n", err))
+	}
+
+	id[6] = id[6]&0x0f | 0x40 // Version 4
+	id[8] = id[8]&0x3f | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// newID returns a new ID from the IDGenerator of the Instance.
+func (instance *Instance) newID() string {
+	if instance.ids == nil {
+		return randomIDs{}.NewID()
+	}
+
+	return instance.ids.NewID()
+}
+
+// beginRun records the ID of the run described by report, either as
+// provided with WithRunID or newly generated, such that it is attached to
+// every Event and log line until the returned function is called once the run
+// is complete.
+func (instance *Instance) beginRun(report *RunReport) func() {
+	report.RunID = instance.runID
+	if report.RunID == "" {
+		report.RunID = instance.newID()
+	}
+
+	instance.activeRun = report.RunID
+	return func() { instance.activeRun = "" }
+}
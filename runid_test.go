@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestRunID ensures that the ID of each run is attached to its events, log
+// lines, history entries, and notifications, that an ID may be provided with
+// WithRunID, and that IDs are generated by the IDGenerator provided.
+func TestRunID(t *testing.T) {
+	registry.Lock()
+	saved := registry.plugins
+	registry.Unlock()
+	defer func() {
+		registry.Lock()
+		registry.plugins = saved
+		registry.Unlock()
+	}()
+
+	var notified []string
+	RegisterNotifier(NotifierFunc(func(report *RunReport) {
+		notified = append(notified, report.RunID)
+	}))
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	RunWithDB(func(db *sql.DB) {
+		logger := &recordingLogger{}
+		instance, err := NewInstance(db, "testing/meta", WithLogger(logger))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		var events []Event
+		instance.AddReporter(ReporterFunc(func(event Event) {
+			events = append(events, event)
+		}))
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		first := instance.Report().RunID
+		if !uuid.MatchString(first) {
+			t.Errorf("Instance.Goto: got run ID '%s' expected a random UUID", first)
+		}
+
+		for _, event := range events {
+			if event.RunID != first {
+				t.Errorf("Reporter: got run ID '%s' for %s expected '%s'", event.RunID, event.Message, first)
+			}
+		}
+		for _, fields := range logger.fields {
+			if last := fields[len(fields)-1]; last != (Field{"run_id", first}) {
+				t.Errorf("Logger: got fields '%v' expected run ID last", fields)
+			}
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		} else if second := instance.Report().RunID; second == first {
+			t.Error("Instance.Latest: expected a new run ID for every run")
+		}
+
+		history, err := instance.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		} else if len(history) != 2 || history[0].RunID != first || history[1].RunID != instance.Report().RunID ||
+			!uuid.MatchString(history[0].ID) || history[0].ID == history[1].ID {
+			t.Errorf("Instance.History: got %v expected an ID and the run ID for every entry", history)
+		}
+
+		if strings.Join(notified, ",") != first+","+instance.Report().RunID {
+			t.Errorf("Notifier: got run IDs %v expected those of both runs", notified)
+		}
+
+		// IDs may be generated otherwise, and the ID of every run provided
+		count := 0
+		counter := IDGeneratorFunc(func() string {
+			count++
+			return strconv.Itoa(count)
+		})
+
+		provided, err := NewInstance(db, "testing/meta", WithRunID("deploy-42"), WithIDGenerator(counter))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		provided.Output = &strings.Builder{}
+
+		if err := provided.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		} else if report := provided.Report(); report.RunID != "deploy-42" {
+			t.Errorf("Instance.Goto: got run ID '%s' expected 'deploy-42'", report.RunID)
+		}
+
+		history, err = provided.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		} else if last := history[len(history)-1]; last.RunID != "deploy-42" || last.ID != "2" {
+			t.Errorf("Instance.History: got ID '%s' and run ID '%s' expected '2' and 'deploy-42'", last.ID,
+				last.RunID)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// RehearseAgainst brings the database to the target version only once the
+// migrations have succeeded against a copy of its schema held in sandbox, a
+// scratch database such as one started in a container for the purpose, which
+// must be empty. Unlike Rehearse, nothing is rolled back, so DDL is rehearsed
+// faithfully even where the Dialect commits it implicitly, and the database
+// itself holds no locks while the rehearsal runs.
+//
+// The tables of the database, along with their columns, keys, and indexes as
+// found by Introspect, are created within sandbox without any data, and the
+// version of the database is recorded there. Sandbox is then brought to the
+// target version exactly as Goto would, by an Instance created with the same
+// options as this one, save that it applies its statements to sandbox even if
+// WithExecutor is in use and is never published with WithExpvar. If the
+// rehearsal fails, its RunReport is returned along with
+// an *ErrFatal wrapping the error and the database is left untouched.
+// Otherwise Goto is called for the database, and the RunReport of that run is
+// returned. Views, triggers, and other constraints are not copied, so
+// migrations which rely on them cannot be rehearsed in this way.
+func (instance *Instance) RehearseAgainst(sandbox *sql.DB, target int) (*RunReport, error) {
+	if instance.closed {
+		return nil, NewFatalf("Instance.RehearseAgainst: instance has been closed")
+	} else if instance.readOnly {
+		return nil, NewFatalf("Instance.RehearseAgainst: instance is read-only")
+	} else if instance.Dirty() {
+		return nil, NewFatalf("Instance.RehearseAgainst: database is dirty, call Resume before rehearsing")
+	}
+
+	current := instance.Version()
+	if target == current {
+		return nil, &ErrNoMigrations{target}
+	}
+
+	schema, err := instance.Introspect()
+	if err != nil {
+		return nil, err
+	}
+
+	// The scope provided with WithScope is entered once more by NewInstance
+	root := instance.root
+	if instance.scope != "" {
+		root = filepath.Dir(root)
+	}
+
+	options := append(instance.options[:len(instance.options):len(instance.options)], func(sandboxed *Instance) {
+		sandboxed.executor, sandboxed.expvar = nil, false
+	})
+	sandboxed, err := NewInstance(sandbox, root, options...)
+	if err != nil {
+		return nil, err
+	}
+	sandboxed.Output = instance.Output
+
+	if existing, err := sandboxed.Introspect(); err != nil {
+		return nil, err
+	} else if len(existing.Tables) > 0 || sandboxed.Version() != 0 {
+		return nil, NewFatalf("Instance.RehearseAgainst: sandbox is not empty, found %d tables at version %d",
+			len(existing.Tables), sandboxed.Version())
+	}
+
+	for _, statement := range schemaStatements(schema, instance.dialect) {
+		if _, err := sandbox.Exec(statement); err != nil {
+			return nil, NewFatalf("Instance.RehearseAgainst: got error while copying schema to sandbox:\n%s\n%s",
+				statement, err)
+		}
+	}
+
+	if current != 0 {
+		if err := sandboxed.recordVersion(sandbox, 0, current); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := sandboxed.Goto(target); err != nil {
+		return sandboxed.Report(), NewFatalf("Instance.RehearseAgainst: rehearsal failed, database left at "+
+			"version %d:\n%s", current, err)
+	}
+
+	err = instance.Goto(target)
+	return instance.Report(), err
+}
+
+// schemaStatements returns the statements which create the tables of schema,
+// along with their primary keys, unique constraints, and indexes, as they are
+// written by dialect.
+func schemaStatements(schema *Schema, dialect Dialect) []string {
+	statements := make([]string, 0, len(schema.Tables))
+	var indexes []string
+	for _, table := range schema.Tables {
+		definitions := make([]string, 0, len(table.Columns))
+		for _, column := range table.Columns {
+			definitions = append(definitions, columnDefinition(column, dialect))
+		}
+
+		for _, index := range table.Indexes {
+			switch {
+			case index.Columns == "":
+				continue
+			case index.Name == "PRIMARY":
+				// MySQL names the index of every primary key PRIMARY
+				definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", index.Columns))
+			case strings.HasPrefix(index.Name, "sqlite_autoindex_"):
+				// SQLite creates these for PRIMARY KEY and UNIQUE constraints, and reserves their names
+				definitions = append(definitions, fmt.Sprintf("UNIQUE (%s)", index.Columns))
+			default:
+				kind := "INDEX"
+				if index.Unique {
+					kind = "UNIQUE INDEX"
+				}
+				indexes = append(indexes, fmt.Sprintf("CREATE %s %s ON %s (%s);", kind, index.Name, table.Name,
+					index.Columns))
+			}
+		}
+
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (\n\t%s\n);", table.Name,
+			strings.Join(definitions, ",\n\t")))
+	}
+
+	return append(statements, indexes...)
+}
+
+// columnDefinition returns the definition of column within a CREATE TABLE
+// statement written by dialect. As the sequences behind the defaults of
+// PostgreSQL serial columns are not copied, such columns are declared serial
+// once more.
+func columnDefinition(column Column, dialect Dialect) string {
+	kind, value := column.Type, column.Default
+	if dialect.Name() == "postgres" && strings.HasPrefix(value, "nextval(") {
+		switch kind {
+		case "smallint":
+			kind = "smallserial"
+		case "integer":
+			kind = "serial"
+		case "bigint":
+			kind = "bigserial"
+		}
+		value = ""
+	}
+
+	definition := column.Name
+	if kind != "" {
+		definition += " " + kind
+	}
+	if !column.Nullable {
+		definition += " NOT NULL"
+	}
+	if value != "" {
+		definition += " DEFAULT " + value
+	}
+
+	return definition
+}
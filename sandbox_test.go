@@ -0,0 +1,121 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRehearseAgainst ensures that the schema of the database is copied to
+// the sandbox without its data and migrated there first, and that the
+// database itself is only migrated once the rehearsal succeeds.
+func TestRehearseAgainst(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	if err := ioutil.WriteFile(filepath.Join(root, "version_1", "test_index.sql"), []byte("-- @migrate/up\n"+
+		"CREATE INDEX test_last ON test(last_name);\n-- @migrate/down\nDROP INDEX test_last;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "version_4"), 0755); err != nil {
+		t.Fatal("os.Mkdir: got error:\n", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "version_4", "broken.sql"), []byte("-- @migrate/up\n"+
+		"ALTER TABLE missing ADD COLUMN Age INT;\n-- @migrate/down\nSELECT 1;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	open := func(name string) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(root, name))
+		if err != nil {
+			t.Fatal("sql.Open: got error:\n", err)
+		}
+		return db
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if _, err := db.Exec(`INSERT INTO test VALUES (1, 'Jane', 'Doe');`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		sandbox := open("sandbox.db")
+		defer sandbox.Close()
+
+		report, err := instance.RehearseAgainst(sandbox, 3)
+		if err != nil {
+			t.Fatal("Instance.RehearseAgainst: got error:\n", err)
+		} else if report.From != 1 || report.Version != 3 || instance.Version() != 3 {
+			t.Errorf("Instance.RehearseAgainst: got report from %d to %d expected the database at version 3",
+				report.From, report.Version)
+		}
+
+		var count int
+		if err := sandbox.QueryRow(`SELECT COUNT(*) FROM new_test;`).Scan(&count); err != nil {
+			t.Fatal("sql.DB.QueryRow: got error with sandbox:\n", err)
+		} else if count != 0 {
+			t.Errorf("Instance.RehearseAgainst: got %d rows in sandbox expected the schema alone", count)
+		}
+		if err := sandbox.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'test_last';`).Scan(
+			&count); err != nil || count != 1 {
+			t.Errorf("Instance.RehearseAgainst: expected index to be copied to sandbox")
+		}
+
+		expectError(t, "Instance.RehearseAgainst", "sandbox not empty", func() error {
+			_, err := instance.RehearseAgainst(sandbox, 1)
+			return err
+		}, "sandbox is not empty, found 1 tables at version 3")
+
+		failing := open("failing.db")
+		defer failing.Close()
+
+		report, err = instance.RehearseAgainst(failing, 4)
+		if _, ok := err.(*ErrFatal); !ok || !strings.Contains(err.Error(), "rehearsal failed") {
+			t.Errorf("Instance.RehearseAgainst: got error '%v' expected the rehearsal to fail", err)
+		} else if report == nil || len(report.Failed) != 1 || instance.Version() != 3 {
+			t.Errorf("Instance.RehearseAgainst: got version %d expected the database untouched at 3",
+				instance.Version())
+		}
+	})
+}
+
+// TestRehearseAgainstOptions ensures that the sandbox is migrated with the
+// options of the Instance, such that a part excluded with WithIgnore is not
+// applied to the sandbox either.
+func TestRehearseAgainstOptions(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	if err := ioutil.WriteFile(filepath.Join(root, "version_2", "scratch.sql"), []byte("-- @migrate/up\n"+
+		"ALTER TABLE missing ADD COLUMN Age INT;\n-- @migrate/down\nSELECT 1;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithIgnore("scratch.sql"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		sandbox, err := sql.Open("sqlite3", filepath.Join(root, "sandbox.db"))
+		if err != nil {
+			t.Fatal("sql.Open: got error:\n", err)
+		}
+		defer sandbox.Close()
+
+		if _, err := instance.RehearseAgainst(sandbox, 2); err != nil {
+			t.Fatal("Instance.RehearseAgainst: got error with ignored part:\n", err)
+		} else if version := instance.Version(); version != 2 {
+			t.Errorf("Instance.Version: got %d expected 2", version)
+		}
+	})
+}
@@ -0,0 +1,147 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// ScaffoldTemplate is the name of the template from which Scaffold creates
+// new part files, unless another is named.
+const ScaffoldTemplate = "part.sql.tmpl"
+
+// DefaultScaffold is the template used by Scaffold when no templates are
+// provided. As a part file may not hold any SQL or comments before its first
+// directive, headers are best written with `-- @migrate/meta`.
+const DefaultScaffold = `-- @migrate/meta created={{ .Time.Format "2006-01-02" }}
+
+-- @migrate/up
+
+-- @migrate/down
+`
+
+// ScaffoldData is passed to the template from which a part file is created.
+type ScaffoldData struct {
+	Version int
+	Name    string
+	Time    time.Time
+	Data    map[string]interface{} // Values provided with Scaffold, such as the author or ticket
+}
+
+// Scaffold creates new migrations from templates, allowing every migration to
+// begin with a house-style header and skeleton.
+type Scaffold struct {
+	// Templates holds the templates from which part files are created, such
+	// as http.Dir("templates") or an embedded file system. If nil,
+	// DefaultScaffold is used.
+	Templates http.FileSystem
+	// Template names the template to use, ScaffoldTemplate if empty.
+	Template string
+	// Data is made available to the template as `.Data`.
+	Data map[string]interface{}
+	// Reserver, if not nil, allocates the version of each migration created,
+	// recorded against Owner, rather than numbering it one after the latest
+	// existing version alone. The version reserved may lie beyond those
+	// reserved by others but not yet merged, in which case NewInstance
+	// returns an *ErrGap until they are.
+	Reserver Reserver
+	Owner    string
+}
+
+// Create creates a new migration directory within root, numbered one after the
+// latest existing version or as reserved with the Reserver, holding a single
+// part file rendered from the template of the Scaffold. The part file is named
+// after name, with any character other than a letter or digit replaced by an
+// underscore. Create returns the path of the new part file.
+func (scaffold Scaffold) Create(root, name string) (string, error) {
+	source, err := scaffold.source()
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(ScaffoldTemplate).Funcs(templateFuncs).Parse(source)
+	if err != nil {
+		return "", NewFatalf("Scaffold.Create: got error while parsing template:\n%s", err)
+	}
+
+	directories, err := versionDirectories(root)
+	if err != nil {
+		return "", err
+	}
+
+	version := 1
+	for existing := range directories {
+		if existing >= version {
+			version = existing + 1
+		}
+	}
+
+	name = strings.Trim(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, name), "_")
+	if name == "" {
+		return "", NewFatalf("Scaffold.Create: got empty migration name")
+	}
+
+	if scaffold.Reserver != nil {
+		reservation, err := scaffold.Reserver.Reserve(version-1, scaffold.Owner)
+		if err != nil {
+			return "", err
+		}
+		version = reservation.Version
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, ScaffoldData{Version: version, Name: name, Time: time.Now(),
+		Data: scaffold.Data}); err != nil {
+		return "", NewFatalf("Scaffold.Create: got error while rendering template:\n%s", err)
+	}
+
+	directory := filepath.Join(root, fmt.Sprintf("version_%d", version))
+	if err := os.Mkdir(directory, 0755); err != nil {
+		return "", NewFatalf("Scaffold.Create: got error while creating migration directory:\n%s", err)
+	}
+
+	path := filepath.Join(directory, name+".sql")
+	if err := ioutil.WriteFile(path, buffer.Bytes(), 0644); err != nil {
+		os.RemoveAll(directory)
+		return "", NewFatalf("Scaffold.Create: got error while writing part file:\n%s", err)
+	}
+
+	return path, nil
+}
+
+// source returns the contents of the template of the Scaffold.
+func (scaffold Scaffold) source() (string, error) {
+	if scaffold.Templates == nil {
+		return DefaultScaffold, nil
+	}
+
+	name := scaffold.Template
+	if name == "" {
+		name = ScaffoldTemplate
+	}
+
+	file, err := scaffold.Templates.Open("/" + name)
+	if err != nil {
+		return "", NewFatalf("Scaffold.Create: got error while opening template '%s':\n%s", name, err)
+	}
+	defer file.Close()
+
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", NewFatalf("Scaffold.Create: got error while reading template '%s':\n%s", name, err)
+	}
+
+	return string(contents), nil
+}
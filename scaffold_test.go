@@ -0,0 +1,53 @@
+package migrate
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestScaffold ensures that Scaffold creates the next migration from either
+// the default template or a template provided.
+func TestScaffold(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+
+	path, err := Scaffold{}.Create(root, "Add Users!")
+	if err != nil {
+		t.Fatal("Scaffold.Create: got error:\n", err)
+	}
+	if expected := filepath.Join(root, "version_4", "add_users.sql"); path != expected {
+		t.Errorf("Scaffold.Create: got path '%s' expected '%s'", path, expected)
+	}
+	if contents, err := ioutil.ReadFile(path); err != nil {
+		t.Fatal("ioutil.ReadFile: got error:\n", err)
+	} else if !strings.HasPrefix(string(contents), "-- @migrate/meta created=") {
+		t.Errorf("Scaffold.Create: got unexpected contents from default template:\n%s", contents)
+	}
+
+	scaffold := Scaffold{Templates: http.Dir("testing/scaffold"), Data: map[string]interface{}{
+		"Author": "jane", "Ticket": "OPS-12"}}
+	path, err = scaffold.Create(root, "posts")
+	if err != nil {
+		t.Fatal("Scaffold.Create: got error:\n", err)
+	}
+
+	part, err := NewPart(path)
+	if err != nil {
+		t.Fatal("NewPart: got error with scaffolded part:\n", err)
+	}
+	if !strings.HasSuffix(part.Path, filepath.Join("version_5", "posts.sql")) || part.Meta["author"] != "jane" ||
+		part.Meta["ticket"] != "OPS-12" {
+		t.Errorf("Scaffold.Create: got part '%#v' expected version 5 by 'jane' for 'OPS-12'", part)
+	}
+
+	expectError(t, "Scaffold.Create", "missing template", func() error {
+		_, err := Scaffold{Templates: http.Dir("testing/scaffold"), Template: "nothing.tmpl"}.Create(root, "x")
+		return err
+	}, "error while opening template 'nothing.tmpl'")
+	expectError(t, "Scaffold.Create", "empty name", func() error {
+		_, err := Scaffold{}.Create(root, "!!")
+		return err
+	}, "empty migration name")
+}
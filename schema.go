@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+)
+
+// regexSchema matches the name of a schema which may be provided with
+// WithSchema, such that it may be safely interpolated into statements.
+var regexSchema = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// qualify returns the name of a table within schema, or the name alone if
+// schema is empty.
+func qualify(schema, table string) string {
+	if schema == "" {
+		return table
+	}
+
+	return schema + "." + table
+}
+
+// table returns the name of one of the tables in which migrate records its
+// state, suffixed with the scope provided with WithScope and qualified with
+// the schema provided with WithSchema.
+func (instance *Instance) table(name string) string {
+	if instance.scope != "" {
+		name += "_" + instance.scope
+	}
+
+	return qualify(instance.schema, name)
+}
+
+// metadataTable returns the name of the metadata table, qualified with the
+// schema provided with WithSchema such that it is never resolved through the
+// search path. Unlike the other tables of migrate it keeps the name given to
+// it by the metadb package, without the scope, and is shared by every scope.
+func (instance *Instance) metadataTable() string {
+	return qualify(instance.schema, "metadata")
+}
+
+// metaKey returns the name of a metadata entry recorded by the Instance,
+// prefixed by the scope provided with WithScope, as the metadata table of a
+// schema is shared by every scope.
+func (instance *Instance) metaKey(name string) string {
+	if instance.scope == "" {
+		return name
+	}
+
+	return instance.scope + "." + name
+}
+
+// searchPath returns the statement which sets the search path to the schema
+// provided with WithSchema, for the current transaction only if local is true,
+// or an empty string if no schema is in use or the dialect has no search path.
+func (instance *Instance) searchPath(local bool) string {
+	if instance.schema == "" {
+		return ""
+	} else if path, ok := instance.dialect.(SearchPath); ok {
+		return path.SearchPath(instance.schema, local)
+	}
+
+	return ""
+}
+
+// begin starts a transaction within which unqualified names are resolved in
+// the schema provided with WithSchema, if any.
+func (instance *Instance) begin() (*sql.Tx, error) {
+	transaction, err := instance.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if statement := instance.searchPath(true); statement != "" {
+		if _, err := transaction.Exec(statement); err != nil {
+			transaction.Rollback()
+			return nil, err
+		}
+	}
+
+	return transaction, nil
+}
+
+// connExecer adapts a *sql.Conn to the execer interface.
+type connExecer struct {
+	*sql.Conn
+}
+
+// Exec implements the execer interface for connExecer.
+func (conn connExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return conn.ExecContext(context.Background(), query, args...)
+}
+
+// QueryRow implements the execer interface for connExecer.
+func (conn connExecer) QueryRow(query string, args ...interface{}) *sql.Row {
+	return conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// pin returns an execer with which statements are applied outside of a
+// transaction. If the search path must be set for the schema provided with
+// WithSchema, the execer is bound to a single connection on which it is set,
+// and the returned release function restores the default search path and
+// returns the connection to the pool. Otherwise, the database itself is
+// returned.
+func (instance *Instance) pin(ctx context.Context) (execer, func(), error) {
+	statement := instance.searchPath(false)
+	if statement == "" {
+		return instance.db, func() {}, nil
+	}
+
+	conn, err := instance.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	} else if _, err := conn.ExecContext(ctx, statement); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	release := func() {
+		if reset := instance.dialect.(SearchPath).SearchPath("", false); reset != "" {
+			conn.ExecContext(context.Background(), reset)
+		}
+		conn.Close()
+	}
+
+	return connExecer{conn}, release, nil
+}
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSchema ensures that WithSchema records the state of a run, including
+// the version in the metadata table, within the schema provided,
+// independently of the default schema.
+func TestSchema(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+
+	RunWithDB(func(db *sql.DB) {
+		// Attached databases are the closest equivalent of a schema in SQLite
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec(`ATTACH DATABASE ? AS tenant_a;`, filepath.Join(directory, "tenant_a.sqlite")); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		global, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		tenant, err := NewInstance(db, "testing/meta", WithSchema("tenant_a"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		tenant.Output = &strings.Builder{}
+
+		if err := tenant.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if version := tenant.Version(); version != 1 {
+			t.Errorf("Instance.Version: got %d expected 1 within schema", version)
+		}
+		if version := global.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d expected 0 outside of schema", version)
+		}
+		if !tenant.meta.Exists(DefaultVersionKey) || global.meta.Exists(DefaultVersionKey) {
+			t.Errorf("Instance.Goto: expected version to be recorded under '%s' only within schema",
+				DefaultVersionKey)
+		}
+
+		for table, expected := range map[string]int{"tenant_a.migrate_history": 1, "migrate_history": 0,
+			"tenant_a.metadata": 1, "metadata": 0} {
+			var count int
+			if err := db.QueryRow(`SELECT COUNT(*) FROM ` + table + `;`).Scan(&count); err != nil {
+				t.Fatal("sql.DB.QueryRow: got error:\n", err)
+			} else if count != expected {
+				t.Errorf("Instance.Goto: got %d entries in %s expected %d", count, table, expected)
+			}
+		}
+
+		if history, err := tenant.History(); err != nil || len(history) != 1 {
+			t.Errorf("Instance.History: got %d entries and error '%v' expected 1", len(history), err)
+		}
+
+		if _, err := NewInstance(db, "testing/meta", WithSchema("tenant; DROP TABLE metadata")); err == nil {
+			t.Error("NewInstance: expected error with invalid schema name")
+		}
+	})
+
+	cases := []struct {
+		schema   string
+		local    bool
+		expected string
+	}{
+		{"tenant_a", true, "SELECT set_config('search_path', 'tenant_a, ' || current_setting('search_path'), true);"},
+		{"tenant_a", false, "SELECT set_config('search_path', 'tenant_a, ' || current_setting('search_path'), " +
+			"false);"},
+		{"", false, "SET SESSION search_path TO DEFAULT;"},
+	}
+	for _, c := range cases {
+		if statement := Postgres.(SearchPath).SearchPath(c.schema, c.local); statement != c.expected {
+			t.Errorf("Dialect.SearchPath: got '%s' expected '%s'", statement, c.expected)
+		}
+	}
+	if statement := SQLite.(SearchPath).SearchPath("tenant_a", true); statement != "" {
+		t.Errorf("Dialect.SearchPath: got '%s' from sqlite dialect expected none", statement)
+	}
+}
@@ -0,0 +1,43 @@
+package migrate
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// enterScope moves the instance directory into the subdirectory named by the
+// scope provided with WithScope, if any, returning an error if the scope is
+// not a valid name.
+func (instance *Instance) enterScope() error {
+	if instance.scope == "" {
+		return nil
+	} else if !regexSchema.MatchString(instance.scope) {
+		return fmt.Errorf("invalid scope name '%s'", instance.scope)
+	}
+
+	instance.root = filepath.Join(instance.root, instance.scope)
+	return nil
+}
+
+// isScope reports whether the directory at path holds a scope, as migrated
+// with WithScope, that is whether it is not itself a version directory but
+// holds version directories of its own.
+func isScope(path string) bool {
+	if strings.HasPrefix(filepath.Base(path), "version_") {
+		return false
+	}
+
+	entries, err := readDir(path)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), "version_") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,84 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestScope ensures that the subdirectory named with WithScope is migrated as
+// a unit of its own, versioned and recorded apart from the rest of the tree,
+// which skips it.
+func TestScope(t *testing.T) {
+	root := CopyTree(t, "testing/meta")
+	directory := filepath.Join(root, "reporting", "version_1")
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		t.Fatal("os.MkdirAll: got error:\n", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(directory, "reports.sql"), []byte("-- @migrate/up\n"+
+		"CREATE TABLE reports(ID INT PRIMARY KEY);\n-- @migrate/down\nDROP TABLE reports;\n"), 0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		parent, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		parent.Output = &strings.Builder{}
+
+		scoped, err := NewInstance(db, root, WithScope("reporting"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		scoped.Output = &strings.Builder{}
+
+		if list := scoped.List(); !reflect.DeepEqual(list, []int{1}) {
+			t.Errorf("Instance.List: got %v for scope expected [1]", list)
+		}
+		if list := parent.List(); !reflect.DeepEqual(list, []int{1, 2}) {
+			t.Errorf("Instance.List: got %v for parent expected [1 2]", list)
+		}
+
+		if err := parent.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if version := scoped.Version(); version != 0 {
+			t.Errorf("Instance.Version: got %d for scope expected 0", version)
+		}
+
+		if err := scoped.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if parent.Version() != 2 || scoped.Version() != 1 {
+			t.Errorf("Instance.Version: got %d for parent and %d for scope expected 2 and 1", parent.Version(),
+				scoped.Version())
+		}
+		if !tableExists(db, "reports") || !tableExists(db, "migrate_history_reporting") {
+			t.Error("Instance.Latest: expected scope to be applied and recorded in its own history")
+		}
+
+		history, err := scoped.History()
+		if err != nil {
+			t.Fatal("Instance.History: got error:\n", err)
+		} else if len(history) != 1 || history[0].Part != "reports.sql" {
+			t.Errorf("Instance.History: got %v for scope expected reports.sql alone", history)
+		}
+
+		if err := scoped.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		if parent.Version() != 2 || tableExists(db, "reports") {
+			t.Errorf("Instance.Goto: got parent at version %d expected the scope alone to be reverted",
+				parent.Version())
+		}
+	})
+
+	expectError(t, "Inspect", "invalid scope", func() error {
+		return Inspect(root, WithScope("../reporting"))
+	}, "invalid scope name '../reporting'")
+}
@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// regexSecret matches a `{{secret "name"}}` placeholder within the SQL of a
+// part, capturing the quoted name.
+var regexSecret = regexp.MustCompile(`\{\{\s*secret\s+("(?:[^"\\]|\\.)*")\s*\}\}`)
+
+// SecretProvider provides the values of the secrets referenced by
+// `{{secret "name"}}` placeholders within the SQL of a part, such as the
+// password of a role created by a migration.
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// SecretFunc adapts an ordinary function to a SecretProvider, such as one
+// which reads a secret from Vault with its Go client:
+//
+//	migrate.SecretFunc(func(name string) (string, error) {
+//		secret, err := client.Logical().Read("secret/data/migrate")
+//		if err != nil {
+//			return "", err
+//		}
+//		value, _ := secret.Data["data"].(map[string]interface{})[name].(string)
+//		return value, nil
+//	})
+type SecretFunc func(name string) (string, error)
+
+// Secret calls fn with the name provided.
+func (fn SecretFunc) Secret(name string) (string, error) {
+	return fn(name)
+}
+
+// EnvSecrets provides secrets from environment variables, named by the name
+// of the secret following Prefix. An error is returned if the variable is not
+// set.
+type EnvSecrets struct {
+	Prefix string
+}
+
+// Secret returns the value of the environment variable named by Prefix and
+// name.
+func (env EnvSecrets) Secret(name string) (string, error) {
+	value, ok := os.LookupEnv(env.Prefix + name)
+	if !ok {
+		return "", errors.New("environment variable '" + env.Prefix + name + "' is not set")
+	}
+
+	return value, nil
+}
+
+// FileSecrets provides secrets from files within Directory, named by the name
+// of the secret, as mounted by Docker and Kubernetes secrets. A single
+// trailing newline is removed from the contents of each file.
+type FileSecrets struct {
+	Directory string
+}
+
+// Secret returns the contents of the file named by name within Directory.
+func (files FileSecrets) Secret(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", errors.New("secret name '" + name + "' is not a valid file name")
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(files.Directory, name))
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSuffix(string(contents), "\n")
+	return strings.TrimSuffix(value, "\r"), nil
+}
+
+// secretPlaceholder returns the placeholder referencing the secret named by
+// name, allowing templates to pass a placeholder through to be resolved at
+// execution time rather than when the template is rendered.
+func secretPlaceholder(name string) string {
+	return "{{secret " + strconv.Quote(name) + "}}"
+}
+
+// resolveSecrets returns sql with every secret placeholder replaced by the
+// value of the secret, along with the values substituted so that they may be
+// redacted from errors. An error naming the secret but never including its
+// value is returned if a secret cannot be provided.
+func (instance *Instance) resolveSecrets(sql string) (string, []string, error) {
+	matches := regexSecret.FindAllStringSubmatchIndex(sql, -1)
+	if matches == nil {
+		return sql, nil, nil
+	}
+
+	var builder strings.Builder
+	values := make([]string, 0, len(matches))
+	last := 0
+	for _, match := range matches {
+		name, err := strconv.Unquote(sql[match[2]:match[3]])
+		if err != nil {
+			return "", nil, NewFatalf("got invalid secret name %s", sql[match[2]:match[3]])
+		} else if instance.secrets == nil {
+			return "", nil, NewFatalf("got secret '%s' but no SecretProvider was provided with WithSecrets", name)
+		}
+
+		value, err := instance.secrets.Secret(name)
+		if err != nil {
+			return "", nil, NewFatalf("got error while resolving secret '%s':\n%s", name, redact(err.Error(), value))
+		}
+
+		builder.WriteString(sql[last:match[0]])
+		builder.WriteString(value)
+		values = append(values, value)
+		last = match[1]
+	}
+	builder.WriteString(sql[last:])
+
+	return builder.String(), values, nil
+}
+
+// redact returns text with every occurrence of the values provided replaced.
+func redact(text string, values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			text = strings.Replace(text, value, "[REDACTED]", -1)
+		}
+	}
+
+	return text
+}
+
+// redactError returns err unchanged if its message does not include any of
+// the values provided, or an error with a redacted message otherwise.
+func redactError(err error, values []string) error {
+	if message := redact(err.Error(), values...); message != err.Error() {
+		return errors.New(message)
+	}
+
+	return err
+}
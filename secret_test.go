@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSecrets ensures that secret placeholders are resolved when statements
+// are executed, and that their values are never written elsewhere.
+func TestSecrets(t *testing.T) {
+	values := map[string]string{"api_key": "hunter2", "token": "s3cr3t"}
+	provider := SecretFunc(func(name string) (string, error) {
+		if value, ok := values[name]; ok {
+			return value, nil
+		}
+		return "", errors.New("unknown secret")
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/secrets", WithTemplateData(map[string]string{"Name": "ci"}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		expectError(t, "Instance.Latest", "secret without a provider", instance.Latest,
+			"got secret 'api_key' but no SecretProvider")
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/secrets", WithSecrets(provider),
+			WithTemplateData(map[string]string{"Name": "ci"}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		planned, err := instance.Plan(1)
+		if err != nil {
+			t.Fatal("Instance.Plan: got error:\n", err)
+		}
+		for _, statement := range planned {
+			if strings.Contains(statement.SQL, "hunter2") || strings.Contains(statement.SQL, "s3cr3t") {
+				t.Errorf("Instance.Plan: got secret value in statement '%s'", statement.SQL)
+			}
+		}
+		if !strings.Contains(planned[1].SQL, `{{secret "api_key"}}`) ||
+			!strings.Contains(planned[2].SQL, `{{secret "token"}}`) {
+			t.Errorf("Instance.Plan: expected placeholders, got '%s' and '%s'", planned[1].SQL, planned[2].SQL)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		stored := make(map[string]string)
+		err = query(db, "SELECT Name, Value FROM credentials", nil, func(rows *sql.Rows) error {
+			var name, value string
+			if err := rows.Scan(&name, &value); err != nil {
+				return err
+			}
+			stored[name] = value
+			return nil
+		})
+		if err != nil {
+			t.Fatal("query: got error:\n", err)
+		} else if stored["api"] != "hunter2" || stored["ci"] != "s3cr3t" {
+			t.Errorf("Instance.Latest: got credentials '%v' expected resolved secrets", stored)
+		}
+
+		if strings.Contains(output.String(), "hunter2") || strings.Contains(output.String(), "s3cr3t") {
+			t.Errorf("Instance.Latest: got secret value in output:\n%s", output)
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		delete(values, "token")
+		instance, err := NewInstance(db, "testing/secrets", WithSecrets(provider),
+			WithTemplateData(map[string]string{"Name": "ci"}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		expectError(t, "Instance.Latest", "unknown secret", instance.Latest,
+			"got error while resolving secret 'token':\nunknown secret")
+	})
+
+	if err := redactError(errors.New("near 'hunter2': syntax error"), []string{"hunter2"}); err.Error() !=
+		"near '[REDACTED]': syntax error" {
+		t.Errorf("redactError: got '%s' expected value to be redacted", err)
+	}
+}
+
+// TestSecretProviders ensures that the built-in providers read secrets from
+// the environment and from files.
+func TestSecretProviders(t *testing.T) {
+	os.Setenv("MIGRATE_TEST_PASSWORD", "hunter2")
+	defer os.Unsetenv("MIGRATE_TEST_PASSWORD")
+
+	env := EnvSecrets{Prefix: "MIGRATE_TEST_"}
+	if value, err := env.Secret("PASSWORD"); err != nil || value != "hunter2" {
+		t.Errorf("EnvSecrets.Secret: got '%s' and error '%v' expected 'hunter2'", value, err)
+	}
+	if _, err := env.Secret("MISSING"); err == nil {
+		t.Error("EnvSecrets.Secret: expected error for unset variable")
+	}
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "password"), []byte("hunter2\n"), 0600); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	files := FileSecrets{Directory: dir}
+	if value, err := files.Secret("password"); err != nil || value != "hunter2" {
+		t.Errorf("FileSecrets.Secret: got '%s' and error '%v' expected 'hunter2'", value, err)
+	}
+	if _, err := files.Secret("../password"); err == nil {
+		t.Error("FileSecrets.Secret: expected error for name outside directory")
+	}
+}
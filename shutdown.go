@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Exit codes returned by ExitCode.
+const (
+	ExitSuccess     = 0   // The run succeeded
+	ExitFailure     = 1   // The run failed for any reason other than an interruption
+	ExitInterrupted = 130 // The run was interrupted by a signal and rolled back, as with SIGINT in a shell
+)
+
+// ShutdownContext returns a copy of parent which is cancelled as soon as the
+// process receives one of the signals provided, or SIGINT or SIGTERM if none
+// are provided, such that a deploy cancelled part way through a run is
+// stopped cleanly rather than killed. Passed to GotoContext or RunDeferred,
+// the statement being executed is cancelled, the transaction is rolled back
+// or the database is left to be resumed, and the migration lock is released
+// before the method returns. Only the first signal is handled, so a second
+// signal terminates the process as usual should the database fail to respond.
+// The returned stop function must be called once the run has finished to
+// stop listening for signals.
+//
+//	ctx, stop := migrate.ShutdownContext(context.Background())
+//	defer stop()
+//	err := instance.GotoContext(ctx, target)
+//	os.Exit(migrate.ExitCode(err))
+func ShutdownContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+
+	go func() {
+		select {
+		case <-received:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(received)
+	}()
+
+	return ctx, cancel
+}
+
+// ExitCode returns the code with which a process should exit after a run
+// returned err: ExitSuccess if err is nil, ExitInterrupted if the run failed
+// because its context was cancelled, such as by ShutdownContext, or
+// ExitFailure otherwise. Automation may thereby tell a cancelled deploy from
+// a migration which failed.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	} else if applyErr, ok := err.(*ErrApply); ok && applyErr.Report.Interrupted {
+		return ExitInterrupted
+	}
+
+	return ExitFailure
+}
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestShutdownContext ensures that a signal cancels a run, which is rolled back
+// and releases the lock, and that the interruption is given a distinct exit
+// code.
+func TestShutdownContext(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/timeout")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		ctx, stop := ShutdownContext(context.Background())
+		defer stop()
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			if process, err := os.FindProcess(os.Getpid()); err == nil {
+				process.Signal(os.Interrupt)
+			}
+		}()
+
+		err = instance.GotoContext(ctx, 2)
+		if code := ExitCode(err); code != ExitInterrupted {
+			t.Fatalf("ExitCode: got %d for '%v' expected %d", code, err, ExitInterrupted)
+		}
+		if report := instance.Report(); report.Outcome != RolledBack || instance.Version() != 0 {
+			t.Errorf("Instance.GotoContext: got outcome '%s' at version %d expected rollback to 0", report.Outcome,
+				instance.Version())
+		}
+
+		// The lock was released, so another run may proceed
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+	})
+
+	if code := ExitCode(nil); code != ExitSuccess {
+		t.Errorf("ExitCode: got %d for nil expected %d", code, ExitSuccess)
+	}
+	if code := ExitCode(&ErrDirty{}); code != ExitFailure {
+		t.Errorf("ExitCode: got %d for *ErrDirty expected %d", code, ExitFailure)
+	}
+}
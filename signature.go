@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// SignatureFile is the name of the file, placed at the root of an instance
+// directory, which holds the signature over the manifest of the tree.
+const SignatureFile = "migrate.sig"
+
+// Keyring holds the public keys trusted to sign migration trees.
+type Keyring []ed25519.PublicKey
+
+// ErrSignature is returned by NewInstance when WithSignatureVerification is
+// in use and the migration tree is not signed by a trusted key.
+type ErrSignature struct {
+	Root   string
+	Reason string
+}
+
+// Error implements the error interface for ErrSignature.
+func (err *ErrSignature) Error() string {
+	return fmt.Sprintf("NewInstance: failed to verify signature of migrations in '%s': %s", err.Root, err.Reason)
+}
+
+// Manifest returns the manifest of the migrations held by the Instance,
+// listing the RawChecksum of every part ordered by version and then by name,
+// one per line, followed by the Checksum of every asset of the version, such
+// as the CSV files loaded by parts. It is the manifest rather than the files
+// themselves which is signed, so that any change to the files, even to
+// comments or whitespace, or any part or asset added or removed, invalidates
+// the signature.
+func (instance *Instance) Manifest() []byte {
+	var builder strings.Builder
+	for _, version := range instance.List() {
+		migration := instance.migrations[version]
+		for _, part := range migration.Parts {
+			fmt.Fprintf(&builder, "%s/%s %s\n", migration.Name, part.Name, part.RawChecksum)
+		}
+		for _, asset := range migration.Assets {
+			fmt.Fprintf(&builder, "%s/%s %s\n", migration.Name, asset.Name, asset.Checksum)
+		}
+	}
+
+	return []byte(builder.String())
+}
+
+// Sign signs the manifest of the migrations held by the Instance with the
+// private key provided, writing the signature to the SignatureFile at the
+// root of the instance directory.
+func (instance *Instance) Sign(key ed25519.PrivateKey) error {
+	if instance.partial() {
+		return NewFatalf("Instance.Sign: cannot sign migrations loaded with WithVersionRange")
+	}
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, instance.Manifest()))
+	if err := ioutil.WriteFile(filepath.Join(instance.root, SignatureFile), []byte(signature+"\n"),
+		0644); err != nil {
+		return NewFatalf("Instance.Sign: got error while writing signature:\n%s", err)
+	}
+
+	return nil
+}
+
+// verifySignature returns an *ErrSignature unless the SignatureFile at the
+// root of the instance directory holds a valid signature over the manifest by
+// one of the keys in the keyring.
+func (instance *Instance) verifySignature(keyring Keyring) error {
+	contents, err := ioutil.ReadFile(filepath.Join(instance.root, SignatureFile))
+	if err != nil {
+		return &ErrSignature{Root: instance.root, Reason: fmt.Sprintf("could not read %s: %s", SignatureFile, err)}
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return &ErrSignature{Root: instance.root, Reason: "malformed signature"}
+	}
+
+	manifest := instance.Manifest()
+	for _, key := range keyring {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, manifest, signature) {
+			return nil
+		}
+	}
+
+	return &ErrSignature{Root: instance.root, Reason: "signature does not match any trusted key"}
+}
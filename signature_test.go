@@ -0,0 +1,108 @@
+package migrate
+
+import (
+	"crypto/ed25519"
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSignature ensures that a signed migration tree is only loaded when the
+// signature is valid and made by a trusted key, and that modifying any part
+// invalidates the signature.
+func TestSignature(t *testing.T) {
+	root := CopyTree(t, "testing/working")
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal("ed25519.GenerateKey: got error:\n", err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal("ed25519.GenerateKey: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		expectError(t, "NewInstance", "unsigned migrations", func() error {
+			_, err := NewInstance(db, root, WithSignatureVerification(Keyring{public}))
+			return err
+		}, "could not read migrate.sig")
+
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		manifest := string(instance.Manifest())
+		if !strings.HasPrefix(manifest, "version_1/test.sql ") || strings.Count(manifest, "\n") != 3 {
+			t.Errorf("Instance.Manifest: got unexpected manifest:\n%s", manifest)
+		}
+
+		if err := instance.Sign(private); err != nil {
+			t.Fatal("Instance.Sign: got error:\n", err)
+		}
+
+		if _, err := NewInstance(db, root, WithSignatureVerification(Keyring{otherPublic, public})); err != nil {
+			t.Error("NewInstance: got error with valid signature:\n", err)
+		}
+
+		expectError(t, "NewInstance", "untrusted key", func() error {
+			_, err := NewInstance(db, root, WithSignatureVerification(Keyring{otherPublic}))
+			return err
+		}, "does not match any trusted key")
+
+		path := filepath.Join(root, "version_2", "test.sql")
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal("ioutil.ReadFile: got error:\n", err)
+		}
+		if err := ioutil.WriteFile(path, append(contents, []byte("\n-- Modified\n")...), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		expectError(t, "NewInstance", "modified part", func() error {
+			_, err := NewInstance(db, root, WithSignatureVerification(Keyring{public}))
+			return err
+		}, "does not match any trusted key")
+	})
+}
+
+// TestSignatureAssets ensures that the assets of a signed migration tree are
+// signed along with its parts, such that modifying a CSV file loaded by a part
+// invalidates the signature.
+func TestSignatureAssets(t *testing.T) {
+	root := CopyTree(t, "testing/assets")
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal("ed25519.GenerateKey: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+
+		if manifest := string(instance.Manifest()); !strings.Contains(manifest, "version_1/countries.csv ") {
+			t.Errorf("Instance.Manifest: got manifest without countries.csv:\n%s", manifest)
+		}
+		if err := instance.Sign(private); err != nil {
+			t.Fatal("Instance.Sign: got error:\n", err)
+		}
+
+		path := filepath.Join(root, "version_1", "countries.csv")
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal("ioutil.ReadFile: got error:\n", err)
+		}
+		if err := ioutil.WriteFile(path, append(contents, []byte("XX,Nowhere,0\n")...), 0644); err != nil {
+			t.Fatal("ioutil.WriteFile: got error:\n", err)
+		}
+
+		expectError(t, "NewInstance", "modified asset", func() error {
+			_, err := NewInstance(db, root, WithSignatureVerification(Keyring{public}))
+			return err
+		}, "does not match any trusted key")
+	})
+}
@@ -0,0 +1,249 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// databaseFile returns the path of the file holding the main database of a
+// SQLite connection, returning an error if the database is held in memory.
+func databaseFile(ctx context.Context, conn *sql.Conn) (string, error) {
+	rows, err := conn.QueryContext(ctx, `PRAGMA database_list;`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return "", err
+		} else if name == "main" && file != "" {
+			return file, nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return "", NewFatalf("database is held in memory")
+}
+
+// copyFile copies the file at from to the path to, replacing it atomically.
+func copyFile(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	destination, err := ioutil.TempFile(filepath.Dir(to), filepath.Base(to)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(destination, source); err != nil {
+		destination.Close()
+		os.Remove(destination.Name())
+		return err
+	} else if err := destination.Close(); err != nil {
+		os.Remove(destination.Name())
+		return err
+	}
+
+	return os.Rename(destination.Name(), to)
+}
+
+// sqliteConn returns a dedicated connection to the database of the Instance,
+// which must be a SQLite database.
+func (instance *Instance) sqliteConn(ctx context.Context, caller string) (*sql.Conn, error) {
+	if instance.closed {
+		return nil, NewFatalf("%s: instance has been closed", caller)
+	} else if instance.dialect != SQLite {
+		return nil, NewFatalf("%s: snapshots are only supported by the sqlite dialect, not %s", caller,
+			instance.dialect.Name())
+	}
+
+	conn, err := instance.db.Conn(ctx)
+	if err != nil {
+		return nil, NewFatalf("%s: got error while connecting to database:\n%s", caller, err)
+	}
+
+	return conn, nil
+}
+
+// Snapshot copies the file holding the SQLite database of the Instance to
+// path, such that a test suite may migrate a database once and restore it
+// with RestoreSnapshot before each test rather than migrating it again.
+// Writers are locked out of the database while it is copied, and an error is
+// returned if a migration is being applied. If the database is in WAL mode,
+// the write-ahead log is copied alongside the snapshot. Snapshot is only
+// supported by the SQLite dialect.
+func (instance *Instance) Snapshot(path string) error {
+	ctx := context.Background()
+	conn, err := instance.sqliteConn(ctx, "Instance.Snapshot")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	file, err := databaseFile(ctx, conn)
+	if err != nil {
+		return NewFatalf("Instance.Snapshot: got error while locating database file:\n%s", err)
+	}
+
+	// Hold the reserved lock, which allows readers but no other writers, while the file is copied
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE;`); err != nil {
+		return NewFatalf("Instance.Snapshot: got error while locking database:\n%s", err)
+	}
+	defer conn.ExecContext(ctx, `ROLLBACK;`)
+
+	var holders int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+instance.table("migrate_lock")+
+		`;`).Scan(&holders); err != nil {
+		return NewFatalf("Instance.Snapshot: got error while reading migration lock:\n%s", err)
+	} else if holders > 0 {
+		return NewFatalf("Instance.Snapshot: cannot snapshot the database while migrations are being applied")
+	}
+
+	if err := copyFile(file, path); err != nil {
+		return NewFatalf("Instance.Snapshot: got error while copying database:\n%s", err)
+	}
+
+	os.Remove(path + "-wal")
+	if _, err := os.Stat(file + "-wal"); err == nil {
+		if err := copyFile(file+"-wal", path+"-wal"); err != nil {
+			return NewFatalf("Instance.Snapshot: got error while copying write-ahead log:\n%s", err)
+		}
+	}
+
+	return nil
+}
+
+// RestoreSnapshot replaces the contents of the SQLite database of the Instance
+// with those of a snapshot written by Snapshot, including the version, within
+// a single transaction. The snapshot is read by attaching it to the database
+// rather than copying it over the database file, so that other connections
+// to the database observe the restored contents safely. An error is returned
+// if a migration is being applied. RestoreSnapshot is only supported by the
+// SQLite dialect.
+func (instance *Instance) RestoreSnapshot(path string) (err error) {
+	if instance.readOnly {
+		return NewFatalf("Instance.RestoreSnapshot: instance is read-only")
+	} else if _, err := os.Stat(path); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while opening snapshot:\n%s", err)
+	}
+
+	ctx := context.Background()
+	conn, err := instance.sqliteConn(ctx, "Instance.RestoreSnapshot")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Neither attaching databases nor disabling foreign keys is possible within a transaction
+	var foreignKeys int
+	if err := conn.QueryRowContext(ctx, `PRAGMA foreign_keys;`).Scan(&foreignKeys); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while reading foreign key enforcement:\n%s", err)
+	} else if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF;`); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while disabling foreign keys:\n%s", err)
+	}
+	defer func() {
+		if foreignKeys == 1 {
+			conn.ExecContext(ctx, `PRAGMA foreign_keys = ON;`)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS migrate_snapshot;`, path); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while attaching snapshot:\n%s", err)
+	}
+	defer conn.ExecContext(ctx, `DETACH DATABASE migrate_snapshot;`)
+
+	if _, err := conn.ExecContext(ctx, `BEGIN IMMEDIATE;`); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while locking database:\n%s", err)
+	}
+	defer func() {
+		if err != nil {
+			conn.ExecContext(ctx, `ROLLBACK;`)
+		}
+	}()
+
+	var holders int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+instance.table("migrate_lock")+
+		`;`).Scan(&holders); err == nil && holders > 0 {
+		return NewFatalf("Instance.RestoreSnapshot: cannot restore the database while migrations are being " +
+			"applied")
+	}
+
+	// Drop every object of the database, then recreate each object of the snapshot along with its rows
+	existing, err := listObjects(ctx, connExecer{conn}, SQLite.(*dialect).objects)
+	if err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while listing objects:\n%s", err)
+	}
+
+	for _, object := range existing {
+		if _, err := conn.ExecContext(ctx, "DROP "+object.kind+" IF EXISTS main."+object.name+";"); err != nil {
+			return NewFatalf("Instance.RestoreSnapshot: got error while dropping %s:\n%s", object.name, err)
+		}
+	}
+
+	type object struct{ kind, name, sql string }
+	var objects []object
+	rows, err := conn.QueryContext(ctx, `SELECT type, name, sql FROM migrate_snapshot.sqlite_master WHERE `+
+		`sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY type = 'table' DESC, type = 'index' DESC, rowid;`)
+	if err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while reading snapshot:\n%s", err)
+	}
+	for rows.Next() {
+		var entry object
+		if err := rows.Scan(&entry.kind, &entry.name, &entry.sql); err != nil {
+			rows.Close()
+			return NewFatalf("Instance.RestoreSnapshot: got error while reading snapshot:\n%s", err)
+		}
+		objects = append(objects, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while reading snapshot:\n%s", err)
+	}
+
+	for _, entry := range objects {
+		if _, err := conn.ExecContext(ctx, entry.sql); err != nil {
+			return NewFatalf("Instance.RestoreSnapshot: got error while creating %s '%s':\n%s", entry.kind,
+				entry.name, err)
+		}
+
+		if entry.kind == "table" {
+			name := quoteIdentifier(entry.name, `"`)
+			if _, err := conn.ExecContext(ctx, `INSERT INTO main.`+name+` SELECT * FROM migrate_snapshot.`+
+				name+`;`); err != nil {
+				return NewFatalf("Instance.RestoreSnapshot: got error while copying rows of '%s':\n%s", entry.name,
+					err)
+			}
+		}
+	}
+
+	// Rows of sqlite_sequence, which holds the next value of AUTOINCREMENT columns, are not copied with the table
+	var sequences int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM migrate_snapshot.sqlite_master WHERE `+
+		`name = 'sqlite_sequence';`).Scan(&sequences); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while reading snapshot:\n%s", err)
+	} else if sequences > 0 {
+		if _, err := conn.ExecContext(ctx, `DELETE FROM main.sqlite_sequence; INSERT INTO main.sqlite_sequence `+
+			`SELECT * FROM migrate_snapshot.sqlite_sequence;`); err != nil {
+			return NewFatalf("Instance.RestoreSnapshot: got error while copying sequences:\n%s", err)
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, `COMMIT;`); err != nil {
+		return NewFatalf("Instance.RestoreSnapshot: got error while committing restored database:\n%s", err)
+	}
+
+	return nil
+}
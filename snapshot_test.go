@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSnapshot ensures that a database restored from a snapshot holds the
+// objects, rows, and version it held when the snapshot was taken.
+func TestSnapshot(t *testing.T) {
+	directory, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal("ioutil.TempDir: got error:\n", err)
+	}
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "snapshot.sqlite")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		} else if _, err := db.Exec(`INSERT INTO billing (ID) VALUES (7);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		if err := instance.Snapshot(path); err != nil {
+			t.Fatal("Instance.Snapshot: got error:\n", err)
+		}
+
+		if err := instance.Goto(2); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		} else if _, err := db.Exec(`DELETE FROM billing;`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		if err := instance.RestoreSnapshot(path); err != nil {
+			t.Fatal("Instance.RestoreSnapshot: got error:\n", err)
+		}
+		if version := instance.Version(); version != 1 {
+			t.Errorf("Instance.Version: got %d expected 1 once restored", version)
+		}
+		if tableExists(db, "invoices") {
+			t.Error("Instance.RestoreSnapshot: expected 'invoices' created after the snapshot to be dropped")
+		}
+		var id int
+		if err := db.QueryRow(`SELECT ID FROM billing;`).Scan(&id); err != nil || id != 7 {
+			t.Errorf("Instance.RestoreSnapshot: got row %d and error '%v' expected 7", id, err)
+		}
+
+		// The restored database may be migrated as usual
+		if err := instance.Goto(2); err != nil {
+			t.Error("Instance.Goto: got error once restored:\n", err)
+		}
+
+		expectError(t, "Instance.RestoreSnapshot", "missing snapshot", func() error {
+			return instance.RestoreSnapshot(filepath.Join(directory, "missing.sqlite"))
+		}, "error while opening snapshot")
+	})
+}
@@ -0,0 +1,409 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Statement is a single SQL statement within either the up or down section of
+// a Part.
+type Statement struct {
+	SQL  string
+	Line int // Line of the part file on which the statement begins
+	// CSV is set if the statement is a `-- @migrate/load-csv` directive, in
+	// which case SQL holds the directive itself rather than SQL to execute.
+	CSV *CSVLoad
+}
+
+// ErrStatement is returned when a single statement within a part fails to
+// apply, identifying which statement failed.
+type ErrStatement struct {
+	Part   string
+	Index  int // Index of the statement within its section, beginning at 0
+	Line   int // Line of the part file on which the statement begins
+	Offset int // Character offset within the statement reported by the driver, or -1
+	SQL    string
+	Err    error
+}
+
+// Error implements the error interface for ErrStatement.
+func (err *ErrStatement) Error() string {
+	location := fmt.Sprintf("line %d", err.Line)
+	if err.Offset >= 0 {
+		location += fmt.Sprintf(", offset %d", err.Offset)
+	}
+
+	firstLine := strings.SplitN(err.SQL, "\n", 2)[0]
+	return fmt.Sprintf("statement %d at %s (%s): %s", err.Index+1, location, firstLine, err.Err)
+}
+
+// Unwrap returns the error returned by the database for the statement.
+func (err *ErrStatement) Unwrap() error {
+	return err.Err
+}
+
+// applyStatements executes each statement provided in order, stopping at and
+// returning an *ErrStatement for the first statement which fails, including a
+// statement cancelled along with ctx, DDL bounded by WithLockTimeout if in use,
+// and a statement estimated to touch more rows than WithRowLimit allows. Once
+// ctx is done, no further statements are executed. If WithIdempotent is in use,
+// each statement is first rewritten by the Dialect, and skipped, as affecting
+// no rows, if the Dialect guards it and its guard query returns a truthy
+// value. If WithExecutor is in use, the statements are executed by the
+// Executor provided rather than by exec. The number of rows affected by each
+// statement executed successfully is returned, or -1 for a statement whose
+// driver does not report it.
+func (instance *Instance) applyStatements(ctx context.Context, exec execer, transactional bool, version int,
+	part *Part, statements []Statement) ([]int64, error) {
+	var executor Executor = exec
+	if instance.executor != nil {
+		executor = instance.executor
+	}
+
+	rows := make([]int64, 0, len(statements))
+
+	for index, statement := range statements {
+		// A cancelled run stops at the next statement rather than once the whole part has been applied
+		if err := ctx.Err(); err != nil {
+			return rows, &ErrStatement{Part: part.Name, Index: index, Line: statement.Line, Offset: -1,
+				SQL: statement.SQL, Err: err}
+		}
+
+		if statement.CSV != nil {
+			affected, err := instance.loadCSV(ctx, executor, statement.CSV)
+			if err != nil {
+				return rows, &ErrStatement{Part: part.Name, Index: index, Line: statement.Line,
+					Offset: driverOffset(err), SQL: statement.SQL, Err: err}
+			}
+
+			rows = append(rows, affected)
+			instance.log(LevelDebug, "statement applied", Field{"version", version}, Field{"part", part.Name},
+				Field{"statement", index + 1}, Field{"verb", "INSERT"}, Field{"rows_affected", affected})
+			continue
+		}
+
+		sql := statement.SQL
+		if instance.idempotent {
+			sql = instance.dialect.Idempotent(sql)
+		}
+
+		// A statement guarded by the Dialect is skipped once applying it would change nothing
+		if guard := instance.statementGuard(sql); guard != "" {
+			if skip, err := evaluateGuard(ctx, exec, guard); err != nil {
+				return rows, &ErrStatement{Part: part.Name, Index: index, Line: statement.Line, Offset: -1,
+					SQL: guard, Err: err}
+			} else if skip {
+				rows = append(rows, 0)
+				instance.log(LevelDebug, "statement skipped", Field{"version", version}, Field{"part", part.Name},
+					Field{"statement", index + 1}, Field{"reason", "guard"})
+				continue
+			}
+		}
+
+		// Secrets are resolved last, so that the SQL of an ErrStatement never includes their values
+		resolved, secrets, err := instance.resolveSecrets(sql)
+		if err != nil {
+			return rows, &ErrStatement{Part: part.Name, Index: index, Line: statement.Line, Offset: -1, SQL: sql,
+				Err: err}
+		}
+
+		if err := instance.checkRowLimit(ctx, exec, version, part, resolved); err != nil {
+			return rows, &ErrStatement{Part: part.Name, Index: index, Line: statement.Line, Offset: -1, SQL: sql,
+				Err: redactError(err, secrets)}
+		}
+
+		res, err := instance.execStatement(ctx, exec, executor, transactional, version, part, resolved)
+		if _, fatal := err.(*ErrFatal); fatal {
+			return rows, err
+		}
+		if err != nil {
+			return rows, &ErrStatement{Part: part.Name, Index: index, Line: statement.Line,
+				Offset: driverOffset(err), SQL: sql, Err: redactError(err, secrets)}
+		}
+
+		affected := int64(-1)
+		if res != nil {
+			if count, err := res.RowsAffected(); err == nil {
+				affected = count
+			}
+		}
+		rows = append(rows, affected)
+
+		instance.log(LevelDebug, "statement applied", Field{"version", version}, Field{"part", part.Name},
+			Field{"statement", index + 1}, Field{"verb", statementVerb(sql)}, Field{"rows_affected", affected})
+	}
+
+	return rows, nil
+}
+
+// sayRows writes a MessageStatement to Output for each statement of a part
+// applied in direction, other than DDL, noting the number of rows which it
+// affected as returned by applyStatements. Nothing is written unless
+// WithVerbose is in use.
+func (instance *Instance) sayRows(version int, part *Part, direction string, rows []int64) {
+	if !instance.verbose {
+		return
+	}
+
+	statements := part.UpStatements
+	if direction == "down" {
+		statements = part.DownStatements
+	}
+
+	for index, affected := range rows {
+		if affected >= 0 && index < len(statements) && !isDDL(statements[index].SQL) {
+			instance.say(MessageStatement, MessageData{Version: version, Part: part.Name,
+				Verb: statementVerb(statements[index].SQL), Rows: affected})
+		}
+	}
+}
+
+// statementVerb returns the keyword with which a statement begins, such as
+// UPDATE or INSERT, ignoring any leading comments.
+func statementVerb(statement string) string {
+	_, sql := splitLeadingComments(statement)
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return strings.ToUpper(strings.TrimRight(fields[0], ";("))
+}
+
+// statementGuard returns the query with which the Dialect guards statement,
+// if it implements Guards and WithIdempotent is in use, or an empty string.
+func (instance *Instance) statementGuard(statement string) string {
+	if guards, ok := instance.dialect.(Guards); ok && instance.idempotent {
+		return guards.Guard(statement)
+	}
+
+	return ""
+}
+
+// evaluateGuard runs the guard query provided, returning true if the first
+// column of the first row it returns holds a truthy value. A guard query which
+// returns no rows, NULL, zero, false, or an empty string is not truthy.
+func evaluateGuard(ctx context.Context, exec execer, query string) (bool, error) {
+	var value interface{}
+	if err := exec.QueryRowContext(ctx, query).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("migrate: got error while evaluating guard query '%s':\n%s", query, err)
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case int64:
+		return value != 0, nil
+	case float64:
+		return value != 0, nil
+	case []byte:
+		return isTruthy(string(value)), nil
+	case string:
+		return isTruthy(value), nil
+	default:
+		return true, nil
+	}
+}
+
+// isTruthy returns true if the string provided does not represent zero, false,
+// or an empty value.
+func isTruthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "f", "false", "n", "no":
+		return false
+	default:
+		return true
+	}
+}
+
+// driverOffset returns the character offset within a failed statement
+// reported by the database driver, or -1 if the driver does not provide one.
+// Drivers such as lib/pq and pgx expose the offset through a Position field
+// on their error types, which is looked up by name to avoid depending upon
+// any particular driver.
+func driverOffset(err error) int {
+	value := reflect.Indirect(reflect.ValueOf(err))
+	if value.Kind() != reflect.Struct {
+		return -1
+	}
+
+	offset := -1
+	field := value.FieldByName("Position")
+	switch field.Kind() {
+	case reflect.String:
+		if parsed, err := strconv.Atoi(field.String()); err == nil {
+			offset = parsed
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		offset = int(field.Int())
+	}
+
+	// Drivers report offsets beginning at 1, using 0 when no offset is known
+	if offset <= 0 {
+		return -1
+	}
+
+	return offset
+}
+
+// sourceLine is a single non-blank line of a part file.
+type sourceLine struct {
+	Number int
+	Text   string
+}
+
+// splitStatements joins the lines provided and splits them into individual
+// statements at each semicolon, ignoring semicolons inside of quotes,
+// comments, and the BEGIN ... END bodies of triggers, procedures, and
+// functions. Within a body, END IF, END LOOP, END WHILE, and END REPEAT close
+// blocks of their own rather than a BEGIN or CASE.
+func splitStatements(lines []sourceLine) []Statement {
+	statements := make([]Statement, 0)
+
+	var current strings.Builder
+	line := 0        // Line on which the current statement begins
+	quote := ""      // Delimiter of the quoted string or identifier being read, if any
+	comment := false // Whether a block comment is being read
+	firstWord := ""  // First word of the current statement
+	body := false    // Whether the statement creates a trigger, procedure, or function
+	depth := 0       // Depth of nested BEGIN/CASE ... END blocks within a body
+
+	finish := func() {
+		if line > 0 {
+			statements = append(statements, Statement{SQL: strings.TrimSpace(current.String()), Line: line})
+		}
+
+		current.Reset()
+		line, firstWord, body, depth = 0, "", false, 0
+	}
+
+	for _, source := range lines {
+		text := source.Text
+		start := 0 // Index of the first character of text not yet written to current
+
+		for i := 0; i < len(text); i++ {
+			switch {
+			case comment:
+				if strings.HasPrefix(text[i:], "*/") {
+					comment = false
+					i++
+				}
+				continue
+			case quote != "":
+				if strings.HasPrefix(text[i:], quote) {
+					i += len(quote) - 1
+					quote = ""
+				}
+				continue
+			case strings.HasPrefix(text[i:], "--"):
+				i = len(text)
+				continue
+			case strings.HasPrefix(text[i:], "/*"):
+				comment = true
+				i++
+				continue
+			case unicode.IsSpace(rune(text[i])):
+				continue
+			}
+
+			if line == 0 {
+				line = source.Number
+			}
+
+			switch char := text[i]; {
+			case char == '\'' || char == '"' || char == '`':
+				quote = string(char)
+			case char == '$':
+				if tag := dollarTag(text[i:]); tag != "" {
+					quote = tag
+					i += len(tag) - 1
+				}
+			case char == ';' && depth == 0:
+				current.WriteString(text[start : i+1])
+				start = i + 1
+				finish()
+			case isWordChar(char) && (i == 0 || !isWordChar(text[i-1])):
+				end := i
+				for end < len(text) && isWordChar(text[end]) {
+					end++
+				}
+
+				word := strings.ToUpper(text[i:end])
+				if firstWord == "" {
+					firstWord = word
+				} else if firstWord == "CREATE" && (word == "TRIGGER" || word == "PROCEDURE" || word == "FUNCTION") {
+					body = true
+				} else if body && (word == "BEGIN" || word == "CASE") {
+					depth++
+				} else if body && word == "END" {
+					// Skip the keyword following END, which would otherwise open a block of its own
+					next, length := nextWord(text[end:])
+					if next == "IF" || next == "LOOP" || next == "WHILE" || next == "REPEAT" {
+						end += length
+					} else if depth > 0 {
+						depth--
+						if next == "CASE" {
+							end += length
+						}
+					}
+				}
+
+				i = end - 1
+			}
+		}
+
+		current.WriteString(text[start:])
+		current.WriteString("\n")
+	}
+
+	finish()
+	return statements
+}
+
+// nextWord returns the word at the beginning of text, after any spaces, in
+// upper case along with the length of text up to its end, or an empty string
+// if text does not continue with a word.
+func nextWord(text string) (string, int) {
+	start := 0
+	for start < len(text) && unicode.IsSpace(rune(text[start])) {
+		start++
+	}
+
+	end := start
+	for end < len(text) && isWordChar(text[end]) {
+		end++
+	}
+
+	return strings.ToUpper(text[start:end]), end
+}
+
+// dollarTag returns the Postgres dollar-quote tag, such as `$$` or `$body$`,
+// at the beginning of text, or an empty string if there is none.
+func dollarTag(text string) string {
+	for i := 1; i < len(text); i++ {
+		if text[i] == '$' {
+			return text[:i+1]
+		} else if !isWordChar(text[i]) {
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// isWordChar returns true if char may form part of an SQL keyword or
+// identifier.
+func isWordChar(char byte) bool {
+	return char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9'
+}
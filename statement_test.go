@@ -0,0 +1,273 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestSplitStatements ensures that part files are split into statements at
+// each semicolon outside of quotes, comments, and trigger bodies, and that the
+// line on which each statement begins is recorded.
+func TestSplitStatements(t *testing.T) {
+	part, err := NewPart("testing/statements/version_1/test.sql")
+	if err != nil {
+		t.Fatal("NewPart: got error:\n", err)
+	}
+
+	expected := []Statement{
+		{"CREATE TABLE first(ID INT PRIMARY KEY);", 3, nil},
+		{"-- A trigger whose body contains semicolons\nCREATE TRIGGER first_insert AFTER INSERT ON first\nBEGIN\n" +
+			"UPDATE first SET ID = CASE WHEN NEW.ID < 0 THEN 0 ELSE NEW.ID END WHERE ID = NEW.ID;\nEND;", 6, nil},
+		{"INSERT INTO first (ID) VALUES ('a;b');", 11, nil},
+		{"INSERT INTO missing (ID) VALUES (1);", 12, nil},
+	}
+
+	if len(part.UpStatements) != len(expected) {
+		t.Fatalf("NewPart.UpStatements: got %d statements expected %d:\n%#v", len(part.UpStatements),
+			len(expected), part.UpStatements)
+	}
+
+	for key, statement := range expected {
+		if part.UpStatements[key] != statement {
+			t.Errorf("NewPart.UpStatements: got statement %d '%#v' expected '%#v'", key,
+				part.UpStatements[key], statement)
+		}
+	}
+
+	if len(part.DownStatements) != 1 || part.DownStatements[0].Line != 16 {
+		t.Errorf("NewPart.DownStatements: got '%#v' expected a single statement on line 16", part.DownStatements)
+	}
+
+	statements := splitStatements([]sourceLine{{1, "SELECT $body$ ; $body$, '--;'; /* ; */"}, {2, "SELECT 1"}})
+	if len(statements) != 2 || !strings.HasSuffix(statements[1].SQL, "SELECT 1") || statements[1].Line != 2 {
+		t.Errorf("splitStatements: got '%#v' expected two statements", statements)
+	}
+
+	// Bodies of triggers, procedures, and functions, whose END IF and the like close blocks of their own
+	for name, body := range map[string][]string{
+		"trigger": {"CREATE TRIGGER clamp BEFORE INSERT ON first FOR EACH ROW", "BEGIN", "IF NEW.ID < 0 THEN",
+			"SET NEW.ID = 0;", "END IF;", "SET @inserted = @inserted + 1;", "END;"},
+		"procedure": {"CREATE PROCEDURE fill()", "BEGIN", "DECLARE i INT DEFAULT 0;", "WHILE i < 10 DO",
+			"SET i = i + 1;", "END WHILE;", "CASE i WHEN 10 THEN SET i = 0; ELSE SET i = 1; END CASE;",
+			"counter: LOOP LEAVE counter; END LOOP counter;", "REPEAT SET i = i - 1; UNTIL i <= 0 END REPEAT;",
+			"END;"},
+		"function": {"CREATE OR REPLACE FUNCTION sign_of(x INT) RETURNS INT DETERMINISTIC", "BEGIN",
+			"IF x < 0 THEN RETURN -1; END IF;", "RETURN CASE WHEN x > 0 THEN 1 ELSE 0 END;", "END;"},
+	} {
+		lines := make([]sourceLine, 0)
+		for number, text := range append(body, "SELECT 1;") {
+			lines = append(lines, sourceLine{number + 1, text})
+		}
+
+		statements := splitStatements(lines)
+		if len(statements) != 2 || statements[0].SQL != strings.Join(body, "\n") || statements[1].Line != len(body)+1 {
+			t.Errorf("splitStatements: got '%#v' expected the %s and a statement following it", statements, name)
+		}
+	}
+}
+
+// driverError imitates the error type of a driver which reports the offset at
+// which a statement failed.
+type driverError struct {
+	Position string
+}
+
+// Error implements the error interface for driverError.
+func (err *driverError) Error() string {
+	return "syntax error"
+}
+
+// TestStatementError ensures that the failing statement within a part is
+// identified when a migration fails.
+func TestStatementError(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/statements")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		err = instance.Latest()
+		if applyErr, ok := err.(*ErrApply); !ok {
+			t.Fatalf("Instance.Latest: expected error of type *ErrApply, got:\n%s", err)
+		} else if statementErr, ok := applyErr.Report.Failed[0].Err.(*ErrStatement); !ok {
+			t.Errorf("Instance.Latest: expected part error of type *ErrStatement, got:\n%s",
+				applyErr.Report.Failed[0].Err)
+		} else if statementErr.Index != 3 || statementErr.Line != 12 || statementErr.Offset != -1 {
+			t.Errorf("ErrStatement: got statement %d on line %d offset %d expected statement 3 on line 12 offset -1",
+				statementErr.Index, statementErr.Line, statementErr.Offset)
+		} else if !strings.Contains(err.Error(), "statement 4 at line 12 (INSERT INTO missing") {
+			t.Errorf("ErrApply.Error: expected failing statement in error, got:\n%s", err)
+		}
+	})
+
+	if offset := driverOffset(&driverError{"15"}); offset != 15 {
+		t.Errorf("driverOffset: got %d expected 15", offset)
+	}
+	if offset := driverOffset(errors.New("syntax error")); offset != -1 {
+		t.Errorf("driverOffset: got %d expected -1", offset)
+	}
+}
+
+// TestGuard ensures that parts are skipped when their guard query returns a
+// truthy value and are not reverted when migrating down, and that guard query
+// results are interpreted as expected.
+func TestGuard(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		if _, err := db.Exec(`CREATE TABLE existing(ID INT PRIMARY KEY);`); err != nil {
+			t.Fatal("sql.DB.Exec: got error:\n", err)
+		}
+
+		instance, err := NewInstance(db, "testing/guard")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+
+		report := instance.Report()
+		if len(report.Skipped) != 1 || report.Skipped[0].Part != "a.sql" ||
+			report.Skipped[0].Reason != "guard query returned true" {
+			t.Errorf("Instance.Report: got skipped parts '%#v' expected 'a.sql' skipped by its guard query",
+				report.Skipped)
+		}
+		if len(report.Applied) != 1 || report.Applied[0].Part != "b.sql" {
+			t.Errorf("Instance.Report: got applied parts '%#v' expected 'b.sql'", report.Applied)
+		}
+
+		for query, expected := range map[string]bool{
+			"SELECT 1":                     true,
+			"SELECT 0":                     false,
+			"SELECT NULL":                  false,
+			"SELECT 'yes'":                 true,
+			"SELECT 'false'":               false,
+			"SELECT 1 WHERE 1 = 0":         false,
+			"SELECT COUNT(*) FROM fresh":   false,
+			"SELECT COUNT(*) FROM missing": false,
+		} {
+			truthy, err := evaluateGuard(context.Background(), db, query)
+			if query == "SELECT COUNT(*) FROM missing" {
+				if err == nil {
+					t.Error("evaluateGuard: expected error with invalid guard query")
+				}
+			} else if err != nil {
+				t.Errorf("evaluateGuard: got error with '%s':\n%s", query, err)
+			} else if truthy != expected {
+				t.Errorf("evaluateGuard: got %t with '%s' expected %t", truthy, query, expected)
+			}
+		}
+
+		// The part skipped is left alone when migrating down, as the table it would drop predates it
+		if planned, err := instance.Plan(0); err != nil {
+			t.Fatal("Instance.Plan: got error:\n", err)
+		} else if len(planned) != 1 || planned[0].Part != "b.sql" {
+			t.Errorf("Instance.Plan: got '%#v' expected only 'b.sql' to be reverted", planned)
+		}
+		if rehearsal, err := instance.Rehearse(0); err != nil {
+			t.Fatal("Instance.Rehearse: got error:\n", err)
+		} else if len(rehearsal.Skipped) != 1 || rehearsal.Skipped[0].Part != "a.sql" ||
+			rehearsal.Skipped[0].Reason != "skipped when applied" {
+			t.Errorf("Instance.Rehearse: got skipped parts '%#v' expected 'a.sql'", rehearsal.Skipped)
+		}
+
+		if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		} else if report := instance.Report(); len(report.Skipped) != 1 || report.Skipped[0].Part != "a.sql" {
+			t.Errorf("Instance.Goto: got skipped parts '%#v' expected 'a.sql'", report.Skipped)
+		}
+		if !tableExists(db, "existing") || tableExists(db, "fresh") {
+			t.Error("Instance.Goto: expected the skipped part alone not to be reverted")
+		}
+	})
+}
+
+// TestRowsAffected ensures that the number of rows affected by each statement
+// is recorded in the report and written to Output with WithVerbose.
+func TestRowsAffected(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/data", WithVerbose())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		applied := instance.Report().Applied
+		if len(applied) != 2 || len(applied[0].RowsAffected) != 2 || applied[0].RowsAffected[1] != 1 {
+			t.Fatalf("Instance.Report: got applied parts '%#v' expected a row inserted by '1_users.sql'", applied)
+		}
+		if rows := applied[1].Rows(); rows != 1 {
+			t.Errorf("PartResult.Rows: got %d expected 1 row updated by '2_backfill.sql'", rows)
+		}
+
+		if !strings.Contains(output.String(), "- Applied '1_users.sql'\n  - INSERT touched 1 row(s)\n") ||
+			!strings.Contains(output.String(), "  - UPDATE touched 1 row(s)\n") {
+			t.Errorf("Instance.Goto: got output:\n%s\nexpected rows affected by each statement", output.String())
+		} else if strings.Contains(output.String(), "CREATE") {
+			t.Errorf("Instance.Goto: got output:\n%s\nexpected no message for DDL", output.String())
+		}
+	})
+
+	for number, expected := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 1238112: "1,238,112",
+		-45000: "-45,000"} {
+		if formatted := thousands(number); formatted != expected {
+			t.Errorf("thousands: got '%s' for %d expected '%s'", formatted, number, expected)
+		}
+	}
+}
+
+// TestStatementCancel ensures that a part stops at the next statement once the
+// context of its run is cancelled, and that the run is rolled back.
+func TestStatementCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	executed := make([]string, 0)
+	executor := JobFunc(func(ctx context.Context, statement string) (int64, error) {
+		executed = append(executed, statement)
+		cancel()
+		return 0, nil
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/statements", WithExecutor(executor))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		err = instance.GotoContext(ctx, 1)
+		if len(executed) != 1 {
+			t.Errorf("Instance.GotoContext: got %d statements executed expected 1", len(executed))
+		}
+
+		applyErr, ok := err.(*ErrApply)
+		if !ok {
+			t.Fatalf("Instance.GotoContext: expected error of type *ErrApply, got:\n%v", err)
+		}
+		if !applyErr.Report.Interrupted || applyErr.Report.Outcome != RolledBack {
+			t.Errorf("Instance.GotoContext: got outcome %s expected the interrupted run to be rolled back",
+				applyErr.Report.Outcome)
+		}
+		if statementErr, ok := applyErr.Report.Failed[0].Err.(*ErrStatement); !ok {
+			t.Errorf("Instance.GotoContext: expected part error of type *ErrStatement, got:\n%s",
+				applyErr.Report.Failed[0].Err)
+		} else if statementErr.Index != 1 || statementErr.Err != context.Canceled {
+			t.Errorf("ErrStatement: got statement %d with '%v' expected statement 1 to be cancelled",
+				statementErr.Index, statementErr.Err)
+		}
+		if instance.Version() != 0 {
+			t.Errorf("Instance.Version: got %d expected 0", instance.Version())
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package migrate
+
+import "time"
+
+// PartStatus describes a single part of a migration and the state recorded
+// for it in the database.
+type PartStatus struct {
+	Name  string            `json:"name"`
+	Meta  map[string]string `json:"meta"`
+	State PartState         `json:"state"`
+}
+
+// MigrationStatus describes a single migration and whether it is currently
+// applied to the database.
+type MigrationStatus struct {
+	Version int          `json:"version"`
+	Name    string       `json:"name"`
+	Applied bool         `json:"applied"`
+	Parts   []PartStatus `json:"parts"`
+}
+
+// Status returns the status of every available migration, ordered by version,
+// along with the state recorded for each of its parts. If the states cannot be
+// read, every part is reported in the state PartNone.
+func (instance *Instance) Status() []MigrationStatus {
+	current := instance.Version()
+	statuses := make([]MigrationStatus, 0, len(instance.migrations))
+	states, err := instance.partStates()
+	if err != nil {
+		instance.log(LevelWarn, "part states unavailable", Field{"error", err})
+	}
+
+	for _, version := range instance.List() {
+		migration := instance.migrations[version]
+		status := MigrationStatus{Version: version, Name: migration.Name, Applied: version <= current}
+
+		for _, part := range migration.Parts {
+			status.Parts = append(status.Parts, PartStatus{Name: part.Name, Meta: part.Meta,
+				State: states[version][part.Name]})
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// EnsureUpToDate returns an *ErrBehind listing every pending version if the
+// database has not been migrated to the latest available version. It is
+// intended to be called at application startup, allowing a service to refuse
+// to run against a stale schema, or to merely warn, without applying any
+// migrations itself. If the database is instead ahead of the latest available
+// version, an *ErrFutureSchema is returned unless WithAllowFutureSchema is in
+// use.
+func (instance *Instance) EnsureUpToDate() error {
+	current := instance.Version()
+	if latest := instance.latest(); current > latest && !instance.allowFutureSchema {
+		return &ErrFutureSchema{Version: current, Latest: latest}
+	}
+
+	pending := make([]int, 0)
+	for _, version := range instance.List() {
+		if version > current {
+			pending = append(pending, version)
+		}
+	}
+
+	if len(pending) > 0 {
+		return &ErrBehind{Version: current, Pending: pending}
+	}
+
+	return nil
+}
+
+// Stats is a snapshot of the state of an Instance, holding only plain values
+// so that it may be readily published to a dashboard, such as with expvar.
+type Stats struct {
+	Version int // Version the database is currently at
+	Total   int // Number of available migrations
+	Applied int
+	Pending int
+	Dirty   bool
+	Locked  bool // Whether any process, including this one, holds the migration lock
+
+	LastRun         bool // Whether a run has been made by the Instance, in which case the fields below are set
+	LastOutcome     Outcome
+	LastDuration    time.Duration
+	LastFailedParts int
+	LastErr         error
+}
+
+// Stats returns a snapshot of the state of the Instance and its most recent
+// run.
+func (instance *Instance) Stats() Stats {
+	stats := Stats{Version: instance.Version(), Total: len(instance.migrations), Dirty: instance.Dirty()}
+	for _, version := range instance.List() {
+		if version <= stats.Version {
+			stats.Applied++
+		} else {
+			stats.Pending++
+		}
+	}
+
+	var holders int
+	err := instance.db.QueryRow(`SELECT COUNT(*) FROM ` + instance.table("migrate_lock") + `;`).Scan(&holders)
+	if err == nil {
+		stats.Locked = holders > 0
+	}
+
+	if report := instance.report; report != nil {
+		stats.LastRun = true
+		stats.LastOutcome = report.Outcome
+		stats.LastDuration = report.Duration
+		stats.LastFailedParts = len(report.Failed)
+		stats.LastErr = report.Err
+	}
+
+	return stats
+}
@@ -0,0 +1,142 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestStatus ensures that Status reports every migration in order along with
+// whether it is applied and the metadata of its parts.
+func TestStatus(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		status := instance.Status()
+		if len(status) != 2 {
+			t.Fatalf("Instance.Status: got %d migrations expected 2", len(status))
+		}
+		if status[0].Version != 1 || !status[0].Applied || status[1].Version != 2 || status[1].Applied {
+			t.Errorf("Instance.Status: got '%#v' expected only version 1 to be applied", status)
+		}
+		if len(status[0].Parts) != 1 || status[0].Parts[0].Meta["author"] != "jane" {
+			t.Errorf("Instance.Status: got parts '%#v' expected 'billing.sql' authored by 'jane'", status[0].Parts)
+		}
+	})
+}
+
+// TestEnsureUpToDate ensures that EnsureUpToDate reports pending versions
+// until the database has been migrated to the latest version.
+func TestEnsureUpToDate(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		err = instance.EnsureUpToDate()
+		if behind, ok := err.(*ErrBehind); !ok {
+			t.Fatalf("Instance.EnsureUpToDate: got '%v' expected *ErrBehind", err)
+		} else if behind.Version != 0 || len(behind.Pending) != 2 || behind.Pending[0] != 1 {
+			t.Errorf("Instance.EnsureUpToDate: got '%#v' expected versions 1 and 2 pending", behind)
+		}
+		if !errors.Is(err, CodeBehind) {
+			t.Errorf("Instance.EnsureUpToDate: expected error matching '%s'", CodeBehind)
+		}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+		expectError(t, "Instance.EnsureUpToDate", "version 1 applied", instance.EnsureUpToDate,
+			"1 migration(s) pending: 2")
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.EnsureUpToDate(); err != nil {
+			t.Error("Instance.EnsureUpToDate: got error:\n", err)
+		}
+	})
+}
+
+// TestFutureSchema ensures that a database ahead of the latest known
+// migration is refused unless explicitly allowed.
+func TestFutureSchema(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.meta.Set("migrateVersion", 3); err != nil {
+			t.Fatal("metaStore.Set: got error:\n", err)
+		}
+
+		for name, fn := range map[string]func() error{
+			"Instance.EnsureUpToDate": instance.EnsureUpToDate,
+			"Instance.Latest":         instance.Latest,
+			"Instance.Goto":           func() error { return instance.Goto(1) },
+		} {
+			if err := fn(); !errors.Is(err, CodeFutureSchema) {
+				t.Errorf("%s: got '%v' expected error matching '%s'", name, err, CodeFutureSchema)
+			}
+		}
+
+		instance, err = NewInstance(db, "testing/meta", WithAllowFutureSchema())
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.EnsureUpToDate(); err != nil {
+			t.Error("Instance.EnsureUpToDate: got error with WithAllowFutureSchema:\n", err)
+		}
+		expectError(t, "Instance.Latest", "WithAllowFutureSchema", instance.Latest,
+			"ahead of the latest known migration version 2")
+	})
+}
+
+// TestStats ensures that Stats reflects the state of the Instance before and
+// after a run.
+func TestStats(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if stats := instance.Stats(); stats.Total != 2 || stats.Pending != 2 || stats.Applied != 0 ||
+			stats.LastRun || stats.Locked {
+			t.Errorf("Instance.Stats: got '%#v' expected 2 pending versions and no run", stats)
+		}
+
+		if err := instance.Goto(1); err != nil {
+			t.Fatal("Instance.Goto: got error:\n", err)
+		}
+
+		stats := instance.Stats()
+		if stats.Version != 1 || stats.Applied != 1 || stats.Pending != 1 || !stats.LastRun ||
+			stats.LastOutcome != Succeeded || stats.LastDuration <= 0 {
+			t.Errorf("Instance.Stats: got '%#v' expected a successful run to version 1", stats)
+		}
+
+		if err := instance.lock(); err != nil {
+			t.Fatal("Instance.lock: got error:\n", err)
+		}
+		if !instance.Stats().Locked {
+			t.Error("Instance.Stats: expected migrations to be locked")
+		}
+	})
+}
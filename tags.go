@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseTags adds the comma-separated tags provided with `-- @migrate/tags` to
+// part, ignoring any which it already has.
+func parseTags(part *Part, argument string) error {
+	for _, tag := range strings.Split(argument, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || strings.ContainsAny(tag, " \t") {
+			return fmt.Errorf("migrate: expected comma-separated tags without spaces, got '%s'", argument)
+		}
+
+		if !part.HasTag(tag) {
+			part.Tags = append(part.Tags, tag)
+		}
+	}
+
+	return nil
+}
+
+// HasTag reports whether part is marked with tag.
+func (part *Part) HasTag(tag string) bool {
+	for _, existing := range part.Tags {
+		if existing == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// excludedBy returns the reason for which part is excluded from runs by
+// WithTags or WithoutTags, or an empty string if it is applied.
+func (instance *Instance) excludedBy(part *Part) string {
+	for _, tag := range instance.excludeTags {
+		if part.HasTag(tag) {
+			return fmt.Sprintf("tag '%s' excluded", tag)
+		}
+	}
+
+	if len(instance.includeTags) == 0 || len(part.Tags) == 0 {
+		return ""
+	}
+	for _, tag := range instance.includeTags {
+		if part.HasTag(tag) {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("tags '%s' not included", strings.Join(part.Tags, ","))
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestTags ensures that parts are included or excluded by their tags with
+// WithTags and WithoutTags, and that the parts excluded are queued to be
+// applied later by RunDeferred.
+func TestTags(t *testing.T) {
+	root := CopyTree(t, "testing/tags")
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithoutTags("heavy"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		output := &strings.Builder{}
+		instance.Output = output
+
+		if tags := instance.migrations[1].Parts[1].Tags; !reflect.DeepEqual(tags, []string{"search", "heavy"}) {
+			t.Errorf("NewInstance: got tags %v expected [search heavy]", tags)
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if tableExists(db, "search") || !tableExists(db, "stats") {
+			t.Error("WithoutTags: expected only the part tagged heavy to be skipped")
+		}
+		if !strings.Contains(output.String(), "tag 'heavy' excluded, deferred until RunDeferred") {
+			t.Errorf("WithoutTags: got output '%s' expected the part to be reported skipped", output.String())
+		}
+
+		deferred, err := instance.Deferred()
+		if err != nil {
+			t.Fatal("Instance.Deferred: got error:\n", err)
+		} else if len(deferred) != 1 || deferred[0].Part != "search.sql" {
+			t.Errorf("Instance.Deferred: got '%#v' expected search.sql alone", deferred)
+		}
+
+		if err := instance.RunDeferred(context.Background()); err != nil {
+			t.Fatal("Instance.RunDeferred: got error:\n", err)
+		} else if !tableExists(db, "search") {
+			t.Error("Instance.RunDeferred: expected the skipped part to be applied")
+		}
+	})
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root, WithTags("heavy"))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if !tableExists(db, "items") || !tableExists(db, "search") || tableExists(db, "stats") {
+			t.Error("WithTags: expected untagged parts and those tagged heavy alone to be applied")
+		}
+
+		if err := instance.Goto(0); err != nil {
+			t.Fatal("Instance.Goto: got error migrating down with a part skipped:\n", err)
+		}
+		if deferred, err := instance.Deferred(); err != nil || len(deferred) != 0 {
+			t.Errorf("Instance.Deferred: got '%#v' and error '%v' expected nothing queued", deferred, err)
+		}
+	})
+
+	expectError(t, "NewPart", "empty tag", func() error {
+		_, err := parsePart("tags.sql", []byte("-- @migrate/tags a,,b\n-- @migrate/up\nSELECT 1;\n"+
+			"-- @migrate/down\nSELECT 1;\n"))
+		return err
+	}, "expected comma-separated tags")
+}
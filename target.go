@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// ResolveTarget returns the version identified by target, given the current
+// version of the database and the latest version available. The target may
+// be an absolute version such as "3", a version relative to the current
+// version such as "+2" or "-1", or one of the aliases "latest", "previous" or
+// "prev", and "current". An error is returned if target is not understood or
+// resolves to a version below 0, while a version above latest is returned as
+// is, so that Goto reports that it does not exist. ResolveTarget is used by
+// GotoTarget, and is exported so that tooling may resolve targets exactly as
+// GotoTarget does, such as to display the version a run would reach.
+func ResolveTarget(target string, current, latest int) (int, error) {
+	target = strings.TrimSpace(target)
+
+	var version int
+	switch strings.ToLower(target) {
+	case "latest":
+		version = latest
+	case "previous", "prev":
+		version = current - 1
+	case "current":
+		version = current
+	default:
+		number, err := strconv.Atoi(target)
+		if err != nil {
+			return 0, NewFatalf("ResolveTarget: expected a version, a relative version such as '+1' or '-1', or "+
+				"one of 'latest', 'previous', or 'current', got '%s'", target)
+		}
+
+		version = number
+		if strings.HasPrefix(target, "+") || strings.HasPrefix(target, "-") {
+			version = current + number
+		}
+	}
+
+	if version < 0 {
+		return 0, NewFatalf("ResolveTarget: target '%s' resolves to version %d from version %d, below 0", target,
+			version, current)
+	}
+
+	return version, nil
+}
+
+// GotoTarget migrates the database to the version identified by target, as
+// resolved by ResolveTarget from the current version of the database, such
+// that scripts may migrate to "latest", or back by one version with "-1",
+// without computing the absolute version themselves.
+func (instance *Instance) GotoTarget(target string) error {
+	return instance.GotoTargetContext(context.Background(), target)
+}
+
+// GotoTargetContext behaves exactly as GotoTarget, except that the statements
+// of each part are executed with the context provided, as with GotoContext.
+func (instance *Instance) GotoTargetContext(ctx context.Context, target string) error {
+	version, err := ResolveTarget(target, instance.Version(), instance.latest())
+	if err != nil {
+		return err
+	}
+
+	return instance.GotoContext(ctx, version)
+}
@@ -0,0 +1,72 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// TestResolveTarget ensures that absolute, relative, and symbolic targets are
+// resolved from the current version.
+func TestResolveTarget(t *testing.T) {
+	cases := []struct {
+		target   string
+		expected int
+	}{
+		{"latest", 5},
+		{"LATEST", 5},
+		{"prev", 1},
+		{"previous", 1},
+		{"current", 2},
+		{"+2", 4},
+		{"-1", 1},
+		{"-2", 0},
+		{"4", 4},
+		{" 0 ", 0},
+		{"9", 9},
+	}
+
+	for _, c := range cases {
+		if version, err := ResolveTarget(c.target, 2, 5); err != nil {
+			t.Errorf("ResolveTarget: got error with '%s':\n%s", c.target, err)
+		} else if version != c.expected {
+			t.Errorf("ResolveTarget: got %d from '%s' expected %d", version, c.target, c.expected)
+		}
+	}
+
+	expectError(t, "ResolveTarget", "below version 0", func() error {
+		_, err := ResolveTarget("-3", 2, 5)
+		return err
+	}, "below 0")
+	expectError(t, "ResolveTarget", "unknown alias", func() error {
+		_, err := ResolveTarget("newest", 2, 5)
+		return err
+	}, "got 'newest'")
+}
+
+// TestGotoTarget ensures that GotoTarget migrates to the version resolved.
+func TestGotoTarget(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/working")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		for _, step := range []struct {
+			target   string
+			expected int
+		}{{"latest", 3}, {"-2", 1}, {"+1", 2}, {"prev", 1}} {
+			if err := instance.GotoTarget(step.target); err != nil {
+				t.Fatalf("Instance.GotoTarget: got error with '%s':\n%s", step.target, err)
+			} else if version := instance.Version(); version != step.expected {
+				t.Errorf("Instance.GotoTarget: got version %d with '%s' expected %d", version, step.target,
+					step.expected)
+			}
+		}
+
+		expectError(t, "Instance.GotoTarget", "version past latest", func() error {
+			return instance.GotoTarget("+5")
+		}, "does not exist")
+	})
+}
@@ -0,0 +1,122 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// TemplateExtension is the extension of part files which are rendered with
+// text/template before being parsed.
+const TemplateExtension = ".sql.tmpl"
+
+// loader holds the configuration with which migrations and their parts are
+// loaded from disk.
+type loader struct {
+	ignore    ignorer
+	assets    ignorer           // Patterns matching files loaded as assets
+	data      interface{}       // Data with which templates are rendered
+	funcs     template.FuncMap  // Functions available to templates, in addition to templateFuncs
+	algorithm ChecksumAlgorithm // Algorithm with which checksums are computed, SHA256 if unset
+}
+
+// isPartFile returns true if name has the extension of a part file.
+func isPartFile(name string) bool {
+	return strings.HasSuffix(name, TemplateExtension) || filepath.Ext(name) == ".sql"
+}
+
+// part reads and parses the part file at path, rendering it first if it is a
+// template.
+func (loader loader) part(path string) (*Part, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(path, TemplateExtension) {
+		if contents, err = loader.render(path, contents); err != nil {
+			return nil, err
+		}
+	}
+
+	part, err := parsePart(path, contents)
+	if err == nil && loader.algorithm.New != nil {
+		part.Checksum, part.RawChecksum = loader.algorithm.checksums(contents)
+	}
+
+	return part, err
+}
+
+// render executes the template held by contents with the data and functions
+// of the loader.
+func (loader loader) render(path string, contents []byte) ([]byte, error) {
+	_, name := filepath.Split(path)
+	tmpl, err := template.New(name).Funcs(templateFuncs).Funcs(loader.funcs).Option("missingkey=error").
+		Parse(string(contents))
+	if err != nil {
+		return nil, NewFatalf("Migration.AddFile: got error while parsing template '%s':\n%s", path, err)
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, loader.data); err != nil {
+		return nil, NewFatalf("Migration.AddFile: got error while rendering template '%s':\n%s", path, err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// templateFuncs holds the functions available to every template, named and
+// behaving as their counterparts in the sprig library.
+var templateFuncs = template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+	"sub": func(a, b int) int { return a - b },
+	"mul": func(a, b int) int { return a * b },
+	"div": func(a, b int) int { return a / b },
+	"mod": func(a, b int) int { return a % b },
+	"until": func(count int) []int {
+		values := make([]int, 0, count)
+		for i := 0; i < count; i++ {
+			values = append(values, i)
+		}
+		return values
+	},
+	"seq": func(first, last int) []int {
+		values := make([]int, 0)
+		for i := first; i <= last; i++ {
+			values = append(values, i)
+		}
+		return values
+	},
+	"secret":  secretPlaceholder,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"repeat":  func(count int, value string) string { return strings.Repeat(value, count) },
+	"replace": func(old, new, value string) string { return strings.Replace(value, old, new, -1) },
+	"join": func(separator string, values interface{}) string {
+		switch values := values.(type) {
+		case []string:
+			return strings.Join(values, separator)
+		case []int:
+			joined := make([]string, len(values))
+			for i, value := range values {
+				joined[i] = strconv.Itoa(value)
+			}
+			return strings.Join(joined, separator)
+		default:
+			return fmt.Sprint(values)
+		}
+	},
+	"quote":  func(value interface{}) string { return strconv.Quote(fmt.Sprint(value)) },
+	"squote": func(value interface{}) string { return "'" + fmt.Sprint(value) + "'" },
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" || value == 0 || value == false {
+			return fallback
+		}
+		return value
+	},
+}
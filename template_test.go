@@ -0,0 +1,57 @@
+package migrate
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// TestTemplateParts ensures that part files ending with the TemplateExtension
+// are rendered with the data provided before being parsed.
+func TestTemplateParts(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		expectError(t, "NewInstance", "missing template data", func() error {
+			_, err := NewInstance(db, "testing/template")
+			return err
+		}, "error while rendering template", "shards.sql.tmpl")
+
+		instance, err := NewInstance(db, "testing/template", WithTemplateData(map[string]int{"Shards": 4}))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		part := instance.migrations[1].Parts[0]
+		if part.Name != "shards.sql.tmpl" || len(part.UpStatements) != 4 || len(part.DownStatements) != 4 {
+			t.Fatalf("NewInstance: got part '%s' with %d up statements expected 'shards.sql.tmpl' with 4",
+				part.Name, len(part.UpStatements))
+		}
+
+		if err := instance.Latest(); err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if _, err := db.Exec(`SELECT * FROM events_3;`); err != nil {
+			t.Error("Instance.Latest: expected table 'events_3' to exist:\n", err)
+		}
+	})
+
+	for source, expected := range map[string]string{
+		`{{ join ", " (seq 1 3) }}`:           "1, 2, 3",
+		`{{ add 2 (mul 3 4) }}`:               "14",
+		`{{ replace "-" "_" "a-b" | upper }}`: "A_B",
+		`{{ default "none" "" }}`:             "none",
+		`{{ squote "x" }} {{ quote "y" }}`:    `'x' "y"`,
+		`{{ shout "hi" }}`:                    "HI!",
+	} {
+		loader := loader{funcs: template.FuncMap{"shout": func(value string) string {
+			return strings.ToUpper(value) + "!"
+		}}}
+
+		if rendered, err := loader.render("test.sql.tmpl", []byte(source)); err != nil {
+			t.Errorf("loader.render: got error with '%s':\n%s", source, err)
+		} else if string(rendered) != expected {
+			t.Errorf("loader.render: got '%s' with '%s' expected '%s'", rendered, source, expected)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+-- @migrate/up
+
+CREATE TABLE users(ID INT PRIMARY KEY, Name TEXT, Age INT);
+CREATE TABLE sessions(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE sessions;
+DROP TABLE users;
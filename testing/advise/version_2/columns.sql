@@ -0,0 +1,15 @@
+-- @migrate/up
+
+ALTER TABLE users ALTER COLUMN Age TYPE BIGINT;
+ALTER TABLE users ALTER COLUMN Name SET NOT NULL;
+ALTER TABLE users ADD COLUMN Email TEXT NOT NULL;
+ALTER TABLE users ADD COLUMN Active INT NOT NULL DEFAULT 1;
+ALTER TABLE sessions ADD COLUMN Token TEXT NOT NULL;
+
+-- @migrate/down
+
+ALTER TABLE sessions DROP COLUMN Token;
+ALTER TABLE users DROP COLUMN Active;
+ALTER TABLE users DROP COLUMN Email;
+ALTER TABLE users ALTER COLUMN Name DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN Age TYPE INT;
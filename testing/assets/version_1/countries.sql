@@ -0,0 +1,9 @@
+-- @migrate/up
+
+CREATE TABLE countries(Code VARCHAR(2) PRIMARY KEY, Name VARCHAR(255) NOT NULL, Population INT);
+
+-- @migrate/load-csv countries.csv INTO countries
+
+-- @migrate/down
+
+DROP TABLE countries;
@@ -0,0 +1,3 @@
+-- Lower case the email of every user
+-- @migrate/backfill table=users cursor=ID batch=2 version=1
+UPDATE users SET Email = LOWER(Email) WHERE ID > ? AND ID <= ?;
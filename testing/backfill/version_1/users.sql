@@ -0,0 +1,8 @@
+-- @migrate/up
+
+CREATE TABLE users(ID INT PRIMARY KEY, Email VARCHAR(255) NOT NULL);
+INSERT INTO users (ID, Email) VALUES (1, 'Ada@Example.com'), (2, 'Grace@Example.com'), (3, 'Alan@Example.com');
+
+-- @migrate/down
+
+DROP TABLE users;
@@ -0,0 +1,8 @@
+-- @migrate/up
+-- @migrate/sometimes
+
+CREATE TABLE test(ID INT);
+
+-- @migrate/down
+
+DROP TABLE test;
@@ -0,0 +1,8 @@
+-- @migrate/up
+
+CREATE TABLE tampered(ID INT PRIMARY KEY);
+INSERT INTO metadata (Name, Value, ValueType) VALUES ('migrateVersion', 7, 1);
+
+-- @migrate/down
+
+DROP TABLE tampered;
@@ -0,0 +1,15 @@
+-- @migrate/up
+
+CREATE TABLE accounts(
+	ID INTEGER NOT NULL PRIMARY KEY,
+	Email VARCHAR(255) NOT NULL,
+	Name VARCHAR(100),
+	Active BOOLEAN NOT NULL DEFAULT FALSE,
+	Balance NUMERIC(10,2) NOT NULL DEFAULT 0
+);
+
+CREATE UNIQUE INDEX accounts_email ON accounts(Email);
+
+-- @migrate/down
+
+DROP TABLE accounts;
@@ -0,0 +1,15 @@
+-- @migrate/up
+
+CREATE TABLE posts(
+	ID INTEGER NOT NULL PRIMARY KEY,
+	AccountID INTEGER NOT NULL,
+	Title VARCHAR(255) NOT NULL DEFAULT 'Untitled',
+	Body TEXT,
+	CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX posts_account ON posts(AccountID, CreatedAt);
+
+-- @migrate/down
+
+DROP TABLE posts;
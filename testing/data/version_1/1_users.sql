@@ -0,0 +1,8 @@
+-- @migrate/up
+
+CREATE TABLE users(ID INT PRIMARY KEY, Name TEXT, Slug TEXT);
+INSERT INTO users (ID, Name) VALUES (1, 'Jane');
+
+-- @migrate/down
+
+DROP TABLE users;
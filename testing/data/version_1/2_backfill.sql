@@ -0,0 +1,8 @@
+-- @migrate/kind data
+-- @migrate/up
+
+UPDATE users SET Slug = lower(Name);
+
+-- @migrate/down
+
+UPDATE users SET Slug = NULL;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE tags(ID INT PRIMARY KEY, Name TEXT);
+
+-- @migrate/down
+
+DROP TABLE tags;
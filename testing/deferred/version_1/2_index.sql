@@ -0,0 +1,8 @@
+-- @migrate/deferred
+-- @migrate/up
+
+CREATE INDEX users_name ON users(Name);
+
+-- @migrate/down
+
+DROP INDEX users_name;
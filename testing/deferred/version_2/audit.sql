@@ -0,0 +1,8 @@
+-- @migrate/deferred
+-- @migrate/up
+
+INSERT INTO audit (Note) VALUES ('deployed');
+
+-- @migrate/down
+
+DELETE FROM audit;
@@ -0,0 +1,9 @@
+-- @migrate/up
+
+CREATE TABLE users(ID INTEGER PRIMARY KEY, Name TEXT NOT NULL, Email TEXT, Active INT NOT NULL DEFAULT 1);
+CREATE UNIQUE INDEX users_email ON users(Email);
+
+-- @migrate/down
+
+DROP INDEX users_email;
+DROP TABLE users;
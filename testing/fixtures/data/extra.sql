@@ -0,0 +1,2 @@
+INSERT INTO posts (ID, UserID, Title) VALUES (3, 1, 'Extra; with a semicolon');
+UPDATE users SET Admin = 0 WHERE Admin IS NULL;
@@ -0,0 +1,18 @@
+-- @migrate/up
+
+CREATE TABLE users(
+	ID INT PRIMARY KEY,
+	Name VARCHAR(255) NOT NULL,
+	Admin BOOLEAN
+);
+
+CREATE TABLE posts(
+	ID INT PRIMARY KEY,
+	UserID INT NOT NULL REFERENCES users(ID),
+	Title VARCHAR(255)
+);
+
+-- @migrate/down
+
+DROP TABLE posts;
+DROP TABLE users;
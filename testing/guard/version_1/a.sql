@@ -0,0 +1,8 @@
+-- @migrate/skip-if SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'existing'
+-- @migrate/up
+
+CREATE TABLE existing(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE existing;
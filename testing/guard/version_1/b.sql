@@ -0,0 +1,8 @@
+-- @migrate/skip-if SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'fresh'
+-- @migrate/up
+
+CREATE TABLE fresh(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE fresh;
@@ -0,0 +1,9 @@
+-- @migrate/up
+
+CREATE TABLE existing(ID INT PRIMARY KEY);
+CREATE INDEX existing_id ON existing (ID);
+
+-- @migrate/down
+
+DROP INDEX existing_id;
+DROP TABLE existing;
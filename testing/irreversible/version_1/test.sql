@@ -0,0 +1,6 @@
+-- @migrate/up
+
+CREATE TABLE test(ID INT PRIMARY KEY);
+INSERT INTO test (ID) VALUES (1);
+
+-- @migrate/irreversible
@@ -0,0 +1,11 @@
+-- @migrate/up
+
+CREATE TABLE users(ID INT PRIMARY KEY, Name TEXT);
+CREATE TABLE "posts"(ID INT PRIMARY KEY, UserID INT REFERENCES users(ID) ON DELETE CASCADE);
+CREATE TABLE comments(ID INT PRIMARY KEY);
+CREATE INDEX posts_user ON posts(UserID);
+
+-- @migrate/down
+
+DROP TABLE comments;
+DROP TABLE Posts;
@@ -0,0 +1,11 @@
+-- @migrate/up
+
+ALTER TABLE users ADD COLUMN Email TEXT;
+ALTER TABLE users ADD COLUMN Phone TEXT;
+ALTER TABLE comments ADD CONSTRAINT comments_id CHECK (ID > 0);
+DROP INDEX posts_user;
+
+-- @migrate/down
+
+-- Phone is kept
+ALTER TABLE users DROP COLUMN Email;
@@ -0,0 +1,9 @@
+-- @migrate/meta author=jane ticket=PROJ-123
+-- @migrate/meta description="add billing tables"
+-- @migrate/up
+
+CREATE TABLE billing(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE billing;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE invoices(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE invoices;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE posts(ID INT PRIMARY KEY, UserID INT, Title TEXT);
+
+-- @migrate/down
+
+DROP TABLE posts;
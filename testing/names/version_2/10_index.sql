@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE INDEX tags_name ON tags(Name);
+
+-- @migrate/down
+
+DROP INDEX tags_name;
@@ -0,0 +1,5 @@
+-- @migrate/up
+
+DELETE FROM tags WHERE Name IS NULL;
+
+-- @migrate/irreversible
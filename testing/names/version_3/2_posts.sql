@@ -0,0 +1,7 @@
+-- @migrate/up
+
+INSERT INTO tags(ID, Name) VALUES (1, 'announcement');
+
+-- @migrate/down
+
+DELETE FROM tags WHERE ID = 1;
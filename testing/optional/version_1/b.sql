@@ -0,0 +1,9 @@
+-- @migrate/optional
+-- @migrate/up
+
+CREATE TABLE b(ID INT PRIMARY KEY);
+CREATE INDEX missing_index ON missing (ID);
+
+-- @migrate/down
+
+DROP TABLE IF EXISTS b;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE c(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE c;
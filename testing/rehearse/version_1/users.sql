@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE users(ID INT PRIMARY KEY, Name TEXT);
+
+-- @migrate/down
+
+DROP TABLE users;
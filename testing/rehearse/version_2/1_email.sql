@@ -0,0 +1,7 @@
+-- @migrate/up
+
+ALTER TABLE users ADD COLUMN Email TEXT;
+
+-- @migrate/down
+
+ALTER TABLE users DROP COLUMN Email;
@@ -0,0 +1,9 @@
+-- @migrate/up
+
+CREATE INDEX users_email ON users(Email);
+CREATE INDEX users_phone ON users(Phone);
+
+-- @migrate/down
+
+DROP INDEX users_phone;
+DROP INDEX users_email;
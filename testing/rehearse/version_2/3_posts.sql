@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE posts(ID INT PRIMARY KEY, Author INT REFERENCES users(ID));
+
+-- @migrate/down
+
+DROP TABLE posts;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE prerequisite_check(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE prerequisite_check;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE a(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE a;
@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE b AS SELECT * FROM prerequisite;
+
+-- @migrate/down
+
+DROP TABLE b;
@@ -0,0 +1,8 @@
+-- @migrate/up
+
+CREATE TABLE items(ID INT);
+INSERT INTO items (ID) VALUES (1), (2), (3);
+
+-- @migrate/down
+
+DROP TABLE items;
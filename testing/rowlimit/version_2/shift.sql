@@ -0,0 +1,7 @@
+-- @migrate/up
+
+UPDATE items SET ID = ID + 10;
+
+-- @migrate/down
+
+UPDATE items SET ID = ID - 10;
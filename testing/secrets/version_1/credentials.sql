@@ -0,0 +1,8 @@
+-- @migrate/up
+
+CREATE TABLE credentials(Name TEXT PRIMARY KEY, Value TEXT);
+INSERT INTO credentials VALUES ('api', '{{secret "api_key"}}');
+
+-- @migrate/down
+
+DROP TABLE credentials;
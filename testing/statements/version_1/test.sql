@@ -0,0 +1,16 @@
+-- @migrate/up
+
+CREATE TABLE first(ID INT PRIMARY KEY);
+
+-- A trigger whose body contains semicolons
+CREATE TRIGGER first_insert AFTER INSERT ON first
+BEGIN
+	UPDATE first SET ID = CASE WHEN NEW.ID < 0 THEN 0 ELSE NEW.ID END WHERE ID = NEW.ID;
+END;
+
+INSERT INTO first (ID) VALUES ('a;b');
+INSERT INTO missing (ID) VALUES (1);
+
+-- @migrate/down
+
+DROP TABLE first;
@@ -0,0 +1,14 @@
+-- @migrate/up
+
+CREATE TABLE orders(
+	ID INT PRIMARY KEY,
+	Total INT NOT NULL
+);
+
+INSERT INTO orders (ID, Total)
+SELECT 1, 10
+WHERE 1 = 1;
+
+-- @migrate/down
+
+DROP TABLE orders;
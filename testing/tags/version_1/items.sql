@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE items(ID INT);
+
+-- @migrate/down
+
+DROP TABLE items;
@@ -0,0 +1,9 @@
+-- @migrate/tags search, heavy
+-- @migrate/tags search
+-- @migrate/up
+
+CREATE TABLE search(ID INT);
+
+-- @migrate/down
+
+DROP TABLE search;
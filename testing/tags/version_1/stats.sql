@@ -0,0 +1,8 @@
+-- @migrate/tags stats
+-- @migrate/up
+
+CREATE TABLE stats(ID INT);
+
+-- @migrate/down
+
+DROP TABLE stats;
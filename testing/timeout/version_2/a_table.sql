@@ -0,0 +1,7 @@
+-- @migrate/up
+
+CREATE TABLE slow(ID INT PRIMARY KEY);
+
+-- @migrate/down
+
+DROP TABLE slow;
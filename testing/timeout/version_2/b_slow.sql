@@ -0,0 +1,12 @@
+-- @migrate/up
+
+WITH RECURSIVE counter(x) AS (
+	SELECT 1
+	UNION ALL
+	SELECT x + 1 FROM counter WHERE x < 1000000000
+)
+SELECT COUNT(*) FROM counter;
+
+-- @migrate/down
+
+SELECT 1;
@@ -0,0 +1,54 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVersionTimeout ensures that a version which takes longer than the
+// timeout configured is cancelled and rolled back.
+func TestVersionTimeout(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/timeout", WithVersionTimeout(100*time.Millisecond))
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		start := time.Now()
+		err = instance.Latest()
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Instance.Latest: took %s expected the slow part to be cancelled", elapsed)
+		}
+
+		timeout, ok := err.(*ErrTimeout)
+		if !ok {
+			t.Fatalf("Instance.Latest: got '%v' expected *ErrTimeout", err)
+		}
+		if timeout.Version != 2 || timeout.Report.Outcome != RolledBack || timeout.Report.Version != 0 {
+			t.Errorf("Instance.Latest: got '%#v' expected version 2 to time out and be rolled back", timeout)
+		}
+		if !errors.Is(err, CodeTimeout) {
+			t.Errorf("Instance.Latest: expected error matching '%s'", CodeTimeout)
+		}
+		if instance.Version() != 0 {
+			t.Errorf("Instance.Version: got %d expected 0", instance.Version())
+		}
+
+		if _, err := db.Exec(`SELECT * FROM test;`); err == nil {
+			t.Error("Instance.Latest: expected table 'test' to be rolled back")
+		}
+
+		// Cancelling the context is reported as an ordinary failure rather than a timeout
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err = instance.GotoContext(ctx, 1)
+		if _, ok := err.(*ErrApply); !ok {
+			t.Errorf("Instance.GotoContext: got '%v' expected *ErrApply with cancelled context", err)
+		}
+	})
+}
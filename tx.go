@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+)
+
+// GotoInTx behaves as Goto, except that the migrations are applied within tx,
+// a transaction begun by the caller, such as the unit of work of a framework
+// or a transaction which a test rolls back once it completes. The version
+// reached is recorded within tx as well, so it is only visible to others once
+// the caller commits tx, and is discarded along with every part applied if
+// the caller rolls tx back. GotoInTx neither commits nor rolls back tx: if the
+// run fails, only the changes made by the run are rolled back, by way of a
+// savepoint, leaving tx usable. WithoutTransaction has no effect on GotoInTx.
+//
+// The migration lock is not acquired, as tx isolates the run instead, though
+// the version recorded is still checked against that read when the run began
+// in case another process migrated the database in the meantime. Unqualified
+// names are resolved as elsewhere within tx, even if WithSchema is in use.
+func (instance *Instance) GotoInTx(tx *sql.Tx, version int) error {
+	return instance.GotoInTxContext(context.Background(), tx, version)
+}
+
+// GotoInTxContext behaves exactly as GotoInTx, except that the statements of
+// each part are executed with the context provided, as with GotoContext.
+func (instance *Instance) GotoInTxContext(ctx context.Context, tx *sql.Tx, version int) error {
+	if tx == nil {
+		return NewFatalf("Instance.GotoInTx: expected transaction, got nil")
+	}
+
+	instance.external = tx
+	defer func() { instance.external = nil }()
+
+	return instance.run(ctx, version, false, "")
+}
+
+// commit commits the transaction of a run, or releases the savepoint within
+// which the run was applied if the transaction belongs to the caller of
+// GotoInTx.
+func (instance *Instance) commit(transaction *sql.Tx) error {
+	if transaction != instance.external {
+		return transaction.Commit()
+	}
+
+	_, err := transaction.Exec(`RELEASE SAVEPOINT migrate_run;`)
+	return err
+}
+
+// rollback rolls back the transaction of a run, or only the changes made
+// since the savepoint within which the run was applied if the transaction
+// belongs to the caller of GotoInTx.
+func (instance *Instance) rollback(transaction *sql.Tx) error {
+	if transaction != instance.external {
+		return transaction.Rollback()
+	}
+
+	if _, err := transaction.Exec(`ROLLBACK TO SAVEPOINT migrate_run;`); err != nil {
+		return err
+	}
+
+	_, err := transaction.Exec(`RELEASE SAVEPOINT migrate_run;`)
+	return err
+}
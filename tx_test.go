@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGotoInTx ensures that GotoInTx applies migrations and records the
+// version within the transaction of the caller, which may commit or roll
+// back the run along with the rest of its work, and that a failed run leaves
+// the transaction usable.
+func TestGotoInTx(t *testing.T) {
+	root := CopyTree(t, "testing/meta")
+	if err := os.MkdirAll(filepath.Join(root, "version_3"), 0755); err != nil {
+		t.Fatal("os.MkdirAll: got error:\n", err)
+	} else if err := ioutil.WriteFile(filepath.Join(root, "version_3", "broken.sql"), []byte("-- @migrate/up\n"+
+		"CREATE TABLE broken(ID INT);\nINSERT INTO missing (ID) VALUES (1);\n-- @migrate/down\nDROP TABLE broken;\n"),
+		0644); err != nil {
+		t.Fatal("ioutil.WriteFile: got error:\n", err)
+	}
+
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, root)
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal("db.Begin: got error:\n", err)
+		}
+		if err := instance.GotoInTx(tx, 2); err != nil {
+			t.Fatal("Instance.GotoInTx: got error:\n", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO invoices (ID) VALUES (1);`); err != nil {
+			t.Error("Instance.GotoInTx: expected invoices table within transaction, got error:\n", err)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatal("Tx.Rollback: got error:\n", err)
+		}
+		if version := instance.Version(); version != 0 || tableExists(db, "invoices") {
+			t.Errorf("Instance.GotoInTx: got version %d expected 0 once the transaction was rolled back", version)
+		}
+
+		if tx, err = db.Begin(); err != nil {
+			t.Fatal("db.Begin: got error:\n", err)
+		}
+		if err := instance.GotoInTx(tx, 2); err != nil {
+			t.Fatal("Instance.GotoInTx: got error:\n", err)
+		}
+		if _, err := tx.Exec(`CREATE TABLE marker(ID INT);`); err != nil {
+			t.Fatal("Tx.Exec: got error:\n", err)
+		}
+
+		expectError(t, "Instance.GotoInTx", "failing part", func() error { return instance.GotoInTx(tx, 3) },
+			"missing")
+		if version, _, err := instance.readVersion(tx); err != nil || version != 2 {
+			t.Errorf("Instance.GotoInTx: got version %d within transaction expected 2 after failed run", version)
+		}
+		if _, err := tx.Exec(`INSERT INTO marker (ID) VALUES (1);`); err != nil {
+			t.Error("Instance.GotoInTx: expected transaction to remain usable after failed run, got error:\n", err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal("Tx.Commit: got error:\n", err)
+		}
+
+		if version := instance.Version(); version != 2 {
+			t.Errorf("Instance.GotoInTx: got version %d expected 2 once the transaction was committed", version)
+		}
+		if tableExists(db, "broken") || !tableExists(db, "marker") {
+			t.Error("Instance.GotoInTx: expected only the failed run to be rolled back")
+		}
+
+		expectError(t, "Instance.GotoInTx", "nil transaction", func() error { return instance.GotoInTx(nil, 1) },
+			"got nil")
+	})
+}
@@ -0,0 +1,50 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// Intervals between the polls made by WaitForVersion, doubling from the
+// first up to the longest.
+const (
+	waitFirstInterval   = 50 * time.Millisecond
+	waitLongestInterval = 5 * time.Second
+)
+
+// WaitForVersion blocks until the database reaches or passes version, as
+// recorded by whichever process migrates it, polling the recorded version at
+// intervals which double from 50ms up to 5s. It is intended for workers which
+// must not start until a sibling service has finished migrating the tables
+// they share, and so never applies any migrations itself. The version need not
+// be known to the Instance. An error is returned if ctx is done first, in
+// which case the error reading the version, if any, is included, as a
+// database which has never been migrated is waited for as though at version 0.
+func (instance *Instance) WaitForVersion(ctx context.Context, version int) error {
+	interval := waitFirstInterval
+	for {
+		current, _, err := instance.readVersion(instance.db)
+		if err == nil && current >= version {
+			return nil
+		}
+
+		instance.log(LevelDebug, "waiting for version", Field{"version", current}, Field{"target", version},
+			Field{"interval", interval})
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return NewFatalf("Instance.WaitForVersion: %s while waiting for version %d, last got error "+
+					"while reading version:\n%s", ctx.Err(), version, err)
+			}
+
+			return NewFatalf("Instance.WaitForVersion: %s while waiting for version %d, database at version %d",
+				ctx.Err(), version, current)
+		case <-instance.clock.After(interval):
+		}
+
+		if interval *= 2; interval > waitLongestInterval {
+			interval = waitLongestInterval
+		}
+	}
+}
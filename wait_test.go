@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitForVersion ensures that WaitForVersion returns once another process
+// migrates the database to the version awaited, and fails once its context is
+// done if the database never reaches it.
+func TestWaitForVersion(t *testing.T) {
+	RunWithDB(func(db *sql.DB) {
+		instance, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		instance.Output = &strings.Builder{}
+
+		migrator, err := NewInstance(db, "testing/meta")
+		if err != nil {
+			t.Fatal("NewInstance: got error:\n", err)
+		}
+		migrator.Output = &strings.Builder{}
+
+		migrated := make(chan error, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			migrated <- migrator.Latest()
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := instance.WaitForVersion(ctx, 2); err != nil {
+			t.Fatal("Instance.WaitForVersion: got error:\n", err)
+		}
+		if err := <-migrated; err != nil {
+			t.Fatal("Instance.Latest: got error:\n", err)
+		}
+		if err := instance.WaitForVersion(ctx, 1); err != nil {
+			t.Error("Instance.WaitForVersion: got error for version already passed:\n", err)
+		}
+
+		short, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+		defer cancel()
+		expectError(t, "Instance.WaitForVersion", "version never reached", func() error {
+			return instance.WaitForVersion(short, 5)
+		}, "database at version 2")
+	})
+}